@@ -16,6 +16,11 @@ limitations under the License.
 
 package v1alpha3
 
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
 // UCloudMachineTemplateResource describes the data needed to create am UCloudMachine from a template
 type UCloudMachineTemplateResource struct {
 	// Spec is the specification of the desired behavior of the machine.
@@ -32,36 +37,91 @@ type Filter struct {
 }
 
 type Network struct {
-	VPC      VPC      `json:"vpc,omitempty"`
-	Subnet   Subnet   `json:"subnet,omitempty"`
+	VPC VPC `json:"vpc,omitempty"`
+	// Subnet is the subnet of the control plane.
+	Subnet Subnet `json:"subnet,omitempty"`
+	// Subnets are the subnets of the cluster, in the order of the spec.
+	Subnets  []Subnet `json:"subnets,omitempty"`
 	ULB      ULB      `json:"ulb,omitempty"`
 	Nat      Nat      `json:"nat,omitempty"`
 	Firewall Firewall `json:"firewall,omitempty"`
+	// MachineFirewalls are the firewalls of MachineFirewalls in the spec by tag.
+	MachineFirewalls map[string]Firewall `json:"machineFirewalls,omitempty"`
 }
 
 type NetworkSpec struct {
-	VPC      VPCSpec      `json:"vpc,omitempty"`
-	Subnet   SubnetSpec   `json:"subnet,omitempty"`
+	VPC VPCSpec `json:"vpc,omitempty"`
+	// 集群的子网，设置 subnets 时不使用。
+	Subnet SubnetSpec `json:"subnet,omitempty"`
+
+	// 集群的子网列表，机器按角色和可用区选择子网，NAT 网关绑定所有子网。
+	// 子网只能追加，不能修改或删除。
+	// +optional
+	Subnets []SubnetSpec `json:"subnets,omitempty"`
+
 	Nat      NatSpec      `json:"nat,omitempty"`
 	ULB      ULBSpec      `json:"ulb,omitempty"`
+	// 集群的防火墙，用于 NAT 网关、堡垒机和没有选择机器防火墙的机器。
+	// 不指定 firewallId 时创建一个属于集群的防火墙，没有规则时默认开放 22 端口、API Server 的后端端口和 ICMP。
 	Firewall FirewallSpec `json:"firewall,omitempty"`
+
+	// 机器防火墙，UCloudMachine 通过 additionalNetworkTags 中的标签选择。
+	// +optional
+	MachineFirewalls []MachineFirewallSpec `json:"machineFirewalls,omitempty"`
 }
 
+// The roles of the subnets of a cluster.
+const (
+	// SubnetRoleControlPlane is the role of the subnets of control plane machines.
+	SubnetRoleControlPlane = "control-plane"
+	// SubnetRoleNode is the role of the subnets of worker machines.
+	SubnetRoleNode = "node"
+	// SubnetRoleBastion is the role of the subnet of the bastion, which uses the subnet
+	// of the control plane if there is none.
+	SubnetRoleBastion = "bastion"
+)
+
+// The modes of the load balancer of the API server.
+const (
+	// ULBModePublic is the mode of an outer-mode load balancer, the API server is reached
+	// at the EIP bound to it.
+	ULBModePublic = "public"
+	// ULBModeInternal is the mode of an inner-mode load balancer in the control plane
+	// subnet, the API server is only reached at its private address.
+	ULBModeInternal = "internal"
+)
+
 // SubnetSpec configures an UCLOUD Subnet.
 
 type SubnetSpec struct {
 	// 使用一个已经存在的 Subnet
 	SubnetId string `json:"subnetId,omitempty"`
 
-	// 子网的名称。
+	// 子网的角色，control-plane、node 或 bastion。没有角色的子网用于没有专属子网的角色。
+	// +kubebuilder:validation:Enum=control-plane;node;bastion
+	// +optional
+	Role string `json:"role,omitempty"`
+
+	// 子网服务的可用区，该可用区的机器优先使用此子网。默认服务所有可用区。
+	// +optional
+	Zone string `json:"zone,omitempty"`
+
+	// 子网的名称。默认按角色生成，如 <集群名>-controlplane-subnet，指定可用区时加上可用区后缀。
 	SubnetName string `json:"subnetName,omitempty"`
 	// 子网的网段。子网网段要求如下：
 	//   子网网段的掩码长度范围为16-29位。
 	//   子网的网段必须从属于所在VPC的网段。
 	//   子网的网段不能与所在VPC中路由条目的目标网段相同，但可以是目标网段的子集。
 	//   如果子网的网段与所在VPC的网段相同时，VPC只能有一个子网。
+	// 不指定时在VPC的网段中自动分配，避开VPC中已有的子网和集群的 Pod、Service 网段。
 	CidrBlock string `json:"cidrBlock,omitempty"`
 
+	// 自动分配网段时子网的掩码长度，默认为24。
+	// +kubebuilder:validation:Minimum=16
+	// +kubebuilder:validation:Maximum=29
+	// +optional
+	PrefixLength int `json:"prefixLength,omitempty"`
+
 	// 子网的描述信息。
 	Description string `json:"description,omitempty"`
 }
@@ -78,6 +138,7 @@ type VPCSpec struct {
 	//   10.0.0.0/8
 	//   172.16.0.0/12
 	//   192.168.0.0/16
+	// 默认为 10.0.0.0/8。
 	CidrBlock string `json:"cidrBlock,omitempty"`
 
 	// VPC的描述信息。
@@ -114,11 +175,29 @@ type EIPSpec struct {
 
 	// EIP的带宽峰值，单位为Mbps，默认值为5。
 	Bandwidth int `json:"bandwidth,omitempty"`
+
+	// 弹性IP的线路，如 Bgp 和 International，默认为地域的默认线路。
+	// +optional
+	OperatorName string `json:"operatorName,omitempty"`
+
+	// 弹性IP的计费模式，Bandwidth 为按带宽计费，Traffic 为按流量计费，默认为 Bandwidth。
+	// +kubebuilder:validation:Enum=Bandwidth;Traffic
+	// +optional
+	PayMode string `json:"payMode,omitempty"`
+
+	// 付费方式，支持 Month、Year 和 Dynamic。
+	ChargeSpec `json:",inline"`
 }
 
 // ULBSpec 负载均衡（Server Load Balancer）是对多台云服务器进行流量分发的负载均衡服务,
 // 流量分发到apiserver
 type ULBSpec struct {
+	// 负载均衡的模式，public 为外网模式，通过 ULB 绑定的 EIP 访问 API Server；internal 为内网模式，
+	// 在集群的子网中创建，不绑定 EIP，通过内网 VIP 访问 API Server。默认为 public，创建后不可修改。
+	// +kubebuilder:validation:Enum=public;internal
+	// +optional
+	Mode string `json:"mode,omitempty"`
+
 	// 使用一个已经存在的负载均衡
 	LoadBalancerId string `json:"loadBalancerId,omitempty"`
 	// 使用一个已经存在的后端服务器组
@@ -129,10 +208,90 @@ type ULBSpec struct {
 	// 后端服务器组名
 	VServerName string `json:"vserverName,omitempty"`
 
+	// API Server 的监听器配置，即 VServer 的端口、转发方式、负载均衡算法、健康检查和会话保持。
+	// +optional
+	Listener ULBListenerSpec `json:"listener,omitempty"`
+
 	// ULB 绑定的 EIP 信息
 	EIP EIPSpec `json:"eip,omitempty"`
+
+	// 付费方式，支持 Month、Year 和 Dynamic，不支持 Quantity。
+	ChargeSpec `json:",inline"`
+}
+
+// ULBListenerSpec 负载均衡中 API Server 的监听器（VServer）配置
+type ULBListenerSpec struct {
+	// 监听器的端口，即 API Server endpoint 的端口。默认为 Cluster 的 clusterNetwork.apiServerPort，
+	// 没有设置时为 6443，创建后不可修改。
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	FrontendPort int `json:"frontendPort,omitempty"`
+	// 后端服务器即控制面机器上 API Server 的端口。默认为 Cluster 的 clusterNetwork.apiServerPort，
+	// 没有设置时为 6443。PacketsTransmit 模式下必须与监听器的端口相同。
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	BackendPort int `json:"backendPort,omitempty"`
+
+	// 转发方式，RequestProxy 为请求代理，PacketsTransmit 为报文转发。默认为 RequestProxy，创建后不可修改。
+	// +kubebuilder:validation:Enum=RequestProxy;PacketsTransmit
+	// +optional
+	ListenType string `json:"listenType,omitempty"`
+	// 负载均衡算法，默认为 Roundrobin。Leastconn 仅用于 RequestProxy，ConsistentHash、SourcePort 和
+	// ConsistentHashPort 仅用于 PacketsTransmit。
+	// +kubebuilder:validation:Enum=Roundrobin;Source;ConsistentHash;SourcePort;ConsistentHashPort;WeightRoundrobin;Leastconn
+	// +optional
+	Method string `json:"method,omitempty"`
+
+	// 健康检查
+	// +optional
+	HealthCheck ULBHealthCheckSpec `json:"healthCheck,omitempty"`
+
+	// 会话保持方式，None 为关闭，ServerInsert 为自动生成 KEY，UserDefined 为用户自定义 KEY。默认为 None。
+	// +kubebuilder:validation:Enum=None;ServerInsert;UserDefined
+	// +optional
+	PersistenceType string `json:"persistenceType,omitempty"`
+	// 用户自定义的会话保持 KEY，PersistenceType 为 UserDefined 时必填。
+	// +optional
+	PersistenceInfo string `json:"persistenceInfo,omitempty"`
+}
+
+// ULBHealthCheckSpec 监听器的健康检查
+type ULBHealthCheckSpec struct {
+	// 健康检查类型，Port 检查后端端口是否可以连接，Path 通过 HTTP 请求检查路径。默认为 Port。
+	// +kubebuilder:validation:Enum=Port;Path
+	// +optional
+	Type string `json:"type,omitempty"`
+	// HTTP 检查的路径，Type 为 Path 时必填，例如 /healthz。
+	// +optional
+	Path string `json:"path,omitempty"`
+	// HTTP 检查的域名。
+	// +optional
+	Domain string `json:"domain,omitempty"`
 }
 
+// The defaults of the listener of the api server.
+const (
+	// DefaultAPIServerPort is the port of the api server if the cluster doesn't set one.
+	DefaultAPIServerPort = 6443
+	// ULBListenTypeRequestProxy is the listen type of a listener proxying the requests.
+	ULBListenTypeRequestProxy = "RequestProxy"
+	// ULBListenTypePacketsTransmit is the listen type of a listener forwarding the packets,
+	// the backends see the address of the client.
+	ULBListenTypePacketsTransmit = "PacketsTransmit"
+	// ULBMethodRoundrobin is the default balancing method.
+	ULBMethodRoundrobin = "Roundrobin"
+	// ULBHealthCheckPort checks that the backend port accepts connections.
+	ULBHealthCheckPort = "Port"
+	// ULBHealthCheckPath checks a path of the backends over HTTP.
+	ULBHealthCheckPath = "Path"
+	// ULBPersistenceNone turns session persistence off.
+	ULBPersistenceNone = "None"
+	// ULBPersistenceUserDefined persists sessions with a key given by the user.
+	ULBPersistenceUserDefined = "UserDefined"
+)
+
 // FirewallSpec 防火墙
 type FirewallSpec struct {
 	// 使用一个已经存在的防火墙
@@ -147,6 +306,15 @@ type FirewallSpec struct {
 	Description string `json:"description,omitempty"`
 }
 
+// MachineFirewallSpec 按标签分配给机器的防火墙
+type MachineFirewallSpec struct {
+	// 标签，UCloudMachine 的 additionalNetworkTags 包含此标签时使用该防火墙
+	Tag string `json:"tag"`
+
+	// 使用一个已经存在的防火墙，否则创建一个属于集群的防火墙，名称默认为 <集群名>-<标签>
+	FirewallSpec `json:",inline"`
+}
+
 // FirewallRuleSpec 防火墙入方向规则
 // 详细文档见 [AuthorizeFirewall]
 type FirewallRuleSpec struct {
@@ -190,6 +358,8 @@ type VPC struct {
 	VpcName         string `json:"vpcName,omitempty"`
 	CreationTime    string `json:"creationTime,omitempty"`
 	CidrBlock       string `json:"cidrBlock,omitempty"`
+	// CidrBlocks are all the network segments of the VPC, CidrBlock is the first of them.
+	CidrBlocks      []string `json:"cidrBlocks,omitempty"`
 	VRouterId       string `json:"vRouterId,omitempty"`
 	Description     string `json:"description,omitempty"`
 	IsDefault       bool   `json:"isDefault,omitempty"`
@@ -208,6 +378,7 @@ type Subnet struct {
 	CreationTime            string `json:"creationTime,omitempty"`
 	IsDefault               bool   `json:"isDefault,omitempty"`
 	NetworkAclId            string `json:"networkAclId,omitempty"`
+	Role                    string `json:"role,omitempty"`
 }
 
 type Nat struct {
@@ -221,6 +392,8 @@ type Nat struct {
 	CreationTime   string                            `json:"creationTime,omitempty"`
 	Status         string                            `json:"status,omitempty"`
 	SnatTableIds   SnatTableIdsInDescribeNatGateways `json:"snatTableIds,omitempty"`
+	// SubnetIds are the subnets the NAT gateway serves.
+	SubnetIds []string `json:"subnetIds,omitempty"`
 }
 
 type SnatTableIdsInDescribeNatGateways struct {
@@ -233,9 +406,12 @@ type EIP struct {
 	Status          string `json:"status,omitempty"`
 	Bandwidth       int    `json:"bandwidth,omitempty"`
 	ChargeType      string `json:"chargeType,omitempty"`
+	PayMode         string `json:"payMode,omitempty"`
 	EIPName         string `json:"eipName,omitempty"`
 	Descritpion     string `json:"descritpion,omitempty"`
 	Mode            string `json:"mode,omitempty"`
+	// ExpireTime is when the EIP is paid until, it is not set for Dynamic.
+	ExpireTime *metav1.Time `json:"expireTime,omitempty"`
 }
 
 type ULB struct {
@@ -248,6 +424,14 @@ type ULB struct {
 	NetworkType        string `json:"networkType,omitempty"`
 	CreateTime         string `json:"createTime,omitempty"`
 	VServerId          string `json:"vserverId,omitempty"`
+	ChargeType         string `json:"chargeType,omitempty"`
+	// PrivateIP is the private address of an internal load balancer, the address of the
+	// api server endpoint.
+	PrivateIP string `json:"privateIP,omitempty"`
+	// FrontendPort is the port of the listener of the api server, the port of the endpoint.
+	FrontendPort int `json:"frontendPort,omitempty"`
+	// ExpireTime is when the load balancer is paid until, it is not set for Dynamic.
+	ExpireTime *metav1.Time `json:"expireTime,omitempty"`
 }
 
 type Firewall struct {
@@ -257,6 +441,8 @@ type Firewall struct {
 	VpcId           string `json:"vpcId,omitempty"`
 	CreationTime    string `json:"creationTime,omitempty"`
 	FirewallType    string `json:"firewallType,omitempty"`
+	// Rules are the rules applied to the firewall, as protocol|ports|source|action|priority|remark.
+	Rules []string `json:"rules,omitempty"`
 }
 
 type Instance struct {
@@ -265,14 +451,135 @@ type Instance struct {
 	PublicIP     string `json:"publicIP,omitempty"`
 	InstanceType string `json:"instanceType,omitempty"`
 	Zone         string `json:"zone,omitempty"`
-	Name         string `json:"zone,omitempty"`
+	Name         string `json:"name,omitempty"`
+	ChargeType   string `json:"chargeType,omitempty"`
+	// ExpireTime is when the instance is paid until, it is not set for Dynamic and Postpay.
+	ExpireTime *metav1.Time `json:"expireTime,omitempty"`
 }
 
 type BastionSpec struct {
+	// Enabled creates the bastion even if no login is configured, it then gets a
+	// generated password. The bastion is also created if a password or SSHKey is set.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
 	// SSHPassword should be base64 encoded
+	// Deprecated: use SSHPasswordSecretRef, the password is only obfuscated here.
 	SSHPassword string `json:"sshPassword,omitempty"`
+	// SSHPasswordSecretRef references the key of a Secret in the UCloudCluster
+	// namespace holding the password.
+	// +optional
+	SSHPasswordSecretRef *corev1.SecretKeySelector `json:"sshPasswordSecretRef,omitempty"`
+	// SSHKey logs in to the bastion with a key pair instead of a password.
+	// +optional
+	SSHKey *SSHKeySpec `json:"sshKey,omitempty"`
 	// Zone if not set, will choose a zone randomly in region
 	Zone string `json:"zone,omitempty"`
+	// ImageLookup finds the image of the bastion, the newest available image in its
+	// zone matching it is used.
+	// +optional
+	ImageLookup *ImageLookupSpec `json:"imageLookup,omitempty"`
+	// ChargeSpec is how the bastion is paid for.
+	ChargeSpec `json:",inline"`
+}
+
+// PHostSpec configures a bare-metal machine.
+type PHostSpec struct {
+	// Type is the bare-metal machine type, e.g. db-2 or Storage-V1.
+	Type string `json:"type"`
+
+	// Raid is the RAID level of the disks. Defaults to the level of the type.
+	// +kubebuilder:validation:Enum=Raid0;Raid1;Raid5;Raid10;NoRaid
+	// +optional
+	Raid string `json:"raid,omitempty"`
+}
+
+// ChargeSpec configures how a resource is paid for.
+type ChargeSpec struct {
+	// ChargeType is Month or Year to pay in advance, Dynamic to pay by the hour in
+	// advance or Postpay to pay by the hour afterwards. Not every resource supports
+	// every charge type. Defaults to Month.
+	// +kubebuilder:validation:Enum=Month;Year;Dynamic;Postpay
+	// +optional
+	ChargeType string `json:"chargeType,omitempty"`
+
+	// Quantity is the number of months or years paid in advance, at most 11 months or
+	// 5 years. Defaults to 1.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Quantity int `json:"quantity,omitempty"`
+}
+
+// ImageLookupSpec selects an image by its properties. An image must match all the
+// fields that are set.
+type ImageLookupSpec struct {
+	// NamePattern is a regular expression the image name must match.
+	// +optional
+	NamePattern string `json:"namePattern,omitempty"`
+
+	// OsType is the type of the operating system, Linux or Windows.
+	// +kubebuilder:validation:Enum=Linux;Windows
+	// +optional
+	OsType string `json:"osType,omitempty"`
+
+	// OsNamePattern is a regular expression the name of the operating system must
+	// match, e.g. "Ubuntu 18\.04".
+	// +optional
+	OsNamePattern string `json:"osNamePattern,omitempty"`
+
+	// Owner is who provides the image: Base for the images of UCloud, Business for
+	// the image market and Custom for the images of the project. Defaults to Custom.
+	// +kubebuilder:validation:Enum=Base;Business;Custom
+	// +optional
+	Owner string `json:"owner,omitempty"`
+
+	// Vendor is the vendor of an image market image.
+	// +optional
+	Vendor string `json:"vendor,omitempty"`
+
+	// MatchKubernetesVersion requires the name or description of the image to contain
+	// the Kubernetes version of the machine, e.g. v1.18.3 or 1.18.3.
+	// +optional
+	MatchKubernetesVersion bool `json:"matchKubernetesVersion,omitempty"`
+}
+
+// DiskSpec configures a disk of an instance.
+type DiskSpec struct {
+	// IsBoot marks the boot disk, an instance has exactly one.
+	// +optional
+	IsBoot bool `json:"isBoot,omitempty"`
+
+	// Type is the disk type. Defaults to CLOUD_SSD.
+	// +kubebuilder:validation:Enum=CLOUD_SSD;CLOUD_RSSD;CLOUD_NORMAL;LOCAL_NORMAL;LOCAL_SSD;EXCLUSIVE_LOCAL_DISK
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// Size in GB, a multiple of 10. Defaults to 40 for the boot disk.
+	// +optional
+	Size int `json:"size,omitempty"`
+
+	// BackupType is the backup plan of the disk. Defaults to NONE.
+	// +kubebuilder:validation:Enum=NONE;DATAARK;SNAPSHOT
+	// +optional
+	BackupType string `json:"backupType,omitempty"`
+
+	// KmsKeyId encrypts the disk with the given KMS key. Only cloud disks can be encrypted.
+	// +optional
+	KmsKeyId string `json:"kmsKeyId,omitempty"`
+
+	// SnapshotId creates a data disk from a snapshot.
+	// +optional
+	SnapshotId string `json:"snapshotId,omitempty"`
+}
+
+// SSHKeySpec configures the key pair used to log in to an instance.
+// Exactly one of PublicKey and KeyPairId should be set.
+type SSHKeySpec struct {
+	// PublicKey is an OpenSSH public key, it is imported as a UCloud key pair.
+	// +optional
+	PublicKey string `json:"publicKey,omitempty"`
+	// KeyPairId is the id of an existing UCloud key pair.
+	// +optional
+	KeyPairId string `json:"keyPairId,omitempty"`
 }
 
 type Group struct {
@@ -281,3 +588,36 @@ type Group struct {
 	// GroupId
 	GroupId string `json:"groupId,omitempty"`
 }
+
+// ConditionType is the type of a condition of a UCloudCluster.
+type ConditionType string
+
+const (
+	// NetworkInSyncCondition reports whether the network resources recorded in the status
+	// still exist in UCloud and match the spec.
+	NetworkInSyncCondition ConditionType = "NetworkInSync"
+
+	// NetworkDriftedReason is the reason of NetworkInSyncCondition when a resource that is
+	// not created by cluster-api-provider-ucloud drifted, or a drift can't be repaired.
+	NetworkDriftedReason = "NetworkDrifted"
+	// NetworkRepairedReason is the reason of NetworkInSyncCondition when all the drifts
+	// were repaired.
+	NetworkRepairedReason = "NetworkRepaired"
+)
+
+// Condition is an observation of the state of a UCloudCluster.
+type Condition struct {
+	// Type of the condition.
+	Type ConditionType `json:"type"`
+	// Status of the condition, one of True, False or Unknown.
+	Status corev1.ConditionStatus `json:"status"`
+	// LastTransitionTime is when the condition last changed its status.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Reason is a CamelCase reason for the last transition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable message about the last transition.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha3
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 )
@@ -48,6 +49,58 @@ type UCloudClusterSpec struct {
 	// Bastion
 	// +optional
 	Bastion BastionSpec `json:"bastion,omitempty"`
+
+	// IdentityRef is a reference to the UCloudClusterIdentity whose key pair is used
+	// to manage this cluster. If not set, the controller's own credentials are used.
+	// +optional
+	IdentityRef *UCloudClusterIdentityReference `json:"identityRef,omitempty"`
+
+	// NodeCredential configures the UCloud credential made available to the nodes
+	// of the workload cluster, e.g. for the cloud provider and CSI driver.
+	// +optional
+	NodeCredential NodeCredentialSpec `json:"nodeCredential,omitempty"`
+
+	// API configures how the UCloud API is reached for this cluster.
+	// +optional
+	API *APISpec `json:"api,omitempty"`
+}
+
+// APISpec configures the endpoint and transport of UCloud API requests.
+type APISpec struct {
+	// BaseURL is the UCloud API endpoint, e.g. a regional internal or private cloud endpoint.
+	// Defaults to https://api.ucloud.cn.
+	// +optional
+	BaseURL string `json:"baseURL,omitempty"`
+
+	// Timeout of every API request. Defaults to 30s.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// ProxyURL is the HTTP proxy API requests are sent through.
+	// Defaults to the proxy environment variables of the manager.
+	// +optional
+	ProxyURL string `json:"proxyURL,omitempty"`
+
+	// UserAgent is appended to the User-Agent header of API requests.
+	// +optional
+	UserAgent string `json:"userAgent,omitempty"`
+}
+
+// NodeCredentialSpec configures the UCloud credential handed to workload cluster nodes.
+type NodeCredentialSpec struct {
+	// SecretRef references a Secret in the UCloudCluster namespace holding a dedicated,
+	// limited-permission key pair under the UCLOUD_ACCESS_PUBKEY and UCLOUD_ACCESS_PRIKEY keys.
+	// Once the control plane is initialized, the key pair is copied to the
+	// ucloud-credentials Secret in the kube-system namespace of the workload cluster.
+	// +optional
+	SecretRef *corev1.LocalObjectReference `json:"secretRef,omitempty"`
+
+	// InjectIntoUserData enables the legacy substitution of the UCLOUD_CREDENTIAL placeholder
+	// in bootstrap data. The key pair from SecretRef is injected if set, otherwise the
+	// controller's own credential is. User data can be read from every node, so this is
+	// disabled by default and the placeholder is replaced with an empty string.
+	// +optional
+	InjectIntoUserData bool `json:"injectIntoUserData,omitempty"`
 }
 
 // UCloudClusterStatus defines the observed state of UCloudCluster
@@ -64,6 +117,10 @@ type UCloudClusterStatus struct {
 	Group Group `json:"group,omitempty"`
 
 	Ready bool `json:"ready"`
+
+	// Conditions are the latest observations of the state of the cluster.
+	// +optional
+	Conditions []Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -93,6 +150,30 @@ type UCloudClusterList struct {
 	Items           []UCloudCluster `json:"items"`
 }
 
+// GetCondition returns the condition of the given type, or nil if it is not set.
+func (c *UCloudCluster) GetCondition(conditionType ConditionType) *Condition {
+	for i := range c.Status.Conditions {
+		if c.Status.Conditions[i].Type == conditionType {
+			return &c.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or updates the condition of the same type. The last transition
+// time is kept if the status doesn't change.
+func (c *UCloudCluster) SetCondition(condition Condition) {
+	condition.LastTransitionTime = metav1.Now()
+	if existing := c.GetCondition(condition.Type); existing != nil {
+		if existing.Status == condition.Status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		}
+		*existing = condition
+		return
+	}
+	c.Status.Conditions = append(c.Status.Conditions, condition)
+}
+
 func init() {
 	SchemeBuilder.Register(&UCloudCluster{}, &UCloudClusterList{})
 }
@@ -0,0 +1,315 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// The charge types each kind of resource supports.
+var (
+	instanceChargeTypes = []string{"Month", "Year", "Dynamic", "Postpay"}
+	phostChargeTypes    = []string{"Month", "Year", "Dynamic"}
+	eipChargeTypes      = []string{"Month", "Year", "Dynamic"}
+	ulbChargeTypes      = []string{"Month", "Year", "Dynamic"}
+)
+
+func (r *UCloudCluster) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-infrastructure-cluster-x-k8s-io-v1alpha3-ucloudcluster,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=ucloudclusters,versions=v1alpha3,name=validation.ucloudcluster.infrastructure.cluster.x-k8s.io
+
+var _ webhook.Validator = &UCloudCluster{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *UCloudCluster) ValidateCreate() error {
+	return r.validate(nil)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *UCloudCluster) ValidateUpdate(old runtime.Object) error {
+	oldUCloudCluster, ok := old.(*UCloudCluster)
+	if !ok {
+		return apierrors.NewBadRequest("expected an UCloudCluster")
+	}
+	return r.validate(oldUCloudCluster)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *UCloudCluster) ValidateDelete() error {
+	return nil
+}
+
+// validate validates the cluster, and the update of old if it is not nil.
+func (r *UCloudCluster) validate(old *UCloudCluster) error {
+	spec := field.NewPath("spec")
+	network := spec.Child("network")
+	errs := validateCharge(spec.Child("bastion"), r.Spec.Bastion.ChargeSpec, instanceChargeTypes)
+	if r.Spec.Bastion.SSHPassword != "" && r.Spec.Bastion.SSHPasswordSecretRef != nil {
+		errs = append(errs, field.Forbidden(spec.Child("bastion", "sshPassword"), "cannot be set together with sshPasswordSecretRef"))
+	}
+	errs = append(errs, validateCharge(network.Child("nat", "eip"), r.Spec.Network.Nat.EIP.ChargeSpec, eipChargeTypes)...)
+	errs = append(errs, validateCharge(network.Child("ulb", "eip"), r.Spec.Network.ULB.EIP.ChargeSpec, eipChargeTypes)...)
+	errs = append(errs, validateCharge(network.Child("ulb"), r.Spec.Network.ULB.ChargeSpec, ulbChargeTypes)...)
+	if r.Spec.Network.ULB.Quantity != 0 {
+		errs = append(errs, field.Forbidden(network.Child("ulb", "quantity"), "is not supported for load balancers"))
+	}
+	internal := r.Spec.Network.ULB.Mode == ULBModeInternal
+	if internal && !reflect.DeepEqual(r.Spec.Network.ULB.EIP, EIPSpec{}) {
+		errs = append(errs, field.Forbidden(network.Child("ulb", "eip"), "an internal load balancer has no eip"))
+	}
+	if old != nil && internal != (old.Spec.Network.ULB.Mode == ULBModeInternal) {
+		errs = append(errs, field.Forbidden(network.Child("ulb", "mode"), "the mode of the load balancer can't be changed"))
+	}
+	errs = append(errs, validateULBListener(network.Child("ulb", "listener"), r.Spec.Network.ULB.Listener)...)
+	if old != nil {
+		listener, oldListener := r.Spec.Network.ULB.Listener, old.Spec.Network.ULB.Listener
+		if listener.FrontendPort != oldListener.FrontendPort {
+			errs = append(errs, field.Forbidden(network.Child("ulb", "listener", "frontendPort"), "the port of the listener can't be changed"))
+		}
+		if listenType(listener) != listenType(oldListener) {
+			errs = append(errs, field.Forbidden(network.Child("ulb", "listener", "listenType"), "the listen type of the listener can't be changed"))
+		}
+	}
+	if firewall := r.Spec.Network.Firewall; firewall.FirewallId != "" || len(firewall.Rules) > 0 {
+		errs = append(errs, validateFirewall(network.Child("firewall"), firewall)...)
+	}
+	errs = append(errs, validateMachineFirewalls(network.Child("machineFirewalls"), r.Spec.Network.MachineFirewalls)...)
+	errs = append(errs, validateSubnet(network.Child("subnet"), r.Spec.Network.Subnet)...)
+	errs = append(errs, validateSubnets(network.Child("subnets"), r.Spec.Network.Subnets)...)
+	if old != nil {
+		for i, subnet := range old.Spec.Network.Subnets {
+			if i >= len(r.Spec.Network.Subnets) || !reflect.DeepEqual(subnet, r.Spec.Network.Subnets[i]) {
+				errs = append(errs, field.Forbidden(network.Child("subnets").Index(i), "subnets can be added, but not changed or removed"))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("UCloudCluster").GroupKind(), r.Name, errs)
+	}
+	return nil
+}
+
+// validateULBListener validates the listener of the api server.
+func validateULBListener(path *field.Path, listener ULBListenerSpec) field.ErrorList {
+	var errs field.ErrorList
+	packetsTransmit := listenType(listener) == ULBListenTypePacketsTransmit
+	if packetsTransmit && listener.BackendPort != listener.FrontendPort {
+		errs = append(errs, field.Invalid(path.Child("backendPort"), listener.BackendPort, "must be the frontend port when packets are transmitted"))
+	}
+	switch listener.Method {
+	case "Leastconn":
+		if packetsTransmit {
+			errs = append(errs, field.Forbidden(path.Child("method"), "is only supported by RequestProxy"))
+		}
+	case "ConsistentHash", "SourcePort", "ConsistentHashPort":
+		if !packetsTransmit {
+			errs = append(errs, field.Forbidden(path.Child("method"), "is only supported by PacketsTransmit"))
+		}
+	}
+	if listener.HealthCheck.Type == ULBHealthCheckPath {
+		if !strings.HasPrefix(listener.HealthCheck.Path, "/") {
+			errs = append(errs, field.Invalid(path.Child("healthCheck", "path"), listener.HealthCheck.Path, "must be a path such as /healthz"))
+		}
+	} else if listener.HealthCheck.Path != "" || listener.HealthCheck.Domain != "" {
+		errs = append(errs, field.Forbidden(path.Child("healthCheck"), "path and domain are only checked by Path"))
+	}
+	if listener.PersistenceType == ULBPersistenceUserDefined {
+		if listener.PersistenceInfo == "" {
+			errs = append(errs, field.Required(path.Child("persistenceInfo"), "is required by UserDefined"))
+		}
+	} else if listener.PersistenceInfo != "" {
+		errs = append(errs, field.Forbidden(path.Child("persistenceInfo"), "is only used by UserDefined"))
+	}
+	return errs
+}
+
+// listenType returns the listen type of the listener, RequestProxy if it isn't set.
+func listenType(listener ULBListenerSpec) string {
+	if listener.ListenType == "" {
+		return ULBListenTypeRequestProxy
+	}
+	return listener.ListenType
+}
+
+// validateCharge validates the charge of a resource supporting chargeTypes.
+func validateCharge(path *field.Path, charge ChargeSpec, chargeTypes []string) field.ErrorList {
+	var errs field.ErrorList
+	supported := charge.ChargeType == ""
+	for _, chargeType := range chargeTypes {
+		supported = supported || charge.ChargeType == chargeType
+	}
+	if !supported {
+		errs = append(errs, field.NotSupported(path.Child("chargeType"), charge.ChargeType, chargeTypes))
+	}
+	switch {
+	case charge.Quantity < 0:
+		errs = append(errs, field.Invalid(path.Child("quantity"), charge.Quantity, "must not be negative"))
+	case charge.Quantity == 0:
+	case charge.ChargeType == "Dynamic" || charge.ChargeType == "Postpay":
+		errs = append(errs, field.Forbidden(path.Child("quantity"), "only Month and Year are paid for a period"))
+	case charge.ChargeType == "Year" && charge.Quantity > 5:
+		errs = append(errs, field.Invalid(path.Child("quantity"), charge.Quantity, "must be at most 5 years"))
+	case (charge.ChargeType == "" || charge.ChargeType == "Month") && charge.Quantity > 11:
+		errs = append(errs, field.Invalid(path.Child("quantity"), charge.Quantity, "must be at most 11 months, use Year for longer periods"))
+	}
+	return errs
+}
+
+// validateSubnets validates the subnets of a cluster. Each role and zone has at most
+// one subnet, and control plane and worker machines must both have a subnet.
+func validateSubnets(path *field.Path, subnets []SubnetSpec) field.ErrorList {
+	if len(subnets) == 0 {
+		return nil
+	}
+	var errs field.ErrorList
+	roles := map[string]int{}
+	for _, subnet := range subnets {
+		roles[subnet.Role]++
+	}
+	zones := map[string]bool{}
+	for i, subnet := range subnets {
+		key := subnet.Role + "/" + subnet.Zone
+		if zones[key] {
+			errs = append(errs, field.Duplicate(path.Index(i), key))
+		}
+		zones[key] = true
+		errs = append(errs, validateSubnet(path.Index(i), subnet)...)
+	}
+	for _, role := range []string{SubnetRoleControlPlane, SubnetRoleNode} {
+		if roles[role] == 0 && roles[""] == 0 {
+			errs = append(errs, field.Required(path, "no subnet for role "+role))
+		}
+	}
+	return errs
+}
+
+// validateSubnet validates the CIDR block of a subnet, which is allocated if it is empty.
+func validateSubnet(path *field.Path, subnet SubnetSpec) field.ErrorList {
+	var errs field.ErrorList
+	if subnet.CidrBlock != "" {
+		if _, _, err := net.ParseCIDR(subnet.CidrBlock); err != nil {
+			errs = append(errs, field.Invalid(path.Child("cidrBlock"), subnet.CidrBlock, err.Error()))
+		}
+		if subnet.PrefixLength != 0 {
+			errs = append(errs, field.Forbidden(path.Child("prefixLength"), "is only used to allocate a CIDR block"))
+		}
+	}
+	if subnet.SubnetId != "" && subnet.PrefixLength != 0 {
+		errs = append(errs, field.Forbidden(path.Child("prefixLength"), "is only used to create a subnet"))
+	}
+	return errs
+}
+
+// validateMachineFirewalls validates the firewalls machines select by tag.
+func validateMachineFirewalls(path *field.Path, firewalls []MachineFirewallSpec) field.ErrorList {
+	var errs field.ErrorList
+	tags := map[string]bool{}
+	for i, firewall := range firewalls {
+		switch {
+		case firewall.Tag == "":
+			errs = append(errs, field.Required(path.Index(i).Child("tag"), ""))
+		case tags[firewall.Tag]:
+			errs = append(errs, field.Duplicate(path.Index(i).Child("tag"), firewall.Tag))
+		}
+		tags[firewall.Tag] = true
+		errs = append(errs, validateFirewall(path.Index(i), firewall.FirewallSpec)...)
+	}
+	return errs
+}
+
+// validateFirewall validates a firewall, which is either an existing firewall or
+// created with its rules.
+func validateFirewall(path *field.Path, firewall FirewallSpec) field.ErrorList {
+	if firewall.FirewallId != "" {
+		if len(firewall.Rules) > 0 {
+			return field.ErrorList{field.Forbidden(path.Child("rules"), "the rules of an existing firewall are not managed")}
+		}
+		return nil
+	}
+	if len(firewall.Rules) == 0 {
+		return field.ErrorList{field.Required(path.Child("rules"), "a new firewall needs at least one rule")}
+	}
+	var errs field.ErrorList
+	for i, rule := range firewall.Rules {
+		errs = append(errs, validateFirewallRule(path.Child("rules").Index(i), rule)...)
+	}
+	return errs
+}
+
+// validateFirewallRule validates a firewall rule. UCloud firewalls only filter
+// inbound traffic by protocol, port and source.
+func validateFirewallRule(path *field.Path, rule *FirewallRuleSpec) field.ErrorList {
+	if rule == nil {
+		return field.ErrorList{field.Required(path, "")}
+	}
+	var errs field.ErrorList
+	protocol := strings.ToLower(rule.IpProtocol)
+	switch protocol {
+	case "tcp", "udp":
+		if rule.PortRange != "" && !validPortRange(rule.PortRange) {
+			errs = append(errs, field.Invalid(path.Child("portRange"), rule.PortRange, "must be a port or a range of ports such as 1/200"))
+		}
+	case "icmp", "gre":
+		if rule.PortRange != "" && rule.PortRange != "-1/-1" {
+			errs = append(errs, field.Invalid(path.Child("portRange"), rule.PortRange, "must be -1/-1 or empty for "+protocol))
+		}
+	default:
+		errs = append(errs, field.NotSupported(path.Child("ipProtocol"), rule.IpProtocol, []string{"tcp", "udp", "icmp", "gre"}))
+	}
+	switch strings.ToLower(rule.Policy) {
+	case "", "accept", "drop":
+	default:
+		errs = append(errs, field.NotSupported(path.Child("policy"), rule.Policy, []string{"accept", "drop"}))
+	}
+	if rule.SourcePortRange != "" {
+		errs = append(errs, field.Forbidden(path.Child("sourcePortRange"), "is not supported by UCloud firewalls"))
+	}
+	if rule.DestCidrIp != "" {
+		errs = append(errs, field.Forbidden(path.Child("destCidrIp"), "is not supported by UCloud firewalls"))
+	}
+	return errs
+}
+
+// validPortRange returns true if portRange is a port or a range of ports such as 1/200.
+func validPortRange(portRange string) bool {
+	parts := strings.Split(portRange, "/")
+	if len(parts) > 2 {
+		return false
+	}
+	var ports []int
+	for _, part := range parts {
+		port, err := strconv.Atoi(part)
+		if err != nil || port < 1 || port > 65535 {
+			return false
+		}
+		ports = append(ports, port)
+	}
+	return len(ports) == 1 || ports[0] <= ports[1]
+}
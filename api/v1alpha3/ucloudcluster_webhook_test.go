@@ -0,0 +1,219 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestUCloudClusterValidateCharge(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    UCloudClusterSpec
+		wantErr bool
+	}{
+		{name: "defaults"},
+		{name: "postpay bastion", spec: UCloudClusterSpec{Bastion: BastionSpec{ChargeSpec: ChargeSpec{ChargeType: "Postpay"}}}},
+		{name: "yearly eip", spec: UCloudClusterSpec{Network: NetworkSpec{Nat: NatSpec{EIP: EIPSpec{ChargeSpec: ChargeSpec{ChargeType: "Year", Quantity: 3}}}}}},
+		{name: "postpay eip", spec: UCloudClusterSpec{Network: NetworkSpec{Nat: NatSpec{EIP: EIPSpec{ChargeSpec: ChargeSpec{ChargeType: "Postpay"}}}}}, wantErr: true},
+		{name: "ulb quantity", spec: UCloudClusterSpec{Network: NetworkSpec{ULB: ULBSpec{ChargeSpec: ChargeSpec{Quantity: 2}}}}, wantErr: true},
+		{name: "dynamic with quantity", spec: UCloudClusterSpec{Bastion: BastionSpec{ChargeSpec: ChargeSpec{ChargeType: "Dynamic", Quantity: 2}}}, wantErr: true},
+		{name: "too many months", spec: UCloudClusterSpec{Network: NetworkSpec{ULB: ULBSpec{EIP: EIPSpec{ChargeSpec: ChargeSpec{Quantity: 12}}}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			cluster := &UCloudCluster{Spec: tt.spec}
+			if tt.wantErr {
+				g.Expect(cluster.ValidateCreate()).NotTo(Succeed())
+			} else {
+				g.Expect(cluster.ValidateCreate()).To(Succeed())
+			}
+		})
+	}
+}
+
+func TestUCloudClusterValidateBastion(t *testing.T) {
+	secretRef := &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "bastion"}, Key: "password"}
+	tests := []struct {
+		name    string
+		bastion BastionSpec
+		wantErr bool
+	}{
+		{name: "generated password", bastion: BastionSpec{Enabled: true}},
+		{name: "password", bastion: BastionSpec{SSHPassword: "cGFzc3dvcmQ="}},
+		{name: "password secret", bastion: BastionSpec{SSHPasswordSecretRef: secretRef}},
+		{name: "password and password secret", bastion: BastionSpec{SSHPassword: "cGFzc3dvcmQ=", SSHPasswordSecretRef: secretRef}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			cluster := &UCloudCluster{Spec: UCloudClusterSpec{Bastion: tt.bastion}}
+			if tt.wantErr {
+				g.Expect(cluster.ValidateCreate()).NotTo(Succeed())
+			} else {
+				g.Expect(cluster.ValidateCreate()).To(Succeed())
+			}
+		})
+	}
+}
+
+func TestUCloudClusterValidateFirewalls(t *testing.T) {
+	ssh := &FirewallRuleSpec{IpProtocol: "tcp", PortRange: "22/22"}
+	tests := []struct {
+		name      string
+		firewall  FirewallSpec
+		firewalls []MachineFirewallSpec
+		wantErr   bool
+	}{
+		{name: "default cluster firewall"},
+		{name: "cluster firewall rules", firewall: FirewallSpec{Rules: []*FirewallRuleSpec{ssh}}},
+		{name: "rules of existing cluster firewall", firewall: FirewallSpec{FirewallId: "firewall-1", Rules: []*FirewallRuleSpec{ssh}}, wantErr: true},
+		{name: "invalid cluster firewall rule", firewall: FirewallSpec{Rules: []*FirewallRuleSpec{{IpProtocol: "tcp", Policy: "reject"}}}, wantErr: true},
+		{name: "new firewall", firewalls: []MachineFirewallSpec{{Tag: "worker", FirewallSpec: FirewallSpec{Rules: []*FirewallRuleSpec{ssh}}}}},
+		{name: "existing firewall", firewalls: []MachineFirewallSpec{{Tag: "worker", FirewallSpec: FirewallSpec{FirewallId: "firewall-1"}}}},
+		{name: "icmp", firewalls: []MachineFirewallSpec{{Tag: "worker", FirewallSpec: FirewallSpec{Rules: []*FirewallRuleSpec{{IpProtocol: "ICMP", Policy: "drop"}}}}}},
+		{name: "no tag", firewalls: []MachineFirewallSpec{{FirewallSpec: FirewallSpec{FirewallId: "firewall-1"}}}, wantErr: true},
+		{name: "duplicate tag", firewalls: []MachineFirewallSpec{
+			{Tag: "worker", FirewallSpec: FirewallSpec{FirewallId: "firewall-1"}},
+			{Tag: "worker", FirewallSpec: FirewallSpec{FirewallId: "firewall-2"}},
+		}, wantErr: true},
+		{name: "new firewall without rules", firewalls: []MachineFirewallSpec{{Tag: "worker"}}, wantErr: true},
+		{name: "rules of existing firewall", firewalls: []MachineFirewallSpec{{Tag: "worker", FirewallSpec: FirewallSpec{FirewallId: "firewall-1", Rules: []*FirewallRuleSpec{ssh}}}}, wantErr: true},
+		{name: "reversed port range", firewalls: []MachineFirewallSpec{{Tag: "worker", FirewallSpec: FirewallSpec{Rules: []*FirewallRuleSpec{{IpProtocol: "tcp", PortRange: "200/1"}}}}}, wantErr: true},
+		{name: "all protocols", firewalls: []MachineFirewallSpec{{Tag: "worker", FirewallSpec: FirewallSpec{Rules: []*FirewallRuleSpec{{IpProtocol: "all"}}}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			cluster := &UCloudCluster{Spec: UCloudClusterSpec{Network: NetworkSpec{Firewall: tt.firewall, MachineFirewalls: tt.firewalls}}}
+			if tt.wantErr {
+				g.Expect(cluster.ValidateCreate()).NotTo(Succeed())
+			} else {
+				g.Expect(cluster.ValidateCreate()).To(Succeed())
+			}
+		})
+	}
+}
+
+func TestUCloudClusterValidateSubnets(t *testing.T) {
+	controlPlane := SubnetSpec{Role: SubnetRoleControlPlane}
+	node := SubnetSpec{Role: SubnetRoleNode}
+	tests := []struct {
+		name    string
+		old     []SubnetSpec
+		subnets []SubnetSpec
+		wantErr bool
+	}{
+		{name: "single subnet"},
+		{name: "allocated cidr blocks", subnets: []SubnetSpec{controlPlane, {Role: SubnetRoleNode, PrefixLength: 20}}},
+		{name: "shared subnet", subnets: []SubnetSpec{{CidrBlock: "10.0.0.0/24"}, {Role: SubnetRoleBastion, CidrBlock: "10.0.1.0/24"}}},
+		{name: "subnets per zone", subnets: []SubnetSpec{
+			controlPlane,
+			{Role: SubnetRoleNode, Zone: "cn-bj2-02", CidrBlock: "10.1.0.0/24"},
+			{Role: SubnetRoleNode, Zone: "cn-bj2-03", SubnetId: "subnet-1"},
+		}},
+		{name: "no node subnet", subnets: []SubnetSpec{controlPlane}, wantErr: true},
+		{name: "duplicate role and zone", subnets: []SubnetSpec{controlPlane, node, {Role: SubnetRoleNode, CidrBlock: "10.2.0.0/16"}}, wantErr: true},
+		{name: "prefix length of a cidr block", subnets: []SubnetSpec{controlPlane, {Role: SubnetRoleNode, CidrBlock: "10.1.0.0/16", PrefixLength: 24}}, wantErr: true},
+		{name: "prefix length of an existing subnet", subnets: []SubnetSpec{controlPlane, {Role: SubnetRoleNode, SubnetId: "subnet-1", PrefixLength: 24}}, wantErr: true},
+		{name: "invalid cidr block", subnets: []SubnetSpec{controlPlane, {Role: SubnetRoleNode, CidrBlock: "10.1.0.0"}}, wantErr: true},
+		{name: "subnet added", old: []SubnetSpec{controlPlane, node}, subnets: []SubnetSpec{controlPlane, node, {Role: SubnetRoleBastion, CidrBlock: "10.2.0.0/24"}}},
+		{name: "subnet removed", old: []SubnetSpec{controlPlane, node, {CidrBlock: "10.2.0.0/24"}}, subnets: []SubnetSpec{controlPlane, node}, wantErr: true},
+		{name: "subnet changed", old: []SubnetSpec{controlPlane, node}, subnets: []SubnetSpec{controlPlane, {Role: SubnetRoleNode, CidrBlock: "10.2.0.0/16"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			cluster := &UCloudCluster{Spec: UCloudClusterSpec{Network: NetworkSpec{Subnets: tt.subnets}}}
+			old := &UCloudCluster{Spec: UCloudClusterSpec{Network: NetworkSpec{Subnets: tt.old}}}
+			if tt.wantErr {
+				g.Expect(cluster.ValidateUpdate(old)).NotTo(Succeed())
+			} else {
+				g.Expect(cluster.ValidateUpdate(old)).To(Succeed())
+			}
+		})
+	}
+}
+
+func TestUCloudClusterValidateULBMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     ULBSpec
+		ulb     ULBSpec
+		wantErr bool
+	}{
+		{name: "public"},
+		{name: "internal", old: ULBSpec{Mode: ULBModeInternal}, ulb: ULBSpec{Mode: ULBModeInternal, ChargeSpec: ChargeSpec{ChargeType: "Dynamic"}}},
+		{name: "default mode set", ulb: ULBSpec{Mode: ULBModePublic}},
+		{name: "internal with eip", old: ULBSpec{Mode: ULBModeInternal}, ulb: ULBSpec{Mode: ULBModeInternal, EIP: EIPSpec{Bandwidth: 10}}, wantErr: true},
+		{name: "public to internal", ulb: ULBSpec{Mode: ULBModeInternal}, wantErr: true},
+		{name: "internal to public", old: ULBSpec{Mode: ULBModeInternal}, ulb: ULBSpec{Mode: ULBModePublic}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			cluster := &UCloudCluster{Spec: UCloudClusterSpec{Network: NetworkSpec{ULB: tt.ulb}}}
+			old := &UCloudCluster{Spec: UCloudClusterSpec{Network: NetworkSpec{ULB: tt.old}}}
+			if tt.wantErr {
+				g.Expect(cluster.ValidateUpdate(old)).NotTo(Succeed())
+			} else {
+				g.Expect(cluster.ValidateUpdate(old)).To(Succeed())
+			}
+		})
+	}
+}
+
+func TestUCloudClusterValidateULBListener(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      ULBListenerSpec
+		listener ULBListenerSpec
+		wantErr  bool
+	}{
+		{name: "default"},
+		{name: "request proxy", listener: ULBListenerSpec{FrontendPort: 443, BackendPort: 8443, Method: "Leastconn"}, old: ULBListenerSpec{FrontendPort: 443}},
+		{name: "packets transmit", listener: ULBListenerSpec{ListenType: "PacketsTransmit", FrontendPort: 8443, BackendPort: 8443, Method: "ConsistentHash"}, old: ULBListenerSpec{ListenType: "PacketsTransmit", FrontendPort: 8443}},
+		{name: "health check path", listener: ULBListenerSpec{HealthCheck: ULBHealthCheckSpec{Type: "Path", Path: "/healthz", Domain: "kubernetes"}}},
+		{name: "user defined persistence", listener: ULBListenerSpec{PersistenceType: "UserDefined", PersistenceInfo: "apiserver"}},
+		{name: "method changed", listener: ULBListenerSpec{Method: "Source"}, old: ULBListenerSpec{Method: "Roundrobin"}},
+		{name: "frontend port changed", listener: ULBListenerSpec{FrontendPort: 443}, wantErr: true},
+		{name: "listen type changed", listener: ULBListenerSpec{ListenType: "PacketsTransmit"}, wantErr: true},
+		{name: "packets transmit to another port", listener: ULBListenerSpec{ListenType: "PacketsTransmit", FrontendPort: 443, BackendPort: 6443}, old: ULBListenerSpec{ListenType: "PacketsTransmit", FrontendPort: 443}, wantErr: true},
+		{name: "least connections transmitting packets", listener: ULBListenerSpec{ListenType: "PacketsTransmit", Method: "Leastconn"}, old: ULBListenerSpec{ListenType: "PacketsTransmit"}, wantErr: true},
+		{name: "consistent hash proxying requests", listener: ULBListenerSpec{Method: "ConsistentHash"}, wantErr: true},
+		{name: "health check path missing", listener: ULBListenerSpec{HealthCheck: ULBHealthCheckSpec{Type: "Path"}}, wantErr: true},
+		{name: "health check path of port", listener: ULBListenerSpec{HealthCheck: ULBHealthCheckSpec{Path: "/healthz"}}, wantErr: true},
+		{name: "user defined persistence without key", listener: ULBListenerSpec{PersistenceType: "UserDefined"}, wantErr: true},
+		{name: "persistence key not used", listener: ULBListenerSpec{PersistenceType: "ServerInsert", PersistenceInfo: "apiserver"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			cluster := &UCloudCluster{Spec: UCloudClusterSpec{Network: NetworkSpec{ULB: ULBSpec{Listener: tt.listener}}}}
+			old := &UCloudCluster{Spec: UCloudClusterSpec{Network: NetworkSpec{ULB: ULBSpec{Listener: tt.old}}}}
+			if tt.wantErr {
+				g.Expect(cluster.ValidateUpdate(old)).NotTo(Succeed())
+			} else {
+				g.Expect(cluster.ValidateUpdate(old)).To(Succeed())
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// UCloudClusterIdentitySpec defines the UCloud account a UCloudCluster acts as.
+type UCloudClusterIdentitySpec struct {
+	// SecretRef references the Secret holding the UCloud key pair, its namespace is required.
+	// The Secret must contain the UCLOUD_ACCESS_PUBKEY and UCLOUD_ACCESS_PRIKEY keys.
+	SecretRef corev1.SecretReference `json:"secretRef"`
+
+	// AllowedNamespaces is used to identify which namespaces are allowed to use
+	// this identity. Namespaces can be selected either by name or with a label selector.
+	// An empty allowedNamespaces object allows UCloudClusters in any namespace to use
+	// this identity. If this field is nil, no namespace is allowed to use it.
+	// +optional
+	AllowedNamespaces *AllowedNamespaces `json:"allowedNamespaces,omitempty"`
+}
+
+// AllowedNamespaces selects the namespaces allowed to use an identity.
+type AllowedNamespaces struct {
+	// NamespaceList is a list of namespace names.
+	// +optional
+	NamespaceList []string `json:"list,omitempty"`
+
+	// Selector is a label selector matching namespaces.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// UCloudClusterIdentityReference references a UCloudClusterIdentity.
+type UCloudClusterIdentityReference struct {
+	// Name of the UCloudClusterIdentity.
+	Name string `json:"name"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=ucloudclusteridentities,scope=Cluster,categories=cluster-api
+// +kubebuilder:storageversion
+
+// UCloudClusterIdentity is the Schema for the ucloudclusteridentities API
+type UCloudClusterIdentity struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec UCloudClusterIdentitySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// UCloudClusterIdentityList contains a list of UCloudClusterIdentity
+type UCloudClusterIdentityList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []UCloudClusterIdentity `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&UCloudClusterIdentity{}, &UCloudClusterIdentityList{})
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha3
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/errors"
@@ -28,26 +29,81 @@ const (
 	MachineFinalizer = "ucloudmachine.infrastructure.cluster.x-k8s.io"
 )
 
+// The kinds of instance a UCloudMachine can run on.
+const (
+	// UHostInstanceKind is a virtual machine.
+	UHostInstanceKind = "uhost"
+	// UPHostInstanceKind is a bare-metal machine.
+	UPHostInstanceKind = "uphost"
+)
+
 // UCloudMachineSpec defines the desired state of UCloudMachine
 type UCloudMachineSpec struct {
-	// InstanceType is the type of instance to create. Example: n1.standard-2
+	// InstanceKind is uhost for a virtual machine or uphost for a bare-metal machine.
+	// Bare-metal machines can only be workers, they need ImageId and PHost and are
+	// logged in to with a password. Defaults to uhost.
+	// +kubebuilder:validation:Enum=uhost;uphost
+	// +optional
+	InstanceKind string `json:"instanceKind,omitempty"`
+
+	// PHost configures a bare-metal machine.
+	// +optional
+	PHost *PHostSpec `json:"pHost,omitempty"`
+
+	// InstanceType is the name of an instance type in the catalog configured for the
+	// manager, e.g. o.c6.2xlarge. "uhost" is a general purpose N machine with 4 cores
+	// and 8 GB memory, and is the only known type if no catalog is configured.
 	InstanceType string `json:"instanceType"`
 
-	// CPU core number
+	// CPU core number, overrides the instance type.
 	CPU int `json:"cpu,omitempty"`
 
-	// Memory
+	// Memory in MB, overrides the instance type.
 	Memory int `json:"memory,omitempty"`
 
+	// MachineType is the machine family, e.g. N, C, G or O. Overrides the instance type.
+	// +optional
+	MachineType string `json:"machineType,omitempty"`
+
+	// MinimalCpuPlatform is the minimal CPU platform, e.g. Intel/Cascadelake.
+	// Overrides the instance type.
+	// +optional
+	MinimalCpuPlatform string `json:"minimalCpuPlatform,omitempty"`
+
+	// GPU is the number of GPUs, overrides the instance type.
+	// +optional
+	GPU int `json:"gpu,omitempty"`
+
+	// GPUType is the GPU model, e.g. K80, P40 or V100. Overrides the instance type.
+	// +optional
+	GPUType string `json:"gpuType,omitempty"`
+
 	// RootDiskSize
 	RootDiskSize int `json:"rootDiskSize,omitempty"`
 
 	// DataDiskSize
 	DataDiskSize int `json:"dataDiskSize,omitempty"`
 
-	// SSHPassword should be base64 encoded
+	// Disks is the disk layout of the instance. If it has no boot disk, a CLOUD_SSD
+	// boot disk of RootDiskSize is added. Unlike with DataDiskSize, no data disk is
+	// created unless listed, so a list with only a boot disk creates none.
+	// +optional
+	Disks []DiskSpec `json:"disks,omitempty"`
+
+	// SSHPassword should be base64 encoded.
+	// Deprecated: use SSHPasswordSecretRef, the password is only obfuscated here.
 	SSHPassword string `json:"sshPassword,omitempty"`
 
+	// SSHPasswordSecretRef references the key of a Secret in the UCloudMachine
+	// namespace holding the password. If no password or SSHKey is set, a password is
+	// generated and stored in the Secret named <name>-ssh-password.
+	// +optional
+	SSHPasswordSecretRef *corev1.SecretKeySelector `json:"sshPasswordSecretRef,omitempty"`
+
+	// SSHKey logs in to the instance with a key pair instead of a password.
+	// +optional
+	SSHKey *SSHKeySpec `json:"sshKey,omitempty"`
+
 	// ProviderID is the unique identifier as specified by the cloud provider.
 	// +optional
 	ProviderID *string `json:"providerID,omitempty"`
@@ -56,16 +112,38 @@ type UCloudMachineSpec struct {
 	// +optional
 	ImageId *string `json:"imageId,omitempty"`
 
+	// ImageLookup finds the image of the machine if ImageId is not set, the newest
+	// available image in the zone of the machine matching it is used.
+	// +optional
+	ImageLookup *ImageLookupSpec `json:"imageLookup,omitempty"`
+
 	// PublicIP specifies whether the instance should get a public IP.
 	// Set this to true if you don't have a NAT instances or Cloud Nat setup.
 	// +optional
 	PublicIP *bool `json:"publicIP,omitempty"`
 
+	// EIP configures the EIP bound to the instance if PublicIP is true. An existing
+	// EIP is bound by EIPId and kept when the machine is deleted, otherwise a new EIP
+	// is allocated and released with the machine.
+	// +optional
+	EIP *EIPSpec `json:"eip,omitempty"`
+
 	// AdditionalNetworkTags is a list of network tags that should be applied to the
-	// instance. These tags are set in addition to any network tags defined
-	// at the cluster level or in the actuator.
+	// instance. A tag naming one of the machine firewalls of the cluster attaches that
+	// firewall to the instance, at most one tag may name a firewall since an instance
+	// has a single firewall. It can be changed, the firewall is updated in place.
 	// +optional
 	AdditionalNetworkTags []string `json:"additionalNetworkTags,omitempty"`
+
+	// FirewallId is an existing firewall to attach to the instance, it takes precedence
+	// over AdditionalNetworkTags. Without either, the instance gets the firewall of
+	// the cluster. It can be changed, the firewall is updated in place.
+	// +optional
+	FirewallId string `json:"firewallId,omitempty"`
+
+	// ChargeSpec is how the instance is paid for, Dynamic or Postpay suit
+	// short-lived and autoscaled machines.
+	ChargeSpec `json:",inline"`
 }
 
 // UCloudMachineStatus defines the observed state of UCloudMachine
@@ -83,6 +161,32 @@ type UCloudMachineStatus struct {
 	// ClusterId
 	ClusterId string `json:"clusterId,omitempty"`
 
+	// CreateTime is when the controller created the instance, the UCloud API may not
+	// list it for a while after.
+	// +optional
+	CreateTime *metav1.Time `json:"createTime,omitempty"`
+
+	// ImageId is the image the instance was created from.
+	// +optional
+	ImageId string `json:"imageId,omitempty"`
+
+	// ChargeType is how the instance is paid for.
+	// +optional
+	ChargeType string `json:"chargeType,omitempty"`
+
+	// ExpireTime is when the instance is paid until, it is not set for Dynamic and
+	// Postpay.
+	// +optional
+	ExpireTime *metav1.Time `json:"expireTime,omitempty"`
+
+	// FirewallId is the firewall attached to the instance.
+	// +optional
+	FirewallId string `json:"firewallId,omitempty"`
+
+	// EIP is the EIP bound to the instance if PublicIP is true.
+	// +optional
+	EIP *EIP `json:"eip,omitempty"`
+
 	// Addresses contains the UCLOUD instance associated addresses.
 	Addresses []clusterv1.MachineAddress `json:"addresses,omitempty"`
 
@@ -18,6 +18,8 @@ package v1alpha3
 
 import (
 	"reflect"
+	"regexp"
+	"strings"
 
 	"github.com/pkg/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -31,21 +33,177 @@ import (
 // log is for logging in this package.
 var _ = logf.Log.WithName("ucloudmachine-resource")
 
-func (r *UCloudMachine) SetupWebhookWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewWebhookManagedBy(mgr).
-		For(r).
-		Complete()
+// SetupWebhookWithManager registers the validating webhook of UCloudMachines. Instance
+// types are validated against instanceTypes if it is not nil.
+func (r *UCloudMachine) SetupWebhookWithManager(mgr ctrl.Manager, instanceTypes InstanceTypeValidator) error {
+	mgr.GetWebhookServer().Register("/validate-infrastructure-cluster-x-k8s-io-v1alpha3-ucloudmachine", newValidatingWebhook(r, instanceTypes))
+	return nil
 }
 
 // +kubebuilder:webhook:verbs=create;update,path=/validate-infrastructure-cluster-x-k8s-io-v1alpha3-ucloudmachine,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=ucloudmachines,versions=v1alpha3,name=validation.ucloudmachine.infrastructure.cluster.x-k8s.io
 
 var _ webhook.Validator = &UCloudMachine{}
 
-// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+// ValidateCreate implements webhook.Validator, it doesn't validate the instance type.
 func (r *UCloudMachine) ValidateCreate() error {
+	return r.validateCreate(nil)
+}
+
+// validateCreate validates a new machine, its instance type is validated against
+// instanceTypes if it is not nil.
+func (r *UCloudMachine) validateCreate(instanceTypes InstanceTypeValidator) error {
+	errs := validateLogin(field.NewPath("spec"), &r.Spec)
+	errs = append(errs, validateInstanceType(field.NewPath("spec"), &r.Spec, instanceTypes)...)
+	errs = append(errs, validateDisks(field.NewPath("spec"), &r.Spec)...)
+	errs = append(errs, validateImageLookup(field.NewPath("spec"), &r.Spec)...)
+	errs = append(errs, validateInstanceKind(field.NewPath("spec"), &r.Spec)...)
+	errs = append(errs, validatePublicIP(field.NewPath("spec"), &r.Spec)...)
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("UCloudMachine").GroupKind(), r.Name, errs)
+	}
 	return nil
 }
 
+// validateLogin validates how a machine spec is logged in to.
+func validateLogin(path *field.Path, spec *UCloudMachineSpec) field.ErrorList {
+	var errs field.ErrorList
+	if key := spec.SSHKey; key != nil && (key.PublicKey == "") == (key.KeyPairId == "") {
+		errs = append(errs, field.Invalid(path.Child("sshKey"), key, "exactly one of publicKey and keyPairId must be set"))
+	}
+	if spec.SSHPassword != "" && spec.SSHPasswordSecretRef != nil {
+		errs = append(errs, field.Forbidden(path.Child("sshPassword"), "cannot be set together with sshPasswordSecretRef"))
+	}
+	return errs
+}
+
+// validateInstanceKind validates the fields of a machine spec that depend on the kind
+// of its instance, bare-metal machines support fewer options than virtual machines.
+func validateInstanceKind(path *field.Path, spec *UCloudMachineSpec) field.ErrorList {
+	if spec.InstanceKind != UPHostInstanceKind {
+		errs := validateCharge(path, spec.ChargeSpec, instanceChargeTypes)
+		if spec.PHost != nil {
+			errs = append(errs, field.Forbidden(path.Child("pHost"), "is only supported for uphost instances"))
+		}
+		return errs
+	}
+
+	errs := validateCharge(path, spec.ChargeSpec, phostChargeTypes)
+	if spec.PHost == nil || spec.PHost.Type == "" {
+		errs = append(errs, field.Required(path.Child("pHost", "type"), "is required for uphost instances"))
+	}
+	if spec.ImageId == nil {
+		errs = append(errs, field.Required(path.Child("imageId"), "is required for uphost instances"))
+	}
+	if spec.ImageLookup != nil {
+		errs = append(errs, field.Forbidden(path.Child("imageLookup"), "is not supported for uphost instances"))
+	}
+	if spec.SSHKey != nil {
+		errs = append(errs, field.Forbidden(path.Child("sshKey"), "uphost instances are logged in to with a password"))
+	}
+	if len(spec.Disks) > 0 {
+		errs = append(errs, field.Forbidden(path.Child("disks"), "the disks of uphost instances are given by their type"))
+	}
+	return errs
+}
+
+// validatePublicIP validates the EIP of a machine spec.
+func validatePublicIP(path *field.Path, spec *UCloudMachineSpec) field.ErrorList {
+	if spec.EIP == nil {
+		return nil
+	}
+	if spec.PublicIP == nil || !*spec.PublicIP {
+		return field.ErrorList{field.Forbidden(path.Child("eip"), "is only used if publicIP is true")}
+	}
+	return validateCharge(path.Child("eip"), spec.EIP.ChargeSpec, eipChargeTypes)
+}
+
+// validateInstanceType validates the instance type of a machine spec against instanceTypes.
+func validateInstanceType(path *field.Path, spec *UCloudMachineSpec, instanceTypes InstanceTypeValidator) field.ErrorList {
+	var errs field.ErrorList
+	if instanceTypes != nil && spec.InstanceType != "" {
+		if err := instanceTypes.Validate(spec.InstanceType); err != nil {
+			errs = append(errs, field.Invalid(path.Child("instanceType"), spec.InstanceType, err.Error()))
+		}
+	}
+	return errs
+}
+
+// validateDisks validates the disk layout of a machine spec.
+func validateDisks(path *field.Path, spec *UCloudMachineSpec) field.ErrorList {
+	var errs field.ErrorList
+	if len(spec.Disks) == 0 {
+		return errs
+	}
+	if spec.DataDiskSize != 0 {
+		errs = append(errs, field.Forbidden(path.Child("dataDiskSize"), "cannot be set together with disks"))
+	}
+
+	bootType := "CLOUD_SSD"
+	boot := 0
+	for _, disk := range spec.Disks {
+		if disk.IsBoot {
+			boot++
+			if disk.Type != "" {
+				bootType = disk.Type
+			}
+		}
+	}
+	if boot > 1 {
+		errs = append(errs, field.Invalid(path.Child("disks"), boot, "at most one disk can be the boot disk"))
+	}
+
+	for i, disk := range spec.Disks {
+		diskPath := path.Child("disks").Index(i)
+		local := isLocalDisk(disk.Type)
+		if disk.Size < 0 || disk.Size%10 != 0 {
+			errs = append(errs, field.Invalid(diskPath.Child("size"), disk.Size, "must be a multiple of 10"))
+		}
+		if disk.Size == 0 && !disk.IsBoot && disk.SnapshotId == "" {
+			errs = append(errs, field.Required(diskPath.Child("size"), "data disks must have a size unless created from a snapshot"))
+		}
+		if disk.IsBoot && disk.SnapshotId != "" {
+			errs = append(errs, field.Forbidden(diskPath.Child("snapshotId"), "the boot disk is created from the image"))
+		}
+		if local && disk.KmsKeyId != "" {
+			errs = append(errs, field.Forbidden(diskPath.Child("kmsKeyId"), "local disks cannot be encrypted"))
+		}
+		if local && disk.SnapshotId != "" {
+			errs = append(errs, field.Forbidden(diskPath.Child("snapshotId"), "local disks cannot be created from a snapshot"))
+		}
+		if local && disk.BackupType == "SNAPSHOT" {
+			errs = append(errs, field.Forbidden(diskPath.Child("backupType"), "local disks cannot be backed up with snapshots"))
+		}
+		if local && !disk.IsBoot && !isLocalDisk(bootType) {
+			errs = append(errs, field.Forbidden(diskPath.Child("type"), "local data disks require a local boot disk"))
+		}
+	}
+	return errs
+}
+
+// validateImageLookup validates the image lookup of a machine spec.
+func validateImageLookup(path *field.Path, spec *UCloudMachineSpec) field.ErrorList {
+	var errs field.ErrorList
+	lookup := spec.ImageLookup
+	if lookup == nil {
+		return errs
+	}
+	if spec.ImageId != nil {
+		errs = append(errs, field.Forbidden(path.Child("imageLookup"), "cannot be set together with imageId"))
+	}
+	if _, err := regexp.Compile(lookup.NamePattern); err != nil {
+		errs = append(errs, field.Invalid(path.Child("imageLookup", "namePattern"), lookup.NamePattern, err.Error()))
+	}
+	if _, err := regexp.Compile(lookup.OsNamePattern); err != nil {
+		errs = append(errs, field.Invalid(path.Child("imageLookup", "osNamePattern"), lookup.OsNamePattern, err.Error()))
+	}
+	return errs
+}
+
+// isLocalDisk returns true if the disk type is a disk local to the host.
+func isLocalDisk(diskType string) bool {
+	return strings.HasPrefix(diskType, "LOCAL_") || diskType == "EXCLUSIVE_LOCAL_DISK"
+}
+
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *UCloudMachine) ValidateUpdate(old runtime.Object) error {
 	newUCloudMachine, err := runtime.DefaultUnstructuredConverter.ToUnstructured(r)
@@ -76,6 +234,10 @@ func (r *UCloudMachine) ValidateUpdate(old runtime.Object) error {
 	delete(oldUCloudMachineSpec, "additionalNetworkTags")
 	delete(newUCloudMachineSpec, "additionalNetworkTags")
 
+	// allow changes to firewallId
+	delete(oldUCloudMachineSpec, "firewallId")
+	delete(newUCloudMachineSpec, "firewallId")
+
 	if !reflect.DeepEqual(oldUCloudMachineSpec, newUCloudMachineSpec) {
 		return apierrors.NewInvalid(GroupVersion.WithKind("UCloudMachine").GroupKind(), r.Name, field.ErrorList{
 			field.Forbidden(field.NewPath("spec"), "cannot be modified"),
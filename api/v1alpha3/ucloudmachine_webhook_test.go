@@ -0,0 +1,158 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestUCloudMachineValidateDisks(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    UCloudMachineSpec
+		wantErr bool
+	}{
+		{name: "legacy sizes", spec: UCloudMachineSpec{RootDiskSize: 40, DataDiskSize: 100}},
+		{name: "boot disk only", spec: UCloudMachineSpec{Disks: []DiskSpec{{IsBoot: true, Type: "CLOUD_RSSD"}}}},
+		{name: "local disks", spec: UCloudMachineSpec{Disks: []DiskSpec{
+			{IsBoot: true, Type: "LOCAL_NORMAL", Size: 40},
+			{Type: "LOCAL_NORMAL", Size: 100},
+		}}},
+		{name: "data disk from snapshot", spec: UCloudMachineSpec{Disks: []DiskSpec{{SnapshotId: "snap-1"}}}},
+		{name: "two boot disks", spec: UCloudMachineSpec{Disks: []DiskSpec{{IsBoot: true}, {IsBoot: true}}}, wantErr: true},
+		{name: "data disk without size", spec: UCloudMachineSpec{Disks: []DiskSpec{{Type: "CLOUD_SSD"}}}, wantErr: true},
+		{name: "size not a multiple of 10", spec: UCloudMachineSpec{Disks: []DiskSpec{{Size: 25}}}, wantErr: true},
+		{name: "encrypted local disk", spec: UCloudMachineSpec{Disks: []DiskSpec{
+			{IsBoot: true, Type: "LOCAL_NORMAL"},
+			{Type: "LOCAL_NORMAL", Size: 100, KmsKeyId: "kms-1"},
+		}}, wantErr: true},
+		{name: "local data disk with cloud boot disk", spec: UCloudMachineSpec{Disks: []DiskSpec{{Type: "LOCAL_SSD", Size: 100}}}, wantErr: true},
+		{name: "disks with data disk size", spec: UCloudMachineSpec{DataDiskSize: 100, Disks: []DiskSpec{{Size: 100}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			machine := &UCloudMachine{Spec: tt.spec}
+			template := &UCloudMachineTemplate{Spec: UCloudMachineTemplateSpec{
+				Template: UCloudMachineTemplateResource{Spec: tt.spec},
+			}}
+			if tt.wantErr {
+				g.Expect(machine.ValidateCreate()).NotTo(Succeed())
+				g.Expect(template.ValidateCreate()).NotTo(Succeed())
+			} else {
+				g.Expect(machine.ValidateCreate()).To(Succeed())
+				g.Expect(template.ValidateCreate()).To(Succeed())
+			}
+		})
+	}
+}
+
+func TestUCloudMachineValidateInstanceKind(t *testing.T) {
+	phost := &PHostSpec{Type: "db-2"}
+	tests := []struct {
+		name    string
+		spec    UCloudMachineSpec
+		wantErr bool
+	}{
+		{name: "uhost by default"},
+		{name: "uphost", spec: UCloudMachineSpec{InstanceKind: "uphost", PHost: phost, ImageId: pointer.StringPtr("pimg-1")}},
+		{name: "uphost paid by the hour", spec: UCloudMachineSpec{InstanceKind: "uphost", PHost: phost, ImageId: pointer.StringPtr("pimg-1"), ChargeSpec: ChargeSpec{ChargeType: "Dynamic"}}},
+		{name: "uphost without pHost", spec: UCloudMachineSpec{InstanceKind: "uphost", ImageId: pointer.StringPtr("pimg-1")}, wantErr: true},
+		{name: "uphost without image", spec: UCloudMachineSpec{InstanceKind: "uphost", PHost: phost}, wantErr: true},
+		{name: "uphost with disks", spec: UCloudMachineSpec{InstanceKind: "uphost", PHost: phost, ImageId: pointer.StringPtr("pimg-1"), Disks: []DiskSpec{{Size: 100}}}, wantErr: true},
+		{name: "uphost postpay", spec: UCloudMachineSpec{InstanceKind: "uphost", PHost: phost, ImageId: pointer.StringPtr("pimg-1"), ChargeSpec: ChargeSpec{ChargeType: "Postpay"}}, wantErr: true},
+		{name: "uhost with pHost", spec: UCloudMachineSpec{PHost: phost}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			machine := &UCloudMachine{Spec: tt.spec}
+			template := &UCloudMachineTemplate{Spec: UCloudMachineTemplateSpec{
+				Template: UCloudMachineTemplateResource{Spec: tt.spec},
+			}}
+			if tt.wantErr {
+				g.Expect(machine.ValidateCreate()).NotTo(Succeed())
+				g.Expect(template.ValidateCreate()).NotTo(Succeed())
+			} else {
+				g.Expect(machine.ValidateCreate()).To(Succeed())
+				g.Expect(template.ValidateCreate()).To(Succeed())
+			}
+		})
+	}
+}
+
+func TestUCloudMachineValidatePublicIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    UCloudMachineSpec
+		wantErr bool
+	}{
+		{name: "default eip", spec: UCloudMachineSpec{PublicIP: pointer.BoolPtr(true)}},
+		{name: "yearly eip", spec: UCloudMachineSpec{PublicIP: pointer.BoolPtr(true), EIP: &EIPSpec{ChargeSpec: ChargeSpec{ChargeType: "Year"}}}},
+		{name: "eip without public ip", spec: UCloudMachineSpec{EIP: &EIPSpec{Bandwidth: 10}}, wantErr: true},
+		{name: "postpay eip", spec: UCloudMachineSpec{PublicIP: pointer.BoolPtr(true), EIP: &EIPSpec{ChargeSpec: ChargeSpec{ChargeType: "Postpay"}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			machine := &UCloudMachine{Spec: tt.spec}
+			if tt.wantErr {
+				g.Expect(machine.ValidateCreate()).NotTo(Succeed())
+			} else {
+				g.Expect(machine.ValidateCreate()).To(Succeed())
+			}
+		})
+	}
+}
+
+func TestUCloudMachineValidateLogin(t *testing.T) {
+	secretRef := &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "machine"}, Key: "password"}
+	tests := []struct {
+		name    string
+		spec    UCloudMachineSpec
+		wantErr bool
+	}{
+		{name: "generated password"},
+		{name: "public key", spec: UCloudMachineSpec{SSHKey: &SSHKeySpec{PublicKey: "ssh-rsa AAAA"}}},
+		{name: "key pair", spec: UCloudMachineSpec{SSHKey: &SSHKeySpec{KeyPairId: "uk-1"}}},
+		{name: "empty ssh key", spec: UCloudMachineSpec{SSHKey: &SSHKeySpec{}}, wantErr: true},
+		{name: "public key and key pair", spec: UCloudMachineSpec{SSHKey: &SSHKeySpec{PublicKey: "ssh-rsa AAAA", KeyPairId: "uk-1"}}, wantErr: true},
+		{name: "password", spec: UCloudMachineSpec{SSHPassword: "cGFzc3dvcmQ="}},
+		{name: "password secret", spec: UCloudMachineSpec{SSHPasswordSecretRef: secretRef}},
+		{name: "password and password secret", spec: UCloudMachineSpec{SSHPassword: "cGFzc3dvcmQ=", SSHPasswordSecretRef: secretRef}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			machine := &UCloudMachine{Spec: tt.spec}
+			template := &UCloudMachineTemplate{Spec: UCloudMachineTemplateSpec{
+				Template: UCloudMachineTemplateResource{Spec: tt.spec},
+			}}
+			if tt.wantErr {
+				g.Expect(machine.ValidateCreate()).NotTo(Succeed())
+				g.Expect(template.ValidateCreate()).NotTo(Succeed())
+			} else {
+				g.Expect(machine.ValidateCreate()).To(Succeed())
+				g.Expect(template.ValidateCreate()).To(Succeed())
+			}
+		})
+	}
+}
@@ -20,23 +20,42 @@ import (
 	"errors"
 	"reflect"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
-func (r *UCloudMachineTemplate) SetupWebhookWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewWebhookManagedBy(mgr).
-		For(r).
-		Complete()
+// SetupWebhookWithManager registers the validating webhook of UCloudMachineTemplates.
+// Instance types are validated against instanceTypes if it is not nil.
+func (r *UCloudMachineTemplate) SetupWebhookWithManager(mgr ctrl.Manager, instanceTypes InstanceTypeValidator) error {
+	mgr.GetWebhookServer().Register("/validate-infrastructure-cluster-x-k8s-io-v1alpha3-ucloudmachinetemplate", newValidatingWebhook(r, instanceTypes))
+	return nil
 }
 
 // +kubebuilder:webhook:verbs=create;update,path=/validate-infrastructure-cluster-x-k8s-io-v1alpha3-ucloudmachinetemplate,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=ucloudmachinetemplates,versions=v1alpha3,name=validation.ucloudmachinetemplate.infrastructure.x-k8s.io
 
 var _ webhook.Validator = &UCloudMachineTemplate{}
 
-// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+// ValidateCreate implements webhook.Validator, it doesn't validate the instance type.
 func (r *UCloudMachineTemplate) ValidateCreate() error {
+	return r.validateCreate(nil)
+}
+
+// validateCreate validates a new machine template, its instance type is validated
+// against instanceTypes if it is not nil.
+func (r *UCloudMachineTemplate) validateCreate(instanceTypes InstanceTypeValidator) error {
+	path := field.NewPath("spec", "template", "spec")
+	errs := validateLogin(path, &r.Spec.Template.Spec)
+	errs = append(errs, validateInstanceType(path, &r.Spec.Template.Spec, instanceTypes)...)
+	errs = append(errs, validateDisks(path, &r.Spec.Template.Spec)...)
+	errs = append(errs, validateImageLookup(path, &r.Spec.Template.Spec)...)
+	errs = append(errs, validateInstanceKind(path, &r.Spec.Template.Spec)...)
+	errs = append(errs, validatePublicIP(path, &r.Spec.Template.Spec)...)
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("UCloudMachineTemplate").GroupKind(), r.Name, errs)
+	}
 	return nil
 }
 
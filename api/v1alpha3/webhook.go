@@ -0,0 +1,95 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"context"
+	"net/http"
+
+	"k8s.io/api/admission/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// InstanceTypeValidator returns an error if an instance type is unknown.
+// +kubebuilder:object:generate=false
+type InstanceTypeValidator interface {
+	Validate(name string) error
+}
+
+// instanceTypeValidator is implemented by the types whose instance type is validated
+// when they are created.
+// +kubebuilder:object:generate=false
+type instanceTypeValidator interface {
+	admission.Validator
+	validateCreate(instanceTypes InstanceTypeValidator) error
+}
+
+// validatingHandler validates objects like the handler controller-runtime registers
+// for an admission.Validator, except that instance types are validated against
+// instanceTypes on create. Instance types are not validated if it is nil.
+type validatingHandler struct {
+	validator     instanceTypeValidator
+	instanceTypes InstanceTypeValidator
+	decoder       *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &validatingHandler{}
+
+// newValidatingWebhook returns the validating webhook of the type of validator.
+func newValidatingWebhook(validator instanceTypeValidator, instanceTypes InstanceTypeValidator) *admission.Webhook {
+	return &admission.Webhook{
+		Handler: &validatingHandler{validator: validator, instanceTypes: instanceTypes},
+	}
+}
+
+// InjectDecoder injects the decoder into a validatingHandler.
+func (h *validatingHandler) InjectDecoder(d *admission.Decoder) error {
+	h.decoder = d
+	return nil
+}
+
+// Handle handles admission requests.
+func (h *validatingHandler) Handle(_ context.Context, req admission.Request) admission.Response {
+	obj := h.validator.DeepCopyObject().(instanceTypeValidator)
+	var err error
+	switch req.Operation {
+	case v1beta1.Create:
+		if err := h.decoder.Decode(req, obj); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		err = obj.validateCreate(h.instanceTypes)
+	case v1beta1.Update:
+		oldObj := obj.DeepCopyObject()
+		if err := h.decoder.DecodeRaw(req.Object, obj); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if err := h.decoder.DecodeRaw(req.OldObject, oldObj); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		err = obj.ValidateUpdate(oldObj)
+	case v1beta1.Delete:
+		// OldObject contains the object being deleted
+		if err := h.decoder.DecodeRaw(req.OldObject, obj); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		err = obj.ValidateDelete()
+	}
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+	return admission.Allowed("")
+}
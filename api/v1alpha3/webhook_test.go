@@ -0,0 +1,87 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// fakeInstanceTypes knows the instance types in the map.
+type fakeInstanceTypes map[string]bool
+
+func (f fakeInstanceTypes) Validate(name string) error {
+	if !f[name] {
+		return errors.Errorf("unknown instance type %q", name)
+	}
+	return nil
+}
+
+func TestValidatingHandler(t *testing.T) {
+	g := NewWithT(t)
+	scheme := runtime.NewScheme()
+	g.Expect(AddToScheme(scheme)).To(Succeed())
+	decoder, err := admission.NewDecoder(scheme)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	request := func(obj runtime.Object) admission.Request {
+		raw, err := json.Marshal(obj)
+		g.Expect(err).NotTo(HaveOccurred())
+		return admission.Request{AdmissionRequest: v1beta1.AdmissionRequest{
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		}}
+	}
+	handle := func(validator instanceTypeValidator, instanceTypes InstanceTypeValidator, obj runtime.Object) bool {
+		webhook := newValidatingWebhook(validator, instanceTypes)
+		_, err := admission.InjectDecoderInto(decoder, webhook.Handler)
+		g.Expect(err).NotTo(HaveOccurred())
+		return webhook.Handle(context.Background(), request(obj)).Allowed
+	}
+
+	instanceTypes := fakeInstanceTypes{"n-standard-2": true}
+	for _, tt := range []struct {
+		instanceType  string
+		instanceTypes InstanceTypeValidator
+		allowed       bool
+	}{
+		{"n-standard-2", instanceTypes, true},
+		{"n-unknown-2", instanceTypes, false},
+		{"", instanceTypes, true},
+		// instance types are not validated without a catalog
+		{"n-unknown-2", nil, true},
+	} {
+		spec := UCloudMachineSpec{InstanceType: tt.instanceType}
+		machine := &UCloudMachine{Spec: spec}
+		template := &UCloudMachineTemplate{Spec: UCloudMachineTemplateSpec{
+			Template: UCloudMachineTemplateResource{Spec: spec},
+		}}
+		g.Expect(handle(&UCloudMachine{}, tt.instanceTypes, machine)).To(Equal(tt.allowed), "machine %q", tt.instanceType)
+		g.Expect(handle(&UCloudMachineTemplate{}, tt.instanceTypes, template)).To(Equal(tt.allowed), "template %q", tt.instanceType)
+	}
+
+	// the other validations still apply
+	invalid := &UCloudMachine{Spec: UCloudMachineSpec{Disks: []DiskSpec{{Size: 25}}}}
+	g.Expect(handle(&UCloudMachine{}, instanceTypes, invalid)).To(BeFalse())
+}
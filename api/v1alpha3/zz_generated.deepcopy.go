@@ -21,14 +21,77 @@ limitations under the License.
 package v1alpha3
 
 import (
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	apiv1alpha3 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/errors"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *APISpec) DeepCopyInto(out *APISpec) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new APISpec.
+func (in *APISpec) DeepCopy() *APISpec {
+	if in == nil {
+		return nil
+	}
+	out := new(APISpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AllowedNamespaces) DeepCopyInto(out *AllowedNamespaces) {
+	*out = *in
+	if in.NamespaceList != nil {
+		in, out := &in.NamespaceList, &out.NamespaceList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AllowedNamespaces.
+func (in *AllowedNamespaces) DeepCopy() *AllowedNamespaces {
+	if in == nil {
+		return nil
+	}
+	out := new(AllowedNamespaces)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BastionSpec) DeepCopyInto(out *BastionSpec) {
 	*out = *in
+	if in.SSHPasswordSecretRef != nil {
+		in, out := &in.SSHPasswordSecretRef, &out.SSHPasswordSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SSHKey != nil {
+		in, out := &in.SSHKey, &out.SSHKey
+		*out = new(SSHKeySpec)
+		**out = **in
+	}
+	if in.ImageLookup != nil {
+		in, out := &in.ImageLookup, &out.ImageLookup
+		*out = new(ImageLookupSpec)
+		**out = **in
+	}
+	out.ChargeSpec = in.ChargeSpec
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BastionSpec.
@@ -41,9 +104,59 @@ func (in *BastionSpec) DeepCopy() *BastionSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChargeSpec) DeepCopyInto(out *ChargeSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChargeSpec.
+func (in *ChargeSpec) DeepCopy() *ChargeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ChargeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Condition) DeepCopyInto(out *Condition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Condition.
+func (in *Condition) DeepCopy() *Condition {
+	if in == nil {
+		return nil
+	}
+	out := new(Condition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskSpec) DeepCopyInto(out *DiskSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskSpec.
+func (in *DiskSpec) DeepCopy() *DiskSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EIP) DeepCopyInto(out *EIP) {
 	*out = *in
+	if in.ExpireTime != nil {
+		in, out := &in.ExpireTime, &out.ExpireTime
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EIP.
@@ -59,6 +172,7 @@ func (in *EIP) DeepCopy() *EIP {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EIPSpec) DeepCopyInto(out *EIPSpec) {
 	*out = *in
+	out.ChargeSpec = in.ChargeSpec
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EIPSpec.
@@ -94,6 +208,11 @@ func (in *Filter) DeepCopy() *Filter {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Firewall) DeepCopyInto(out *Firewall) {
 	*out = *in
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Firewall.
@@ -162,9 +281,28 @@ func (in *Group) DeepCopy() *Group {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageLookupSpec) DeepCopyInto(out *ImageLookupSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageLookupSpec.
+func (in *ImageLookupSpec) DeepCopy() *ImageLookupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageLookupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Instance) DeepCopyInto(out *Instance) {
 	*out = *in
+	if in.ExpireTime != nil {
+		in, out := &in.ExpireTime, &out.ExpireTime
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Instance.
@@ -177,12 +315,33 @@ func (in *Instance) DeepCopy() *Instance {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineFirewallSpec) DeepCopyInto(out *MachineFirewallSpec) {
+	*out = *in
+	in.FirewallSpec.DeepCopyInto(&out.FirewallSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineFirewallSpec.
+func (in *MachineFirewallSpec) DeepCopy() *MachineFirewallSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineFirewallSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Nat) DeepCopyInto(out *Nat) {
 	*out = *in
-	out.EIP = in.EIP
-	out.Firewall = in.Firewall
+	in.EIP.DeepCopyInto(&out.EIP)
+	in.Firewall.DeepCopyInto(&out.Firewall)
 	in.SnatTableIds.DeepCopyInto(&out.SnatTableIds)
+	if in.SubnetIds != nil {
+		in, out := &in.SubnetIds, &out.SubnetIds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Nat.
@@ -230,11 +389,23 @@ func (in *NatSpec) DeepCopy() *NatSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Network) DeepCopyInto(out *Network) {
 	*out = *in
-	out.VPC = in.VPC
+	in.VPC.DeepCopyInto(&out.VPC)
 	out.Subnet = in.Subnet
-	out.ULB = in.ULB
+	if in.Subnets != nil {
+		in, out := &in.Subnets, &out.Subnets
+		*out = make([]Subnet, len(*in))
+		copy(*out, *in)
+	}
+	in.ULB.DeepCopyInto(&out.ULB)
 	in.Nat.DeepCopyInto(&out.Nat)
-	out.Firewall = in.Firewall
+	in.Firewall.DeepCopyInto(&out.Firewall)
+	if in.MachineFirewalls != nil {
+		in, out := &in.MachineFirewalls, &out.MachineFirewalls
+		*out = make(map[string]Firewall, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Network.
@@ -252,9 +423,21 @@ func (in *NetworkSpec) DeepCopyInto(out *NetworkSpec) {
 	*out = *in
 	out.VPC = in.VPC
 	out.Subnet = in.Subnet
+	if in.Subnets != nil {
+		in, out := &in.Subnets, &out.Subnets
+		*out = make([]SubnetSpec, len(*in))
+		copy(*out, *in)
+	}
 	out.Nat = in.Nat
 	out.ULB = in.ULB
 	in.Firewall.DeepCopyInto(&out.Firewall)
+	if in.MachineFirewalls != nil {
+		in, out := &in.MachineFirewalls, &out.MachineFirewalls
+		*out = make([]MachineFirewallSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkSpec.
@@ -267,6 +450,56 @@ func (in *NetworkSpec) DeepCopy() *NetworkSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeCredentialSpec) DeepCopyInto(out *NodeCredentialSpec) {
+	*out = *in
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeCredentialSpec.
+func (in *NodeCredentialSpec) DeepCopy() *NodeCredentialSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeCredentialSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PHostSpec) DeepCopyInto(out *PHostSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PHostSpec.
+func (in *PHostSpec) DeepCopy() *PHostSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PHostSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SSHKeySpec) DeepCopyInto(out *SSHKeySpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SSHKeySpec.
+func (in *SSHKeySpec) DeepCopy() *SSHKeySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SSHKeySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SnatTableIdsInDescribeNatGateways) DeepCopyInto(out *SnatTableIdsInDescribeNatGateways) {
 	*out = *in
@@ -344,6 +577,100 @@ func (in *UCloudCluster) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UCloudClusterIdentity) DeepCopyInto(out *UCloudClusterIdentity) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UCloudClusterIdentity.
+func (in *UCloudClusterIdentity) DeepCopy() *UCloudClusterIdentity {
+	if in == nil {
+		return nil
+	}
+	out := new(UCloudClusterIdentity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *UCloudClusterIdentity) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UCloudClusterIdentityList) DeepCopyInto(out *UCloudClusterIdentityList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]UCloudClusterIdentity, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UCloudClusterIdentityList.
+func (in *UCloudClusterIdentityList) DeepCopy() *UCloudClusterIdentityList {
+	if in == nil {
+		return nil
+	}
+	out := new(UCloudClusterIdentityList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *UCloudClusterIdentityList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UCloudClusterIdentityReference) DeepCopyInto(out *UCloudClusterIdentityReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UCloudClusterIdentityReference.
+func (in *UCloudClusterIdentityReference) DeepCopy() *UCloudClusterIdentityReference {
+	if in == nil {
+		return nil
+	}
+	out := new(UCloudClusterIdentityReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UCloudClusterIdentitySpec) DeepCopyInto(out *UCloudClusterIdentitySpec) {
+	*out = *in
+	out.SecretRef = in.SecretRef
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = new(AllowedNamespaces)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UCloudClusterIdentitySpec.
+func (in *UCloudClusterIdentitySpec) DeepCopy() *UCloudClusterIdentitySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(UCloudClusterIdentitySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UCloudClusterList) DeepCopyInto(out *UCloudClusterList) {
 	*out = *in
@@ -381,7 +708,18 @@ func (in *UCloudClusterSpec) DeepCopyInto(out *UCloudClusterSpec) {
 	*out = *in
 	out.ControlPlaneEndpoint = in.ControlPlaneEndpoint
 	in.Network.DeepCopyInto(&out.Network)
-	out.Bastion = in.Bastion
+	in.Bastion.DeepCopyInto(&out.Bastion)
+	if in.IdentityRef != nil {
+		in, out := &in.IdentityRef, &out.IdentityRef
+		*out = new(UCloudClusterIdentityReference)
+		**out = **in
+	}
+	in.NodeCredential.DeepCopyInto(&out.NodeCredential)
+	if in.API != nil {
+		in, out := &in.API, &out.API
+		*out = new(APISpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UCloudClusterSpec.
@@ -408,9 +746,16 @@ func (in *UCloudClusterStatus) DeepCopyInto(out *UCloudClusterStatus) {
 	if in.Bastion != nil {
 		in, out := &in.Bastion, &out.Bastion
 		*out = new(Instance)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	out.Group = in.Group
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UCloudClusterStatus.
@@ -485,6 +830,26 @@ func (in *UCloudMachineList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UCloudMachineSpec) DeepCopyInto(out *UCloudMachineSpec) {
 	*out = *in
+	if in.PHost != nil {
+		in, out := &in.PHost, &out.PHost
+		*out = new(PHostSpec)
+		**out = **in
+	}
+	if in.Disks != nil {
+		in, out := &in.Disks, &out.Disks
+		*out = make([]DiskSpec, len(*in))
+		copy(*out, *in)
+	}
+	if in.SSHPasswordSecretRef != nil {
+		in, out := &in.SSHPasswordSecretRef, &out.SSHPasswordSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SSHKey != nil {
+		in, out := &in.SSHKey, &out.SSHKey
+		*out = new(SSHKeySpec)
+		**out = **in
+	}
 	if in.ProviderID != nil {
 		in, out := &in.ProviderID, &out.ProviderID
 		*out = new(string)
@@ -495,16 +860,27 @@ func (in *UCloudMachineSpec) DeepCopyInto(out *UCloudMachineSpec) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.ImageLookup != nil {
+		in, out := &in.ImageLookup, &out.ImageLookup
+		*out = new(ImageLookupSpec)
+		**out = **in
+	}
 	if in.PublicIP != nil {
 		in, out := &in.PublicIP, &out.PublicIP
 		*out = new(bool)
 		**out = **in
 	}
+	if in.EIP != nil {
+		in, out := &in.EIP, &out.EIP
+		*out = new(EIPSpec)
+		**out = **in
+	}
 	if in.AdditionalNetworkTags != nil {
 		in, out := &in.AdditionalNetworkTags, &out.AdditionalNetworkTags
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	out.ChargeSpec = in.ChargeSpec
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UCloudMachineSpec.
@@ -520,6 +896,19 @@ func (in *UCloudMachineSpec) DeepCopy() *UCloudMachineSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UCloudMachineStatus) DeepCopyInto(out *UCloudMachineStatus) {
 	*out = *in
+	if in.CreateTime != nil {
+		in, out := &in.CreateTime, &out.CreateTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ExpireTime != nil {
+		in, out := &in.ExpireTime, &out.ExpireTime
+		*out = (*in).DeepCopy()
+	}
+	if in.EIP != nil {
+		in, out := &in.EIP, &out.EIP
+		*out = new(EIP)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Addresses != nil {
 		in, out := &in.Addresses, &out.Addresses
 		*out = make([]apiv1alpha3.MachineAddress, len(*in))
@@ -640,7 +1029,11 @@ func (in *UCloudMachineTemplateSpec) DeepCopy() *UCloudMachineTemplateSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ULB) DeepCopyInto(out *ULB) {
 	*out = *in
-	out.EIP = in.EIP
+	in.EIP.DeepCopyInto(&out.EIP)
+	if in.ExpireTime != nil {
+		in, out := &in.ExpireTime, &out.ExpireTime
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ULB.
@@ -653,10 +1046,43 @@ func (in *ULB) DeepCopy() *ULB {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ULBHealthCheckSpec) DeepCopyInto(out *ULBHealthCheckSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ULBHealthCheckSpec.
+func (in *ULBHealthCheckSpec) DeepCopy() *ULBHealthCheckSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ULBHealthCheckSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ULBListenerSpec) DeepCopyInto(out *ULBListenerSpec) {
+	*out = *in
+	out.HealthCheck = in.HealthCheck
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ULBListenerSpec.
+func (in *ULBListenerSpec) DeepCopy() *ULBListenerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ULBListenerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ULBSpec) DeepCopyInto(out *ULBSpec) {
 	*out = *in
+	out.Listener = in.Listener
 	out.EIP = in.EIP
+	out.ChargeSpec = in.ChargeSpec
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ULBSpec.
@@ -672,6 +1098,11 @@ func (in *ULBSpec) DeepCopy() *ULBSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VPC) DeepCopyInto(out *VPC) {
 	*out = *in
+	if in.CidrBlocks != nil {
+		in, out := &in.CidrBlocks, &out.CidrBlocks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VPC.
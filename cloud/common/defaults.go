@@ -161,6 +161,11 @@ func GenerateNodeSubnetName(clusterName string) string {
 	return fmt.Sprintf("%s-%s", clusterName, "node-subnet")
 }
 
+// GenerateBastionSubnetName generates a bastion subnet name, based on the cluster name.
+func GenerateBastionSubnetName(clusterName string) string {
+	return fmt.Sprintf("%s-%s", clusterName, "bastion-subnet")
+}
+
 // GenerateInternalLBName generates a internal load balancer name, based on the cluster name.
 func GenerateInternalLBName(clusterName string) string {
 	return fmt.Sprintf("%s-%s", clusterName, "internal-lb")
@@ -16,14 +16,142 @@ limitations under the License.
 
 package common
 
-// import (
-// 	"github.com/Azure/go-autorest/autorest"
-// )
-
-// // ResourceNotFound parses the error to check if it's a resource not found
-// func ResourceNotFound(err error) bool {
-// 	if derr, ok := err.(autorest.DetailedError); ok && derr.StatusCode == 404 {
-// 		return true
-// 	}
-// 	return false
-// }
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	uerr "github.com/ucloud/ucloud-sdk-go/ucloud/error"
+)
+
+// ErrorReason classifies the errors returned by the UCloud API.
+type ErrorReason string
+
+const (
+	// ReasonNotFound means the resource does not exist (any more).
+	ReasonNotFound ErrorReason = "NotFound"
+	// ReasonQuotaExceeded means the quota of the account or project is exhausted.
+	ReasonQuotaExceeded ErrorReason = "QuotaExceeded"
+	// ReasonThrottled means the request was rejected by rate limiting.
+	ReasonThrottled ErrorReason = "Throttled"
+	// ReasonInsufficientBalance means the account cannot pay for the resource.
+	ReasonInsufficientBalance ErrorReason = "InsufficientBalance"
+	// ReasonInvalidParameter means the request was rejected because of its parameters.
+	ReasonInvalidParameter ErrorReason = "InvalidParameter"
+)
+
+// UCloud retcodes with a known meaning.
+const (
+	RetCodeInvalidParameter = 230
+	RetCodeUHostNotFound    = 8039
+	RetCodeNATGWNotFound    = 54002
+	RetCodeVPCNotFound      = 58103
+	RetCodeULBNotFound      = 63059
+)
+
+// retCodeReasons maps UCloud retcodes to the reason they stand for.
+var retCodeReasons = map[int]ErrorReason{
+	RetCodeInvalidParameter: ReasonInvalidParameter,
+	RetCodeUHostNotFound:    ReasonNotFound,
+	RetCodeNATGWNotFound:    ReasonNotFound,
+	RetCodeVPCNotFound:      ReasonNotFound,
+	RetCodeULBNotFound:      ReasonNotFound,
+}
+
+// messageReasons classifies retcodes missing from retCodeReasons by their message,
+// since each UCloud product uses its own retcodes for the same condition. It never
+// yields ReasonNotFound or ReasonInvalidParameter: a missing resource is taken as
+// deleted and a rejected parameter fails machines for good, so a message such as
+// "project not exist" must not be mistaken for them. Only the retcodes of
+// retCodeReasons do.
+var messageReasons = []struct {
+	substr string
+	reason ErrorReason
+}{
+	{"quota", ReasonQuotaExceeded},
+	{"balance", ReasonInsufficientBalance},
+	{"frequency", ReasonThrottled},
+	{"too many requests", ReasonThrottled},
+}
+
+// Error is a UCloud API error with a known reason.
+type Error struct {
+	Reason  ErrorReason
+	Code    int
+	Message string
+
+	err error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: retcode %d: %s", e.Reason, e.Code, e.Message)
+}
+
+// Cause returns the underlying sdk error.
+func (e *Error) Cause() error {
+	return e.err
+}
+
+// Classify returns a typed *Error for UCloud API errors with a known reason,
+// and err unchanged otherwise.
+func Classify(err error) error {
+	cause := errors.Cause(err)
+	if e, ok := cause.(*Error); ok {
+		return e
+	}
+	serverErr, ok := cause.(uerr.ServerError)
+	if !ok {
+		return err
+	}
+
+	reason, ok := retCodeReasons[serverErr.Code()]
+	if !ok && serverErr.StatusCode() == 429 {
+		reason, ok = ReasonThrottled, true
+	}
+	if !ok && serverErr.Code() > 0 {
+		message := strings.ToLower(serverErr.Message())
+		for _, m := range messageReasons {
+			if strings.Contains(message, m.substr) {
+				reason, ok = m.reason, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return err
+	}
+	return &Error{Reason: reason, Code: serverErr.Code(), Message: serverErr.Message(), err: serverErr}
+}
+
+// ReasonForError returns the reason of a UCloud API error, or an empty string if it is unknown.
+func ReasonForError(err error) ErrorReason {
+	if e, ok := Classify(err).(*Error); ok {
+		return e.Reason
+	}
+	return ""
+}
+
+// IsNotFound returns true if err means the resource does not exist.
+func IsNotFound(err error) bool {
+	return ReasonForError(err) == ReasonNotFound
+}
+
+// IsQuotaExceeded returns true if err means a quota is exhausted.
+func IsQuotaExceeded(err error) bool {
+	return ReasonForError(err) == ReasonQuotaExceeded
+}
+
+// IsThrottled returns true if err means the request was rate limited.
+func IsThrottled(err error) bool {
+	return ReasonForError(err) == ReasonThrottled
+}
+
+// IsInsufficientBalance returns true if err means the account balance is insufficient.
+func IsInsufficientBalance(err error) bool {
+	return ReasonForError(err) == ReasonInsufficientBalance
+}
+
+// IsInvalidParameter returns true if err means the request parameters were rejected.
+func IsInvalidParameter(err error) bool {
+	return ReasonForError(err) == ReasonInvalidParameter
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	uerr "github.com/ucloud/ucloud-sdk-go/ucloud/error"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorReason
+	}{
+		{"invalid parameter retcode", uerr.NewServerCodeError(RetCodeInvalidParameter, "Params [ImageId] not available"), ReasonInvalidParameter},
+		{"uhost not found retcode", uerr.NewServerCodeError(RetCodeUHostNotFound, "UHost not found"), ReasonNotFound},
+		{"nat gateway not found retcode", uerr.NewServerCodeError(RetCodeNATGWNotFound, "natgw missing"), ReasonNotFound},
+		{"vpc not found retcode", uerr.NewServerCodeError(RetCodeVPCNotFound, "vpc missing"), ReasonNotFound},
+		{"ulb not found retcode", uerr.NewServerCodeError(RetCodeULBNotFound, "ulb missing"), ReasonNotFound},
+		{"rate limited status", uerr.NewServerStatusError(429, "429 Too Many Requests"), ReasonThrottled},
+		{"quota message", uerr.NewServerCodeError(8300, "Quota not enough"), ReasonQuotaExceeded},
+		{"balance message", uerr.NewServerCodeError(8301, "Account balance not enough"), ReasonInsufficientBalance},
+		{"frequency message", uerr.NewServerCodeError(8302, "Request frequency exceeded"), ReasonThrottled},
+		{"too many requests message", uerr.NewServerCodeError(8303, "Too many requests"), ReasonThrottled},
+		{"wrapped", errors.Wrap(uerr.NewServerCodeError(RetCodeVPCNotFound, "vpc missing"), "describe vpc failed"), ReasonNotFound},
+		{"params error message", uerr.NewServerCodeError(8304, "Params error: ChargeType"), ""},
+		{"not exist message", uerr.NewServerCodeError(8012, "Project not exist"), ""},
+		{"not found message", uerr.NewServerCodeError(8013, "Image Not Found"), ""},
+		{"unknown message", uerr.NewServerCodeError(8305, "Internal error"), ""},
+		{"message without retcode", uerr.NewServerStatusError(503, "not found"), ""},
+		{"not a server error", errors.New("resource not found"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			classified := Classify(tt.err)
+			g.Expect(ReasonForError(tt.err)).To(Equal(tt.want))
+			if tt.want == "" {
+				g.Expect(classified).To(Equal(tt.err))
+				return
+			}
+			e, ok := classified.(*Error)
+			g.Expect(ok).To(BeTrue())
+			g.Expect(e.Reason).To(Equal(tt.want))
+			g.Expect(e.Cause()).To(Equal(errors.Cause(tt.err)))
+			// a classified error is classified the same again
+			g.Expect(Classify(errors.Wrap(e, "wrapped"))).To(Equal(e))
+		})
+	}
+}
+
+func TestErrorReasonHelpers(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(IsNotFound(uerr.NewServerCodeError(RetCodeUHostNotFound, ""))).To(BeTrue())
+	g.Expect(IsQuotaExceeded(uerr.NewServerCodeError(8300, "quota not enough"))).To(BeTrue())
+	g.Expect(IsThrottled(uerr.NewServerStatusError(429, ""))).To(BeTrue())
+	g.Expect(IsInsufficientBalance(uerr.NewServerCodeError(8301, "balance not enough"))).To(BeTrue())
+	g.Expect(IsInvalidParameter(uerr.NewServerCodeError(RetCodeInvalidParameter, ""))).To(BeTrue())
+	g.Expect(IsInvalidParameter(uerr.NewServerCodeError(8304, "params error"))).To(BeFalse())
+	g.Expect(IsNotFound(errors.New("boom"))).To(BeFalse())
+	g.Expect(Classify(nil)).To(BeNil())
+	g.Expect(ReasonForError(nil)).To(BeEmpty())
+}
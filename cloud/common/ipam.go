@@ -0,0 +1,79 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"encoding/binary"
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultSubnetPrefixLength is the prefix length of the subnets allocated when neither
+// a CIDR block nor a prefix length is given.
+const DefaultSubnetPrefixLength = 24
+
+// AllocateCIDR returns the first CIDR block with the prefix length inside the IPv4 CIDR
+// block within that doesn't overlap any of the used CIDR blocks. Used CIDR blocks that
+// are not valid IPv4 CIDR blocks are ignored.
+func AllocateCIDR(within string, prefixLength int, used []string) (string, error) {
+	_, network, err := net.ParseCIDR(within)
+	if err != nil || network.IP.To4() == nil {
+		return "", errors.Errorf("%q is not an IPv4 CIDR block", within)
+	}
+	ones, _ := network.Mask.Size()
+	if prefixLength < ones || prefixLength > 32 {
+		return "", errors.Errorf("a /%d CIDR block does not fit in %s", prefixLength, within)
+	}
+
+	var usedRanges [][2]uint64
+	for _, cidr := range used {
+		_, usedNetwork, err := net.ParseCIDR(cidr)
+		if err != nil || usedNetwork.IP.To4() == nil {
+			continue
+		}
+		usedRanges = append(usedRanges, cidrRange(usedNetwork))
+	}
+
+	size := uint64(1) << uint(32-prefixLength)
+	bounds := cidrRange(network)
+	for start := bounds[0]; start+size <= bounds[1]; {
+		// the first block after all the used ranges overlapping this one
+		next := uint64(0)
+		for _, r := range usedRanges {
+			if r[0] < start+size && start < r[1] {
+				if after := (r[1] + size - 1) / size * size; after > next {
+					next = after
+				}
+			}
+		}
+		if next == 0 {
+			ip := make(net.IP, 4)
+			binary.BigEndian.PutUint32(ip, uint32(start))
+			return (&net.IPNet{IP: ip, Mask: net.CIDRMask(prefixLength, 32)}).String(), nil
+		}
+		start = next
+	}
+	return "", errors.Errorf("no free /%d CIDR block left in %s", prefixLength, within)
+}
+
+// cidrRange returns the first address of an IPv4 network and the first address after it.
+func cidrRange(network *net.IPNet) [2]uint64 {
+	ones, _ := network.Mask.Size()
+	first := uint64(binary.BigEndian.Uint32(network.IP.To4()))
+	return [2]uint64{first, first + uint64(1)<<uint(32-ones)}
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestAllocateCIDR(t *testing.T) {
+	tests := []struct {
+		name         string
+		within       string
+		prefixLength int
+		used         []string
+		want         string
+		wantErr      bool
+	}{
+		{name: "empty vpc", within: "10.0.0.0/16", prefixLength: 24, want: "10.0.0.0/24"},
+		{name: "after used subnets", within: "10.0.0.0/16", prefixLength: 24, used: []string{"10.0.0.0/24", "10.0.1.0/25"}, want: "10.0.2.0/24"},
+		{name: "in a gap", within: "10.0.0.0/16", prefixLength: 24, used: []string{"10.0.0.0/24", "10.0.2.0/24"}, want: "10.0.1.0/24"},
+		{name: "around a larger range", within: "10.0.0.0/8", prefixLength: 16, used: []string{"10.0.0.0/16", "10.1.0.0/16", "10.0.0.0/12"}, want: "10.16.0.0/16"},
+		{name: "inside a smaller range", within: "10.0.0.0/8", prefixLength: 12, used: []string{"10.0.3.0/24"}, want: "10.16.0.0/12"},
+		{name: "ranges outside are ignored", within: "10.0.0.0/16", prefixLength: 24, used: []string{"192.168.0.0/16", "not a cidr"}, want: "10.0.0.0/24"},
+		{name: "full", within: "10.0.0.0/24", prefixLength: 25, used: []string{"10.0.0.0/25", "10.0.0.128/26", "10.0.0.224/27"}, wantErr: true},
+		{name: "too large", within: "10.0.0.0/16", prefixLength: 8, wantErr: true},
+		{name: "invalid vpc", within: "", prefixLength: 24, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			got, err := AllocateCIDR(tt.within, tt.prefixLength, tt.used)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(got).To(Equal(tt.want))
+			}
+		})
+	}
+}
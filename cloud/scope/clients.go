@@ -17,26 +17,169 @@ limitations under the License.
 package scope
 
 import (
-	"os"
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
 
+	"github.com/pkg/errors"
 	"github.com/ucloud/ucloud-sdk-go/ucloud"
 	"github.com/ucloud/ucloud-sdk-go/ucloud/auth"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+)
+
+const (
+	// PublicKeyName is the environment variable, Secret key and file name holding the UCloud public key.
+	PublicKeyName = "UCLOUD_ACCESS_PUBKEY"
+	// PrivateKeyName is the environment variable, Secret key and file name holding the UCloud private key.
+	PrivateKeyName = "UCLOUD_ACCESS_PRIKEY"
 )
 
 // UCloudClients contains all the ucloud clients used by the scopes.
 type UCloudClients struct {
 	Config     *ucloud.Config
 	Credential *auth.Credential
+
+	// Transport, if set, is used to send API requests instead of http.DefaultTransport.
+	Transport http.RoundTripper
+
+	// CredentialProvider, if set, is consulted by CurrentCredential so that
+	// rotated keys are picked up without restarting the manager.
+	CredentialProvider CredentialProvider
 }
 
-func (c *UCloudClients) loadDefaultConfig() {
+// proxyTransports caches one transport per proxy URL so that connections are reused across reconciles.
+var proxyTransports sync.Map
+
+// loadConfig builds the ucloud config and HTTP transport from the API settings of a cluster.
+func (c *UCloudClients) loadConfig(spec *infrav1.APISpec) error {
 	cfg := ucloud.NewConfig()
 	c.Config = &cfg
+	if spec == nil {
+		return nil
+	}
+
+	if spec.BaseURL != "" {
+		if _, err := url.Parse(spec.BaseURL); err != nil {
+			return errors.Wrapf(err, "invalid API base URL %q", spec.BaseURL)
+		}
+		cfg.BaseUrl = spec.BaseURL
+	}
+	if spec.Timeout != nil {
+		cfg.Timeout = spec.Timeout.Duration
+	}
+	cfg.UserAgent = spec.UserAgent
+
+	if spec.ProxyURL != "" && c.Transport == nil {
+		proxyURL, err := url.Parse(spec.ProxyURL)
+		if err != nil {
+			return errors.Wrapf(err, "invalid API proxy URL %q", spec.ProxyURL)
+		}
+		transport, ok := proxyTransports.Load(proxyURL.String())
+		if !ok {
+			t := http.DefaultTransport.(*http.Transport).Clone()
+			t.Proxy = http.ProxyURL(proxyURL)
+			transport, _ = proxyTransports.LoadOrStore(proxyURL.String(), t)
+		}
+		c.Transport = transport.(http.RoundTripper)
+	}
+	return nil
+}
+
+// CurrentCredential refreshes Credential from the CredentialProvider, if any, and returns it.
+// The refreshed key pair is copied into the existing Credential so that ucloud clients
+// holding on to it use the current keys as well. The sdk rejects an expired credential
+// before the request handler refreshing it runs, so the copy never expires: the
+// CredentialProvider tracks the expiration and refreshes the key pair ahead of it.
+func (c *UCloudClients) CurrentCredential() (*auth.Credential, error) {
+	if c.CredentialProvider == nil {
+		if c.Credential == nil {
+			return nil, errors.New("no credential configured")
+		}
+		return c.Credential, nil
+	}
+
+	credential, err := c.CredentialProvider.Credential()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get credential")
+	}
+	credential.CanExpire = false
+	if c.Credential == nil {
+		c.Credential = credential
+	} else {
+		*c.Credential = *credential
+	}
+	return c.Credential, nil
 }
 
-func (c *UCloudClients) getCredentialFromEnv() {
+// identityCredentialProvider returns a provider for the key pair referenced by a
+// UCloudClusterIdentity, provided the identity allows being used from the given namespace.
+func identityCredentialProvider(kubeClient client.Client, namespace string, ref *infrav1.UCloudClusterIdentityReference) (CredentialProvider, error) {
+	identity := &infrav1.UCloudClusterIdentity{}
+	if err := kubeClient.Get(context.TODO(), client.ObjectKey{Name: ref.Name}, identity); err != nil {
+		return nil, errors.Wrapf(err, "failed to get UCloudClusterIdentity %s", ref.Name)
+	}
+
+	allowed, err := isNamespaceAllowed(kubeClient, identity.Spec.AllowedNamespaces, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, errors.Errorf("UCloudClusterIdentity %s is not allowed to be used from namespace %s", ref.Name, namespace)
+	}
+
+	// the identity is cluster-scoped, so the namespace of a cluster using it can't stand in
+	// for the namespace of its secret
+	secretRef := identity.Spec.SecretRef
+	if secretRef.Namespace == "" {
+		return nil, errors.Errorf("UCloudClusterIdentity %s must set the namespace of its secretRef", ref.Name)
+	}
+	return &SecretCredentialProvider{
+		Client: kubeClient,
+		Key:    client.ObjectKey{Namespace: secretRef.Namespace, Name: secretRef.Name},
+	}, nil
+}
+
+// credentialFromSecret builds a credential from a Secret holding a UCloud key pair.
+func credentialFromSecret(secret *corev1.Secret) (*auth.Credential, error) {
 	credential := auth.NewCredential()
-	credential.PrivateKey = os.Getenv("UCLOUD_ACCESS_PRIKEY")
-	credential.PublicKey = os.Getenv("UCLOUD_ACCESS_PUBKEY")
-	c.Credential = &credential
+	credential.PublicKey = string(secret.Data[PublicKeyName])
+	credential.PrivateKey = string(secret.Data[PrivateKeyName])
+	if credential.PublicKey == "" || credential.PrivateKey == "" {
+		return nil, errors.Errorf("credential secret %s/%s must contain %s and %s", secret.Namespace, secret.Name, PublicKeyName, PrivateKeyName)
+	}
+	return &credential, nil
+}
+
+// isNamespaceAllowed checks namespace against the allowed namespaces of an identity.
+func isNamespaceAllowed(kubeClient client.Client, allowed *infrav1.AllowedNamespaces, namespace string) (bool, error) {
+	if allowed == nil {
+		return false, nil
+	}
+	if len(allowed.NamespaceList) == 0 && allowed.Selector == nil {
+		return true, nil
+	}
+	for _, name := range allowed.NamespaceList {
+		if name == namespace {
+			return true, nil
+		}
+	}
+	if allowed.Selector == nil {
+		return false, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(allowed.Selector)
+	if err != nil {
+		return false, errors.Wrap(err, "invalid allowedNamespaces selector")
+	}
+	ns := &corev1.Namespace{}
+	if err := kubeClient.Get(context.TODO(), client.ObjectKey{Name: namespace}, ns); err != nil {
+		return false, errors.Wrapf(err, "failed to get namespace %s", namespace)
+	}
+	return selector.Matches(labels.Set(ns.GetLabels())), nil
 }
@@ -0,0 +1,151 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+)
+
+// newIdentityTestClient returns a fake client holding the namespaces team-a, labeled
+// team=a, and team-b, labeled team=b, and objects.
+func newIdentityTestClient(g *WithT, objects ...runtime.Object) client.Client {
+	scheme := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	objects = append(objects,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"team": "a"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b", Labels: map[string]string{"team": "b"}}},
+	)
+	return fake.NewFakeClientWithScheme(scheme, objects...)
+}
+
+func TestIsNamespaceAllowed(t *testing.T) {
+	teamA := &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}
+	tests := []struct {
+		name      string
+		allowed   *infrav1.AllowedNamespaces
+		namespace string
+		want      bool
+		wantErr   bool
+	}{
+		{name: "nil policy", allowed: nil, namespace: "team-a", want: false},
+		{name: "empty policy", allowed: &infrav1.AllowedNamespaces{}, namespace: "team-a", want: true},
+		{name: "listed namespace", allowed: &infrav1.AllowedNamespaces{NamespaceList: []string{"team-b", "team-a"}}, namespace: "team-a", want: true},
+		{name: "namespace not listed", allowed: &infrav1.AllowedNamespaces{NamespaceList: []string{"team-b"}}, namespace: "team-a", want: false},
+		{name: "selected namespace", allowed: &infrav1.AllowedNamespaces{Selector: teamA}, namespace: "team-a", want: true},
+		{name: "namespace not selected", allowed: &infrav1.AllowedNamespaces{Selector: teamA}, namespace: "team-b", want: false},
+		{name: "selected namespace not listed", allowed: &infrav1.AllowedNamespaces{NamespaceList: []string{"team-b"}, Selector: teamA}, namespace: "team-a", want: true},
+		{name: "missing namespace", allowed: &infrav1.AllowedNamespaces{Selector: teamA}, namespace: "team-c", wantErr: true},
+		{name: "invalid selector", allowed: &infrav1.AllowedNamespaces{Selector: &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "team", Operator: "Unknown"}},
+		}}, namespace: "team-a", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			allowed, err := isNamespaceAllowed(newIdentityTestClient(g), tt.allowed, tt.namespace)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(allowed).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestIdentityCredentialProvider(t *testing.T) {
+	credentials := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a-credentials", Namespace: "capu-system"},
+		Data:       map[string][]byte{PublicKeyName: []byte("team-a-public"), PrivateKeyName: []byte("team-a-private")},
+	}
+	tests := []struct {
+		name      string
+		identity  infrav1.UCloudClusterIdentitySpec
+		namespace string
+		wantErr   string
+	}{
+		{
+			name: "allowed namespace",
+			identity: infrav1.UCloudClusterIdentitySpec{
+				SecretRef:         corev1.SecretReference{Name: "team-a-credentials", Namespace: "capu-system"},
+				AllowedNamespaces: &infrav1.AllowedNamespaces{NamespaceList: []string{"team-a"}},
+			},
+			namespace: "team-a",
+		},
+		{
+			name: "denied namespace",
+			identity: infrav1.UCloudClusterIdentitySpec{
+				SecretRef:         corev1.SecretReference{Name: "team-a-credentials", Namespace: "capu-system"},
+				AllowedNamespaces: &infrav1.AllowedNamespaces{NamespaceList: []string{"team-a"}},
+			},
+			namespace: "team-b",
+			wantErr:   "not allowed to be used from namespace team-b",
+		},
+		{
+			name: "no allowed namespaces",
+			identity: infrav1.UCloudClusterIdentitySpec{
+				SecretRef: corev1.SecretReference{Name: "team-a-credentials", Namespace: "capu-system"},
+			},
+			namespace: "team-a",
+			wantErr:   "not allowed to be used from namespace team-a",
+		},
+		{
+			name: "secret without namespace",
+			identity: infrav1.UCloudClusterIdentitySpec{
+				SecretRef:         corev1.SecretReference{Name: "team-a-credentials"},
+				AllowedNamespaces: &infrav1.AllowedNamespaces{},
+			},
+			namespace: "team-a",
+			wantErr:   "must set the namespace of its secretRef",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			identity := &infrav1.UCloudClusterIdentity{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}, Spec: tt.identity}
+			kubeClient := newIdentityTestClient(g, identity, credentials)
+
+			provider, err := identityCredentialProvider(kubeClient, tt.namespace, &infrav1.UCloudClusterIdentityReference{Name: "team-a"})
+			if tt.wantErr != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.wantErr)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			credential, err := provider.Credential()
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(credential.PublicKey).To(Equal("team-a-public"))
+			g.Expect(credential.PrivateKey).To(Equal("team-a-private"))
+		})
+	}
+
+	t.Run("missing identity", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := identityCredentialProvider(newIdentityTestClient(g), "team-a", &infrav1.UCloudClusterIdentityReference{Name: "team-a"})
+		g.Expect(err).To(MatchError(ContainSubstring("failed to get UCloudClusterIdentity team-a")))
+	})
+}
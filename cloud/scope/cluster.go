@@ -22,12 +22,18 @@ import (
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/auth"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/klogr"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
 )
 
 // ClusterScopeParams defines the input parameters used to create a new Scope.
@@ -54,11 +60,24 @@ func NewClusterScope(params ClusterScopeParams) (*ClusterScope, error) {
 	}
 
 	if params.UCloudClients.Config == nil {
-		params.UCloudClients.loadDefaultConfig()
+		if err := params.UCloudClients.loadConfig(params.UCloudCluster.Spec.API); err != nil {
+			return nil, err
+		}
 	}
 
 	if params.UCloudClients.Credential == nil {
-		params.UCloudClients.getCredentialFromEnv()
+		if params.UCloudCluster.Spec.IdentityRef != nil {
+			provider, err := identityCredentialProvider(params.Client, params.UCloudCluster.Namespace, params.UCloudCluster.Spec.IdentityRef)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to get credential from identity")
+			}
+			params.UCloudClients.CredentialProvider = provider
+		} else if params.UCloudClients.CredentialProvider == nil {
+			params.UCloudClients.CredentialProvider = &EnvCredentialProvider{}
+		}
+		if _, err := params.UCloudClients.CurrentCredential(); err != nil {
+			return nil, err
+		}
 	}
 
 	helper, err := patch.NewHelper(params.UCloudCluster, params.Client)
@@ -109,9 +128,37 @@ func (s *ClusterScope) GroupName() string {
 	return s.UCloudCluster.Status.Group.GroupName
 }
 
-// Subnets returns the cluster subnets.
-func (s *ClusterScope) Subnet() infrav1.SubnetSpec {
-	return s.UCloudCluster.Spec.Network.Subnet
+// Subnets returns the cluster subnets, the single subnet of the spec if it doesn't list
+// any. Subnets without a name get the default name of their role.
+func (s *ClusterScope) Subnets() []infrav1.SubnetSpec {
+	specs := s.UCloudCluster.Spec.Network.Subnets
+	if len(specs) == 0 {
+		subnet := s.UCloudCluster.Spec.Network.Subnet
+		if subnet.SubnetName == "" {
+			subnet.SubnetName = "cluster-api-" + s.Name() + "-subnet"
+		}
+		return []infrav1.SubnetSpec{subnet}
+	}
+	subnets := make([]infrav1.SubnetSpec, 0, len(specs))
+	for _, subnet := range specs {
+		if subnet.SubnetName == "" {
+			switch subnet.Role {
+			case infrav1.SubnetRoleControlPlane:
+				subnet.SubnetName = common.GenerateControlPlaneSubnetName(s.Name())
+			case infrav1.SubnetRoleNode:
+				subnet.SubnetName = common.GenerateNodeSubnetName(s.Name())
+			case infrav1.SubnetRoleBastion:
+				subnet.SubnetName = common.GenerateBastionSubnetName(s.Name())
+			default:
+				subnet.SubnetName = "cluster-api-" + s.Name() + "-subnet"
+			}
+			if subnet.Zone != "" {
+				subnet.SubnetName += "-" + subnet.Zone
+			}
+		}
+		subnets = append(subnets, subnet)
+	}
+	return subnets
 }
 
 // Name returns the cluster name.
@@ -129,18 +176,100 @@ func (s *ClusterScope) Region() string {
 	return s.UCloudCluster.Spec.Region
 }
 
-// LoadBalancerFrontendPort returns the loadbalancer frontend if specified
-// in the cluster resource's network configuration.
-func (s *ClusterScope) LoadBalancerFrontendPort() int64 {
-	if s.Cluster.Spec.ClusterNetwork.APIServerPort != nil {
-		return int64(*s.Cluster.Spec.ClusterNetwork.APIServerPort)
+// LoadBalancerFrontendPort returns the port of the listener of the api server, the port
+// of the endpoint. It defaults to the api server port of the cluster, and then to 6443.
+func (s *ClusterScope) LoadBalancerFrontendPort() int {
+	if port := s.UCloudCluster.Spec.Network.ULB.Listener.FrontendPort; port != 0 {
+		return port
+	}
+	return s.apiServerPort()
+}
+
+// LoadBalancerBackendPort returns the port of the api server on the control plane
+// machines. It defaults to the api server port of the cluster, and then to 6443.
+func (s *ClusterScope) LoadBalancerBackendPort() int {
+	if port := s.UCloudCluster.Spec.Network.ULB.Listener.BackendPort; port != 0 {
+		return port
+	}
+	return s.apiServerPort()
+}
+
+// LoadBalancerListener returns the listener of the api server with the defaults filled in.
+func (s *ClusterScope) LoadBalancerListener() infrav1.ULBListenerSpec {
+	listener := s.UCloudCluster.Spec.Network.ULB.Listener
+	listener.FrontendPort = s.LoadBalancerFrontendPort()
+	listener.BackendPort = s.LoadBalancerBackendPort()
+	if listener.ListenType == "" {
+		listener.ListenType = infrav1.ULBListenTypeRequestProxy
+	}
+	if listener.Method == "" {
+		listener.Method = infrav1.ULBMethodRoundrobin
+	}
+	if listener.HealthCheck.Type == "" {
+		listener.HealthCheck.Type = infrav1.ULBHealthCheckPort
+	}
+	if listener.PersistenceType == "" {
+		listener.PersistenceType = infrav1.ULBPersistenceNone
+	}
+	return listener
+}
+
+// apiServerPort returns the api server port of the cluster, or 6443 if it isn't set.
+func (s *ClusterScope) apiServerPort() int {
+	if s.Cluster != nil && s.Cluster.Spec.ClusterNetwork != nil && s.Cluster.Spec.ClusterNetwork.APIServerPort != nil {
+		return int(*s.Cluster.Spec.ClusterNetwork.APIServerPort)
 	}
-	return 6443
+	return infrav1.DefaultAPIServerPort
 }
 
-// LoadBalancerBackendPort returns the loadbalancer backend if specified.
-func (s *ClusterScope) LoadBalancerBackendPort() int64 {
-	return 6443
+// NodeCredential returns the credential handed to the workload cluster nodes.
+// It falls back to the cluster credential when no dedicated key pair is referenced.
+func (s *ClusterScope) NodeCredential() (*auth.Credential, error) {
+	ref := s.UCloudCluster.Spec.NodeCredential.SecretRef
+	if ref == nil {
+		return s.Credential, nil
+	}
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: s.UCloudCluster.Namespace, Name: ref.Name}
+	if err := s.client.Get(context.TODO(), key, secret); err != nil {
+		return nil, errors.Wrapf(err, "failed to get node credential secret %s", key)
+	}
+	return credentialFromSecret(secret)
+}
+
+// HasNodeCredential returns true if a dedicated key pair is referenced for the nodes.
+func (s *ClusterScope) HasNodeCredential() bool {
+	return s.UCloudCluster.Spec.NodeCredential.SecretRef != nil
+}
+
+// BastionEnabled returns true if the cluster should have a bastion.
+func (s *ClusterScope) BastionEnabled() bool {
+	bastion := s.UCloudCluster.Spec.Bastion
+	return bastion.Enabled || bastion.SSHPassword != "" || bastion.SSHPasswordSecretRef != nil || bastion.SSHKey != nil
+}
+
+// BastionPasswordSecretName returns the name of the Secret a generated bastion
+// password is stored in.
+func (s *ClusterScope) BastionPasswordSecretName() string {
+	return s.UCloudCluster.Name + "-bastion-ssh-password"
+}
+
+// BastionLoginPassword returns the password to log in to the bastion with. Unless the
+// spec sets one, it is generated and stored in the Secret named by BastionPasswordSecretName.
+func (s *ClusterScope) BastionLoginPassword() (string, error) {
+	if ref := s.UCloudCluster.Spec.Bastion.SSHPasswordSecretRef; ref != nil {
+		return passwordFromSecret(s.client, s.UCloudCluster.Namespace, ref)
+	}
+	if s.UCloudCluster.Spec.Bastion.SSHPassword != "" {
+		record.Warnf(s.UCloudCluster, "DeprecatedSSHPassword", "spec.bastion.sshPassword is deprecated, use spec.bastion.sshPasswordSecretRef instead")
+		return decodePassword(s.UCloudCluster.Spec.Bastion.SSHPassword)
+	}
+	return generatedPassword(s.client, s.UCloudCluster.Namespace, s.BastionPasswordSecretName(), metav1.OwnerReference{
+		APIVersion: infrav1.GroupVersion.String(),
+		Kind:       "UCloudCluster",
+		Name:       s.UCloudCluster.Name,
+		UID:        s.UCloudCluster.UID,
+	})
 }
 
 // ControlPlaneConfigMapName returns the name of the ConfigMap used to
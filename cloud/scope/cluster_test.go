@@ -0,0 +1,90 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/auth"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+)
+
+// newTestClusterScope returns the scope of a cluster in the default namespace, with a
+// fake client holding objects.
+func newTestClusterScope(t *testing.T, spec infrav1.UCloudClusterSpec, objects ...runtime.Object) *ClusterScope {
+	g := NewWithT(t)
+	scheme := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"}}
+	ucloudCluster := &infrav1.UCloudCluster{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"}, Spec: spec}
+	objects = append(objects, cluster, ucloudCluster)
+	clusterScope, err := NewClusterScope(ClusterScopeParams{
+		UCloudClients: UCloudClients{Credential: &auth.Credential{PublicKey: "controller-public", PrivateKey: "controller-private"}},
+		Client:        fake.NewFakeClientWithScheme(scheme, objects...),
+		Cluster:       cluster,
+		UCloudCluster: ucloudCluster,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	return clusterScope
+}
+
+func TestNodeCredential(t *testing.T) {
+	nodeCredentials := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-credentials", Namespace: "default"},
+		Data:       map[string][]byte{PublicKeyName: []byte("node-public"), PrivateKeyName: []byte("node-private")},
+	}
+	incomplete := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "incomplete", Namespace: "default"},
+		Data:       map[string][]byte{PublicKeyName: []byte("node-public")},
+	}
+	tests := []struct {
+		name      string
+		secretRef *corev1.LocalObjectReference
+		want      string
+		wantErr   bool
+	}{
+		{name: "controller credential without a secret", want: "controller-public"},
+		{name: "referenced key pair", secretRef: &corev1.LocalObjectReference{Name: "node-credentials"}, want: "node-public"},
+		{name: "missing secret", secretRef: &corev1.LocalObjectReference{Name: "missing"}, wantErr: true},
+		{name: "incomplete secret", secretRef: &corev1.LocalObjectReference{Name: "incomplete"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			spec := infrav1.UCloudClusterSpec{NodeCredential: infrav1.NodeCredentialSpec{SecretRef: tt.secretRef}}
+			clusterScope := newTestClusterScope(t, spec, nodeCredentials, incomplete)
+			g.Expect(clusterScope.HasNodeCredential()).To(Equal(tt.secretRef != nil))
+			credential, err := clusterScope.NodeCredential()
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(credential.PublicKey).To(Equal(tt.want))
+		})
+	}
+}
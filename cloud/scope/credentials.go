@@ -0,0 +1,240 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/auth"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CredentialProvider supplies the UCloud key pair used to sign API requests.
+// Implementations are consulted before every request, so they must return the
+// current key pair and are expected to pick up rotated keys without a restart.
+type CredentialProvider interface {
+	// Credential returns a fresh copy of the current credential.
+	Credential() (*auth.Credential, error)
+}
+
+// EnvCredentialProvider reads the key pair from the UCLOUD_ACCESS_PUBKEY and
+// UCLOUD_ACCESS_PRIKEY environment variables.
+type EnvCredentialProvider struct{}
+
+// Credential implements CredentialProvider.
+func (p *EnvCredentialProvider) Credential() (*auth.Credential, error) {
+	credential := auth.NewCredential()
+	credential.PublicKey = os.Getenv(PublicKeyName)
+	credential.PrivateKey = os.Getenv(PrivateKeyName)
+	if credential.PublicKey == "" || credential.PrivateKey == "" {
+		return nil, errors.Errorf("environment variables %s and %s must be set", PublicKeyName, PrivateKeyName)
+	}
+	return &credential, nil
+}
+
+// FileCredentialProvider reads the key pair from a directory holding one file per key,
+// named UCLOUD_ACCESS_PUBKEY and UCLOUD_ACCESS_PRIKEY, as produced by mounting a Secret
+// as a volume. The files are re-read whenever their modification time changes.
+type FileCredentialProvider struct {
+	// Dir is the directory holding the key files.
+	Dir string
+
+	mu         sync.Mutex
+	modTime    time.Time
+	credential auth.Credential
+}
+
+// Credential implements CredentialProvider.
+func (p *FileCredentialProvider) Credential() (*auth.Credential, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	publicKeyFile := filepath.Join(p.Dir, PublicKeyName)
+	privateKeyFile := filepath.Join(p.Dir, PrivateKeyName)
+
+	var modTime time.Time
+	for _, name := range []string{publicKeyFile, privateKeyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to stat credential file %s", name)
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+
+	if !modTime.Equal(p.modTime) {
+		publicKey, err := ioutil.ReadFile(publicKeyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read credential file %s", publicKeyFile)
+		}
+		privateKey, err := ioutil.ReadFile(privateKeyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read credential file %s", privateKeyFile)
+		}
+		credential := auth.NewCredential()
+		credential.PublicKey = strings.TrimSpace(string(publicKey))
+		credential.PrivateKey = strings.TrimSpace(string(privateKey))
+		if credential.PublicKey == "" || credential.PrivateKey == "" {
+			return nil, errors.Errorf("credential files in %s must not be empty", p.Dir)
+		}
+		p.credential = credential
+		p.modTime = modTime
+	}
+
+	credential := p.credential
+	return &credential, nil
+}
+
+// SecretCredentialProvider reads the key pair from a Kubernetes Secret holding the
+// UCLOUD_ACCESS_PUBKEY and UCLOUD_ACCESS_PRIKEY keys. The Secret is read on every call,
+// which is cheap with the cache-backed client of the manager.
+type SecretCredentialProvider struct {
+	Client client.Client
+	Key    client.ObjectKey
+}
+
+// Credential implements CredentialProvider.
+func (p *SecretCredentialProvider) Credential() (*auth.Credential, error) {
+	secret := &corev1.Secret{}
+	if err := p.Client.Get(context.TODO(), p.Key, secret); err != nil {
+		return nil, errors.Wrapf(err, "failed to get credential secret %s", p.Key)
+	}
+	return credentialFromSecret(secret)
+}
+
+// ExecCredential is the JSON document an exec credential plugin writes to stdout.
+type ExecCredential struct {
+	PublicKey     string `json:"publicKey"`
+	PrivateKey    string `json:"privateKey"`
+	SecurityToken string `json:"securityToken,omitempty"`
+	// Expiration is an RFC 3339 timestamp after which the plugin is invoked again.
+	Expiration *time.Time `json:"expiration,omitempty"`
+}
+
+// ExecCredentialProvider runs an external plugin and reads an ExecCredential from its
+// output. The result is cached until shortly before it expires or, for credentials
+// without an expiration, for RefreshInterval.
+type ExecCredentialProvider struct {
+	Command string
+	Args    []string
+
+	// RefreshInterval is how long a credential without expiration is cached. Defaults to 5 minutes.
+	RefreshInterval time.Duration
+	// Timeout is how long the plugin may run before it is killed. Defaults to 30 seconds,
+	// as every request waits for the plugin while it runs.
+	Timeout time.Duration
+
+	mu         sync.Mutex
+	refreshAt  time.Time
+	credential *auth.Credential
+}
+
+// execCredentialExpiryMargin is how long before its expiration a credential is refreshed.
+const execCredentialExpiryMargin = time.Minute
+
+// Credential implements CredentialProvider.
+func (p *ExecCredentialProvider) Credential() (*auth.Credential, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.credential == nil || !time.Now().Before(p.refreshAt) {
+		if err := p.refresh(); err != nil {
+			return nil, err
+		}
+	}
+
+	credential := *p.credential
+	return &credential, nil
+}
+
+func (p *ExecCredentialProvider) refresh() error {
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Command, p.Args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return errors.Errorf("credential plugin %s didn't finish within %s", p.Command, timeout)
+		}
+		return errors.Wrapf(err, "credential plugin %s failed: %s", p.Command, strings.TrimSpace(stderr.String()))
+	}
+
+	out := &ExecCredential{}
+	if err := json.Unmarshal(stdout.Bytes(), out); err != nil {
+		return errors.Wrapf(err, "failed to decode output of credential plugin %s", p.Command)
+	}
+	if out.PublicKey == "" || out.PrivateKey == "" {
+		return errors.Errorf("credential plugin %s returned an empty key pair", p.Command)
+	}
+
+	credential := auth.NewCredential()
+	credential.PublicKey = out.PublicKey
+	credential.PrivateKey = out.PrivateKey
+	credential.SecurityToken = out.SecurityToken
+
+	interval := p.RefreshInterval
+	if interval == 0 {
+		interval = 5 * time.Minute
+	}
+	p.refreshAt = time.Now().Add(interval)
+	if out.Expiration != nil {
+		credential.CanExpire = true
+		credential.Expires = *out.Expiration
+		p.refreshAt = out.Expiration.Add(-execCredentialExpiryMargin)
+	}
+	p.credential = &credential
+	return nil
+}
+
+// CredentialProviderChain returns the credential of the first provider that succeeds.
+type CredentialProviderChain []CredentialProvider
+
+// Credential implements CredentialProvider.
+func (c CredentialProviderChain) Credential() (*auth.Credential, error) {
+	if len(c) == 0 {
+		return nil, errors.New("no credential provider configured")
+	}
+	var errs []error
+	for _, provider := range c {
+		credential, err := provider.Credential()
+		if err == nil {
+			return credential, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Wrap(kerrors.NewAggregate(errs), "no credential provider succeeded")
+}
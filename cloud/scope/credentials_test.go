@@ -0,0 +1,240 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/auth"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// writeKeyFiles writes the key files of a FileCredentialProvider, modified at modTime.
+func writeKeyFiles(g *WithT, dir, publicKey, privateKey string, modTime time.Time) {
+	for name, key := range map[string]string{PublicKeyName: publicKey, PrivateKeyName: privateKey} {
+		file := filepath.Join(dir, name)
+		g.Expect(ioutil.WriteFile(file, []byte(key+"\n"), 0600)).To(Succeed())
+		g.Expect(os.Chtimes(file, modTime, modTime)).To(Succeed())
+	}
+}
+
+func TestFileCredentialProvider(t *testing.T) {
+	g := NewWithT(t)
+	dir, err := ioutil.TempDir("", "credentials")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+	provider := &FileCredentialProvider{Dir: dir}
+
+	_, err = provider.Credential()
+	g.Expect(err).To(HaveOccurred())
+
+	modTime := time.Now().Add(-time.Hour)
+	writeKeyFiles(g, dir, "public", "private", modTime)
+	credential, err := provider.Credential()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(credential.PublicKey).To(Equal("public"))
+	g.Expect(credential.PrivateKey).To(Equal("private"))
+
+	// the files are only read again once they are modified
+	writeKeyFiles(g, dir, "unread", "unread", modTime)
+	credential, err = provider.Credential()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(credential.PublicKey).To(Equal("public"))
+
+	writeKeyFiles(g, dir, "rotated-public", "rotated-private", modTime.Add(time.Minute))
+	credential, err = provider.Credential()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(credential.PublicKey).To(Equal("rotated-public"))
+	g.Expect(credential.PrivateKey).To(Equal("rotated-private"))
+
+	// a copy is returned, so a caller can't change the cached credential
+	credential.PublicKey = "changed"
+	credential, err = provider.Credential()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(credential.PublicKey).To(Equal("rotated-public"))
+
+	writeKeyFiles(g, dir, "", "", modTime.Add(2*time.Minute))
+	_, err = provider.Credential()
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestSecretCredentialProvider(t *testing.T) {
+	g := NewWithT(t)
+	scheme := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "capu-system"},
+		Data:       map[string][]byte{PublicKeyName: []byte("public"), PrivateKeyName: []byte("private")},
+	}
+	kubeClient := fake.NewFakeClientWithScheme(scheme, secret)
+	provider := &SecretCredentialProvider{Client: kubeClient, Key: client.ObjectKey{Namespace: "capu-system", Name: "credentials"}}
+
+	credential, err := provider.Credential()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(credential.PublicKey).To(Equal("public"))
+
+	// a rotated key pair is used by the next call
+	secret.Data[PublicKeyName] = []byte("rotated-public")
+	g.Expect(kubeClient.Update(context.TODO(), secret)).To(Succeed())
+	credential, err = provider.Credential()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(credential.PublicKey).To(Equal("rotated-public"))
+
+	delete(secret.Data, PrivateKeyName)
+	g.Expect(kubeClient.Update(context.TODO(), secret)).To(Succeed())
+	_, err = provider.Credential()
+	g.Expect(err).To(HaveOccurred())
+
+	provider.Key.Name = "missing"
+	_, err = provider.Credential()
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestExecCredentialProvider(t *testing.T) {
+	g := NewWithT(t)
+	dir, err := ioutil.TempDir("", "credentials")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	// the plugin prints the content of a file, and counts its runs
+	output := filepath.Join(dir, "output")
+	runs := filepath.Join(dir, "runs")
+	setOutput := func(content string) {
+		g.Expect(ioutil.WriteFile(output, []byte(content), 0600)).To(Succeed())
+	}
+	countRuns := func() int {
+		data, _ := ioutil.ReadFile(runs)
+		return len(data)
+	}
+	provider := &ExecCredentialProvider{Command: "sh", Args: []string{"-c", "printf x >> " + runs + " && cat " + output}}
+
+	setOutput(`{"publicKey": "public", "privateKey": "private", "securityToken": "token"}`)
+	credential, err := provider.Credential()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(credential.PublicKey).To(Equal("public"))
+	g.Expect(credential.SecurityToken).To(Equal("token"))
+	g.Expect(credential.CanExpire).To(BeFalse())
+
+	// the credential is cached for the refresh interval
+	setOutput(`{"publicKey": "rotated-public", "privateKey": "rotated-private"}`)
+	credential, err = provider.Credential()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(credential.PublicKey).To(Equal("public"))
+	g.Expect(countRuns()).To(Equal(1))
+
+	provider.refreshAt = time.Now()
+	credential, err = provider.Credential()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(credential.PublicKey).To(Equal("rotated-public"))
+	g.Expect(countRuns()).To(Equal(2))
+
+	// a credential expiring within the margin is refreshed by every call
+	expiration := time.Now().Add(execCredentialExpiryMargin / 2).UTC().Format(time.RFC3339)
+	setOutput(`{"publicKey": "public", "privateKey": "private", "expiration": "` + expiration + `"}`)
+	provider.refreshAt = time.Now()
+	credential, err = provider.Credential()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(credential.CanExpire).To(BeTrue())
+	_, err = provider.Credential()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(countRuns()).To(Equal(4))
+
+	setOutput(`{"publicKey": "public"}`)
+	provider.refreshAt = time.Now()
+	_, err = provider.Credential()
+	g.Expect(err).To(HaveOccurred())
+
+	setOutput(`not json`)
+	_, err = provider.Credential()
+	g.Expect(err).To(HaveOccurred())
+
+	failing := &ExecCredentialProvider{Command: "sh", Args: []string{"-c", "echo denied >&2; exit 1"}}
+	_, err = failing.Credential()
+	g.Expect(err).To(MatchError(ContainSubstring("denied")))
+
+	// a hung plugin is killed once it times out
+	hung := &ExecCredentialProvider{Command: "sleep", Args: []string{"10"}, Timeout: 100 * time.Millisecond}
+	start := time.Now()
+	_, err = hung.Credential()
+	g.Expect(err).To(MatchError(ContainSubstring("didn't finish")))
+	g.Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+}
+
+// staticCredentialProvider returns its credential, or its error if it has none.
+type staticCredentialProvider struct {
+	credential *auth.Credential
+	err        error
+	calls      int
+}
+
+func (p *staticCredentialProvider) Credential() (*auth.Credential, error) {
+	p.calls++
+	return p.credential, p.err
+}
+
+func TestCredentialProviderChain(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := CredentialProviderChain{}.Credential()
+	g.Expect(err).To(HaveOccurred())
+
+	failing := &staticCredentialProvider{err: os.ErrNotExist}
+	first := &staticCredentialProvider{credential: &auth.Credential{PublicKey: "first"}}
+	second := &staticCredentialProvider{credential: &auth.Credential{PublicKey: "second"}}
+	credential, err := CredentialProviderChain{failing, first, second}.Credential()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(credential.PublicKey).To(Equal("first"))
+	g.Expect(second.calls).To(Equal(0))
+
+	_, err = CredentialProviderChain{failing, failing}.Credential()
+	g.Expect(err).To(MatchError(ContainSubstring("no credential provider succeeded")))
+	g.Expect(failing.calls).To(Equal(3))
+}
+
+func TestCurrentCredentialRotation(t *testing.T) {
+	g := NewWithT(t)
+	provider := &staticCredentialProvider{credential: &auth.Credential{PublicKey: "public", PrivateKey: "private"}}
+	clients := &UCloudClients{CredentialProvider: provider}
+
+	credential, err := clients.CurrentCredential()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(credential.PublicKey).To(Equal("public"))
+
+	// the credential the sdk clients were created with is updated in place
+	provider.credential = &auth.Credential{PublicKey: "rotated-public", PrivateKey: "rotated-private"}
+	rotated, err := clients.CurrentCredential()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rotated).To(BeIdenticalTo(credential))
+	g.Expect(credential.PublicKey).To(Equal("rotated-public"))
+
+	// a failing provider doesn't clear the last credential
+	provider.err = os.ErrPermission
+	_, err = clients.CurrentCredential()
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(credential.PublicKey).To(Equal("rotated-public"))
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
+)
+
+// DefaultInstanceType is the instance type that is always known, it is a general
+// purpose N machine with the default CPU and memory.
+const DefaultInstanceType = "uhost"
+
+// InstanceType is the resolved configuration of a UHost instance.
+type InstanceType struct {
+	// MachineType is the machine family, e.g. N, C, G or O.
+	MachineType string `json:"machineType,omitempty"`
+	// CPUPlatform is the minimal CPU platform, e.g. Intel/Auto or Intel/Cascadelake.
+	CPUPlatform string `json:"cpuPlatform,omitempty"`
+	// CPU is the number of cores.
+	CPU int `json:"cpu,omitempty"`
+	// Memory in MB.
+	Memory int `json:"memory,omitempty"`
+	// GPU is the number of GPUs, GPUType is required if it is set, either by the
+	// catalog or by the machine.
+	GPU int `json:"gpu,omitempty"`
+	// GPUType is the GPU model, e.g. K80, P40 or V100.
+	GPUType string `json:"gpuType,omitempty"`
+}
+
+// defaultInstanceType returns the configuration of DefaultInstanceType.
+func defaultInstanceType() *InstanceType {
+	return &InstanceType{
+		MachineType: "N",
+		CPUPlatform: "Intel/Auto",
+		CPU:         common.DefaultUHostCPU,
+		Memory:      common.DefaultUHostMemory,
+	}
+}
+
+// InstanceTypeCatalog resolves instance type names from a ConfigMap, in which each key is
+// the name of an instance type and each value its InstanceType in YAML or JSON.
+// A nil catalog resolves every name to the default instance type, so that instance
+// types are only enforced once a catalog is configured.
+type InstanceTypeCatalog struct {
+	// Reader reads the ConfigMap. The catalog is only read when instances are created
+	// and validated, so an uncached reader avoids watching ConfigMaps cluster wide.
+	Reader client.Reader
+	Key    client.ObjectKey
+}
+
+// InstanceType returns the configuration of the named instance type.
+func (c *InstanceTypeCatalog) InstanceType(name string) (*InstanceType, error) {
+	if c == nil || name == DefaultInstanceType {
+		return defaultInstanceType(), nil
+	}
+	configMap := &corev1.ConfigMap{}
+	if err := c.Reader.Get(context.TODO(), c.Key, configMap); err != nil {
+		return nil, errors.Wrapf(err, "failed to get instance type catalog %s", c.Key)
+	}
+	data, ok := configMap.Data[name]
+	if !ok {
+		return nil, errors.Errorf("unknown instance type %q, it is not in the catalog %s", name, c.Key)
+	}
+	instanceType, err := parseInstanceType([]byte(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid instance type %q in the catalog %s", name, c.Key)
+	}
+	return instanceType, nil
+}
+
+// Validate returns an error if the named instance type is unknown or invalid.
+func (c *InstanceTypeCatalog) Validate(name string) error {
+	_, err := c.InstanceType(name)
+	return err
+}
+
+// parseInstanceType parses a catalog entry. Fields the entry doesn't set are taken
+// from the default instance type.
+func parseInstanceType(data []byte) (*InstanceType, error) {
+	jsonData, err := yaml.ToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	instanceType := defaultInstanceType()
+	if err := json.Unmarshal(jsonData, instanceType); err != nil {
+		return nil, err
+	}
+	return instanceType, nil
+}
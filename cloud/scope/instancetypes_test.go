@@ -0,0 +1,87 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/klogr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+)
+
+func TestMachineScopeInstanceType(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	catalog := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "instance-types", Namespace: "capu-system"},
+		Data: map[string]string{
+			"o.c6.2xlarge": "machineType: O\ncpuPlatform: Intel/Cascadelake\ncpu: 8\nmemory: 16384\n",
+			"g.v100":       `{"machineType": "G", "cpu": 16, "memory": 65536, "gpu": 1}`,
+		},
+	}
+	client := fake.NewFakeClientWithScheme(scheme, catalog)
+
+	instanceType := func(instanceTypes *InstanceTypeCatalog, spec infrav1.UCloudMachineSpec) (*InstanceType, error) {
+		machineScope, err := NewMachineScope(MachineScopeParams{
+			Client:        client,
+			Logger:        klogr.New(),
+			Cluster:       &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"}},
+			Machine:       newTestMachine(),
+			UCloudCluster: &infrav1.UCloudCluster{},
+			UCloudMachine: &infrav1.UCloudMachine{Spec: spec},
+			InstanceTypes: instanceTypes,
+		})
+		g.Expect(err).NotTo(HaveOccurred())
+		return machineScope.InstanceType()
+	}
+
+	// without a catalog every instance type is the default one
+	g.Expect(instanceType(nil, infrav1.UCloudMachineSpec{InstanceType: "n1.standard-2", CPU: 2})).To(Equal(&InstanceType{
+		MachineType: "N", CPUPlatform: "Intel/Auto", CPU: 2, Memory: 8192,
+	}))
+
+	instanceTypes := &InstanceTypeCatalog{
+		Reader: client,
+		Key:    types.NamespacedName{Namespace: "capu-system", Name: "instance-types"},
+	}
+	g.Expect(instanceType(instanceTypes, infrav1.UCloudMachineSpec{InstanceType: "o.c6.2xlarge", Memory: 32768})).To(Equal(&InstanceType{
+		MachineType: "O", CPUPlatform: "Intel/Cascadelake", CPU: 8, Memory: 32768,
+	}))
+	g.Expect(instanceType(instanceTypes, infrav1.UCloudMachineSpec{InstanceType: "uhost"})).To(Equal(&InstanceType{
+		MachineType: "N", CPUPlatform: "Intel/Auto", CPU: 4, Memory: 8192,
+	}))
+	_, err := instanceType(instanceTypes, infrav1.UCloudMachineSpec{InstanceType: "n1.standard-2"})
+	g.Expect(err).To(MatchError(ContainSubstring("unknown instance type")))
+	_, err = instanceType(instanceTypes, infrav1.UCloudMachineSpec{InstanceType: "g.v100"})
+	g.Expect(err).To(MatchError(ContainSubstring("has gpus but no gpuType")))
+	g.Expect(instanceType(instanceTypes, infrav1.UCloudMachineSpec{InstanceType: "g.v100", GPUType: "V100"})).To(Equal(&InstanceType{
+		MachineType: "G", CPUPlatform: "Intel/Auto", CPU: 16, Memory: 65536, GPU: 1, GPUType: "V100",
+	}))
+}
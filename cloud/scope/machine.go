@@ -22,6 +22,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/klogr"
 	"k8s.io/utils/pointer"
@@ -30,6 +31,7 @@ import (
 	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
@@ -43,6 +45,10 @@ type MachineScopeParams struct {
 	Machine       *clusterv1.Machine
 	UCloudCluster *infrav1.UCloudCluster
 	UCloudMachine *infrav1.UCloudMachine
+
+	// InstanceTypes resolves the instance type of the machine. Every instance type
+	// resolves to the default one if not set.
+	InstanceTypes *InstanceTypeCatalog
 }
 
 // NewMachineScope creates a new MachineScope from the supplied parameters.
@@ -80,14 +86,16 @@ func NewMachineScope(params MachineScopeParams) (*MachineScope, error) {
 		UCloudMachine: params.UCloudMachine,
 		Logger:        params.Logger,
 		patchHelper:   helper,
+		instanceTypes: params.InstanceTypes,
 	}, nil
 }
 
 // MachineScope defines a scope defined around a machine and its cluster.
 type MachineScope struct {
 	logr.Logger
-	client      client.Client
-	patchHelper *patch.Helper
+	client        client.Client
+	patchHelper   *patch.Helper
+	instanceTypes *InstanceTypeCatalog
 
 	Cluster       *clusterv1.Cluster
 	Machine       *clusterv1.Machine
@@ -123,6 +131,19 @@ func (m *MachineScope) IsControlPlane() bool {
 	return util.IsControlPlaneMachine(m.Machine)
 }
 
+// InstanceKind returns the kind of instance of the machine, uhost or uphost.
+func (m *MachineScope) InstanceKind() string {
+	if m.UCloudMachine.Spec.InstanceKind == "" {
+		return infrav1.UHostInstanceKind
+	}
+	return m.UCloudMachine.Spec.InstanceKind
+}
+
+// PublicIP returns true if the instance of the machine gets an EIP.
+func (m *MachineScope) PublicIP() bool {
+	return m.UCloudMachine.Spec.PublicIP != nil && *m.UCloudMachine.Spec.PublicIP
+}
+
 // Role returns the machine role from the labels.
 func (m *MachineScope) Role() string {
 	if util.IsControlPlaneMachine(m.Machine) {
@@ -225,6 +246,65 @@ func (m *MachineScope) GetBootstrapData() (string, error) {
 	return string(value), nil
 }
 
+// InstanceType returns the configuration of the instance, the instance type from the
+// catalog with the fields set in the spec overriding it.
+func (m *MachineScope) InstanceType() (*InstanceType, error) {
+	spec := m.UCloudMachine.Spec
+	name := spec.InstanceType
+	if name == "" {
+		name = DefaultInstanceType
+	}
+	instanceType, err := m.instanceTypes.InstanceType(name)
+	if err != nil {
+		return nil, err
+	}
+	if spec.MachineType != "" {
+		instanceType.MachineType = spec.MachineType
+	}
+	if spec.MinimalCpuPlatform != "" {
+		instanceType.CPUPlatform = spec.MinimalCpuPlatform
+	}
+	if spec.CPU != 0 {
+		instanceType.CPU = spec.CPU
+	}
+	if spec.Memory != 0 {
+		instanceType.Memory = spec.Memory
+	}
+	if spec.GPU != 0 {
+		instanceType.GPU = spec.GPU
+	}
+	if spec.GPUType != "" {
+		instanceType.GPUType = spec.GPUType
+	}
+	if instanceType.GPU > 0 && instanceType.GPUType == "" {
+		return nil, errors.Errorf("instance type %q has gpus but no gpuType", name)
+	}
+	return instanceType, nil
+}
+
+// PasswordSecretName returns the name of the Secret a generated password is stored in.
+func (m *MachineScope) PasswordSecretName() string {
+	return m.Name() + "-ssh-password"
+}
+
+// LoginPassword returns the password to log in to the instance with. Unless the spec
+// sets one, it is generated and stored in the Secret named by PasswordSecretName.
+func (m *MachineScope) LoginPassword() (string, error) {
+	if ref := m.UCloudMachine.Spec.SSHPasswordSecretRef; ref != nil {
+		return passwordFromSecret(m.client, m.Namespace(), ref)
+	}
+	if m.UCloudMachine.Spec.SSHPassword != "" {
+		record.Warnf(m.UCloudMachine, "DeprecatedSSHPassword", "spec.sshPassword is deprecated, use spec.sshPasswordSecretRef instead")
+		return decodePassword(m.UCloudMachine.Spec.SSHPassword)
+	}
+	return generatedPassword(m.client, m.Namespace(), m.PasswordSecretName(), metav1.OwnerReference{
+		APIVersion: infrav1.GroupVersion.String(),
+		Kind:       "UCloudMachine",
+		Name:       m.UCloudMachine.Name,
+		UID:        m.UCloudMachine.UID,
+	})
+}
+
 // PatchObject persists the cluster configuration and status.
 func (m *MachineScope) PatchObject() error {
 	return m.patchHelper.Patch(context.TODO(), m.UCloudMachine)
@@ -0,0 +1,97 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/klogr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+)
+
+// newTestMachine returns the machine my-machine-0 of the cluster my-cluster.
+func newTestMachine() *clusterv1.Machine {
+	return &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-machine-0",
+			Namespace: "default",
+			Labels:    map[string]string{clusterv1.ClusterLabelName: "my-cluster"},
+		},
+	}
+}
+
+func TestMachineScopeLoginPassword(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	passwordSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine-password", Namespace: "default"},
+		Data:       map[string][]byte{"pass": []byte("Secr3t-pass")},
+	}
+	client := fake.NewFakeClientWithScheme(scheme, passwordSecret)
+
+	loginPassword := func(spec infrav1.UCloudMachineSpec) (string, error) {
+		machineScope, err := NewMachineScope(MachineScopeParams{
+			Client:        client,
+			Logger:        klogr.New(),
+			Cluster:       &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"}},
+			Machine:       newTestMachine(),
+			UCloudCluster: &infrav1.UCloudCluster{},
+			UCloudMachine: &infrav1.UCloudMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "ucloudmy-machine-0", Namespace: "default"},
+				Spec:       spec,
+			},
+		})
+		g.Expect(err).NotTo(HaveOccurred())
+		return machineScope.LoginPassword()
+	}
+
+	// the secret reference takes precedence over the deprecated field
+	g.Expect(loginPassword(infrav1.UCloudMachineSpec{
+		SSHPassword: "UGFzc3cwcmQ=",
+		SSHPasswordSecretRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "machine-password"},
+			Key:                  "pass",
+		},
+	})).To(Equal("Secr3t-pass"))
+	g.Expect(loginPassword(infrav1.UCloudMachineSpec{SSHPassword: "UGFzc3cwcmQ="})).To(Equal("Passw0rd"))
+
+	_, err := loginPassword(infrav1.UCloudMachineSpec{
+		SSHPasswordSecretRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "machine-password"},
+			Key:                  "missing",
+		},
+	})
+	g.Expect(err).To(HaveOccurred())
+
+	// a generated password is stored and reused
+	generated, err := loginPassword(infrav1.UCloudMachineSpec{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(generated).To(HaveLen(16))
+	g.Expect(loginPassword(infrav1.UCloudMachineSpec{})).To(Equal(generated))
+}
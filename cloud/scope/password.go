@@ -0,0 +1,131 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"math/big"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PasswordSecretKey is the key of the generated password in its Secret.
+const PasswordSecretKey = "password"
+
+const (
+	passwordLength = 16
+
+	lowerChars   = "abcdefghijkmnopqrstuvwxyz"
+	upperChars   = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	digitChars   = "23456789"
+	specialChars = "-_+=.:"
+)
+
+// decodePassword decodes a base64 encoded password from a spec.
+func decodePassword(encoded string) (string, error) {
+	password, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", errors.Wrap(err, "sshPassword is not a valid base64 string")
+	}
+	return string(password), nil
+}
+
+// passwordFromSecret returns the password stored under the key of the referenced Secret.
+func passwordFromSecret(c client.Client, namespace string, ref *corev1.SecretKeySelector) (string, error) {
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+	if err := c.Get(context.TODO(), key, secret); err != nil {
+		return "", errors.Wrapf(err, "failed to get password secret %s", key)
+	}
+	password, ok := secret.Data[ref.Key]
+	if !ok || len(password) == 0 {
+		return "", errors.Errorf("secret %s has no %s key", key, ref.Key)
+	}
+	return string(password), nil
+}
+
+// generatedPassword returns the password stored in the Secret name. If the Secret
+// doesn't exist, a random password is generated and stored in a new Secret owned by
+// owner, so that the password stays the same across reconciles and is deleted with owner.
+func generatedPassword(c client.Client, namespace, name string, owner metav1.OwnerReference) (string, error) {
+	ctx := context.TODO()
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	err := c.Get(ctx, key, secret)
+	if err == nil {
+		password, ok := secret.Data[PasswordSecretKey]
+		if !ok {
+			return "", errors.Errorf("secret %s has no %s key", key, PasswordSecretKey)
+		}
+		return string(password), nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return "", errors.Wrapf(err, "failed to get password secret %s", key)
+	}
+
+	password, err := randomPassword()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate password")
+	}
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       namespace,
+			Name:            name,
+			OwnerReferences: []metav1.OwnerReference{owner},
+		},
+		Data: map[string][]byte{PasswordSecretKey: []byte(password)},
+	}
+	if err := c.Create(ctx, secret); err != nil {
+		return "", errors.Wrapf(err, "failed to create password secret %s", key)
+	}
+	return password, nil
+}
+
+// randomPassword returns a password that meets the UCloud password rules, it has
+// lower and upper case letters, digits and special characters.
+func randomPassword() (string, error) {
+	sets := []string{lowerChars, upperChars, digitChars, specialChars}
+	all := lowerChars + upperChars + digitChars + specialChars
+	password := make([]byte, passwordLength)
+	for i := range password {
+		chars := all
+		if i < len(sets) {
+			chars = sets[i]
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return "", err
+		}
+		password[i] = chars[n.Int64()]
+	}
+	// move the required characters away from the start
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+	return string(password), nil
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+)
+
+// defaultChargeType is the charge type of resources whose spec doesn't set one.
+const defaultChargeType = "Month"
+
+// chargeType returns the charge type of a spec.
+func chargeType(charge infrav1.ChargeSpec) string {
+	if charge.ChargeType == "" {
+		return defaultChargeType
+	}
+	return charge.ChargeType
+}
+
+// chargeQuantity returns the number of periods to pay for in advance.
+func chargeQuantity(charge infrav1.ChargeSpec) int {
+	if charge.Quantity == 0 {
+		return 1
+	}
+	return charge.Quantity
+}
+
+// ExpireTime converts the expiry of a resource reported by the API for the status.
+// It is nil for resources paid by the hour, whose expiry is only when the next
+// hour is charged.
+func ExpireTime(chargeType string, expireTime int) *metav1.Time {
+	if expireTime <= 0 || chargeType == "Dynamic" || chargeType == "Postpay" {
+		return nil
+	}
+	t := metav1.Unix(int64(expireTime), 0)
+	return &t
+}
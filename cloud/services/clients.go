@@ -0,0 +1,304 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"time"
+
+	"github.com/ucloud/ucloud-sdk-go/services/udisk"
+	"github.com/ucloud/ucloud-sdk-go/services/uhost"
+	"github.com/ucloud/ucloud-sdk-go/services/ulb"
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
+	"github.com/ucloud/ucloud-sdk-go/services/uphost"
+	"github.com/ucloud/ucloud-sdk-go/services/vpc"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/request"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/response"
+)
+
+// UHostAPI is the part of the UHost API used by the services.
+type UHostAPI interface {
+	// CreateUHostInstancePlus creates an instance with the fields missing from the sdk request, such as KeyPairId.
+	CreateUHostInstancePlus(req *CreateUHostInstanceRequestPlus) (*uhost.CreateUHostInstanceResponse, error)
+	NewDescribeUHostInstanceRequest() *uhost.DescribeUHostInstanceRequest
+	DescribeUHostInstance(req *uhost.DescribeUHostInstanceRequest) (*uhost.DescribeUHostInstanceResponse, error)
+	NewPoweroffUHostInstanceRequest() *uhost.PoweroffUHostInstanceRequest
+	PoweroffUHostInstance(req *uhost.PoweroffUHostInstanceRequest) (*uhost.PoweroffUHostInstanceResponse, error)
+	NewTerminateUHostInstanceRequest() *uhost.TerminateUHostInstanceRequest
+	TerminateUHostInstance(req *uhost.TerminateUHostInstanceRequest) (*uhost.TerminateUHostInstanceResponse, error)
+	NewDescribeImageRequest() *uhost.DescribeImageRequest
+	DescribeImage(req *uhost.DescribeImageRequest) (*uhost.DescribeImageResponse, error)
+}
+
+// VPCAPI is the part of the VPC API used by the services.
+type VPCAPI interface {
+	NewCreateVPCRequest() *vpc.CreateVPCRequest
+	CreateVPC(req *vpc.CreateVPCRequest) (*vpc.CreateVPCResponse, error)
+	NewDescribeVPCRequest() *vpc.DescribeVPCRequest
+	DescribeVPC(req *vpc.DescribeVPCRequest) (*vpc.DescribeVPCResponse, error)
+	NewDeleteVPCRequest() *vpc.DeleteVPCRequest
+	DeleteVPC(req *vpc.DeleteVPCRequest) (*vpc.DeleteVPCResponse, error)
+	NewCreateSubnetRequest() *vpc.CreateSubnetRequest
+	CreateSubnet(req *vpc.CreateSubnetRequest) (*vpc.CreateSubnetResponse, error)
+	NewDescribeSubnetRequest() *vpc.DescribeSubnetRequest
+	DescribeSubnet(req *vpc.DescribeSubnetRequest) (*vpc.DescribeSubnetResponse, error)
+	NewDeleteSubnetRequest() *vpc.DeleteSubnetRequest
+	DeleteSubnet(req *vpc.DeleteSubnetRequest) (*vpc.DeleteSubnetResponse, error)
+	NewCreateNATGWRequest() *vpc.CreateNATGWRequest
+	CreateNATGW(req *vpc.CreateNATGWRequest) (*vpc.CreateNATGWResponse, error)
+	NewDescribeNATGWRequest() *vpc.DescribeNATGWRequest
+	DescribeNATGW(req *vpc.DescribeNATGWRequest) (*vpc.DescribeNATGWResponse, error)
+	NewDeleteNATGWRequest() *vpc.DeleteNATGWRequest
+	DeleteNATGW(req *vpc.DeleteNATGWRequest) (*vpc.DeleteNATGWResponse, error)
+	NewUpdateNATGWSubnetRequest() *vpc.UpdateNATGWSubnetRequest
+	UpdateNATGWSubnet(req *vpc.UpdateNATGWSubnetRequest) (*vpc.UpdateNATGWSubnetResponse, error)
+}
+
+// UNetAPI is the part of the UNet API used by the services.
+type UNetAPI interface {
+	NewAllocateEIPRequest() *unet.AllocateEIPRequest
+	AllocateEIP(req *unet.AllocateEIPRequest) (*unet.AllocateEIPResponse, error)
+	NewReleaseEIPRequest() *unet.ReleaseEIPRequest
+	ReleaseEIP(req *unet.ReleaseEIPRequest) (*unet.ReleaseEIPResponse, error)
+	NewBindEIPRequest() *unet.BindEIPRequest
+	BindEIP(req *unet.BindEIPRequest) (*unet.BindEIPResponse, error)
+	NewUnBindEIPRequest() *unet.UnBindEIPRequest
+	UnBindEIP(req *unet.UnBindEIPRequest) (*unet.UnBindEIPResponse, error)
+	NewDescribeEIPRequest() *unet.DescribeEIPRequest
+	DescribeEIP(req *unet.DescribeEIPRequest) (*unet.DescribeEIPResponse, error)
+	NewDescribeFirewallRequest() *unet.DescribeFirewallRequest
+	DescribeFirewall(req *unet.DescribeFirewallRequest) (*unet.DescribeFirewallResponse, error)
+	NewCreateFirewallRequest() *unet.CreateFirewallRequest
+	CreateFirewall(req *unet.CreateFirewallRequest) (*unet.CreateFirewallResponse, error)
+	NewUpdateFirewallRequest() *unet.UpdateFirewallRequest
+	UpdateFirewall(req *unet.UpdateFirewallRequest) (*unet.UpdateFirewallResponse, error)
+	NewDeleteFirewallRequest() *unet.DeleteFirewallRequest
+	DeleteFirewall(req *unet.DeleteFirewallRequest) (*unet.DeleteFirewallResponse, error)
+	NewGrantFirewallRequest() *unet.GrantFirewallRequest
+	GrantFirewall(req *unet.GrantFirewallRequest) (*unet.GrantFirewallResponse, error)
+}
+
+// ULBAPI is the part of the ULB API used by the services.
+type ULBAPI interface {
+	// CreateULBPlus creates a ULB with the fields missing from the sdk request, such as Tag.
+	CreateULBPlus(req *CreateULBRequestPlus) (*ulb.CreateULBResponse, error)
+	NewDescribeULBRequest() *ulb.DescribeULBRequest
+	DescribeULB(req *ulb.DescribeULBRequest) (*ulb.DescribeULBResponse, error)
+	NewDeleteULBRequest() *ulb.DeleteULBRequest
+	DeleteULB(req *ulb.DeleteULBRequest) (*ulb.DeleteULBResponse, error)
+	NewCreateVServerRequest() *ulb.CreateVServerRequest
+	CreateVServer(req *ulb.CreateVServerRequest) (*ulb.CreateVServerResponse, error)
+	NewDescribeVServerRequest() *ulb.DescribeVServerRequest
+	DescribeVServer(req *ulb.DescribeVServerRequest) (*ulb.DescribeVServerResponse, error)
+	NewUpdateVServerAttributeRequest() *ulb.UpdateVServerAttributeRequest
+	UpdateVServerAttribute(req *ulb.UpdateVServerAttributeRequest) (*ulb.UpdateVServerAttributeResponse, error)
+	NewAllocateBackendRequest() *ulb.AllocateBackendRequest
+	AllocateBackend(req *ulb.AllocateBackendRequest) (*ulb.AllocateBackendResponse, error)
+	NewReleaseBackendRequest() *ulb.ReleaseBackendRequest
+	ReleaseBackend(req *ulb.ReleaseBackendRequest) (*ulb.ReleaseBackendResponse, error)
+	NewUpdateBackendAttributeRequest() *ulb.UpdateBackendAttributeRequest
+	UpdateBackendAttribute(req *ulb.UpdateBackendAttributeRequest) (*ulb.UpdateBackendAttributeResponse, error)
+}
+
+// UDiskAPI is the part of the UDisk API used by the services.
+type UDiskAPI interface {
+	NewDescribeUDiskRequest() *udisk.DescribeUDiskRequest
+	DescribeUDisk(req *udisk.DescribeUDiskRequest) (*udisk.DescribeUDiskResponse, error)
+}
+
+// UPHostAPI is the part of the UPHost API used by the services.
+type UPHostAPI interface {
+	// CreatePHostPlus creates a bare-metal machine with the fields missing from the sdk request, such as UserData.
+	CreatePHostPlus(req *CreatePHostRequestPlus) (*uphost.CreatePHostResponse, error)
+	NewDescribePHostRequest() *uphost.DescribePHostRequest
+	DescribePHost(req *uphost.DescribePHostRequest) (*uphost.DescribePHostResponse, error)
+	NewPoweroffPHostRequest() *uphost.PoweroffPHostRequest
+	PoweroffPHost(req *uphost.PoweroffPHostRequest) (*uphost.PoweroffPHostResponse, error)
+	NewTerminatePHostRequest() *uphost.TerminatePHostRequest
+	TerminatePHost(req *uphost.TerminatePHostRequest) (*uphost.TerminatePHostResponse, error)
+}
+
+// UK8SAPI is the UK8S API managing the clusters and hosts created by cluster-api.
+type UK8SAPI interface {
+	CreateCAPUCluster(req *CreateCAPUClusterRequest) (*CreateCAPUClusterResponse, error)
+	DeleteCAPUCluster(req *DeleteCAPUClusterRequest) (*DeleteCAPUClusterResponse, error)
+	CreateCAPUHost(req *CreateCAPUHostRequest) (*CreateCAPUHostResponse, error)
+	DeleteCAPUHost(req *DeleteCAPUHostRequest) (*DeleteCAPUHostResponse, error)
+}
+
+// BusinessGroupAPI is the API managing the business groups resources are organized in.
+type BusinessGroupAPI interface {
+	ListBusinessGroup(req *ListBusinessGroupRequest) (*ListBusinessGroupResponse, error)
+	CreateBusinessGroup(req *CreateBusinessGroupRequest) (*CreateBusinessGroupResponse, error)
+	DeleteBusinessGroup(req *DeleteBusinessGroupRequest) (*DeleteBusinessGroupResponse, error)
+	SearchBusinessGroupResource(req *SearchBusinessGroupResourceRequest) (*SearchBusinessGroupResourceResponse, error)
+}
+
+// KeyPairAPI is the API managing the key pairs instances are logged in to with.
+type KeyPairAPI interface {
+	ImportUHostKeyPairs(req *ImportUHostKeyPairsRequest) (*ImportUHostKeyPairsResponse, error)
+	DescribeUHostKeyPairs(req *DescribeUHostKeyPairsRequest) (*DescribeUHostKeyPairsResponse, error)
+	DeleteUHostKeyPairs(req *DeleteUHostKeyPairsRequest) (*DeleteUHostKeyPairsResponse, error)
+}
+
+// Clients holds the UCloud API implementations used by a Service.
+// Nil fields are filled in with the sdk based implementations by NewServiceWithClients.
+type Clients struct {
+	UHost         UHostAPI
+	VPC           VPCAPI
+	UNet          UNetAPI
+	ULB           ULBAPI
+	UDisk         UDiskAPI
+	UPHost        UPHostAPI
+	UK8S          UK8SAPI
+	BusinessGroup BusinessGroupAPI
+	KeyPair       KeyPairAPI
+}
+
+// sdkUHostClient adds the actions sent through doRequest to the sdk UHost client.
+type sdkUHostClient struct {
+	*uhost.UHostClient
+	actions *actionClient
+}
+
+// CreateUHostInstancePlus implements UHostAPI.
+func (c *sdkUHostClient) CreateUHostInstancePlus(req *CreateUHostInstanceRequestPlus) (*uhost.CreateUHostInstanceResponse, error) {
+	var res uhost.CreateUHostInstanceResponse
+	reqCopier := *req
+	if reqCopier.Password != nil {
+		reqCopier.Password = request.ToBase64Query(reqCopier.Password)
+	}
+	err := c.actions.invoke("CreateUHostInstance", &reqCopier, &res)
+	return &res, err
+}
+
+// sdkUPHostClient adds the actions sent through doRequest to the sdk UPHost client.
+type sdkUPHostClient struct {
+	*uphost.UPHostClient
+	actions *actionClient
+}
+
+// CreatePHostPlus implements UPHostAPI.
+func (c *sdkUPHostClient) CreatePHostPlus(req *CreatePHostRequestPlus) (*uphost.CreatePHostResponse, error) {
+	var res uphost.CreatePHostResponse
+	reqCopier := *req
+	if reqCopier.Password != nil {
+		reqCopier.Password = request.ToBase64Query(reqCopier.Password)
+	}
+	err := c.actions.invoke("CreatePHost", &reqCopier, &res)
+	return &res, err
+}
+
+// sdkULBClient adds the actions sent through doRequest to the sdk ULB client.
+type sdkULBClient struct {
+	*ulb.ULBClient
+	actions *actionClient
+}
+
+// CreateULBPlus implements ULBAPI.
+func (c *sdkULBClient) CreateULBPlus(req *CreateULBRequestPlus) (*ulb.CreateULBResponse, error) {
+	var res ulb.CreateULBResponse
+	err := c.actions.invoke("CreateULB", req, &res)
+	return &res, err
+}
+
+// actionClient sends the actions that are missing from the sdk through doRequest.
+type actionClient struct {
+	s *Service
+}
+
+func (c *actionClient) invoke(action string, req request.Common, res response.Common) error {
+	req.SetAction(action)
+	req.SetRequestTime(time.Now())
+	return c.s.doRequest(req, res)
+}
+
+// CreateCAPUCluster implements UK8SAPI.
+func (c *actionClient) CreateCAPUCluster(req *CreateCAPUClusterRequest) (*CreateCAPUClusterResponse, error) {
+	var res CreateCAPUClusterResponse
+	err := c.invoke("CreateCAPUCluster", req, &res)
+	return &res, err
+}
+
+// DeleteCAPUCluster implements UK8SAPI.
+func (c *actionClient) DeleteCAPUCluster(req *DeleteCAPUClusterRequest) (*DeleteCAPUClusterResponse, error) {
+	var res DeleteCAPUClusterResponse
+	err := c.invoke("DeleteCAPUCluster", req, &res)
+	return &res, err
+}
+
+// CreateCAPUHost implements UK8SAPI.
+func (c *actionClient) CreateCAPUHost(req *CreateCAPUHostRequest) (*CreateCAPUHostResponse, error) {
+	var res CreateCAPUHostResponse
+	err := c.invoke("CreateCAPUHost", req, &res)
+	return &res, err
+}
+
+// DeleteCAPUHost implements UK8SAPI.
+func (c *actionClient) DeleteCAPUHost(req *DeleteCAPUHostRequest) (*DeleteCAPUHostResponse, error) {
+	var res DeleteCAPUHostResponse
+	err := c.invoke("DeleteCAPUHost", req, &res)
+	return &res, err
+}
+
+// ListBusinessGroup implements BusinessGroupAPI.
+func (c *actionClient) ListBusinessGroup(req *ListBusinessGroupRequest) (*ListBusinessGroupResponse, error) {
+	var res ListBusinessGroupResponse
+	err := c.invoke("ListBusinessGroup", req, &res)
+	return &res, err
+}
+
+// CreateBusinessGroup implements BusinessGroupAPI.
+func (c *actionClient) CreateBusinessGroup(req *CreateBusinessGroupRequest) (*CreateBusinessGroupResponse, error) {
+	var res CreateBusinessGroupResponse
+	err := c.invoke("CreateBusinessGroup", req, &res)
+	return &res, err
+}
+
+// DeleteBusinessGroup implements BusinessGroupAPI.
+func (c *actionClient) DeleteBusinessGroup(req *DeleteBusinessGroupRequest) (*DeleteBusinessGroupResponse, error) {
+	var res DeleteBusinessGroupResponse
+	err := c.invoke("DeleteBusinessGroup", req, &res)
+	return &res, err
+}
+
+// SearchBusinessGroupResource implements BusinessGroupAPI.
+func (c *actionClient) SearchBusinessGroupResource(req *SearchBusinessGroupResourceRequest) (*SearchBusinessGroupResourceResponse, error) {
+	var res SearchBusinessGroupResourceResponse
+	err := c.invoke("SearchBusinessGroupResource", req, &res)
+	return &res, err
+}
+
+// ImportUHostKeyPairs implements KeyPairAPI.
+func (c *actionClient) ImportUHostKeyPairs(req *ImportUHostKeyPairsRequest) (*ImportUHostKeyPairsResponse, error) {
+	var res ImportUHostKeyPairsResponse
+	err := c.invoke("ImportUHostKeyPairs", req, &res)
+	return &res, err
+}
+
+// DescribeUHostKeyPairs implements KeyPairAPI.
+func (c *actionClient) DescribeUHostKeyPairs(req *DescribeUHostKeyPairsRequest) (*DescribeUHostKeyPairsResponse, error) {
+	var res DescribeUHostKeyPairsResponse
+	err := c.invoke("DescribeUHostKeyPairs", req, &res)
+	return &res, err
+}
+
+// DeleteUHostKeyPairs implements KeyPairAPI.
+func (c *actionClient) DeleteUHostKeyPairs(req *DeleteUHostKeyPairsRequest) (*DeleteUHostKeyPairsResponse, error) {
+	var res DeleteUHostKeyPairsResponse
+	err := c.invoke("DeleteUHostKeyPairs", req, &res)
+	return &res, err
+}
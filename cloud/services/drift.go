@@ -0,0 +1,58 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+)
+
+// Drift is a difference between a network resource recorded in the status of the
+// cluster and the resource in UCloud, e.g. a NAT gateway deleted in the console.
+type Drift struct {
+	// Resource is the kind and id of the resource, e.g. "nat gateway natgw-xxx".
+	Resource string
+	// Message describes the difference, and how it was repaired.
+	Message string
+	// Repaired is true if the resource was recreated or repaired. Resources that are
+	// not created by cluster-api-provider-ucloud are only reported.
+	Repaired bool
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("%s %s", d.Resource, d.Message)
+}
+
+// Drifts returns the drifts found by the reconciles of this service.
+func (s *Service) Drifts() []Drift {
+	return s.drifts
+}
+
+// reportDrift records a drift of a network resource and emits an event for it.
+func (s *Service) reportDrift(resource, message string, repaired bool) {
+	drift := Drift{Resource: resource, Message: message, Repaired: repaired}
+	s.drifts = append(s.drifts, drift)
+	s.scope.Info("network resource drifted", "resource", resource, "message", message, "repaired", repaired)
+	if repaired {
+		record.Eventf(s.scope.UCloudCluster, infrav1.NetworkRepairedReason, "%s", drift)
+	} else {
+		record.Warnf(s.scope.UCloudCluster, infrav1.NetworkDriftedReason, "%s", drift)
+	}
+}
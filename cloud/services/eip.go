@@ -18,9 +18,12 @@ package services
 
 import (
 	"github.com/pkg/errors"
+	"github.com/ucloud/ucloud-sdk-go/services/uhost"
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
 	"github.com/ucloud/ucloud-sdk-go/ucloud"
 	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
 	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/scope"
 )
 
 func (s *Service) createEIP(eipSpec infrav1.EIPSpec) (eip infrav1.EIP, err error) {
@@ -29,7 +32,14 @@ func (s *Service) createEIP(eipSpec infrav1.EIPSpec) (eip infrav1.EIP, err error
 	req.Region = ucloud.String(s.scope.Region())
 	req.ProjectId = ucloud.String(s.scope.ProjectId())
 	req.Tag = ucloud.String(s.scope.GroupName())
-	req.OperatorName = ucloud.String(common.RegionEIPOperator[s.scope.Region()])
+	if eipSpec.OperatorName != "" {
+		req.OperatorName = ucloud.String(eipSpec.OperatorName)
+	} else {
+		req.OperatorName = ucloud.String(common.RegionEIPOperator[s.scope.Region()])
+	}
+	if eipSpec.PayMode != "" {
+		req.PayMode = ucloud.String(eipSpec.PayMode)
+	}
 	if eipSpec.Bandwidth != 0 {
 		req.Bandwidth = ucloud.Int(eipSpec.Bandwidth)
 	} else {
@@ -38,20 +48,51 @@ func (s *Service) createEIP(eipSpec infrav1.EIPSpec) (eip infrav1.EIP, err error
 	if eipSpec.EIPName != "" {
 		req.Name = ucloud.String(eipSpec.EIPName)
 	}
+	req.ChargeType = ucloud.String(chargeType(eipSpec.ChargeSpec))
+	req.Quantity = ucloud.Int(chargeQuantity(eipSpec.ChargeSpec))
 
 	eipInfo, err := s.unetClient.AllocateEIP(req)
 	if err != nil {
-		return infrav1.EIP{}, errors.Errorf("allocate eip failed: %s", err.Error())
+		return infrav1.EIP{}, errors.Wrap(err, "allocate eip failed")
 	}
 	newEIP := eipInfo.EIPSet[0]
 	eip.Bandwidth = ucloud.IntValue(req.Bandwidth)
 	eip.EIPId = newEIP.EIPId
 	eip.EIPAddr = newEIP.EIPAddr[0].IP
 	eip.EIPName = ucloud.StringValue(req.Name)
+	eip.ChargeType = ucloud.StringValue(req.ChargeType)
+	eip.PayMode = ucloud.StringValue(req.PayMode)
 	s.scope.Info("create eip success", "eipAddr", newEIP.EIPAddr, "eipId", newEIP.EIPId)
+
+	// the expiry is only known once the EIP is paid for
+	info, err := s.describeEIP(eip.EIPId)
+	if err != nil || info == nil {
+		s.scope.Info("describe new eip failed, its expiry is left unknown", "eipId", eip.EIPId, "error", err)
+		return eip, nil
+	}
+	eip.ExpireTime = ExpireTime(info.ChargeType, info.ExpireTime)
 	return eip, nil
 }
 
+// describeEIP returns the EIP with the given id, or nil if it doesn't exist.
+func (s *Service) describeEIP(eipId string) (*unet.UnetEIPSet, error) {
+	req := s.unetClient.NewDescribeEIPRequest()
+	req.Region = ucloud.String(s.scope.Region())
+	req.ProjectId = ucloud.String(s.scope.ProjectId())
+	req.EIPIds = []string{eipId}
+	res, err := s.unetClient.DescribeEIP(req)
+	if err != nil {
+		if common.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "describe eip %s failed", eipId)
+	}
+	if len(res.EIPSet) == 0 {
+		return nil, nil
+	}
+	return &res.EIPSet[0], nil
+}
+
 func (s *Service) deleteEIP(eipId string) error {
 	s.scope.Info("delete eip")
 	req := s.unetClient.NewReleaseEIPRequest()
@@ -60,7 +101,7 @@ func (s *Service) deleteEIP(eipId string) error {
 	req.EIPId = ucloud.String(eipId)
 	_, err := s.unetClient.ReleaseEIP(req)
 	if err != nil {
-		return errors.Errorf("release eip %s failed: %s", eipId, err.Error())
+		return errors.Wrapf(err, "release eip %s failed", eipId)
 	}
 	s.scope.Info("release eip success", "eipId", eipId)
 	return nil
@@ -76,7 +117,7 @@ func (s *Service) bindEIP(eipId, resourceId, resourceType string) error {
 	req.ResourceType = ucloud.String(resourceType)
 	_, err := s.unetClient.BindEIP(req)
 	if err != nil {
-		return errors.Errorf("bind eip %s with %s %s failed: %s", eipId, resourceType, resourceId, err.Error())
+		return errors.Wrapf(err, "bind eip %s with %s %s failed", eipId, resourceType, resourceId)
 	}
 	s.scope.Info("bind eip success", "eipId", eipId, "resourceType", resourceType, "resourceId", resourceId)
 	return nil
@@ -92,8 +133,90 @@ func (s *Service) unbindEIP(eipId, resourceId, resourceType string) error {
 	req.ResourceType = ucloud.String(resourceType)
 	_, err := s.unetClient.UnBindEIP(req)
 	if err != nil {
-		return errors.Errorf("unbind eip %s with %s %s failed: %s", eipId, resourceType, resourceId, err.Error())
+		return errors.Wrapf(err, "unbind eip %s with %s %s failed", eipId, resourceType, resourceId)
 	}
 	s.scope.Info("unbind eip success", "eipId", eipId, "resourceType", resourceType, "resourceId", resourceId)
 	return nil
 }
+
+// ReconcileMachineEIP binds an EIP to the instance of a machine that asks for a public
+// IP. The EIP is recorded in the status before it is bound, so that it is neither
+// allocated twice nor leaked if binding fails.
+func (s *Service) ReconcileMachineEIP(scope *scope.MachineScope, instance *uhost.UHostInstanceSet) error {
+	if !scope.PublicIP() {
+		return nil
+	}
+	eipSpec := infrav1.EIPSpec{}
+	if scope.UCloudMachine.Spec.EIP != nil {
+		eipSpec = *scope.UCloudMachine.Spec.EIP
+	}
+
+	if scope.UCloudMachine.Status.EIP == nil {
+		var eip infrav1.EIP
+		if eipSpec.EIPId != "" {
+			info, err := s.describeEIP(eipSpec.EIPId)
+			if err != nil {
+				return err
+			}
+			if info == nil {
+				return errors.Errorf("eip %s not found", eipSpec.EIPId)
+			}
+			eip = infrav1.EIP{
+				EIPId:      info.EIPId,
+				Bandwidth:  info.Bandwidth,
+				ChargeType: info.ChargeType,
+				PayMode:    info.PayMode,
+				EIPName:    info.Name,
+				ExpireTime: ExpireTime(info.ChargeType, info.ExpireTime),
+			}
+			if len(info.EIPAddr) > 0 {
+				eip.EIPAddr = info.EIPAddr[0].IP
+			}
+		} else {
+			if eipSpec.EIPName == "" {
+				eipSpec.EIPName = instanceName(scope)
+			}
+			var err error
+			eip, err = s.createEIP(eipSpec)
+			if err != nil {
+				return err
+			}
+		}
+		scope.UCloudMachine.Status.EIP = &eip
+	}
+
+	eipId := scope.UCloudMachine.Status.EIP.EIPId
+	for _, ip := range instance.IPSet {
+		if ip.IPId == eipId {
+			return nil
+		}
+	}
+	return s.bindEIP(eipId, instance.UHostId, instanceResourceType(scope))
+}
+
+// DeleteMachineEIP unbinds the EIP of a machine from its instance, and releases it
+// unless it was an existing EIP given by the spec.
+func (s *Service) DeleteMachineEIP(scope *scope.MachineScope) error {
+	eip := scope.UCloudMachine.Status.EIP
+	if eip == nil {
+		return nil
+	}
+	info, err := s.describeEIP(eip.EIPId)
+	if err != nil {
+		return err
+	}
+	if info != nil {
+		if id := scope.GetInstanceID(); id != nil && info.Resource.ResourceId == *id {
+			if err := s.unbindEIP(eip.EIPId, *id, instanceResourceType(scope)); err != nil {
+				return err
+			}
+		}
+		if spec := scope.UCloudMachine.Spec.EIP; spec == nil || spec.EIPId != eip.EIPId {
+			if err := s.deleteEIP(eip.EIPId); err != nil && !common.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	scope.UCloudMachine.Status.EIP = nil
+	return nil
+}
@@ -0,0 +1,800 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/ucloud/ucloud-sdk-go/services/uhost"
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/auth"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/klogr"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/services"
+	"sigs.k8s.io/cluster-api-provider-ucloud/test/fakeucloud"
+)
+
+// fakeUCloudFixture is a cluster whose services run against a fake UCloud API.
+type fakeUCloudFixture struct {
+	server        *fakeucloud.Server
+	client        client.Client
+	cluster       *clusterv1.Cluster
+	ucloudCluster *infrav1.UCloudCluster
+	clusterScope  *scope.ClusterScope
+	svc           *services.Service
+}
+
+// newFakeUCloudService returns the service of the cluster my-cluster with spec, in the
+// project org-test and the region cn-bj2 of a new fake UCloud API. The caller closes
+// the server. Changes to the cluster are seen by the service.
+func newFakeUCloudService(t *testing.T, spec infrav1.UCloudClusterSpec) *fakeUCloudFixture {
+	g := NewWithT(t)
+
+	server := fakeucloud.NewServer()
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	spec.ProjectId = "org-test"
+	spec.Region = "cn-bj2"
+	spec.Version = "1.18.3"
+	spec.API = &infrav1.APISpec{BaseURL: server.URL}
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"}}
+	cluster.Spec.ClusterNetwork = &clusterv1.ClusterNetwork{
+		Pods:     &clusterv1.NetworkRanges{CIDRBlocks: []string{"192.168.0.0/16"}},
+		Services: &clusterv1.NetworkRanges{CIDRBlocks: []string{"10.96.0.0/12"}},
+	}
+	ucloudCluster := &infrav1.UCloudCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"},
+		Spec:       spec,
+	}
+	kubeClient := fake.NewFakeClientWithScheme(scheme, cluster, ucloudCluster)
+	clusterScope, err := scope.NewClusterScope(scope.ClusterScopeParams{
+		UCloudClients: scope.UCloudClients{Credential: &auth.Credential{PublicKey: "public", PrivateKey: "private"}},
+		Client:        kubeClient,
+		Logger:        klogr.New(),
+		Cluster:       cluster,
+		UCloudCluster: ucloudCluster,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	return &fakeUCloudFixture{
+		server:        server,
+		client:        kubeClient,
+		cluster:       cluster,
+		ucloudCluster: ucloudCluster,
+		clusterScope:  clusterScope,
+		svc:           services.NewService(clusterScope),
+	}
+}
+
+// reconcileNetwork creates the network of the cluster up to the load balancer.
+func (f *fakeUCloudFixture) reconcileNetwork(g *WithT) {
+	g.Expect(f.svc.ReconcileUGroup()).To(Succeed())
+	g.Expect(f.svc.ReconcileVPC()).To(Succeed())
+	g.Expect(f.svc.ReconcileSubnet()).To(Succeed())
+	g.Expect(f.svc.ReconcileFirewall()).To(Succeed())
+	g.Expect(f.svc.ReconcileNat()).To(Succeed())
+	g.Expect(f.svc.ReconcileMachineFirewalls()).To(Succeed())
+	g.Expect(f.svc.ReconcileULB()).To(Succeed())
+}
+
+// deleteCluster deletes the cluster, and checks that nothing is left behind.
+func (f *fakeUCloudFixture) deleteCluster(g *WithT) {
+	for deleted := false; !deleted; {
+		var err error
+		deleted, err = f.svc.TerminateBastion()
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+	g.Expect(f.svc.DeleteULB()).To(Succeed())
+	g.Expect(f.svc.DeleteNat()).To(Succeed())
+	g.Expect(f.svc.CleanResourceInGroup()).To(BeTrue())
+	g.Expect(f.svc.DeleteMachineFirewalls()).To(Succeed())
+	g.Expect(f.svc.DeleteFirewall()).To(Succeed())
+	g.Expect(f.svc.DeleteKeyPairs()).To(Succeed())
+	g.Expect(f.svc.DeleteSubnet()).To(Succeed())
+	g.Expect(f.svc.DeleteVPC()).To(Succeed())
+	g.Expect(f.svc.DeleteGroup()).To(Succeed())
+	g.Expect(f.svc.DeleteCAPUCluster()).To(Succeed())
+	g.Expect(f.server.ResourceIds()).To(BeEmpty())
+}
+
+// newMachineScope adds the machine name of the cluster in the zone cn-bj2-02, with its
+// UCloudMachine and bootstrap data, and returns its scope.
+func (f *fakeUCloudFixture) newMachineScope(g *WithT, name string, controlPlane bool, spec infrav1.UCloudMachineSpec) *scope.MachineScope {
+	machine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{
+		Name:      name,
+		Namespace: "default",
+		Labels:    map[string]string{clusterv1.ClusterLabelName: "my-cluster"},
+	}}
+	if controlPlane {
+		machine.Labels[clusterv1.MachineControlPlaneLabelName] = ""
+	}
+	machine.Spec.Version = pointer.StringPtr("v1.18.3")
+	machine.Spec.FailureDomain = pointer.StringPtr("cn-bj2-02")
+	machine.Spec.Bootstrap.DataSecretName = pointer.StringPtr(name + "-bootstrap")
+	ucloudMachine := &infrav1.UCloudMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "ucloud" + name, Namespace: "default"},
+		Spec:       spec,
+	}
+	bootstrapData := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name + "-bootstrap", Namespace: "default"},
+		Data:       map[string][]byte{"value": []byte("#cloud-config")},
+	}
+	for _, obj := range []runtime.Object{machine, ucloudMachine, bootstrapData} {
+		g.Expect(f.client.Create(context.TODO(), obj)).To(Succeed())
+	}
+	machineScope, err := scope.NewMachineScope(scope.MachineScopeParams{
+		Client:        f.client,
+		Logger:        klogr.New(),
+		Cluster:       f.cluster,
+		Machine:       machine,
+		UCloudCluster: f.ucloudCluster,
+		UCloudMachine: ucloudMachine,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	return machineScope
+}
+
+// createRunningInstance creates the instance of a machine, and waits until it runs.
+func (f *fakeUCloudFixture) createRunningInstance(g *WithT, machineScope *scope.MachineScope) *uhost.UHostInstanceSet {
+	f.server.SetTransitionDelay(0)
+	instance, err := f.svc.CreateInstance(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	machineScope.UCloudMachine.Status.Zone = instance.Zone
+	providerID := "ucloud://org-test/" + instance.Zone + "/" + instance.UHostId
+	if machineScope.InstanceKind() == infrav1.UPHostInstanceKind {
+		providerID = "ucloud://org-test/" + instance.Zone + "/uphost/" + instance.UHostId
+	}
+	machineScope.SetProviderID(providerID)
+	instance, err = f.svc.InstanceIfExists(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instance.State).To(Equal("Running"))
+	return instance
+}
+
+// terminateInstance deletes the instance of a machine, each call takes one step: power
+// off, terminate, then observe the instance is gone.
+func (f *fakeUCloudFixture) terminateInstance(g *WithT, machineScope *scope.MachineScope) {
+	for i := 0; i < 2; i++ {
+		g.Expect(f.svc.TerminateInstance(machineScope)).To(BeFalse())
+	}
+	g.Expect(f.svc.TerminateInstance(machineScope)).To(BeTrue())
+}
+
+// TestClusterAgainstFakeUCloud runs the cluster create and delete flows of the services
+// against the fake UCloud API.
+func TestClusterAgainstFakeUCloud(t *testing.T) {
+	g := NewWithT(t)
+	f := newFakeUCloudService(t, infrav1.UCloudClusterSpec{})
+	defer f.server.Close()
+
+	// a transient failure of a read-only action is retried
+	f.server.InjectStatus("DescribeVPC", http.StatusServiceUnavailable, 1)
+	f.reconcileNetwork(g)
+	g.Expect(f.svc.CreateCAPUCluster()).To(Succeed())
+	g.Expect(f.server.Requests("DescribeVPC")).To(Equal(2))
+
+	network := f.ucloudCluster.Status.Network
+	g.Expect(network.VPC.VpcId).NotTo(BeEmpty())
+	g.Expect(network.Subnet.CidrBlock).To(Equal("10.0.0.0/24"))
+	g.Expect(network.Nat.NatGatewayId).NotTo(BeEmpty())
+	g.Expect(network.Nat.EIP.EIPId).NotTo(BeEmpty())
+	g.Expect(network.ULB.EIP.EIPAddr).NotTo(BeEmpty())
+	g.Expect(network.ULB.FrontendPort).To(Equal(6443))
+	g.Expect(f.ucloudCluster.Status.ClusterId).NotTo(BeEmpty())
+
+	// reconciling again changes nothing
+	f.reconcileNetwork(g)
+	g.Expect(f.ucloudCluster.Status.Network).To(Equal(network))
+	g.Expect(f.server.Requests("AllocateEIP")).To(Equal(2))
+	g.Expect(f.svc.Drifts()).To(BeEmpty())
+
+	f.deleteCluster(g)
+}
+
+// TestFirewallsAgainstFakeUCloud checks the firewall of the cluster and the firewalls
+// machines select by tag.
+func TestFirewallsAgainstFakeUCloud(t *testing.T) {
+	g := NewWithT(t)
+	f := newFakeUCloudService(t, infrav1.UCloudClusterSpec{
+		Network: infrav1.NetworkSpec{MachineFirewalls: []infrav1.MachineFirewallSpec{
+			{Tag: "control-plane", FirewallSpec: infrav1.FirewallSpec{Rules: []*infrav1.FirewallRuleSpec{
+				{IpProtocol: "tcp", PortRange: "6443/6443"},
+				{IpProtocol: "tcp", PortRange: "22/22", SourceCidrIp: "10.0.0.0/16", Description: "ssh"},
+			}}},
+			{Tag: "worker", FirewallSpec: infrav1.FirewallSpec{Rules: []*infrav1.FirewallRuleSpec{
+				{IpProtocol: "tcp", PortRange: "30000/32767"},
+			}}},
+		}},
+	})
+	defer f.server.Close()
+	server, ucloudCluster, svc := f.server, f.ucloudCluster, f.svc
+	f.reconcileNetwork(g)
+
+	// the cluster owns a firewall with the default rules, used by the NAT gateway
+	network := ucloudCluster.Status.Network
+	clusterFirewall := network.Firewall.FirewallId
+	g.Expect(clusterFirewall).NotTo(Equal(fakeucloud.DefaultFirewallId))
+	g.Expect(network.Firewall.Rules).To(ConsistOf("TCP|22|0.0.0.0/0|ACCEPT|MEDIUM|ssh", "TCP|6443|0.0.0.0/0|ACCEPT|MEDIUM|apiserver", "ICMP||0.0.0.0/0|ACCEPT|MEDIUM|ping"))
+	g.Expect(network.Nat.Firewall.FirewallId).To(Equal(clusterFirewall))
+	g.Expect(server.FirewallOf(network.Nat.NatGatewayId)).To(Equal(clusterFirewall))
+	workerFirewall := network.MachineFirewalls["worker"].FirewallId
+	g.Expect(network.MachineFirewalls["control-plane"].FirewallId).NotTo(BeEmpty())
+	g.Expect(workerFirewall).NotTo(BeEmpty())
+
+	// the rules of the firewalls owned by the cluster follow the spec
+	ucloudCluster.Spec.Network.MachineFirewalls[1].Rules[0].PortRange = "30000/30100"
+	g.Expect(svc.ReconcileMachineFirewalls()).To(Succeed())
+	g.Expect(svc.ReconcileMachineFirewalls()).To(Succeed())
+	g.Expect(server.Requests("UpdateFirewall")).To(Equal(1))
+	firewall, _ := server.Firewall(workerFirewall)
+	g.Expect(firewall.Rule).To(ConsistOf(unet.FirewallRuleSet{ProtocolType: "TCP", DstPort: "30000-30100", SrcIP: "0.0.0.0/0", RuleAction: "ACCEPT", Priority: "MEDIUM"}))
+	ucloudCluster.Spec.Network.Firewall.Rules = []*infrav1.FirewallRuleSpec{{IpProtocol: "tcp", PortRange: "6443/6443", SourceCidrIp: "10.0.0.0/8", Policy: "drop"}}
+	g.Expect(svc.ReconcileFirewall()).To(Succeed())
+	g.Expect(server.Requests("UpdateFirewall")).To(Equal(2))
+	g.Expect(ucloudCluster.Status.Network.Firewall.FirewallId).To(Equal(clusterFirewall))
+	g.Expect(ucloudCluster.Status.Network.Firewall.Rules).To(ConsistOf("TCP|6443|10.0.0.0/8|DROP|MEDIUM|"))
+	firewall, _ = server.Firewall(clusterFirewall)
+	g.Expect(firewall.Rule).To(ConsistOf(unet.FirewallRuleSet{ProtocolType: "TCP", DstPort: "6443", SrcIP: "10.0.0.0/8", RuleAction: "DROP", Priority: "MEDIUM"}))
+
+	f.deleteCluster(g)
+}
+
+// TestChargeAgainstFakeUCloud checks the charge types and expiry of the resources of a
+// cluster.
+func TestChargeAgainstFakeUCloud(t *testing.T) {
+	g := NewWithT(t)
+	f := newFakeUCloudService(t, infrav1.UCloudClusterSpec{
+		Network: infrav1.NetworkSpec{
+			ULB: infrav1.ULBSpec{EIP: infrav1.EIPSpec{ChargeSpec: infrav1.ChargeSpec{ChargeType: "Year", Quantity: 2}}},
+		},
+	})
+	defer f.server.Close()
+	f.reconcileNetwork(g)
+
+	ulb := f.ucloudCluster.Status.Network.ULB
+	g.Expect(ulb.ChargeType).To(Equal("Month"))
+	g.Expect(ulb.ExpireTime).NotTo(BeNil())
+	g.Expect(ulb.EIP.ChargeType).To(Equal("Year"))
+	g.Expect(ulb.EIP.ExpireTime.Time).To(BeTemporally(">", time.Now().AddDate(1, 11, 0)))
+
+	machineScope := f.newMachineScope(g, "my-machine-0", true, infrav1.UCloudMachineSpec{
+		ImageId:    pointer.StringPtr("uimage-test"),
+		ChargeSpec: infrav1.ChargeSpec{ChargeType: "Dynamic"},
+	})
+	instance := f.createRunningInstance(g, machineScope)
+	g.Expect(instance.ChargeType).To(Equal("Dynamic"))
+}
+
+// TestBastionAgainstFakeUCloud checks that the bastion gets a generated password, which
+// is kept in a secret.
+func TestBastionAgainstFakeUCloud(t *testing.T) {
+	g := NewWithT(t)
+	f := newFakeUCloudService(t, infrav1.UCloudClusterSpec{Bastion: infrav1.BastionSpec{Enabled: true}})
+	defer f.server.Close()
+	f.reconcileNetwork(g)
+
+	g.Expect(f.svc.CreateBastionInstance()).To(Succeed())
+	g.Expect(f.svc.CreateBastionInstance()).To(Succeed())
+	g.Expect(f.server.Requests("CreateUHostInstance")).To(Equal(1))
+	bastion := f.ucloudCluster.Status.Bastion
+	g.Expect(bastion).NotTo(BeNil())
+	g.Expect(bastion.ChargeType).To(Equal("Month"))
+	g.Expect(bastion.ExpireTime).NotTo(BeNil())
+	passwordSecret := &corev1.Secret{}
+	g.Expect(f.client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "my-cluster-bastion-ssh-password"}, passwordSecret)).To(Succeed())
+	g.Expect(passwordSecret.Data[scope.PasswordSecretKey]).To(HaveLen(16))
+
+	f.deleteCluster(g)
+}
+
+// TestImageLookupAgainstFakeUCloud checks that the newest available image matching the
+// lookup and the Kubernetes version is used.
+func TestImageLookupAgainstFakeUCloud(t *testing.T) {
+	g := NewWithT(t)
+	f := newFakeUCloudService(t, infrav1.UCloudClusterSpec{})
+	defer f.server.Close()
+	f.reconcileNetwork(g)
+
+	for _, image := range []uhost.UHostImageSet{
+		{ImageId: "uimage-old", ImageName: "k8s-v1.18.3", CreateTime: 1},
+		{ImageId: "uimage-new", ImageName: "k8s-ubuntu", ImageDescription: "kubernetes 1.18.3", CreateTime: 2},
+		{ImageId: "uimage-other-version", ImageName: "k8s-v1.18.30", CreateTime: 3},
+		{ImageId: "uimage-creating", ImageName: "k8s-v1.18.3", CreateTime: 4, State: "Making"},
+		{ImageId: "uimage-other-zone", ImageName: "k8s-v1.18.3", CreateTime: 5, Zone: "cn-bj2-03"},
+	} {
+		image.ImageType, image.OsType = "Custom", "Linux"
+		if image.State == "" {
+			image.State = "Available"
+		}
+		if image.Zone == "" {
+			image.Zone = "cn-bj2-02"
+		}
+		f.server.AddImage(image)
+	}
+
+	machineScope := f.newMachineScope(g, "my-machine-0", true, infrav1.UCloudMachineSpec{
+		ImageLookup: &infrav1.ImageLookupSpec{NamePattern: "^k8s-", OsType: "Linux", MatchKubernetesVersion: true},
+	})
+	instance := f.createRunningInstance(g, machineScope)
+	g.Expect(instance.ImageId).To(Equal("uimage-new"))
+}
+
+// TestInstanceAgainstFakeUCloud runs the create and delete flows of a control plane
+// instance against the fake UCloud API.
+func TestInstanceAgainstFakeUCloud(t *testing.T) {
+	g := NewWithT(t)
+	f := newFakeUCloudService(t, infrav1.UCloudClusterSpec{
+		Network: infrav1.NetworkSpec{MachineFirewalls: []infrav1.MachineFirewallSpec{
+			{Tag: "control-plane", FirewallSpec: infrav1.FirewallSpec{Rules: []*infrav1.FirewallRuleSpec{{IpProtocol: "tcp", PortRange: "6443/6443"}}}},
+		}},
+	})
+	defer f.server.Close()
+	server, svc := f.server, f.svc
+	f.reconcileNetwork(g)
+	g.Expect(svc.CreateCAPUCluster()).To(Succeed())
+	network := f.ucloudCluster.Status.Network
+
+	machineScope := f.newMachineScope(g, "my-machine-0", true, infrav1.UCloudMachineSpec{
+		ImageId:               pointer.StringPtr("uimage-test"),
+		Disks:                 []infrav1.DiskSpec{{Type: "CLOUD_RSSD", Size: 100, KmsKeyId: "kms-test"}},
+		AdditionalNetworkTags: []string{"control-plane"},
+		SSHKey:                &infrav1.SSHKeySpec{PublicKey: "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIBfAke0ZcwMh5N6JmAb3nVmixZjhA0Vx7Hn1tyPwjkSy test"},
+	})
+
+	// creating an instance does not wait for it to start
+	server.SetTransitionDelay(time.Hour)
+	instance, err := svc.CreateInstance(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instance.State).To(Equal("Initializing"))
+	g.Expect(instance.Zone).To(Equal("cn-bj2-02"))
+	g.Expect(server.FirewallOf(instance.UHostId)).To(Equal(network.MachineFirewalls["control-plane"].FirewallId))
+	g.Expect(server.SubnetOf(instance.UHostId)).To(Equal(network.Subnet.SubnetId))
+	g.Expect(server.Requests("ImportUHostKeyPairs")).To(Equal(1))
+	// a boot disk is added to the disks of the spec
+	g.Expect(instance.DiskSet).To(HaveLen(2))
+	g.Expect(instance.DiskSet[0].IsBoot).To(Equal("true"))
+	g.Expect(instance.DiskSet[0].Size).To(Equal(40))
+	g.Expect(instance.DiskSet[1].Type).To(Equal("CLOUD_RSSD"))
+	g.Expect(instance.DiskSet[1].Encrypted).To(Equal("true"))
+	machineScope.UCloudMachine.Status.Zone = instance.Zone
+	machineScope.SetProviderID("ucloud://org-test/" + instance.Zone + "/" + instance.UHostId)
+
+	server.SetTransitionDelay(0)
+	instance, err = svc.InstanceIfExists(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instance.State).To(Equal("Running"))
+
+	// a control plane instance is a backend of the api server on the backend port
+	g.Expect(svc.AddRealServer(instance.UHostId)).To(Succeed())
+	g.Expect(svc.AddRealServer(instance.UHostId)).To(Succeed())
+	vserver, _ := server.VServer(network.ULB.LoadBalancerId, network.ULB.VServerId)
+	g.Expect(vserver.BackendSet).To(HaveLen(1))
+	g.Expect(vserver.BackendSet[0].Port).To(Equal(6443))
+	g.Expect(svc.CreateCAPUHost(machineScope)).To(Succeed())
+
+	g.Expect(svc.DelRealServer(instance.UHostId)).To(Succeed())
+	g.Expect(svc.DeleteCAPUHost(machineScope)).To(Succeed())
+	f.terminateInstance(g, machineScope)
+	_, exists := server.UHost(instance.UHostId)
+	g.Expect(exists).To(BeFalse())
+
+	f.deleteCluster(g)
+}
+
+// TestMachineEIPAgainstFakeUCloud checks that machines asking for a public IP get an EIP,
+// which is allocated once and released with the machine.
+func TestMachineEIPAgainstFakeUCloud(t *testing.T) {
+	g := NewWithT(t)
+	f := newFakeUCloudService(t, infrav1.UCloudClusterSpec{})
+	defer f.server.Close()
+	server, svc := f.server, f.svc
+	f.reconcileNetwork(g)
+
+	machineScope := f.newMachineScope(g, "my-machine-0", true, infrav1.UCloudMachineSpec{
+		ImageId:  pointer.StringPtr("uimage-test"),
+		PublicIP: pointer.BoolPtr(true),
+		EIP:      &infrav1.EIPSpec{Bandwidth: 10, OperatorName: "Bgp", PayMode: "Traffic"},
+	})
+	instance := f.createRunningInstance(g, machineScope)
+	g.Expect(svc.ReconcileMachineEIP(machineScope, instance)).To(Succeed())
+	machineEIP := machineScope.UCloudMachine.Status.EIP
+	g.Expect(machineEIP).NotTo(BeNil())
+	g.Expect(machineEIP.PayMode).To(Equal("Traffic"))
+	g.Expect(machineEIP.Bandwidth).To(Equal(10))
+	instance, err := svc.InstanceIfExists(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instance.IPSet).To(ContainElement(uhost.UHostIPSet{
+		Bandwidth: 10,
+		IP:        machineEIP.EIPAddr,
+		IPId:      machineEIP.EIPId,
+		Type:      "Bgp",
+	}))
+	g.Expect(svc.ReconcileMachineEIP(machineScope, instance)).To(Succeed())
+	g.Expect(server.Requests("AllocateEIP")).To(Equal(3))
+
+	// a bare-metal machine gets its EIP bound as a upm
+	workerScope := f.newMachineScope(g, "my-worker-0", false, infrav1.UCloudMachineSpec{
+		InstanceKind: infrav1.UPHostInstanceKind,
+		PHost:        &infrav1.PHostSpec{Type: "db-2", Raid: "Raid1"},
+		ImageId:      pointer.StringPtr("pimg-test"),
+		PublicIP:     pointer.BoolPtr(true),
+	})
+	phost := f.createRunningInstance(g, workerScope)
+	g.Expect(svc.ReconcileMachineEIP(workerScope, phost)).To(Succeed())
+	workerEIP := workerScope.UCloudMachine.Status.EIP
+	g.Expect(workerEIP).NotTo(BeNil())
+	phost, err = svc.InstanceIfExists(workerScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(phost.IPSet).To(HaveLen(2))
+	g.Expect(phost.IPSet[1].IPId).To(Equal(workerEIP.EIPId))
+	g.Expect(svc.ReconcileMachineEIP(workerScope, phost)).To(Succeed())
+	g.Expect(server.Requests("BindEIP")).To(Equal(3))
+
+	for _, machineScope := range []*scope.MachineScope{machineScope, workerScope} {
+		eipId := machineScope.UCloudMachine.Status.EIP.EIPId
+		g.Expect(svc.DeleteMachineEIP(machineScope)).To(Succeed())
+		g.Expect(machineScope.UCloudMachine.Status.EIP).To(BeNil())
+		g.Expect(server.ResourceIds()).NotTo(ContainElement(eipId))
+		f.terminateInstance(g, machineScope)
+	}
+	f.deleteCluster(g)
+}
+
+// TestSubnetsAgainstFakeUCloud checks that subnets can be added to a cluster created with
+// a single subnet, that the NAT gateway serves all of them and that machines get the
+// subnet of their role and zone.
+func TestSubnetsAgainstFakeUCloud(t *testing.T) {
+	g := NewWithT(t)
+	f := newFakeUCloudService(t, infrav1.UCloudClusterSpec{})
+	defer f.server.Close()
+	server, ucloudCluster, svc := f.server, f.ucloudCluster, f.svc
+	f.reconcileNetwork(g)
+	network := ucloudCluster.Status.Network
+
+	ucloudCluster.Spec.Network.Subnets = []infrav1.SubnetSpec{
+		{Role: infrav1.SubnetRoleControlPlane, SubnetName: "cluster-api-my-cluster-subnet", CidrBlock: "10.0.0.0/24"},
+		{Role: infrav1.SubnetRoleNode, Zone: "cn-bj2-02", CidrBlock: "10.0.1.0/24"},
+		{Role: infrav1.SubnetRoleNode, Zone: "cn-bj2-03", PrefixLength: 26},
+	}
+	g.Expect(svc.ReconcileSubnet()).To(Succeed())
+	g.Expect(svc.ReconcileNat()).To(Succeed())
+	g.Expect(svc.ReconcileNat()).To(Succeed())
+	subnets := ucloudCluster.Status.Network.Subnets
+	g.Expect(subnets).To(HaveLen(3))
+	g.Expect(subnets[0].SubnetId).To(Equal(network.Subnet.SubnetId))
+	g.Expect(subnets[1].SubnetName).To(Equal("my-cluster-node-subnet-cn-bj2-02"))
+	// the CIDR block of a subnet is allocated in the VPC
+	g.Expect(subnets[2].CidrBlock).To(Equal("10.0.2.0/26"))
+	g.Expect(ucloudCluster.Status.Network.Subnet.SubnetId).To(Equal(network.Subnet.SubnetId))
+	g.Expect(server.NATGWSubnets(network.Nat.NatGatewayId)).To(ConsistOf(subnets[0].SubnetId, subnets[1].SubnetId, subnets[2].SubnetId))
+	g.Expect(server.Requests("UpdateNATGWSubnet")).To(Equal(1))
+
+	workerScope := f.newMachineScope(g, "my-worker-0", false, infrav1.UCloudMachineSpec{ImageId: pointer.StringPtr("uimage-test")})
+	worker := f.createRunningInstance(g, workerScope)
+	g.Expect(server.SubnetOf(worker.UHostId)).To(Equal(subnets[1].SubnetId))
+	controlPlaneScope := f.newMachineScope(g, "my-machine-0", true, infrav1.UCloudMachineSpec{ImageId: pointer.StringPtr("uimage-test")})
+	controlPlane := f.createRunningInstance(g, controlPlaneScope)
+	g.Expect(server.SubnetOf(controlPlane.UHostId)).To(Equal(subnets[0].SubnetId))
+
+	f.terminateInstance(g, workerScope)
+	f.terminateInstance(g, controlPlaneScope)
+	f.deleteCluster(g)
+}
+
+// TestNetworkDriftAgainstFakeUCloud checks that network resources deleted in the console
+// are created again, unless they were given by the spec.
+func TestNetworkDriftAgainstFakeUCloud(t *testing.T) {
+	g := NewWithT(t)
+	f := newFakeUCloudService(t, infrav1.UCloudClusterSpec{
+		Network: infrav1.NetworkSpec{Subnets: []infrav1.SubnetSpec{
+			{Role: infrav1.SubnetRoleControlPlane},
+			{Role: infrav1.SubnetRoleNode, Zone: "cn-bj2-02"},
+			{Role: infrav1.SubnetRoleNode, Zone: "cn-bj2-03"},
+		}},
+	})
+	defer f.server.Close()
+	server, ucloudCluster, svc := f.server, f.ucloudCluster, f.svc
+	f.reconcileNetwork(g)
+	network := ucloudCluster.Status.Network
+	subnets := network.Subnets
+	clusterFirewall := network.Firewall.FirewallId
+
+	// the NAT gateway keeps its EIP
+	for _, id := range []string{network.Nat.NatGatewayId, subnets[2].SubnetId, network.ULB.VServerId} {
+		g.Expect(server.Delete(id)).To(BeTrue())
+	}
+	g.Expect(svc.ReconcileSubnet()).To(Succeed())
+	g.Expect(svc.ReconcileNat()).To(Succeed())
+	g.Expect(svc.ReconcileULB()).To(Succeed())
+	g.Expect(svc.Drifts()).To(HaveLen(3))
+	for _, drift := range svc.Drifts() {
+		g.Expect(drift.Repaired).To(BeTrue())
+	}
+	subnets = ucloudCluster.Status.Network.Subnets
+	g.Expect(subnets[2].SubnetId).NotTo(Equal(network.Subnets[2].SubnetId))
+	g.Expect(subnets[2].SubnetName).To(Equal("my-cluster-node-subnet-cn-bj2-03"))
+	nat := ucloudCluster.Status.Network.Nat
+	g.Expect(nat.NatGatewayId).NotTo(Equal(network.Nat.NatGatewayId))
+	g.Expect(nat.EIP.EIPId).To(Equal(network.Nat.EIP.EIPId))
+	g.Expect(server.FirewallOf(nat.NatGatewayId)).To(Equal(clusterFirewall))
+	g.Expect(server.NATGWSubnets(nat.NatGatewayId)).To(ConsistOf(subnets[0].SubnetId, subnets[1].SubnetId, subnets[2].SubnetId))
+	g.Expect(ucloudCluster.Status.Network.ULB.VServerId).NotTo(Equal(network.ULB.VServerId))
+
+	// a deleted load balancer gets the EIP of the endpoint back
+	g.Expect(server.Delete(network.ULB.LoadBalancerId)).To(BeTrue())
+	g.Expect(svc.ReconcileULB()).To(Succeed())
+	g.Expect(ucloudCluster.Status.Network.ULB.LoadBalancerId).NotTo(Equal(network.ULB.LoadBalancerId))
+	g.Expect(ucloudCluster.Status.Network.ULB.EIP.EIPAddr).To(Equal(network.ULB.EIP.EIPAddr))
+	g.Expect(svc.Drifts()).To(HaveLen(4))
+
+	// a NAT gateway given by the spec is only reported
+	ucloudCluster.Spec.Network.Nat.NatGateway.NatGatewayId = nat.NatGatewayId
+	g.Expect(server.Delete(nat.NatGatewayId)).To(BeTrue())
+	g.Expect(svc.ReconcileNat()).NotTo(Succeed())
+	g.Expect(svc.Drifts()).To(HaveLen(5))
+	g.Expect(svc.Drifts()[4].Repaired).To(BeFalse())
+	g.Expect(ucloudCluster.Status.Network.Nat.NatGatewayId).To(Equal(nat.NatGatewayId))
+	ucloudCluster.Spec.Network.Nat.NatGateway.NatGatewayId = ""
+	g.Expect(svc.ReconcileNat()).To(Succeed())
+	g.Expect(server.Requests("AllocateEIP")).To(Equal(2))
+
+	f.deleteCluster(g)
+}
+
+// TestNatRepairAgainstFakeUCloud checks that the firewall and the subnets of a NAT gateway
+// changed in the console are restored, and only reported as restored once they are.
+func TestNatRepairAgainstFakeUCloud(t *testing.T) {
+	g := NewWithT(t)
+	f := newFakeUCloudService(t, infrav1.UCloudClusterSpec{
+		Network: infrav1.NetworkSpec{Subnets: []infrav1.SubnetSpec{
+			{Role: infrav1.SubnetRoleControlPlane},
+			{Role: infrav1.SubnetRoleNode, Zone: "cn-bj2-02"},
+		}},
+	})
+	defer f.server.Close()
+	server, ucloudCluster, svc := f.server, f.ucloudCluster, f.svc
+	f.reconcileNetwork(g)
+	network := ucloudCluster.Status.Network
+	natId := network.Nat.NatGatewayId
+
+	for _, form := range []url.Values{
+		{"Action": {"GrantFirewall"}, "FWId": {fakeucloud.DefaultFirewallId}, "ResourceType": {"unatgw"}, "ResourceId": {natId}},
+		{"Action": {"UpdateNATGWSubnet"}, "NATGWId": {natId}, "SubnetworkIds.0": {network.Subnets[0].SubnetId}},
+	} {
+		res, err := http.PostForm(server.URL, form)
+		g.Expect(err).NotTo(HaveOccurred())
+		res.Body.Close()
+	}
+
+	// a failed restore is reported, and the status is left as it was
+	server.InjectError("GrantFirewall", common.RetCodeInvalidParameter, "Params [FWId] not available", 1)
+	g.Expect(svc.ReconcileNat()).NotTo(Succeed())
+	g.Expect(svc.Drifts()).To(HaveLen(1))
+	g.Expect(svc.Drifts()[0].Repaired).To(BeFalse())
+	g.Expect(server.FirewallOf(natId)).To(Equal(fakeucloud.DefaultFirewallId))
+	g.Expect(ucloudCluster.Status.Network.Nat).To(Equal(network.Nat))
+
+	g.Expect(svc.ReconcileNat()).To(Succeed())
+	g.Expect(svc.ReconcileNat()).To(Succeed())
+	g.Expect(svc.Drifts()).To(HaveLen(3))
+	g.Expect(svc.Drifts()[1].Message).To(HaveSuffix(", restored"))
+	g.Expect(svc.Drifts()[1].Repaired).To(BeTrue())
+	g.Expect(svc.Drifts()[2].Message).To(HavePrefix("serves subnets"))
+	g.Expect(svc.Drifts()[2].Repaired).To(BeTrue())
+	g.Expect(server.FirewallOf(natId)).To(Equal(network.Firewall.FirewallId))
+	g.Expect(server.NATGWSubnets(natId)).To(ConsistOf(network.Subnets[0].SubnetId, network.Subnets[1].SubnetId))
+	g.Expect(ucloudCluster.Status.Network.Nat).To(Equal(network.Nat))
+
+	f.deleteCluster(g)
+}
+
+// TestBareMetalAgainstFakeUCloud checks that a bare-metal worker goes through the same
+// steps as an instance.
+func TestBareMetalAgainstFakeUCloud(t *testing.T) {
+	g := NewWithT(t)
+	f := newFakeUCloudService(t, infrav1.UCloudClusterSpec{
+		Network: infrav1.NetworkSpec{MachineFirewalls: []infrav1.MachineFirewallSpec{
+			{Tag: "control-plane", FirewallSpec: infrav1.FirewallSpec{Rules: []*infrav1.FirewallRuleSpec{{IpProtocol: "tcp", PortRange: "6443/6443"}}}},
+			{Tag: "worker", FirewallSpec: infrav1.FirewallSpec{Rules: []*infrav1.FirewallRuleSpec{{IpProtocol: "tcp", PortRange: "30000/32767"}}}},
+		}},
+	})
+	defer f.server.Close()
+	server, svc := f.server, f.svc
+	f.reconcileNetwork(g)
+	g.Expect(svc.CreateCAPUCluster()).To(Succeed())
+	network := f.ucloudCluster.Status.Network
+
+	workerScope := f.newMachineScope(g, "my-worker-0", false, infrav1.UCloudMachineSpec{
+		InstanceKind:          infrav1.UPHostInstanceKind,
+		PHost:                 &infrav1.PHostSpec{Type: "db-2", Raid: "Raid1"},
+		ImageId:               pointer.StringPtr("pimg-test"),
+		AdditionalNetworkTags: []string{"worker"},
+	})
+	server.SetTransitionDelay(time.Hour)
+	phost, err := svc.CreateInstance(workerScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(phost.State).To(Equal("Initializing"))
+	g.Expect(phost.ImageId).To(Equal("pimg-test"))
+	g.Expect(phost.ChargeType).To(Equal("Month"))
+	g.Expect(server.SubnetOf(phost.UHostId)).To(Equal(network.Subnet.SubnetId))
+	workerScope.UCloudMachine.Status.Zone = phost.Zone
+	workerScope.SetProviderID("ucloud://org-test/" + phost.Zone + "/uphost/" + phost.UHostId)
+	g.Expect(workerScope.GetInstanceID()).To(Equal(&phost.UHostId))
+
+	server.SetTransitionDelay(0)
+	phost, err = svc.InstanceIfExists(workerScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(phost.State).To(Equal("Running"))
+	g.Expect(phost.IPSet).To(HaveLen(1))
+	g.Expect(phost.IPSet[0].Type).To(Equal("Private"))
+	g.Expect(svc.ReconcileInstanceFirewall(workerScope, phost)).To(Succeed())
+	g.Expect(server.FirewallOf(phost.UHostId)).To(Equal(network.MachineFirewalls["worker"].FirewallId))
+	// changing the tags of a machine moves it to another firewall
+	controlPlaneFirewall := network.MachineFirewalls["control-plane"].FirewallId
+	workerScope.UCloudMachine.Spec.AdditionalNetworkTags = []string{"control-plane"}
+	g.Expect(svc.ReconcileInstanceFirewall(workerScope, phost)).To(Succeed())
+	g.Expect(server.FirewallOf(phost.UHostId)).To(Equal(controlPlaneFirewall))
+	g.Expect(workerScope.UCloudMachine.Status.FirewallId).To(Equal(controlPlaneFirewall))
+	g.Expect(svc.CreateCAPUHost(workerScope)).To(Succeed())
+	g.Expect(svc.DeleteCAPUHost(workerScope)).To(Succeed())
+	f.terminateInstance(g, workerScope)
+
+	f.deleteCluster(g)
+}
+
+// TestInternalULBAgainstFakeUCloud runs the cluster create flow with an internal load
+// balancer against the fake UCloud API.
+func TestInternalULBAgainstFakeUCloud(t *testing.T) {
+	g := NewWithT(t)
+	f := newFakeUCloudService(t, infrav1.UCloudClusterSpec{
+		Network: infrav1.NetworkSpec{ULB: infrav1.ULBSpec{Mode: infrav1.ULBModeInternal}},
+	})
+	defer f.server.Close()
+	server, ucloudCluster, svc := f.server, f.ucloudCluster, f.svc
+
+	f.reconcileNetwork(g)
+	g.Expect(svc.ReconcileULB()).To(Succeed())
+
+	// the load balancer is in the control plane subnet, and only the NAT gateway has an EIP
+	ulb := ucloudCluster.Status.Network.ULB
+	g.Expect(ulb.LoadBalancerName).To(Equal("my-cluster-internal-lb"))
+	g.Expect(ulb.PrivateIP).To(HavePrefix("10.0.0."))
+	g.Expect(ulb.EIP.EIPId).To(BeEmpty())
+	g.Expect(ulb.VServerId).NotTo(BeEmpty())
+	g.Expect(server.Requests("AllocateEIP")).To(Equal(1))
+	g.Expect(svc.Drifts()).To(BeEmpty())
+
+	// a recreated load balancer gets another address, which can't be the endpoint
+	g.Expect(server.Delete(ulb.LoadBalancerId)).To(BeTrue())
+	g.Expect(svc.ReconcileULB()).NotTo(Succeed())
+	g.Expect(svc.ReconcileULB()).NotTo(Succeed())
+	g.Expect(ucloudCluster.Status.Network.ULB.PrivateIP).To(Equal(ulb.PrivateIP))
+	g.Expect(svc.Drifts()).To(HaveLen(3))
+	g.Expect(svc.Drifts()[0].Repaired).To(BeTrue())
+	g.Expect(svc.Drifts()[1].Repaired).To(BeFalse())
+	g.Expect(server.Requests("CreateULB")).To(Equal(2))
+}
+
+// TestULBCreateFailureAgainstFakeUCloud checks that a load balancer whose creation failed
+// after it was created is completed, instead of another one being created.
+func TestULBCreateFailureAgainstFakeUCloud(t *testing.T) {
+	g := NewWithT(t)
+	f := newFakeUCloudService(t, infrav1.UCloudClusterSpec{})
+	defer f.server.Close()
+	server, ucloudCluster, svc := f.server, f.ucloudCluster, f.svc
+	g.Expect(svc.ReconcileUGroup()).To(Succeed())
+	g.Expect(svc.ReconcileVPC()).To(Succeed())
+	g.Expect(svc.ReconcileSubnet()).To(Succeed())
+	g.Expect(svc.ReconcileFirewall()).To(Succeed())
+
+	// the EIP fails to bind, then the vserver of the recorded load balancer fails to be
+	// created
+	for _, action := range []string{"BindEIP", "CreateVServer"} {
+		server.InjectError(action, common.RetCodeInvalidParameter, "Params [ULBId] not available", 1)
+		g.Expect(svc.ReconcileULB()).NotTo(Succeed())
+		g.Expect(ucloudCluster.Status.Network.ULB.LoadBalancerId).NotTo(BeEmpty())
+	}
+	g.Expect(svc.ReconcileULB()).To(Succeed())
+	g.Expect(svc.ReconcileULB()).To(Succeed())
+	g.Expect(server.Requests("CreateULB")).To(Equal(1))
+	g.Expect(server.Requests("AllocateEIP")).To(Equal(1))
+	g.Expect(server.Requests("CreateVServer")).To(Equal(2))
+	g.Expect(svc.Drifts()).To(BeEmpty())
+
+	ulb := ucloudCluster.Status.Network.ULB
+	g.Expect(ulb.EIP.EIPAddr).NotTo(BeEmpty())
+	g.Expect(ulb.FrontendPort).To(Equal(6443))
+	g.Expect(ulb.ExpireTime).NotTo(BeNil())
+	vserver, ok := server.VServer(ulb.LoadBalancerId, ulb.VServerId)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(vserver.FrontendPort).To(Equal(6443))
+
+	g.Expect(svc.ReconcileNat()).To(Succeed())
+	g.Expect(svc.ReconcileMachineFirewalls()).To(Succeed())
+	f.deleteCluster(g)
+}
+
+// TestULBListenerAgainstFakeUCloud checks that the listener of the api server follows the
+// api server port of the cluster and the listener of the spec.
+func TestULBListenerAgainstFakeUCloud(t *testing.T) {
+	g := NewWithT(t)
+	f := newFakeUCloudService(t, infrav1.UCloudClusterSpec{
+		Network: infrav1.NetworkSpec{ULB: infrav1.ULBSpec{Listener: infrav1.ULBListenerSpec{
+			Method:      "Source",
+			HealthCheck: infrav1.ULBHealthCheckSpec{Type: "Path", Path: "/healthz"},
+		}}},
+	})
+	defer f.server.Close()
+	server, ucloudCluster, svc := f.server, f.ucloudCluster, f.svc
+	apiServerPort := int32(8443)
+	f.cluster.Spec.ClusterNetwork.APIServerPort = &apiServerPort
+
+	f.reconcileNetwork(g)
+	g.Expect(svc.ReconcileULB()).To(Succeed())
+
+	// the listener and the default firewall rule use the api server port of the cluster
+	status := ucloudCluster.Status.Network
+	g.Expect(status.ULB.FrontendPort).To(Equal(8443))
+	g.Expect(status.Firewall.Rules).To(ContainElement("TCP|8443|0.0.0.0/0|ACCEPT|MEDIUM|apiserver"))
+	vserver, ok := server.VServer(status.ULB.LoadBalancerId, status.ULB.VServerId)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(vserver.FrontendPort).To(Equal(8443))
+	g.Expect(vserver.ListenType).To(Equal("RequestProxy"))
+	g.Expect(vserver.Method).To(Equal("Source"))
+	g.Expect(vserver.MonitorType).To(Equal("Path"))
+	g.Expect(vserver.Path).To(Equal("/healthz"))
+	g.Expect(server.Requests("UpdateVServerAttribute")).To(Equal(0))
+
+	// the attributes that can be updated converge to the spec, once
+	ucloudCluster.Spec.Network.ULB.Listener.Method = "Leastconn"
+	ucloudCluster.Spec.Network.ULB.Listener.PersistenceType = "UserDefined"
+	ucloudCluster.Spec.Network.ULB.Listener.PersistenceInfo = "apiserver"
+	g.Expect(svc.ReconcileULB()).To(Succeed())
+	g.Expect(svc.ReconcileULB()).To(Succeed())
+	vserver, _ = server.VServer(status.ULB.LoadBalancerId, status.ULB.VServerId)
+	g.Expect(vserver.Method).To(Equal("Leastconn"))
+	g.Expect(vserver.PersistenceType).To(Equal("UserDefined"))
+	g.Expect(vserver.PersistenceInfo).To(Equal("apiserver"))
+	g.Expect(server.Requests("UpdateVServerAttribute")).To(Equal(1))
+	g.Expect(svc.Drifts()).To(BeEmpty())
+
+	// the port of the listener can't be updated, a different one is only reported
+	ucloudCluster.Spec.Network.ULB.Listener.FrontendPort = 443
+	g.Expect(svc.ReconcileULB()).NotTo(Succeed())
+	g.Expect(svc.Drifts()).To(HaveLen(1))
+	g.Expect(svc.Drifts()[0].Repaired).To(BeFalse())
+	g.Expect(ucloudCluster.Status.Network.ULB.FrontendPort).To(Equal(8443))
+}
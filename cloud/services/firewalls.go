@@ -17,12 +17,23 @@ limitations under the License.
 package services
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/pkg/errors"
+	"github.com/ucloud/ucloud-sdk-go/services/uhost"
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
 	"github.com/ucloud/ucloud-sdk-go/ucloud"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/scope"
 )
 
+// defaultFirewallRulePriority is the priority of the rules of the firewalls owned by
+// the cluster.
+const defaultFirewallRulePriority = "MEDIUM"
+
 func (s *Service) getFirewall(firewallId string) (firewall infrav1.Firewall, err error) {
 
 	s.scope.Info("get firewall info")
@@ -34,7 +45,7 @@ func (s *Service) getFirewall(firewallId string) (firewall infrav1.Firewall, err
 	}
 	firewalls, err := s.unetClient.DescribeFirewall(req)
 	if err != nil {
-		return infrav1.Firewall{}, errors.Errorf("get firewall failed: %s", err.Error())
+		return infrav1.Firewall{}, errors.Wrap(err, "get firewall failed")
 	}
 	if len(firewalls.DataSet) == 0 {
 		return infrav1.Firewall{}, errors.Errorf("can not find firewall")
@@ -43,6 +54,332 @@ func (s *Service) getFirewall(firewallId string) (firewall infrav1.Firewall, err
 	firewall.FirewallId = finalFirewall.FWId
 	firewall.FirewallName = finalFirewall.Name
 	firewall.FirewallType = finalFirewall.Type
+	firewall.Description = finalFirewall.Remark
+	firewall.Rules = appliedFirewallRules(finalFirewall.Rule)
 	s.scope.Info("get firewall success", "firewallId", finalFirewall.FWId)
 	return firewall, nil
 }
+
+// ReconcileFirewall makes sure the firewall of the cluster exists. Without a FirewallId
+// in the spec, the cluster owns its firewall and keeps its rules in line with the spec,
+// or with defaultFirewallRules if the spec has none.
+func (s *Service) ReconcileFirewall() error {
+	spec := s.scope.UCloudCluster.Spec.Network.Firewall
+	if spec.FirewallId == "" && len(spec.Rules) == 0 {
+		spec.Rules = defaultFirewallRules(s.scope.LoadBalancerBackendPort())
+	}
+	name := spec.FirewallName
+	if name == "" {
+		name = "firewall-for-" + s.scope.Name()
+	}
+	firewall, err := s.reconcileFirewall(name, spec)
+	if err != nil {
+		return errors.Wrap(err, "reconcile firewall of cluster failed")
+	}
+	s.scope.UCloudCluster.Status.Network.Firewall = firewall
+	return nil
+}
+
+// DeleteFirewall deletes the firewall of the cluster if the cluster owns it.
+func (s *Service) DeleteFirewall() error {
+	firewallId := s.scope.UCloudCluster.Status.Network.Firewall.FirewallId
+	if firewallId == "" {
+		return nil
+	}
+	deleted, err := s.deleteOwnedFirewall(firewallId)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.Errorf("firewall %s of cluster is still in use", firewallId)
+	}
+	s.scope.UCloudCluster.Status.Network.Firewall = infrav1.Firewall{}
+	return nil
+}
+
+// defaultFirewallRules are the rules of the firewall of a cluster that doesn't declare
+// any: SSH, the API server on apiServerPort and ping, from anywhere.
+func defaultFirewallRules(apiServerPort int) []*infrav1.FirewallRuleSpec {
+	apiServerPorts := fmt.Sprintf("%d/%d", apiServerPort, apiServerPort)
+	return []*infrav1.FirewallRuleSpec{
+		{IpProtocol: "tcp", PortRange: "22/22", Description: "ssh"},
+		{IpProtocol: "tcp", PortRange: apiServerPorts, Description: "apiserver"},
+		{IpProtocol: "icmp", Description: "ping"},
+	}
+}
+
+// ReconcileMachineFirewalls makes sure the firewalls machines select by tag exist, and
+// that the rules of the firewalls owned by the cluster match the spec.
+func (s *Service) ReconcileMachineFirewalls() error {
+	status := &s.scope.UCloudCluster.Status.Network
+	specs := s.scope.UCloudCluster.Spec.Network.MachineFirewalls
+	if len(specs) == 0 && len(status.MachineFirewalls) == 0 {
+		return nil
+	}
+	if status.MachineFirewalls == nil {
+		status.MachineFirewalls = map[string]infrav1.Firewall{}
+	}
+	tags := map[string]bool{}
+	for _, spec := range specs {
+		tags[spec.Tag] = true
+		name := spec.FirewallName
+		if name == "" {
+			name = s.scope.Name() + "-" + spec.Tag
+		}
+		firewall, err := s.reconcileFirewall(name, spec.FirewallSpec)
+		if err != nil {
+			return errors.Wrapf(err, "reconcile firewall of tag %s failed", spec.Tag)
+		}
+		status.MachineFirewalls[spec.Tag] = firewall
+	}
+
+	// firewalls no longer in the spec are deleted once no machine uses them
+	for tag, firewall := range status.MachineFirewalls {
+		if tags[tag] {
+			continue
+		}
+		deleted, err := s.deleteOwnedFirewall(firewall.FirewallId)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			delete(status.MachineFirewalls, tag)
+		}
+	}
+	return nil
+}
+
+// DeleteMachineFirewalls deletes the machine firewalls owned by the cluster.
+func (s *Service) DeleteMachineFirewalls() error {
+	status := &s.scope.UCloudCluster.Status.Network
+	for tag, firewall := range status.MachineFirewalls {
+		deleted, err := s.deleteOwnedFirewall(firewall.FirewallId)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return errors.Errorf("firewall %s of tag %s is still in use", firewall.FirewallId, tag)
+		}
+		delete(status.MachineFirewalls, tag)
+	}
+	return nil
+}
+
+// reconcileFirewall returns the existing firewall of spec, or the firewall named name
+// owned by the cluster, which is created or updated to have the rules of spec.
+func (s *Service) reconcileFirewall(name string, spec infrav1.FirewallSpec) (infrav1.Firewall, error) {
+	if spec.FirewallId != "" {
+		return s.getFirewall(spec.FirewallId)
+	}
+	rules := firewallRules(spec.Rules)
+	firewalls, err := s.describeFirewalls()
+	if err != nil {
+		return infrav1.Firewall{}, err
+	}
+	for _, fw := range firewalls {
+		if fw.Name != name || fw.Tag != s.scope.GroupName() {
+			continue
+		}
+		if !sameFirewallRules(rules, fw.Rule) {
+			s.scope.Info("update firewall rules", "firewallId", fw.FWId, "rules", rules)
+			req := s.unetClient.NewUpdateFirewallRequest()
+			req.Region = ucloud.String(s.scope.Region())
+			req.ProjectId = ucloud.String(s.scope.ProjectId())
+			req.FWId = ucloud.String(fw.FWId)
+			req.Rule = rules
+			if _, err := s.unetClient.UpdateFirewall(req); err != nil {
+				return infrav1.Firewall{}, errors.Wrapf(err, "update firewall %s failed", fw.FWId)
+			}
+		}
+		return infrav1.Firewall{
+			FirewallId:   fw.FWId,
+			FirewallName: fw.Name,
+			FirewallType: fw.Type,
+			Description:  fw.Remark,
+			Rules:        rules,
+		}, nil
+	}
+
+	s.scope.Info("create firewall", "name", name, "rules", rules)
+	req := s.unetClient.NewCreateFirewallRequest()
+	req.Region = ucloud.String(s.scope.Region())
+	req.ProjectId = ucloud.String(s.scope.ProjectId())
+	req.Tag = ucloud.String(s.scope.GroupName())
+	req.Name = ucloud.String(name)
+	req.Rule = rules
+	if spec.Description != "" {
+		req.Remark = ucloud.String(spec.Description)
+	}
+	res, err := s.unetClient.CreateFirewall(req)
+	if err != nil {
+		return infrav1.Firewall{}, errors.Wrapf(err, "create firewall %s failed", name)
+	}
+	s.scope.Info("create firewall success", "firewallId", res.FWId)
+	return infrav1.Firewall{
+		FirewallId:   res.FWId,
+		FirewallName: name,
+		FirewallType: "user defined",
+		Description:  spec.Description,
+		Rules:        rules,
+	}, nil
+}
+
+// deleteOwnedFirewall deletes a firewall if it is owned by the cluster and unused.
+// It returns true if the firewall is gone or not owned by the cluster.
+func (s *Service) deleteOwnedFirewall(firewallId string) (bool, error) {
+	firewalls, err := s.describeFirewalls()
+	if err != nil {
+		return false, err
+	}
+	for _, fw := range firewalls {
+		if fw.FWId != firewallId {
+			continue
+		}
+		if fw.Tag != s.scope.GroupName() {
+			return true, nil
+		}
+		if fw.ResourceCount > 0 {
+			s.scope.Info("waiting for firewall to be unused before deleting it", "firewallId", firewallId)
+			return false, nil
+		}
+		s.scope.Info("delete firewall", "firewallId", firewallId)
+		req := s.unetClient.NewDeleteFirewallRequest()
+		req.Region = ucloud.String(s.scope.Region())
+		req.ProjectId = ucloud.String(s.scope.ProjectId())
+		req.FWId = ucloud.String(firewallId)
+		if _, err := s.unetClient.DeleteFirewall(req); err != nil && !common.IsNotFound(err) {
+			return false, errors.Wrapf(err, "delete firewall %s failed", firewallId)
+		}
+		return true, nil
+	}
+	return true, nil
+}
+
+func (s *Service) describeFirewalls() ([]unet.FirewallDataSet, error) {
+	var firewalls []unet.FirewallDataSet
+	req := s.unetClient.NewDescribeFirewallRequest()
+	req.Region = ucloud.String(s.scope.Region())
+	req.ProjectId = ucloud.String(s.scope.ProjectId())
+	req.Limit = ucloud.Int(100)
+	for {
+		req.Offset = ucloud.Int(len(firewalls))
+		res, err := s.unetClient.DescribeFirewall(req)
+		if err != nil {
+			return nil, errors.Wrap(err, "describe firewalls failed")
+		}
+		firewalls = append(firewalls, res.DataSet...)
+		if len(res.DataSet) == 0 || len(firewalls) >= res.TotalCount {
+			return firewalls, nil
+		}
+	}
+}
+
+// ReconcileInstanceFirewall attaches the firewall the machine selects to its instance.
+func (s *Service) ReconcileInstanceFirewall(scope *scope.MachineScope, instance *uhost.UHostInstanceSet) error {
+	firewallId, err := s.machineFirewallId(scope)
+	if err != nil {
+		return err
+	}
+	if firewallId == "" || firewallId == scope.UCloudMachine.Status.FirewallId {
+		return nil
+	}
+	resourceType := instanceResourceType(scope)
+	s.scope.Info("grant firewall", "firewallId", firewallId, "resourceId", instance.UHostId)
+	req := s.unetClient.NewGrantFirewallRequest()
+	req.Region = ucloud.String(s.scope.Region())
+	req.ProjectId = ucloud.String(s.scope.ProjectId())
+	req.FWId = ucloud.String(firewallId)
+	req.ResourceType = ucloud.String(resourceType)
+	req.ResourceId = ucloud.String(instance.UHostId)
+	if _, err := s.unetClient.GrantFirewall(req); err != nil {
+		return errors.Wrapf(err, "grant firewall %s to %s %s failed", firewallId, resourceType, instance.UHostId)
+	}
+	scope.UCloudMachine.Status.FirewallId = firewallId
+	return nil
+}
+
+// machineFirewallId returns the firewall the machine selects: its FirewallId, the
+// machine firewall of one of its tags, or the firewall of the cluster.
+func (s *Service) machineFirewallId(scope *scope.MachineScope) (string, error) {
+	if id := scope.UCloudMachine.Spec.FirewallId; id != "" {
+		return id, nil
+	}
+	var firewallTag string
+	for _, tag := range scope.UCloudMachine.Spec.AdditionalNetworkTags {
+		for _, spec := range s.scope.UCloudCluster.Spec.Network.MachineFirewalls {
+			if spec.Tag != tag {
+				continue
+			}
+			if firewallTag != "" && firewallTag != tag {
+				return "", errors.Errorf("tags %s and %s both select a firewall, an instance has a single firewall", firewallTag, tag)
+			}
+			firewallTag = tag
+		}
+	}
+	if firewallTag == "" {
+		return s.scope.UCloudCluster.Status.Network.Firewall.FirewallId, nil
+	}
+	firewall, ok := s.scope.UCloudCluster.Status.Network.MachineFirewalls[firewallTag]
+	if !ok || firewall.FirewallId == "" {
+		return "", errors.Errorf("firewall of tag %s is not created yet", firewallTag)
+	}
+	return firewall.FirewallId, nil
+}
+
+// firewallRules returns the rules in the format of the UCloud API,
+// protocol|ports|source|action|priority|remark.
+func firewallRules(specs []*infrav1.FirewallRuleSpec) []string {
+	rules := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		protocol := strings.ToUpper(spec.IpProtocol)
+		ports := ""
+		switch protocol {
+		case "TCP", "UDP":
+			ports = strings.Replace(spec.PortRange, "/", "-", 1)
+			if ports == "" {
+				ports = "1-65535"
+			}
+			if parts := strings.Split(ports, "-"); len(parts) == 2 && parts[0] == parts[1] {
+				ports = parts[0]
+			}
+		}
+		source := spec.SourceCidrIp
+		if source == "" {
+			source = "0.0.0.0/0"
+		}
+		action := strings.ToUpper(spec.Policy)
+		if action == "" {
+			action = "ACCEPT"
+		}
+		rules = append(rules, strings.Join([]string{protocol, ports, source, action, defaultFirewallRulePriority, spec.Description}, "|"))
+	}
+	return rules
+}
+
+// sameFirewallRules returns true if a firewall has exactly the rules, in any order.
+func sameFirewallRules(rules []string, current []unet.FirewallRuleSet) bool {
+	if len(rules) != len(current) {
+		return false
+	}
+	want := map[string]int{}
+	for _, rule := range rules {
+		want[strings.ToUpper(rule)]++
+	}
+	for _, rule := range appliedFirewallRules(current) {
+		key := strings.ToUpper(rule)
+		if want[key] == 0 {
+			return false
+		}
+		want[key]--
+	}
+	return true
+}
+
+// appliedFirewallRules returns the rules of a firewall in the format of firewallRules.
+func appliedFirewallRules(current []unet.FirewallRuleSet) []string {
+	rules := make([]string, 0, len(current))
+	for _, rule := range current {
+		rules = append(rules, strings.Join([]string{rule.ProtocolType, rule.DstPort, rule.SrcIP, rule.RuleAction, rule.Priority, rule.Remark}, "|"))
+	}
+	return rules
+}
@@ -49,10 +49,10 @@ func (s *Service) buildHTTPRequest(req request.Common) (*http.HttpRequest, error
 		return nil, errors.Errorf("convert request to map failed, %s", err)
 	}
 
-	// check credential information is available
-	credential := s.scope.Credential
-	if credential == nil {
-		return nil, errors.Errorf("invalid credential information, please set it before request.")
+	// always sign with the current credential so that rotated keys are picked up
+	credential, err := s.scope.CurrentCredential()
+	if err != nil {
+		return nil, errors.Errorf("invalid credential information, %s", err)
 	}
 
 	config := s.scope.Config
@@ -155,14 +155,27 @@ func logDebugHTTPHandler(req *http.HttpRequest, resp *http.HttpResponse, err err
 	return resp, err
 }
 
+// doRequest sends req, retrying it according to shouldRetry.
 func (s *Service) doRequest(req request.Common, res response.Common) error {
+	for retryCount := 0; ; retryCount++ {
+		start := time.Now()
+		err := s.sendRequest(req, res)
+		observeRequest(req, start, err)
+		if retryCount >= maxRetries || !shouldRetry(req.GetAction(), err) {
+			return err
+		}
+		time.Sleep(backoffDelay(retryCount))
+		req.SetRequestTime(time.Now())
+	}
+}
+
+func (s *Service) sendRequest(req request.Common, res response.Common) error {
 	httpReq, err := s.buildHTTPRequest(req)
 	if err != nil {
 		return uerr.NewClientError(uerr.ErrInvalidRequest, err)
 	}
 	res.SetRequest(req)
-	httpClient := http.NewHttpClient()
-	httpResp, err := httpClient.Send(httpReq)
+	httpResp, err := s.httpClient.Send(httpReq)
 
 	// use response middleware to handle http response
 	// such as convert some http status to error
@@ -0,0 +1,206 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/ucloud/ucloud-sdk-go/services/uhost"
+	"github.com/ucloud/ucloud-sdk-go/ucloud"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
+)
+
+// imageCacheTTL is how long the result of an image lookup is reused. New images are
+// picked up by instances created after it expires.
+const imageCacheTTL = 10 * time.Minute
+
+type cachedImage struct {
+	imageId   string
+	expiresAt time.Time
+}
+
+// imageCache caches image lookups across reconciles, keyed by project, zone, lookup
+// and Kubernetes version.
+var imageCache = struct {
+	sync.Mutex
+	images map[string]cachedImage
+}{images: map[string]cachedImage{}}
+
+// resolveImageId returns the image to create an instance from in zone: imageId if it
+// is set, otherwise the image found by lookup, otherwise the default image of the zone.
+func (s *Service) resolveImageId(imageId *string, lookup *infrav1.ImageLookupSpec, zone, version string) (string, error) {
+	if imageId != nil {
+		return *imageId, nil
+	}
+	if lookup != nil {
+		return s.lookupImage(lookup, zone, version)
+	}
+	defaultImageId := common.RegionImageMap[s.scope.Region()][zone]
+	if defaultImageId == "" {
+		return "", errors.Errorf("can not get image id for zone %s, set imageId or imageLookup", zone)
+	}
+	return defaultImageId, nil
+}
+
+// lookupImage returns the newest available image in zone matching lookup.
+func (s *Service) lookupImage(lookup *infrav1.ImageLookupSpec, zone, version string) (string, error) {
+	key := fmt.Sprintf("%s/%s/%s/%+v/%s", s.scope.ProjectId(), s.scope.Region(), zone, *lookup, version)
+	if imageId, ok := getCachedImage(key, time.Now()); ok {
+		return imageId, nil
+	}
+
+	matches, err := imageMatcher(lookup, version)
+	if err != nil {
+		return "", err
+	}
+	images, err := s.describeImages(lookup, zone)
+	if err != nil {
+		return "", err
+	}
+	var newest *uhost.UHostImageSet
+	for i := range images {
+		image := &images[i]
+		if image.State != "Available" || !matches(image) {
+			continue
+		}
+		if newest == nil || image.CreateTime > newest.CreateTime {
+			newest = image
+		}
+	}
+	if newest == nil {
+		return "", errors.Errorf("no available image in zone %s matches %+v", zone, *lookup)
+	}
+	s.scope.Info("found image", "imageid", newest.ImageId, "name", newest.ImageName, "zone", zone)
+
+	cacheImage(key, newest.ImageId, time.Now())
+	return newest.ImageId, nil
+}
+
+// getCachedImage returns the cached image of key if it hasn't expired at now. An
+// expired entry is deleted.
+func getCachedImage(key string, now time.Time) (string, bool) {
+	imageCache.Lock()
+	defer imageCache.Unlock()
+	cached, ok := imageCache.images[key]
+	if !ok {
+		return "", false
+	}
+	if !now.Before(cached.expiresAt) {
+		delete(imageCache.images, key)
+		return "", false
+	}
+	return cached.imageId, true
+}
+
+// cacheImage caches imageId for key. The entries that expired by now are deleted, so
+// lookups that aren't repeated, e.g. of deleted clusters, don't stay in the cache.
+func cacheImage(key, imageId string, now time.Time) {
+	imageCache.Lock()
+	defer imageCache.Unlock()
+	for k, cached := range imageCache.images {
+		if !now.Before(cached.expiresAt) {
+			delete(imageCache.images, k)
+		}
+	}
+	imageCache.images[key] = cachedImage{imageId: imageId, expiresAt: now.Add(imageCacheTTL)}
+}
+
+// describeImages returns the images in zone of the owner and OS type of lookup.
+func (s *Service) describeImages(lookup *infrav1.ImageLookupSpec, zone string) ([]uhost.UHostImageSet, error) {
+	var images []uhost.UHostImageSet
+	req := s.uhostClient.NewDescribeImageRequest()
+	req.Region = ucloud.String(s.scope.Region())
+	req.ProjectId = ucloud.String(s.scope.ProjectId())
+	req.Zone = ucloud.String(zone)
+	req.ImageType = ucloud.String(lookup.Owner)
+	if lookup.Owner == "" {
+		req.ImageType = ucloud.String("Custom")
+	}
+	if lookup.OsType != "" {
+		req.OsType = ucloud.String(lookup.OsType)
+	}
+	req.Limit = ucloud.Int(100)
+	for {
+		req.Offset = ucloud.Int(len(images))
+		res, err := s.uhostClient.DescribeImage(req)
+		if err != nil {
+			return nil, errors.Wrap(err, "describe image failed")
+		}
+		images = append(images, res.ImageSet...)
+		if len(res.ImageSet) == 0 || len(images) >= res.TotalCount {
+			return images, nil
+		}
+	}
+}
+
+// imageMatcher returns a function reporting whether an image matches the filters of
+// lookup that DescribeImage doesn't apply.
+func imageMatcher(lookup *infrav1.ImageLookupSpec, version string) (func(*uhost.UHostImageSet) bool, error) {
+	namePattern, err := regexp.Compile(lookup.NamePattern)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid imageLookup namePattern")
+	}
+	osNamePattern, err := regexp.Compile(lookup.OsNamePattern)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid imageLookup osNamePattern")
+	}
+	version = strings.TrimPrefix(version, "v")
+	if lookup.MatchKubernetesVersion && version == "" {
+		return nil, errors.New("imageLookup matches the Kubernetes version but the machine has no version")
+	}
+	return func(image *uhost.UHostImageSet) bool {
+		if !namePattern.MatchString(image.ImageName) || !osNamePattern.MatchString(image.OsName) {
+			return false
+		}
+		if lookup.Vendor != "" && image.Vendor != lookup.Vendor {
+			return false
+		}
+		if lookup.MatchKubernetesVersion &&
+			!containsVersion(image.ImageName, version) && !containsVersion(image.ImageDescription, version) {
+			return false
+		}
+		return true
+	}, nil
+}
+
+// containsVersion reports whether s contains version as a whole, so that 1.18.3 is
+// not found in 1.18.30.
+func containsVersion(s, version string) bool {
+	for i := strings.Index(s, version); i >= 0; {
+		end := i + len(version)
+		if (i == 0 || !isVersionChar(s[i-1])) && (end == len(s) || !isVersionChar(s[end])) {
+			return true
+		}
+		next := strings.Index(s[i+1:], version)
+		if next < 0 {
+			return false
+		}
+		i += next + 1
+	}
+	return false
+}
+
+func isVersionChar(c byte) bool {
+	return c == '.' || (c >= '0' && c <= '9')
+}
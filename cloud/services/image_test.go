@@ -0,0 +1,132 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/ucloud/ucloud-sdk-go/services/uhost"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+)
+
+func TestContainsVersion(t *testing.T) {
+	tests := []struct {
+		s       string
+		version string
+		want    bool
+	}{
+		{"k8s-1.18.3", "1.18.3", true},
+		{"k8s-1.18.3-ubuntu", "1.18.3", true},
+		{"1.18.3", "1.18.3", true},
+		{"k8s-1.18.30", "1.18.3", false},
+		{"k8s-11.18.3", "1.18.3", false},
+		{"k8s-1.18.30 and 1.18.3", "1.18.3", true},
+		{"k8s-1.18", "1.18.3", false},
+		{"", "1.18.3", false},
+	}
+	g := NewWithT(t)
+	for _, tt := range tests {
+		g.Expect(containsVersion(tt.s, tt.version)).To(Equal(tt.want), "%q contains %q", tt.s, tt.version)
+	}
+}
+
+func TestImageMatcher(t *testing.T) {
+	images := []uhost.UHostImageSet{
+		{ImageId: "uimage-1", ImageName: "k8s-1.18.3", OsName: "Ubuntu 18.04 64位", Vendor: "Ubuntu"},
+		{ImageId: "uimage-2", ImageName: "k8s-1.18.30", OsName: "Ubuntu 18.04 64位", Vendor: "Ubuntu"},
+		{ImageId: "uimage-3", ImageName: "k8s", ImageDescription: "Kubernetes 1.18.3", OsName: "CentOS 7.6 64位", Vendor: "CentOS"},
+		{ImageId: "uimage-4", ImageName: "base", OsName: "CentOS 8.2 64位", Vendor: "CentOS"},
+	}
+	tests := []struct {
+		name    string
+		lookup  infrav1.ImageLookupSpec
+		version string
+		want    []string
+	}{
+		{name: "no filters", want: []string{"uimage-1", "uimage-2", "uimage-3", "uimage-4"}},
+		{name: "name pattern", lookup: infrav1.ImageLookupSpec{NamePattern: "^k8s-"}, want: []string{"uimage-1", "uimage-2"}},
+		{name: "os name pattern", lookup: infrav1.ImageLookupSpec{OsNamePattern: `^CentOS 7\.`}, want: []string{"uimage-3"}},
+		{name: "vendor", lookup: infrav1.ImageLookupSpec{Vendor: "CentOS"}, want: []string{"uimage-3", "uimage-4"}},
+		{
+			name:    "kubernetes version",
+			lookup:  infrav1.ImageLookupSpec{MatchKubernetesVersion: true},
+			version: "1.18.3",
+			want:    []string{"uimage-1", "uimage-3"},
+		},
+		{
+			name:    "kubernetes version with v prefix",
+			lookup:  infrav1.ImageLookupSpec{MatchKubernetesVersion: true},
+			version: "v1.18.30",
+			want:    []string{"uimage-2"},
+		},
+		{
+			name:    "version and os name pattern",
+			lookup:  infrav1.ImageLookupSpec{MatchKubernetesVersion: true, OsNamePattern: "^Ubuntu"},
+			version: "v1.18.3",
+			want:    []string{"uimage-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			matches, err := imageMatcher(&tt.lookup, tt.version)
+			g.Expect(err).NotTo(HaveOccurred())
+			var got []string
+			for i := range images {
+				if matches(&images[i]) {
+					got = append(got, images[i].ImageId)
+				}
+			}
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestImageMatcherErrors(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := imageMatcher(&infrav1.ImageLookupSpec{NamePattern: "("}, "")
+	g.Expect(err).To(MatchError(ContainSubstring("namePattern")))
+	_, err = imageMatcher(&infrav1.ImageLookupSpec{OsNamePattern: "("}, "")
+	g.Expect(err).To(MatchError(ContainSubstring("osNamePattern")))
+	_, err = imageMatcher(&infrav1.ImageLookupSpec{MatchKubernetesVersion: true}, "v")
+	g.Expect(err).To(MatchError(ContainSubstring("no version")))
+}
+
+func TestImageCache(t *testing.T) {
+	g := NewWithT(t)
+	now := time.Now()
+
+	cacheImage("a", "uimage-a", now)
+	imageId, ok := getCachedImage("a", now.Add(imageCacheTTL-time.Second))
+	g.Expect(ok).To(BeTrue())
+	g.Expect(imageId).To(Equal("uimage-a"))
+
+	// an expired entry is deleted when it is read
+	_, ok = getCachedImage("a", now.Add(imageCacheTTL))
+	g.Expect(ok).To(BeFalse())
+	g.Expect(imageCache.images).NotTo(HaveKey("a"))
+
+	// and when another entry is cached
+	cacheImage("b", "uimage-b", now)
+	cacheImage("c", "uimage-c", now.Add(imageCacheTTL))
+	g.Expect(imageCache.images).NotTo(HaveKey("b"))
+	g.Expect(imageCache.images).To(HaveKey("c"))
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/ucloud/ucloud-sdk-go/ucloud"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/request"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/response"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+)
+
+// setLogin sets how to log in to a new instance: with the key pair of sshKey if it is
+// set, otherwise with the password returned by password.
+func (s *Service) setLogin(req *CreateUHostInstanceRequestPlus, sshKey *infrav1.SSHKeySpec, password func() (string, error)) error {
+	if sshKey != nil {
+		keyPairId, err := s.reconcileKeyPair(sshKey)
+		if err != nil {
+			return err
+		}
+		req.LoginMode = ucloud.String("KeyPair")
+		req.KeyPairId = ucloud.String(keyPairId)
+		return nil
+	}
+	pass, err := password()
+	if err != nil {
+		return err
+	}
+	req.LoginMode = ucloud.String("Password")
+	req.Password = ucloud.String(pass)
+	return nil
+}
+
+// reconcileKeyPair returns the id of the key pair of sshKey. A public key is imported
+// once per cluster, under a name derived from the group name and the key.
+func (s *Service) reconcileKeyPair(sshKey *infrav1.SSHKeySpec) (string, error) {
+	if sshKey.KeyPairId != "" {
+		return sshKey.KeyPairId, nil
+	}
+	if sshKey.PublicKey == "" {
+		return "", errors.New("sshKey must set publicKey or keyPairId")
+	}
+	publicKey := strings.TrimSpace(sshKey.PublicKey)
+	sum := sha256.Sum256([]byte(publicKey))
+	name := s.keyPairPrefix() + hex.EncodeToString(sum[:])[:12]
+
+	keyPairs, err := s.describeKeyPairs()
+	if err != nil {
+		return "", err
+	}
+	for _, keyPair := range keyPairs {
+		if keyPair.KeyPairName == name {
+			return keyPair.KeyPairId, nil
+		}
+	}
+
+	s.scope.Info("import key pair", "name", name)
+	req := &ImportUHostKeyPairsRequest{}
+	req.Region = ucloud.String(s.scope.Region())
+	req.ProjectId = ucloud.String(s.scope.ProjectId())
+	req.KeyPairName = ucloud.String(name)
+	req.PublicKeyBody = ucloud.String(publicKey)
+	res, err := s.keyPairClient.ImportUHostKeyPairs(req)
+	if err != nil {
+		return "", errors.Wrap(err, "import key pair failed")
+	}
+	return res.KeyPairId, nil
+}
+
+// DeleteKeyPairs deletes the key pairs imported for the cluster.
+func (s *Service) DeleteKeyPairs() error {
+	if s.scope.GroupName() == "" {
+		return nil
+	}
+	keyPairs, err := s.describeKeyPairs()
+	if err != nil {
+		return err
+	}
+	req := &DeleteUHostKeyPairsRequest{}
+	req.Region = ucloud.String(s.scope.Region())
+	req.ProjectId = ucloud.String(s.scope.ProjectId())
+	for _, keyPair := range keyPairs {
+		if strings.HasPrefix(keyPair.KeyPairName, s.keyPairPrefix()) {
+			req.KeyPairIds = append(req.KeyPairIds, keyPair.KeyPairId)
+		}
+	}
+	if len(req.KeyPairIds) == 0 {
+		return nil
+	}
+	s.scope.Info("delete key pairs", "keypairids", req.KeyPairIds)
+	if _, err := s.keyPairClient.DeleteUHostKeyPairs(req); err != nil {
+		return errors.Wrap(err, "delete key pairs failed")
+	}
+	return nil
+}
+
+// keyPairPrefix is the name prefix of the key pairs imported for the cluster.
+func (s *Service) keyPairPrefix() string {
+	return s.scope.GroupName() + "-"
+}
+
+func (s *Service) describeKeyPairs() ([]KeyPair, error) {
+	var keyPairs []KeyPair
+	req := &DescribeUHostKeyPairsRequest{}
+	req.Region = ucloud.String(s.scope.Region())
+	req.ProjectId = ucloud.String(s.scope.ProjectId())
+	req.Limit = ucloud.Int(100)
+	for {
+		req.Offset = ucloud.Int(len(keyPairs))
+		res, err := s.keyPairClient.DescribeUHostKeyPairs(req)
+		if err != nil {
+			return nil, errors.Wrap(err, "describe key pairs failed")
+		}
+		keyPairs = append(keyPairs, res.KeyPairs...)
+		if len(res.KeyPairs) == 0 || len(keyPairs) >= res.TotalCount {
+			return keyPairs, nil
+		}
+	}
+}
+
+// KeyPair
+type KeyPair struct {
+	KeyPairId          string
+	KeyPairName        string
+	KeyPairFingerPrint string
+	CreateTime         int
+}
+
+// ImportUHostKeyPairsRequest
+type ImportUHostKeyPairsRequest struct {
+	request.CommonBase
+	KeyPairName   *string `required:"true"`
+	PublicKeyBody *string `required:"true"`
+}
+
+// ImportUHostKeyPairsResponse
+type ImportUHostKeyPairsResponse struct {
+	response.CommonBase
+	KeyPairId          string
+	KeyPairName        string
+	KeyPairFingerPrint string
+}
+
+// DescribeUHostKeyPairsRequest
+type DescribeUHostKeyPairsRequest struct {
+	request.CommonBase
+	KeyPairName *string `required:"false"`
+	Offset      *int    `required:"false"`
+	Limit       *int    `required:"false"`
+}
+
+// DescribeUHostKeyPairsResponse
+type DescribeUHostKeyPairsResponse struct {
+	response.CommonBase
+	KeyPairs   []KeyPair
+	TotalCount int
+}
+
+// DeleteUHostKeyPairsRequest
+type DeleteUHostKeyPairsRequest struct {
+	request.CommonBase
+	KeyPairIds []string `required:"true"`
+}
+
+// DeleteUHostKeyPairsResponse
+type DeleteUHostKeyPairsResponse struct {
+	response.CommonBase
+}
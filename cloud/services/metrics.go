@@ -0,0 +1,88 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/ucloud/ucloud-sdk-go/ucloud"
+	uerr "github.com/ucloud/ucloud-sdk-go/ucloud/error"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/request"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/response"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	outcomeSuccess = "success"
+	outcomeError   = "error"
+)
+
+var (
+	apiRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "capu_ucloud_api_requests_total",
+			Help: "Total number of UCloud API requests, including retries.",
+		},
+		[]string{"action", "region", "retcode", "outcome"},
+	)
+
+	apiRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "capu_ucloud_api_request_duration_seconds",
+			Help:    "Latency of UCloud API requests in seconds.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"action", "region", "retcode", "outcome"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(apiRequestsTotal, apiRequestDuration)
+}
+
+// observeRequest records a single attempt of req that started at start.
+func observeRequest(req request.Common, start time.Time, err error) {
+	retCode, outcome := "0", outcomeSuccess
+	if err != nil {
+		outcome = outcomeError
+		retCode = "-1"
+		if e, ok := err.(uerr.ServerError); ok {
+			retCode = strconv.Itoa(e.Code())
+			if e.Code() < 0 {
+				retCode = "http_" + strconv.Itoa(e.StatusCode())
+			}
+		}
+	}
+
+	labels := prometheus.Labels{
+		"action":  req.GetAction(),
+		"region":  req.GetRegion(),
+		"retcode": retCode,
+		"outcome": outcome,
+	}
+	apiRequestsTotal.With(labels).Inc()
+	apiRequestDuration.With(labels).Observe(time.Since(start).Seconds())
+}
+
+// metricsHandler records every request of the sdk clients. The sdk sets the request
+// time whenever an attempt starts, so it is used as the start of the attempt.
+func metricsHandler(_ *ucloud.Client, req request.Common, resp response.Common, err error) (response.Common, error) {
+	observeRequest(req, req.GetRequestTime(), err)
+	return resp, err
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/ucloud/ucloud-sdk-go/ucloud"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	g := NewWithT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		switch action := r.Form.Get("Action"); action {
+		case "DescribeVPC":
+			_, _ = w.Write([]byte(`{"Action":"DescribeVPCResponse","RetCode":0,"DataSet":[]}`))
+		case "CreateVPC":
+			_, _ = w.Write([]byte(`{"Action":"CreateVPCResponse","RetCode":230,"Message":"Params [Network] not available"}`))
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	backoffDelay = func(int) time.Duration { return 0 }
+	defer func() { backoffDelay = getExpBackoffDelay }()
+	apiRequestsTotal.Reset()
+	apiRequestDuration.Reset()
+	svc := newHTTPTestService(g, server.URL)
+
+	describeReq := svc.vpcClient.NewDescribeVPCRequest()
+	describeReq.Region = ucloud.String(svc.scope.Region())
+	_, err := svc.vpcClient.DescribeVPC(describeReq)
+	g.Expect(err).NotTo(HaveOccurred())
+	createReq := svc.vpcClient.NewCreateVPCRequest()
+	createReq.Region = ucloud.String(svc.scope.Region())
+	_, err = svc.vpcClient.CreateVPC(createReq)
+	g.Expect(err).To(HaveOccurred())
+	subnetReq := svc.vpcClient.NewCreateSubnetRequest()
+	subnetReq.Region = ucloud.String(svc.scope.Region())
+	_, err = svc.vpcClient.CreateSubnet(subnetReq)
+	g.Expect(err).To(HaveOccurred())
+
+	tests := []struct {
+		action  string
+		retCode string
+		outcome string
+	}{
+		{"DescribeVPC", "0", outcomeSuccess},
+		{"CreateVPC", "230", outcomeError},
+		{"CreateSubnet", "http_503", outcomeError},
+	}
+	for _, tt := range tests {
+		labels := prometheus.Labels{"action": tt.action, "region": "cn-bj2", "retcode": tt.retCode, "outcome": tt.outcome}
+		g.Expect(testutil.ToFloat64(apiRequestsTotal.With(labels))).To(Equal(float64(1)), "%v", labels)
+
+		metric := &dto.Metric{}
+		g.Expect(apiRequestDuration.With(labels).(prometheus.Histogram).Write(metric)).To(Succeed())
+		g.Expect(metric.GetHistogram().GetSampleCount()).To(Equal(uint64(1)), "%v", labels)
+	}
+	// no other label sets were recorded
+	g.Expect(testutil.CollectAndCount(apiRequestsTotal)).To(Equal(len(tests)))
+	g.Expect(testutil.CollectAndCount(apiRequestDuration)).To(Equal(len(tests)))
+}
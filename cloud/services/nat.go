@@ -17,17 +17,40 @@ limitations under the License.
 package services
 
 import (
+	"fmt"
+
 	"github.com/pkg/errors"
 	"github.com/ucloud/ucloud-sdk-go/services/vpc"
 	"github.com/ucloud/ucloud-sdk-go/ucloud"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
 )
 
+// ReconcileNat makes sure the NAT gateway of the cluster exists and serves the subnets
+// of the cluster. A recorded NAT gateway that was deleted is created again, unless it was
+// given by the spec.
 func (s *Service) ReconcileNat() error {
-	if len(s.scope.UCloudCluster.Status.Network.Nat.NatGatewayId) > 0 {
-		return nil
+	natSpec := s.scope.UCloudCluster.Spec.Network.Nat
+	status := &s.scope.UCloudCluster.Status.Network.Nat
+	driftedId := status.NatGatewayId
+	if driftedId != "" {
+		natGW, err := s.describeNatGW(driftedId)
+		if err != nil {
+			return err
+		}
+		if natGW != nil {
+			if err := s.repairNat(natGW); err != nil {
+				return err
+			}
+			return s.updateNat(driftedId)
+		}
+		if natSpec.NatGateway.NatGatewayId == driftedId {
+			s.reportDrift("nat gateway "+driftedId, "not found", false)
+			return errors.Errorf("nat gateway %s not found", driftedId)
+		}
 	}
 	s.scope.Info("reconcile nat")
-	natSpec := s.scope.UCloudCluster.Spec.Network.Nat
 	vpcId := s.scope.UCloudCluster.Status.Network.VPC.VpcId
 	if vpcId == "" {
 		return errors.Errorf("vpc is not created")
@@ -41,8 +64,9 @@ func (s *Service) ReconcileNat() error {
 	var finalNatGW *vpc.NatGatewayDataSet
 	natGWs, err := s.vpcClient.DescribeNATGW(req)
 	if err != nil {
-		return errors.Errorf("describe nat gateway failed: %s", err.Error())
+		return errors.Wrap(err, "describe nat gateway failed")
 	}
+	var newEIP *infrav1.EIP
 	natGWExist := false
 	for _, natGW := range natGWs.DataSet {
 		if (natGW.NATGWId == natSpec.NatGateway.NatGatewayId || natGW.NATGWName == natSpec.NatGateway.Name) && natGW.VPCId == vpcId {
@@ -51,20 +75,33 @@ func (s *Service) ReconcileNat() error {
 			break
 		}
 	}
+	firewallId := s.scope.UCloudCluster.Status.Network.Firewall.FirewallId
+	if firewallId == "" {
+		return errors.Errorf("firewall is not created")
+	}
 	if !natGWExist {
-		subnetId := s.scope.UCloudCluster.Status.Network.Subnet.SubnetId
-		if subnetId == "" {
+		subnetIds := s.subnetIds()
+		if len(subnetIds) == 0 {
 			return errors.Errorf("subnet is not created")
 		}
-		firewall, err := s.getFirewall(s.scope.UCloudCluster.Spec.Network.Firewall.FirewallId)
-		if err != nil {
-			return err
+		// the EIP of a deleted NAT gateway is kept if it was not released with it
+		var eip infrav1.EIP
+		if driftedId != "" && status.EIP.EIPId != "" {
+			eipSet, err := s.describeEIP(status.EIP.EIPId)
+			if err != nil {
+				return err
+			}
+			if eipSet != nil && eipSet.Resource.ResourceId == "" {
+				eip = status.EIP
+			}
 		}
-		firewallId := firewall.FirewallId
-		eip, err := s.createEIP(natSpec.EIP)
-		if err != nil {
-			return err
+		if eip.EIPId == "" {
+			eip, err = s.createEIP(natSpec.EIP)
+			if err != nil {
+				return err
+			}
 		}
+		newEIP = &eip
 
 		// Create Nat
 		req := s.vpcClient.NewCreateNATGWRequest()
@@ -78,11 +115,11 @@ func (s *Service) ReconcileNat() error {
 		}
 		req.FirewallId = ucloud.String(firewallId)
 		req.VPCId = ucloud.String(vpcId)
-		req.SubnetworkIds = append(req.SubnetworkIds, subnetId)
+		req.SubnetworkIds = subnetIds
 		req.EIPIds = append(req.EIPIds, eip.EIPId)
 		newNat, err := s.vpcClient.CreateNATGW(req)
 		if err != nil {
-			return errors.Errorf("create nat gw failed: %s", err.Error())
+			return errors.Wrap(err, "create nat gw failed")
 		}
 		finalNatGW = &vpc.NatGatewayDataSet{
 			FirewallId: firewallId,
@@ -94,23 +131,161 @@ func (s *Service) ReconcileNat() error {
 			Bandwidth: eip.Bandwidth,
 			EIPId:     eip.EIPId,
 		})
-		finalNatGW.SubnetSet = append(finalNatGW.SubnetSet, vpc.NatGatewaySubnetSet{
-			SubnetworkId: subnetId,
-		})
+		for _, subnetId := range subnetIds {
+			finalNatGW.SubnetSet = append(finalNatGW.SubnetSet, vpc.NatGatewaySubnetSet{
+				SubnetworkId: subnetId,
+			})
+		}
 	}
 
 	s.scope.Info("reconcile nat success", "status", finalNatGW)
+	if driftedId != "" {
+		s.reportDrift("nat gateway "+driftedId, "not found, replaced by "+finalNatGW.NATGWId, true)
+	}
+
+	status.NatGatewayId = finalNatGW.NATGWId
+	status.Name = finalNatGW.NATGWName
+	status.VpcId = finalNatGW.VPCId
+	status.Firewall.FirewallId = finalNatGW.FirewallId
+	status.EIP.EIPId = finalNatGW.IPSet[0].EIPId
+	status.EIP.Bandwidth = finalNatGW.IPSet[0].Bandwidth
+	status.SubnetIds = nil
+	for _, subnet := range finalNatGW.SubnetSet {
+		status.SubnetIds = append(status.SubnetIds, subnet.SubnetworkId)
+	}
+	if newEIP != nil {
+		status.EIP.ChargeType = newEIP.ChargeType
+		status.EIP.ExpireTime = newEIP.ExpireTime
+	}
+	return s.updateNat(finalNatGW.NATGWId)
+}
+
+// describeNatGW returns the NAT gateway with the id, or nil if it doesn't exist.
+func (s *Service) describeNatGW(natId string) (*vpc.NatGatewayDataSet, error) {
+	req := s.vpcClient.NewDescribeNATGWRequest()
+	req.Region = ucloud.String(s.scope.Region())
+	req.ProjectId = ucloud.String(s.scope.ProjectId())
+	req.NATGWIds = []string{natId}
+	res, err := s.vpcClient.DescribeNATGW(req)
+	if err != nil {
+		if common.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "describe nat gateway %s failed", natId)
+	}
+	for i := range res.DataSet {
+		if res.DataSet[i].NATGWId == natId {
+			return &res.DataSet[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// repairNat restores the firewall and the subnets of the NAT gateway if they were
+// changed outside of the cluster. A drift is only reported as repaired once it is.
+func (s *Service) repairNat(natGW *vpc.NatGatewayDataSet) error {
+	network := &s.scope.UCloudCluster.Status.Network
+	status := &network.Nat
+	resource := "nat gateway " + natGW.NATGWId
+	if firewallId := network.Firewall.FirewallId; firewallId != "" && status.Firewall.FirewallId != "" && natGW.FirewallId != status.Firewall.FirewallId {
+		message := fmt.Sprintf("has firewall %s instead of %s", natGW.FirewallId, status.Firewall.FirewallId)
+		if err := s.grantNatFirewall(natGW.NATGWId, firewallId); err != nil {
+			s.reportDrift(resource, message, false)
+			return err
+		}
+		s.reportDrift(resource, message+", restored", true)
+		status.Firewall.FirewallId = firewallId
+	}
+
+	var subnetIds []string
+	for _, subnet := range natGW.SubnetSet {
+		subnetIds = append(subnetIds, subnet.SubnetworkId)
+	}
+	// the subnets are not recorded for clusters created with a single subnet
+	if status.SubnetIds == nil {
+		status.SubnetIds = subnetIds
+		return nil
+	}
+	if sameIds(subnetIds, status.SubnetIds) {
+		return nil
+	}
+	message := fmt.Sprintf("serves subnets %v instead of %v", subnetIds, status.SubnetIds)
+	if err := s.updateNatSubnets(natGW.NATGWId, status.SubnetIds); err != nil {
+		s.reportDrift(resource, message, false)
+		return err
+	}
+	s.reportDrift(resource, message+", restored", true)
+	return nil
+}
+
+// updateNat attaches the firewall and all the subnets of the cluster to its NAT gateway.
+func (s *Service) updateNat(natId string) error {
+	network := &s.scope.UCloudCluster.Status.Network
+	if firewallId := network.Firewall.FirewallId; firewallId != "" && firewallId != network.Nat.Firewall.FirewallId {
+		if err := s.grantNatFirewall(natId, firewallId); err != nil {
+			return err
+		}
+		network.Nat.Firewall.FirewallId = firewallId
+	}
 
-	s.scope.UCloudCluster.Status.Network.Firewall.FirewallId = finalNatGW.FirewallId
-	s.scope.UCloudCluster.Status.Network.Nat.NatGatewayId = finalNatGW.NATGWId
-	s.scope.UCloudCluster.Status.Network.Nat.Name = finalNatGW.NATGWName
-	s.scope.UCloudCluster.Status.Network.Nat.VpcId = finalNatGW.VPCId
-	s.scope.UCloudCluster.Status.Network.Nat.Firewall.FirewallId = finalNatGW.FirewallId
-	s.scope.UCloudCluster.Status.Network.Nat.EIP.EIPId = finalNatGW.IPSet[0].EIPId
-	s.scope.UCloudCluster.Status.Network.Nat.EIP.Bandwidth = finalNatGW.IPSet[0].Bandwidth
+	subnetIds := s.subnetIds()
+	if len(subnetIds) == 0 || sameIds(subnetIds, network.Nat.SubnetIds) {
+		return nil
+	}
+	if err := s.updateNatSubnets(natId, subnetIds); err != nil {
+		return err
+	}
+	network.Nat.SubnetIds = subnetIds
 	return nil
 }
 
+// grantNatFirewall attaches the firewall to the NAT gateway.
+func (s *Service) grantNatFirewall(natId, firewallId string) error {
+	s.scope.Info("grant firewall", "firewallId", firewallId, "resourceId", natId)
+	req := s.unetClient.NewGrantFirewallRequest()
+	req.Region = ucloud.String(s.scope.Region())
+	req.ProjectId = ucloud.String(s.scope.ProjectId())
+	req.FWId = ucloud.String(firewallId)
+	req.ResourceType = ucloud.String("unatgw")
+	req.ResourceId = ucloud.String(natId)
+	if _, err := s.unetClient.GrantFirewall(req); err != nil {
+		return errors.Wrapf(err, "grant firewall %s to nat gateway %s failed", firewallId, natId)
+	}
+	return nil
+}
+
+// updateNatSubnets makes the NAT gateway serve the subnets.
+func (s *Service) updateNatSubnets(natId string, subnetIds []string) error {
+	s.scope.Info("update nat gateway subnets", "natgwid", natId, "subnetids", subnetIds)
+	req := s.vpcClient.NewUpdateNATGWSubnetRequest()
+	req.Region = ucloud.String(s.scope.Region())
+	req.ProjectId = ucloud.String(s.scope.ProjectId())
+	req.NATGWId = ucloud.String(natId)
+	req.SubnetworkIds = subnetIds
+	if _, err := s.vpcClient.UpdateNATGWSubnet(req); err != nil {
+		return errors.Wrapf(err, "update subnets of nat gateway %s failed", natId)
+	}
+	return nil
+}
+
+// sameIds returns true if a and b have the same ids, in any order.
+func sameIds(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := map[string]int{}
+	for _, id := range a {
+		count[id]++
+	}
+	for _, id := range b {
+		if count[id] == 0 {
+			return false
+		}
+		count[id]--
+	}
+	return true
+}
+
 func (s *Service) DeleteNat() error {
 
 	s.scope.Info("delete nat")
@@ -129,9 +304,9 @@ func (s *Service) DeleteNat() error {
 	delReq.ProjectId = ucloud.String(s.scope.ProjectId())
 	delReq.NATGWId = ucloud.String(id)
 	delReq.ReleaseEip = ucloud.Bool(true)
-	res, err := s.vpcClient.DeleteNATGW(delReq)
-	if err != nil && res.GetRetCode() != 54002 {
-		return errors.Errorf("delete natgateway %s failed: %s", id, err.Error())
+	_, err := s.vpcClient.DeleteNATGW(delReq)
+	if err != nil && !common.IsNotFound(err) {
+		return errors.Wrapf(err, "delete natgateway %s failed", id)
 	}
 	s.scope.Info("delete nat success", "natgatewayid", id)
 	return nil
@@ -0,0 +1,98 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ucloud/ucloud-sdk-go/ucloud"
+	uerr "github.com/ucloud/ucloud-sdk-go/ucloud/error"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/request"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/response"
+
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
+)
+
+// maxRetries is the number of times a failed request is retried.
+const maxRetries = 3
+
+// backoffDelay returns how long to wait before the retry after retryCount retries.
+var backoffDelay = getExpBackoffDelay
+
+// idempotentActionPrefixes lists the prefixes of read-only actions, which can be
+// retried whenever the failure is transient.
+var idempotentActionPrefixes = []string{"Describe", "Get", "List", "Check", "Search"}
+
+// isIdempotentAction returns true if sending action twice has the same effect as sending it once.
+func isIdempotentAction(action string) bool {
+	for _, prefix := range idempotentActionPrefixes {
+		if strings.HasPrefix(action, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldRetry classifies the error of an action. Throttling and failures to connect
+// mean the request was never processed, so every action is retried. Other transient
+// failures, such as timeouts, 5xx responses and retcodes the sdk deems retryable,
+// may happen after the request took effect and are only retried for idempotent actions.
+func shouldRetry(action string, err error) bool {
+	if err == nil {
+		return false
+	}
+	if common.IsThrottled(err) || isConnectError(err) {
+		return true
+	}
+	if !isIdempotentAction(action) {
+		return false
+	}
+	if e, ok := err.(uerr.Error); ok {
+		return e.Retryable()
+	}
+	return uerr.IsNetworkError(err)
+}
+
+// isConnectError returns true if the connection to the API could not be established.
+func isConnectError(err error) bool {
+	if e, ok := err.(uerr.ClientError); ok {
+		err = e.OriginError()
+	}
+	if e, ok := err.(*url.Error); ok {
+		err = e.Err
+	}
+	e, ok := err.(*net.OpError)
+	return ok && e.Op == "dial"
+}
+
+// retryHandler retries requests of the sdk clients according to shouldRetry.
+func retryHandler(c *ucloud.Client, req request.Common, resp response.Common, err error) (response.Common, error) {
+	retryCount := req.GetRetryCount()
+	if retryCount >= maxRetries || !shouldRetry(req.GetAction(), err) {
+		return resp, err
+	}
+
+	req.SetRetryCount(retryCount + 1)
+	time.Sleep(backoffDelay(retryCount))
+
+	// the resp will be changed after invoke
+	err = c.InvokeAction(req.GetAction(), req, resp)
+	return resp, err
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/auth"
+	uerr "github.com/ucloud/ucloud-sdk-go/ucloud/error"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/scope"
+)
+
+// timeoutError is a net.Error for a request that timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// newHTTPTestService returns a Service whose sdk clients send their requests to baseURL.
+func newHTTPTestService(g *WithT, baseURL string) *Service {
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	ucloudCluster := &infrav1.UCloudCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"},
+		Spec: infrav1.UCloudClusterSpec{
+			ProjectId: "org-test",
+			Region:    "cn-bj2",
+			API:       &infrav1.APISpec{BaseURL: baseURL},
+		},
+	}
+	clusterScope, err := scope.NewClusterScope(scope.ClusterScopeParams{
+		UCloudClients: scope.UCloudClients{Credential: &auth.Credential{PublicKey: "public", PrivateKey: "private"}},
+		Client:        fake.NewFakeClientWithScheme(scheme, ucloudCluster),
+		Cluster:       &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"}},
+		UCloudCluster: ucloudCluster,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	return NewService(clusterScope)
+}
+
+func TestShouldRetry(t *testing.T) {
+	unavailable := uerr.NewServerStatusError(http.StatusServiceUnavailable, "503 Service Unavailable")
+	throttled := uerr.NewServerStatusError(http.StatusTooManyRequests, "429 Too Many Requests")
+	timeout := uerr.NewClientError(uerr.ErrNetwork, &url.Error{Op: "Post", URL: "https://api.ucloud.cn", Err: timeoutError{}})
+	connect := uerr.NewClientError(uerr.ErrNetwork, &url.Error{Op: "Post", URL: "https://api.ucloud.cn", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}})
+	invalid := uerr.NewServerCodeError(230, "Params [ImageId] not available")
+
+	tests := []struct {
+		action string
+		err    error
+		want   bool
+	}{
+		{"DescribeUHostInstance", nil, false},
+		{"DescribeUHostInstance", unavailable, true},
+		{"DescribeUHostInstance", timeout, true},
+		{"DescribeUHostInstance", invalid, false},
+		{"ListBusinessGroup", unavailable, true},
+		{"SearchBusinessGroupResource", unavailable, true},
+		{"SearchBusinessGroupResource", timeout, true},
+		{"CreateUHostInstance", unavailable, false},
+		{"CreateUHostInstance", timeout, false},
+		{"AllocateEIP", unavailable, false},
+		{"CreateUHostInstance", throttled, true},
+		{"CreateUHostInstance", connect, true},
+		{"DeleteVPC", connect, true},
+	}
+	g := NewWithT(t)
+	for _, tt := range tests {
+		g.Expect(shouldRetry(tt.action, tt.err)).To(Equal(tt.want), "%s: %v", tt.action, tt.err)
+	}
+}
+
+func TestRetryStopsAtMaxRetries(t *testing.T) {
+	g := NewWithT(t)
+
+	var mu sync.Mutex
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		mu.Lock()
+		requests[r.Form.Get("Action")]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var delays []int
+	backoffDelay = func(retryCount int) time.Duration {
+		delays = append(delays, retryCount)
+		return 0
+	}
+	defer func() { backoffDelay = getExpBackoffDelay }()
+
+	svc := newHTTPTestService(g, server.URL)
+
+	// a read-only action of an sdk client is retried maxRetries times, backing off longer each time
+	_, err := svc.vpcClient.DescribeVPC(svc.vpcClient.NewDescribeVPCRequest())
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(requests["DescribeVPC"]).To(Equal(1 + maxRetries))
+	g.Expect(delays).To(Equal([]int{0, 1, 2}))
+
+	// so is a read-only action sent through doRequest
+	delays = nil
+	_, err = svc.groupClient.ListBusinessGroup(&ListBusinessGroupRequest{})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(requests["ListBusinessGroup"]).To(Equal(1 + maxRetries))
+	g.Expect(delays).To(Equal([]int{0, 1, 2}))
+
+	// a create may have taken effect and is not retried
+	_, err = svc.vpcClient.CreateVPC(svc.vpcClient.NewCreateVPCRequest())
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(requests["CreateVPC"]).To(Equal(1))
+}
+
+func TestGetExpBackoffDelay(t *testing.T) {
+	g := NewWithT(t)
+
+	for retryCount, min := range []time.Duration{100, 400, 1600} {
+		delay := getExpBackoffDelay(retryCount)
+		g.Expect(delay).To(BeNumerically(">=", min*time.Millisecond))
+		g.Expect(delay).To(BeNumerically("<", 2*min*time.Millisecond))
+	}
+	// the delay stops growing after 7 retries
+	g.Expect(getExpBackoffDelay(20)).To(BeNumerically("<", 2*(1<<14)*100*time.Millisecond))
+}
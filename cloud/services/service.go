@@ -17,12 +17,15 @@ limitations under the License.
 package services
 
 import (
+	"github.com/ucloud/ucloud-sdk-go/private/protocol/http"
 	"github.com/ucloud/ucloud-sdk-go/services/udisk"
 	"github.com/ucloud/ucloud-sdk-go/services/uhost"
 	"github.com/ucloud/ucloud-sdk-go/services/ulb"
 	"github.com/ucloud/ucloud-sdk-go/services/unet"
 	"github.com/ucloud/ucloud-sdk-go/services/uphost"
 	"github.com/ucloud/ucloud-sdk-go/services/vpc"
+	"github.com/ucloud/ucloud-sdk-go/ucloud"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/request"
 
 	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/scope"
 )
@@ -33,24 +36,101 @@ import (
 type Service struct {
 	scope *scope.ClusterScope
 
-	// Helper clients for GCP.
-	uhostClient  *uhost.UHostClient
-	unetClient   *unet.UNetClient
-	vpcClient    *vpc.VPCClient
-	ulbClient    *ulb.ULBClient
-	udiskClient  *udisk.UDiskClient
-	uphostClient *uphost.UPHostClient
+	// Helper clients for UCloud.
+	uhostClient   UHostAPI
+	unetClient    UNetAPI
+	vpcClient     VPCAPI
+	ulbClient     ULBAPI
+	udiskClient   UDiskAPI
+	uphostClient  UPHostAPI
+	uk8sClient    UK8SAPI
+	groupClient   BusinessGroupAPI
+	keyPairClient KeyPairAPI
+
+	// httpClient sends the requests of both the sdk clients and doRequest.
+	httpClient http.Client
+
+	// drifts are the drifts of the network resources found while reconciling.
+	drifts []Drift
 }
 
 // NewService returns a new service given the ucloud api client.
 func NewService(newScope *scope.ClusterScope) *Service {
-	return &Service{
-		scope:        newScope,
-		uhostClient:  uhost.NewClient(newScope.Config, newScope.Credential),
-		unetClient:   unet.NewClient(newScope.Config, newScope.Credential),
-		vpcClient:    vpc.NewClient(newScope.Config, newScope.Credential),
-		ulbClient:    ulb.NewClient(newScope.Config, newScope.Credential),
-		udiskClient:  udisk.NewClient(newScope.Config, newScope.Credential),
-		uphostClient: uphost.NewClient(newScope.Config, newScope.Credential),
+	return NewServiceWithClients(newScope, Clients{})
+}
+
+// NewServiceWithClients returns a new service using the given API implementations,
+// e.g. fakes in tests. The sdk based implementations are used for nil fields.
+func NewServiceWithClients(newScope *scope.ClusterScope, clients Clients) *Service {
+	s := &Service{
+		scope:         newScope,
+		httpClient:    newTransportClient(newScope.Transport),
+		uhostClient:   clients.UHost,
+		unetClient:    clients.UNet,
+		vpcClient:     clients.VPC,
+		ulbClient:     clients.ULB,
+		udiskClient:   clients.UDisk,
+		uphostClient:  clients.UPHost,
+		uk8sClient:    clients.UK8S,
+		groupClient:   clients.BusinessGroup,
+		keyPairClient: clients.KeyPair,
+	}
+	actions := &actionClient{s: s}
+	if s.uhostClient == nil {
+		c := uhost.NewClient(newScope.Config, newScope.Credential)
+		s.setupSDKClient(c.Client)
+		s.uhostClient = &sdkUHostClient{UHostClient: c, actions: actions}
+	}
+	if s.unetClient == nil {
+		c := unet.NewClient(newScope.Config, newScope.Credential)
+		s.setupSDKClient(c.Client)
+		s.unetClient = c
+	}
+	if s.vpcClient == nil {
+		c := vpc.NewClient(newScope.Config, newScope.Credential)
+		s.setupSDKClient(c.Client)
+		s.vpcClient = c
+	}
+	if s.ulbClient == nil {
+		c := ulb.NewClient(newScope.Config, newScope.Credential)
+		s.setupSDKClient(c.Client)
+		s.ulbClient = &sdkULBClient{ULBClient: c, actions: actions}
+	}
+	if s.udiskClient == nil {
+		c := udisk.NewClient(newScope.Config, newScope.Credential)
+		s.setupSDKClient(c.Client)
+		s.udiskClient = c
+	}
+	if s.uphostClient == nil {
+		c := uphost.NewClient(newScope.Config, newScope.Credential)
+		s.setupSDKClient(c.Client)
+		s.uphostClient = &sdkUPHostClient{UPHostClient: c, actions: actions}
+	}
+	if s.uk8sClient == nil {
+		s.uk8sClient = actions
+	}
+	if s.groupClient == nil {
+		s.groupClient = actions
+	}
+	if s.keyPairClient == nil {
+		s.keyPairClient = actions
+	}
+	return s
+}
+
+// setupSDKClient makes an sdk client share the transport, credential, metrics and
+// retry policy of doRequest.
+func (s *Service) setupSDKClient(c *ucloud.Client) {
+	_ = c.SetHttpClient(s.httpClient)
+	_ = c.AddRequestHandler(s.refreshCredential)
+	_ = c.AddResponseHandler(metricsHandler)
+	_ = c.AddResponseHandler(retryHandler)
+}
+
+// refreshCredential updates the credential shared with the ucloud clients before each request.
+func (s *Service) refreshCredential(_ *ucloud.Client, req request.Common) (request.Common, error) {
+	if _, err := s.scope.CurrentCredential(); err != nil {
+		return req, err
 	}
+	return req, nil
 }
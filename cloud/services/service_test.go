@@ -0,0 +1,90 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/auth"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/scope"
+)
+
+// expiringCredentialProvider returns a new key pair expiring after ttl on every call.
+type expiringCredentialProvider struct {
+	ttl   time.Duration
+	calls int
+}
+
+func (p *expiringCredentialProvider) Credential() (*auth.Credential, error) {
+	p.calls++
+	credential := auth.NewCredential()
+	credential.PublicKey = fmt.Sprintf("public-%d", p.calls)
+	credential.PrivateKey = "private"
+	credential.CanExpire = true
+	credential.Expires = time.Now().Add(p.ttl)
+	return &credential, nil
+}
+
+func TestRefreshExpiredCredential(t *testing.T) {
+	g := NewWithT(t)
+
+	var publicKeys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		publicKeys = append(publicKeys, r.Form.Get("PublicKey"))
+		_, _ = w.Write([]byte(`{"Action":"DescribeVPCResponse","RetCode":0,"DataSet":[]}`))
+	}))
+	defer server.Close()
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	ucloudCluster := &infrav1.UCloudCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"},
+		Spec: infrav1.UCloudClusterSpec{
+			ProjectId: "org-test",
+			Region:    "cn-bj2",
+			API:       &infrav1.APISpec{BaseURL: server.URL},
+		},
+	}
+	provider := &expiringCredentialProvider{ttl: 10 * time.Millisecond}
+	clusterScope, err := scope.NewClusterScope(scope.ClusterScopeParams{
+		UCloudClients: scope.UCloudClients{CredentialProvider: provider},
+		Client:        fake.NewFakeClientWithScheme(scheme, ucloudCluster),
+		Cluster:       &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"}},
+		UCloudCluster: ucloudCluster,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	svc := NewService(clusterScope)
+
+	// the credential fetched with the scope has expired by the time of the request,
+	// which is sent with a refreshed one
+	time.Sleep(20 * time.Millisecond)
+	_, err = svc.vpcClient.DescribeVPC(svc.vpcClient.NewDescribeVPCRequest())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(publicKeys).To(Equal([]string{"public-2"}))
+}
@@ -23,111 +23,285 @@ import (
 	"github.com/pkg/errors"
 	"github.com/ucloud/ucloud-sdk-go/services/vpc"
 	"github.com/ucloud/ucloud-sdk-go/ucloud"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
 )
 
+// ReconcileSubnet makes sure the subnets of the cluster exist, and records them in the
+// status in the order of the spec. Recorded subnets that were deleted are created again,
+// unless they were given by the spec.
 func (s *Service) ReconcileSubnet() error {
-	if len(s.scope.UCloudCluster.Status.Network.Subnet.SubnetId) > 0 {
-		return nil
-	}
-	vpcId := s.scope.UCloudCluster.Status.Network.VPC.VpcId
+	network := &s.scope.UCloudCluster.Status.Network
+	specs := s.scope.Subnets()
+	vpcId := network.VPC.VpcId
 	if vpcId == "" {
 		return errors.Errorf("vpc is not created, subnet must be owned by a vpc")
 	}
-	s.scope.Info("reconcile subnet")
-	// Create Subnet
-	var finalSubnet *vpc.VPCSubnetInfoSet
-	subnetSpec := s.scope.UCloudCluster.Spec.Network.Subnet
-	req := s.vpcClient.NewDescribeSubnetRequest()
+	if len(network.Subnets) != len(specs) {
+		s.scope.Info("reconcile subnet")
+	}
+	existing, err := s.describeSubnets(vpcId)
+	if err != nil {
+		return err
+	}
+
+	recorded := s.subnetIds()
+	subnets := make([]infrav1.Subnet, 0, len(specs))
+	for index, subnetSpec := range specs {
+		driftedId := ""
+		if index < len(recorded) {
+			driftedId = recorded[index]
+		}
+		var finalSubnet *vpc.VPCSubnetInfoSet
+		for i, subnetInfo := range existing {
+			if subnetInfo.SubnetId == driftedId {
+				driftedId = ""
+			}
+			if finalSubnet == nil && (subnetInfo.SubnetId == subnetSpec.SubnetId ||
+				(subnetSpec.SubnetId == "" && subnetInfo.SubnetName == subnetSpec.SubnetName && subnetInfo.Tag == s.scope.GroupName())) {
+				finalSubnet = &existing[i]
+			}
+		}
+		if finalSubnet == nil {
+			if subnetSpec.SubnetId != "" {
+				if driftedId == subnetSpec.SubnetId {
+					s.reportDrift("subnet "+driftedId, "not found", false)
+				}
+				return errors.Errorf("subnet %s not found in vpc %s", subnetSpec.SubnetId, vpcId)
+			}
+			if subnetSpec.CidrBlock == "" {
+				cidrBlock, err := s.allocateSubnetCidr(subnetSpec.PrefixLength, existing)
+				if err != nil {
+					return err
+				}
+				subnetSpec.CidrBlock = cidrBlock
+			}
+			subnetInfo, err := s.createSubnet(vpcId, subnetSpec)
+			if err != nil {
+				return err
+			}
+			existing = append(existing, *subnetInfo)
+			finalSubnet = subnetInfo
+		}
+		s.scope.Info("reconcile subnet success", "status", finalSubnet)
+		if driftedId != "" {
+			s.reportDrift("subnet "+driftedId, "not found, replaced by "+finalSubnet.SubnetId, true)
+		}
+		subnets = append(subnets, infrav1.Subnet{
+			SubnetId:   finalSubnet.SubnetId,
+			VpcId:      vpcId,
+			CidrBlock:  finalSubnet.Subnet + "/" + finalSubnet.Netmask,
+			ZoneId:     subnetSpec.Zone,
+			SubnetName: finalSubnet.SubnetName,
+			Role:       subnetSpec.Role,
+		})
+	}
+	network.Subnets = subnets
+
+	controlPlane := s.roleSubnets(infrav1.SubnetRoleControlPlane)
+	if len(controlPlane) == 0 {
+		return errors.Errorf("no subnet for role %s", infrav1.SubnetRoleControlPlane)
+	}
+	network.Subnet = controlPlane[0]
+	return nil
+}
+
+// allocateSubnetCidr returns a free CIDR block in the VPC of the cluster, which doesn't
+// overlap the subnets of the VPC and the pod and service CIDR blocks of the cluster.
+// The network segments of the VPC are tried in order.
+func (s *Service) allocateSubnetCidr(prefixLength int, subnets []vpc.VPCSubnetInfoSet) (string, error) {
+	if prefixLength == 0 {
+		prefixLength = common.DefaultSubnetPrefixLength
+	}
+	var used []string
+	for _, subnet := range subnets {
+		used = append(used, subnet.Subnet+"/"+subnet.Netmask)
+	}
+	if clusterNetwork := s.scope.Cluster.Spec.ClusterNetwork; clusterNetwork != nil {
+		if clusterNetwork.Pods != nil {
+			used = append(used, clusterNetwork.Pods.CIDRBlocks...)
+		}
+		if clusterNetwork.Services != nil {
+			used = append(used, clusterNetwork.Services.CIDRBlocks...)
+		}
+	}
+	vpcStatus := s.scope.UCloudCluster.Status.Network.VPC
+	segments := vpcStatus.CidrBlocks
+	if len(segments) == 0 {
+		// only the first segment was recorded for VPCs reconciled by older versions
+		segments = []string{vpcStatus.CidrBlock}
+	}
+	var errs []error
+	for _, segment := range segments {
+		cidrBlock, err := common.AllocateCIDR(segment, prefixLength, used)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		s.scope.Info("allocate subnet cidr", "cidr", cidrBlock)
+		return cidrBlock, nil
+	}
+	return "", errors.Wrap(kerrors.NewAggregate(errs), "allocate subnet cidr failed")
+}
+
+func (s *Service) createSubnet(vpcId string, subnetSpec infrav1.SubnetSpec) (*vpc.VPCSubnetInfoSet, error) {
+	subnetCidr := strings.Split(subnetSpec.CidrBlock, "/")
+	if len(subnetCidr) != 2 {
+		return nil, errors.Errorf("subnet %s has an invalid cidr %q", subnetSpec.SubnetName, subnetSpec.CidrBlock)
+	}
+	req := s.vpcClient.NewCreateSubnetRequest()
 	req.Region = ucloud.String(s.scope.Region())
 	req.ProjectId = ucloud.String(s.scope.ProjectId())
+	req.SubnetName = ucloud.String(subnetSpec.SubnetName)
+	req.Subnet = ucloud.String(subnetCidr[0])
+	netmask, _ := strconv.Atoi(subnetCidr[1])
+	req.Netmask = ucloud.Int(netmask)
 	req.VPCId = ucloud.String(vpcId)
 	req.Tag = ucloud.String(s.scope.GroupName())
-	if subnetSpec.SubnetId != "" {
-		req.SubnetId = ucloud.String(subnetSpec.SubnetId)
+	if subnetSpec.Description != "" {
+		req.Remark = ucloud.String(subnetSpec.Description)
 	}
-	subnetName := subnetSpec.SubnetName
-	if subnetName == "" {
-		subnetName = "cluster-api-" + s.scope.Cluster.GetName() + "-subnet"
-	}
-	subnets, err := s.vpcClient.DescribeSubnet(req)
+	subnetInfo, err := s.vpcClient.CreateSubnet(req)
 	if err != nil {
-		return errors.Errorf("describe subnet failed: %s", err.Error())
-	}
-	subnetExist := false
-	for _, subnetInfo := range subnets.DataSet {
-		if subnetInfo.SubnetName == subnetName || subnetInfo.SubnetId == subnetSpec.SubnetId {
-			finalSubnet = &subnetInfo
-			subnetExist = true
-			break
-		}
-	}
-	if !subnetExist {
-		req := s.vpcClient.NewCreateSubnetRequest()
-		req.Region = ucloud.String(s.scope.Region())
-		req.ProjectId = ucloud.String(s.scope.ProjectId())
-		req.SubnetName = ucloud.String(subnetName)
-		subnetCidr := strings.Split(subnetSpec.CidrBlock, "/")
-		req.Subnet = ucloud.String(subnetCidr[0])
-		netmask, _ := strconv.Atoi(subnetCidr[1])
-		req.Netmask = ucloud.Int(netmask)
-		req.VPCId = ucloud.String(vpcId)
-		req.Tag = ucloud.String(s.scope.GroupName())
-		subnetInfo, err := s.vpcClient.CreateSubnet(req)
+		return nil, errors.Wrapf(err, "create subnet failed: name %s, cidr %s", subnetSpec.SubnetName, subnetSpec.CidrBlock)
+	}
+	return &vpc.VPCSubnetInfoSet{
+		SubnetName: subnetSpec.SubnetName,
+		Subnet:     subnetCidr[0],
+		Netmask:    subnetCidr[1],
+		SubnetId:   subnetInfo.SubnetId,
+	}, nil
+}
+
+func (s *Service) describeSubnets(vpcId string) ([]vpc.VPCSubnetInfoSet, error) {
+	var subnets []vpc.VPCSubnetInfoSet
+	req := s.vpcClient.NewDescribeSubnetRequest()
+	req.Region = ucloud.String(s.scope.Region())
+	req.ProjectId = ucloud.String(s.scope.ProjectId())
+	req.VPCId = ucloud.String(vpcId)
+	req.Limit = ucloud.Int(100)
+	for {
+		req.Offset = ucloud.Int(len(subnets))
+		res, err := s.vpcClient.DescribeSubnet(req)
 		if err != nil {
-			return errors.Errorf("create subnet failed: name %s, cidr %s", subnetName, subnetSpec.CidrBlock)
+			return nil, errors.Wrap(err, "describe subnet failed")
 		}
-		finalSubnet = &vpc.VPCSubnetInfoSet{
-			SubnetName: subnetName,
-			Subnet:     subnetCidr[0],
-			Netmask:    subnetCidr[1],
-			SubnetId:   subnetInfo.SubnetId,
+		subnets = append(subnets, res.DataSet...)
+		if len(res.DataSet) == 0 || len(subnets) >= res.TotalCount {
+			return subnets, nil
 		}
 	}
+}
 
-	s.scope.Info("reconcile subnet success", "status", finalSubnet)
-
-	s.scope.UCloudCluster.Status.Network.Subnet.CidrBlock = finalSubnet.Subnet + "/" + finalSubnet.Netmask
-	s.scope.UCloudCluster.Status.Network.Subnet.SubnetName = finalSubnet.SubnetName
-	s.scope.UCloudCluster.Status.Network.Subnet.SubnetId = finalSubnet.SubnetId
-	s.scope.UCloudCluster.Status.Network.Subnet.VpcId = vpcId
+// roleSubnets returns the subnets for role: the subnets of the role if there are any,
+// otherwise the subnets without a role. The bastion falls back to the subnets of the
+// control plane.
+func (s *Service) roleSubnets(role string) []infrav1.Subnet {
+	network := s.scope.UCloudCluster.Status.Network
+	if len(network.Subnets) == 0 && network.Subnet.SubnetId != "" {
+		// clusters created with a single subnet before the subnets were listed
+		return []infrav1.Subnet{network.Subnet}
+	}
+	for _, r := range []string{role, ""} {
+		var subnets []infrav1.Subnet
+		for _, subnet := range network.Subnets {
+			if subnet.Role == r {
+				subnets = append(subnets, subnet)
+			}
+		}
+		if len(subnets) > 0 {
+			return subnets
+		}
+	}
+	if role == infrav1.SubnetRoleBastion {
+		return s.roleSubnets(infrav1.SubnetRoleControlPlane)
+	}
 	return nil
 }
 
+// subnetFor returns the subnet for role in zone, a subnet of the zone before a subnet
+// serving any zone.
+func (s *Service) subnetFor(role, zone string) (infrav1.Subnet, error) {
+	subnets := s.roleSubnets(role)
+	for _, z := range []string{zone, ""} {
+		for _, subnet := range subnets {
+			if subnet.ZoneId == z {
+				return subnet, nil
+			}
+		}
+	}
+	return infrav1.Subnet{}, errors.Errorf("no subnet for role %s in zone %s", role, zone)
+}
+
+// subnetZones returns the zones of the subnets for role, or nil if one of them serves
+// any zone.
+func (s *Service) subnetZones(role string) []string {
+	var zones []string
+	for _, subnet := range s.roleSubnets(role) {
+		if subnet.ZoneId == "" {
+			return nil
+		}
+		zones = append(zones, subnet.ZoneId)
+	}
+	return zones
+}
+
+// subnetIds returns the ids of the subnets of the cluster.
+func (s *Service) subnetIds() []string {
+	network := s.scope.UCloudCluster.Status.Network
+	if len(network.Subnets) == 0 && network.Subnet.SubnetId != "" {
+		return []string{network.Subnet.SubnetId}
+	}
+	ids := make([]string, 0, len(network.Subnets))
+	for _, subnet := range network.Subnets {
+		ids = append(ids, subnet.SubnetId)
+	}
+	return ids
+}
+
+// DeleteSubnet deletes the subnets created for the cluster.
 func (s *Service) DeleteSubnet() error {
 
 	s.scope.Info("delete subnet")
 
-	id := s.scope.UCloudCluster.Status.Network.Subnet.SubnetId
-	if len(id) == 0 {
-		return nil
-	}
-
-	if s.scope.UCloudCluster.Spec.Network.Subnet.SubnetId == id {
-		s.scope.Info("subnet was not created by cluster-api-provider-ucloud, will not be deleted", "subnetid", id)
-		return nil
-	}
-
-	// timer := time.NewTimer(5 * time.Minute)
-	// func() {
-	// 	for {
-	// 		s.scope.Info("waiting for a few minutes because of the delay on UCloud when cleanning resources in subnet")
-	// 		select {
-	// 		case <-timer.C:
-	// 			return
-	// 		default:
-	// 			time.Sleep(30 * time.Second)
-	// 		}
-	// 	}
-	// }()
-
-	delReq := s.vpcClient.NewDeleteSubnetRequest()
-	delReq.Region = ucloud.String(s.scope.Region())
-	delReq.ProjectId = ucloud.String(s.scope.ProjectId())
-	delReq.SubnetId = ucloud.String(id)
-	res, err := s.vpcClient.DeleteSubnet(delReq)
-	if err != nil && res.GetRetCode() != 8039 {
-		return errors.Errorf("delete subnet %s failed", id)
-	}
-	s.scope.Info("delete subnet success", "subnetid", id)
+	notOwned := map[string]bool{}
+	for _, subnetSpec := range s.scope.Subnets() {
+		if subnetSpec.SubnetId != "" {
+			notOwned[subnetSpec.SubnetId] = true
+		}
+	}
+	// DeleteSubnet has no retcode telling a missing subnet apart, so the subnets that
+	// were already deleted are skipped.
+	existing := map[string]bool{}
+	if vpcId := s.scope.UCloudCluster.Status.Network.VPC.VpcId; vpcId != "" {
+		subnets, err := s.describeSubnets(vpcId)
+		if err != nil && !common.IsNotFound(err) {
+			return err
+		}
+		for _, subnet := range subnets {
+			existing[subnet.SubnetId] = true
+		}
+	}
+	for _, id := range s.subnetIds() {
+		if notOwned[id] {
+			s.scope.Info("subnet was not created by cluster-api-provider-ucloud, will not be deleted", "subnetid", id)
+			continue
+		}
+		if !existing[id] {
+			s.scope.Info("subnet was already deleted", "subnetid", id)
+			continue
+		}
+
+		delReq := s.vpcClient.NewDeleteSubnetRequest()
+		delReq.Region = ucloud.String(s.scope.Region())
+		delReq.ProjectId = ucloud.String(s.scope.ProjectId())
+		delReq.SubnetId = ucloud.String(id)
+		if _, err := s.vpcClient.DeleteSubnet(delReq); err != nil {
+			return errors.Wrapf(err, "delete subnet %s failed", id)
+		}
+		s.scope.Info("delete subnet success", "subnetid", id)
+	}
 	return nil
 }
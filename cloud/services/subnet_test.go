@@ -0,0 +1,52 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/ucloud/ucloud-sdk-go/services/vpc"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+)
+
+func TestAllocateSubnetCidr(t *testing.T) {
+	used := []vpc.VPCSubnetInfoSet{{Subnet: "172.16.0.0", Netmask: "24"}}
+
+	t.Run("tries each segment of the VPC", func(t *testing.T) {
+		g := NewWithT(t)
+		svc, ucloudCluster := newVPCTestService(g, infrav1.VPCSpec{}, &fakeVPCAPI{})
+		ucloudCluster.Status.Network.VPC.CidrBlock = "172.16.0.0/24"
+		ucloudCluster.Status.Network.VPC.CidrBlocks = []string{"172.16.0.0/24", "10.10.0.0/16"}
+
+		g.Expect(svc.allocateSubnetCidr(24, nil)).To(Equal("172.16.0.0/24"))
+		g.Expect(svc.allocateSubnetCidr(24, used)).To(Equal("10.10.0.0/24"))
+
+		_, err := svc.allocateSubnetCidr(15, used)
+		g.Expect(err).To(MatchError(ContainSubstring("172.16.0.0/24")))
+		g.Expect(err).To(MatchError(ContainSubstring("10.10.0.0/16")))
+	})
+
+	t.Run("uses the cidr block of an older status", func(t *testing.T) {
+		g := NewWithT(t)
+		svc, ucloudCluster := newVPCTestService(g, infrav1.VPCSpec{}, &fakeVPCAPI{})
+		ucloudCluster.Status.Network.VPC.CidrBlock = "172.16.0.0/16"
+
+		g.Expect(svc.allocateSubnetCidr(0, used)).To(Equal("172.16.1.0/24"))
+	})
+}
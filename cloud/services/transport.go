@@ -0,0 +1,89 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"bytes"
+	"io/ioutil"
+	gohttp "net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/ucloud/ucloud-sdk-go/private/protocol/http"
+)
+
+// transportClient implements the http.Client interface of the ucloud sdk on top of
+// a configurable transport, so that SDK clients and doRequest share proxy settings.
+type transportClient struct {
+	transport gohttp.RoundTripper
+}
+
+func newTransportClient(transport gohttp.RoundTripper) *transportClient {
+	return &transportClient{transport: transport}
+}
+
+// Send sends req and wraps the response the same way as the sdk client does.
+func (c *transportClient) Send(req *http.HttpRequest) (*http.HttpResponse, error) {
+	httpReq, err := buildNativeRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &gohttp.Client{Transport: c.transport, Timeout: req.GetTimeout()}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode >= 400 {
+		return nil, http.NewStatusError(httpResp.StatusCode, httpResp.Status)
+	}
+
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp := http.NewHttpResponse()
+	resp.SetStatusCode(httpResp.StatusCode)
+	_ = resp.SetBody(body)
+	return resp, nil
+}
+
+// buildNativeRequest converts an sdk request to a net/http request.
+func buildNativeRequest(req *http.HttpRequest) (*gohttp.Request, error) {
+	qs, err := req.BuildQueryString()
+	if err != nil {
+		return nil, errors.Errorf("cannot build query string, %s", err)
+	}
+
+	headers := req.GetHeaderMap()
+	var httpReq *gohttp.Request
+	if strings.HasPrefix(headers["Content-Type"], "application/x-www-form-urlencoded") && len(req.GetRequestBody()) == 0 {
+		httpReq, err = gohttp.NewRequest(req.GetMethod(), req.GetURL(), strings.NewReader(qs))
+	} else {
+		httpReq, err = gohttp.NewRequest(req.GetMethod(), req.String(), bytes.NewReader(req.GetRequestBody()))
+	}
+	if err != nil {
+		return nil, errors.Errorf("cannot build request, %s", err)
+	}
+
+	for k, v := range headers {
+		httpReq.Header.Set(k, v)
+	}
+	return httpReq, nil
+}
@@ -20,7 +20,6 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
@@ -30,11 +29,13 @@ import (
 	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
 )
 
+// ReconcileUGroup makes sure the business group of the cluster exists. A recorded group
+// that was deleted is created again.
 func (s *Service) ReconcileUGroup() error {
-	if len(s.scope.UCloudCluster.Status.Group.GroupId) > 0 {
-		return nil
+	status := &s.scope.UCloudCluster.Status.Group
+	if status.GroupId == "" {
+		s.scope.Info("reconcile UGroup")
 	}
-	s.scope.Info("reconcile UGroup")
 	byteArr := []byte(fmt.Sprintf("%s-%s-%s-%s-%s-%s", s.scope.ProjectId(), s.scope.Region(), s.scope.Namespace(), s.scope.UCloudCluster.Name, s.scope.UCloudCluster.Spec.Version, s.scope.Cluster.GetCreationTimestamp().String()))
 	groupName := "capu-" + uuid.NewSHA1(uuid.MustParse(common.ClusterApiUUIDNamespace), byteArr).String()
 	// check if group exist
@@ -42,30 +43,26 @@ func (s *Service) ReconcileUGroup() error {
 	req := &ListBusinessGroupRequest{}
 	req.Region = ucloud.String(s.scope.Region())
 	req.ProjectId = ucloud.String(s.scope.ProjectId())
-	req.SetAction("ListBusinessGroup")
-	req.SetRequestTime(time.Now())
-	var res ListBusinessGroupResponse
-	err := s.doRequest(req, &res)
+	res, err := s.groupClient.ListBusinessGroup(req)
 	if err != nil {
 		return errors.Wrap(err, "list business group failed")
 	}
 	groupExist := false
-	for _, groupInfo := range res.Infos {
+	for i, groupInfo := range res.Infos {
+		if status.GroupId != "" && groupInfo.BusinessId == status.GroupId {
+			return nil
+		}
 		if groupInfo.BusinessName == groupName {
-			finalGroup = &groupInfo
+			finalGroup = &res.Infos[i]
 			groupExist = true
-			break
 		}
 	}
 	if !groupExist {
 		req := &CreateBusinessGroupRequest{}
-		req.SetAction("CreateBusinessGroup")
-		req.SetRequestTime(time.Now())
 		req.Region = ucloud.String(s.scope.Region())
 		req.ProjectId = ucloud.String(s.scope.ProjectId())
 		req.BusinessName = ucloud.String(groupName)
-		var res CreateBusinessGroupResponse
-		err := s.doRequest(req, &res)
+		res, err := s.groupClient.CreateBusinessGroup(req)
 		if err != nil {
 			return errors.Wrap(err, "create business group failed")
 		}
@@ -76,9 +73,12 @@ func (s *Service) ReconcileUGroup() error {
 	}
 
 	s.scope.Info("reconcile group success", "status", finalGroup)
+	if status.GroupId != "" {
+		s.reportDrift("business group "+status.GroupId, "not found, replaced by "+finalGroup.BusinessId, true)
+	}
 
-	s.scope.UCloudCluster.Status.Group.GroupId = finalGroup.BusinessId
-	s.scope.UCloudCluster.Status.Group.GroupName = finalGroup.BusinessName
+	status.GroupId = finalGroup.BusinessId
+	status.GroupName = finalGroup.BusinessName
 	return nil
 }
 
@@ -90,13 +90,10 @@ func (s *Service) DeleteGroup() error {
 	}
 
 	delReq := &DeleteBusinessGroupRequest{}
-	delReq.SetAction("DeleteBusinessGroup")
-	delReq.SetRequestTime(time.Now())
 	delReq.Region = ucloud.String(s.scope.Region())
 	delReq.ProjectId = ucloud.String(s.scope.ProjectId())
 	delReq.BusinessId = ucloud.String(id)
-	var res DeleteBusinessGroupResponse
-	err := s.doRequest(delReq, &res)
+	_, err := s.groupClient.DeleteBusinessGroup(delReq)
 	if err != nil {
 		return errors.Wrap(err, "delete business group failed")
 	}
@@ -104,44 +101,56 @@ func (s *Service) DeleteGroup() error {
 	return nil
 }
 
-func (s *Service) CleanResourceInGroup() error {
+// CleanResourceInGroup deletes the load balancers and instances left in the business group.
+// Instances take several steps to delete, so it returns true once all of them are gone.
+func (s *Service) CleanResourceInGroup() (bool, error) {
 	s.scope.Info("clean resource in group")
 	id := s.scope.UCloudCluster.Status.Group.GroupId
 	if len(id) == 0 {
-		return nil
+		return true, nil
 	}
 	// clean ulbs in group
 	searchReq := &SearchBusinessGroupResourceRequest{}
-	searchReq.SetAction("SearchBusinessGroupResource")
-	searchReq.SetRequestTime(time.Now())
 	searchReq.ProjectId = ucloud.String(s.scope.ProjectId())
 	searchReq.BusinessId = ucloud.String(id)
-	var searchRes SearchBusinessGroupResourceResponse
-	err := s.doRequest(searchReq, &searchRes)
+	searchRes, err := s.groupClient.SearchBusinessGroupResource(searchReq)
 	if err != nil {
-		return errors.Wrap(err, "search resource in business group failed")
+		return false, errors.Wrap(err, "search resource in business group failed")
 	}
 	if searchRes.TotalCount > 10 {
 		searchReq.Limit = ucloud.String(strconv.Itoa(searchRes.TotalCount))
-		err := s.doRequest(searchReq, &searchRes)
+		searchRes, err = s.groupClient.SearchBusinessGroupResource(searchReq)
 		if err != nil {
-			return errors.Wrap(err, "search resource in business group failed")
+			return false, errors.Wrap(err, "search resource in business group failed")
 		}
 	}
+	cleaned := true
 	for _, resource := range searchRes.Infos {
 		switch strings.ToLower(resource.ResourceTypeName) {
 		case "ulb":
 			if err := s.deleteULB(resource.Id); err != nil {
-				return errors.Wrap(err, "clean ulb in business group failed")
+				return false, errors.Wrap(err, "clean ulb in business group failed")
 			}
 		case "uhost":
-			if err := s.terminateUHost(resource.Id, resource.ZoneId); err != nil {
-				return errors.Wrap(err, "clean uhost in business group failed")
+			deleted, err := s.terminateUHost(resource.Id, resource.ZoneId)
+			if err != nil {
+				return false, errors.Wrap(err, "clean uhost in business group failed")
 			}
+			cleaned = cleaned && deleted
+		case "uphost":
+			deleted, err := s.terminatePHost(resource.Id)
+			if err != nil {
+				return false, errors.Wrap(err, "clean uphost in business group failed")
+			}
+			cleaned = cleaned && deleted
 		}
 	}
+	if !cleaned {
+		s.scope.Info("waiting for uhosts in group to be deleted", "groupid", id)
+		return false, nil
+	}
 	s.scope.Info("clean resource in group success", "groupid", id)
-	return nil
+	return true, nil
 }
 
 type ListBusinessGroupRequest struct {
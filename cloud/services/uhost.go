@@ -20,12 +20,13 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"math/rand"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/pkg/errors"
 	"github.com/ucloud/ucloud-sdk-go/services/uhost"
 	"github.com/ucloud/ucloud-sdk-go/ucloud"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/request"
 	"sigs.k8s.io/cluster-api/util/record"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
@@ -39,6 +40,9 @@ func (s *Service) InstanceIfExists(scope *scope.MachineScope) (*uhost.UHostInsta
 	if id == nil {
 		return nil, nil
 	}
+	if scope.InstanceKind() == infrav1.UPHostInstanceKind {
+		return s.phostIfExists(*id)
+	}
 	s.scope.Info("looking for instance by id", "name", scope.Name(), "id", *id)
 	req := s.uhostClient.NewDescribeUHostInstanceRequest()
 	req.Region = ucloud.String(s.scope.Region())
@@ -56,166 +60,257 @@ func (s *Service) InstanceIfExists(scope *scope.MachineScope) (*uhost.UHostInsta
 	return &(hosts.UHostSet[0]), nil
 }
 
-// CreateInstance runs a uhost instance.
+// CreateInstance runs a uhost instance, or a uphost instance for bare-metal machines.
 func (s *Service) CreateInstance(scope *scope.MachineScope) (*uhost.UHostInstanceSet, error) {
+	if scope.InstanceKind() == infrav1.UPHostInstanceKind {
+		return s.createPHost(scope)
+	}
 	s.scope.Info("Creating an instance")
-	bootstrapData, err := scope.GetBootstrapData()
+	userData, err := s.instanceUserData(scope)
 	if err != nil {
 		record.Warnf(scope.Machine, "FailedCreate", "Failed to create instance")
-		return nil, errors.Wrap(err, "failed to retrieve bootstrap data")
+		return nil, err
 	}
 
-	req := s.uhostClient.NewCreateUHostInstanceRequest()
+	req := &CreateUHostInstanceRequestPlus{}
 	req.Region = ucloud.String(s.scope.Region())
 	req.ProjectId = ucloud.String(s.scope.ProjectId())
-	req.Zone = ucloud.String(scope.Zone())
 	req.Tag = ucloud.String(s.scope.GroupName())
-	if ucloud.StringValue(req.Zone) == "" {
-		zones, ok := common.RegionZoneMap[s.scope.Region()]
-		if !ok {
-			record.Warnf(scope.Machine, "FailedCreate", "Failed to create instance")
-			return nil, errors.Errorf("region %s not support", s.scope.Region())
-		}
-		req.Zone = ucloud.String(zones[rand.Intn(len(zones))])
+	zone, err := s.instanceZone(scope)
+	if err != nil {
+		record.Warnf(scope.Machine, "FailedCreate", "Failed to create instance")
+		return nil, err
 	}
-	imageId := s.getImageId(scope, ucloud.StringValue(req.Zone))
-	if imageId == "" {
+	req.Zone = ucloud.String(zone)
+	imageId, err := s.resolveImageId(scope.UCloudMachine.Spec.ImageId, scope.UCloudMachine.Spec.ImageLookup,
+		ucloud.StringValue(req.Zone), ucloud.StringValue(scope.Machine.Spec.Version))
+	if err != nil {
 		record.Warnf(scope.Machine, "FailedCreate", "Failed to create instance")
-		return nil, errors.Errorf("can not get image id for region %s", s.scope.Region())
+		return nil, err
 	}
 	s.scope.Info("use image", "imageid", imageId)
-	if scope.UCloudMachine.Name != "" {
-		req.Name = ucloud.String(scope.UCloudCluster.Namespace + "-" + scope.UCloudMachine.Name)
-	} else {
-		req.Name = ucloud.String(scope.UCloudCluster.Namespace + "-" + scope.UCloudCluster.ClusterName + "-" + scope.Role())
-	}
-	req.ChargeType = ucloud.String("Month")
-	req.Quantity = ucloud.Int(1)
+	req.Name = ucloud.String(instanceName(scope))
+	req.ChargeType = ucloud.String(chargeType(scope.UCloudMachine.Spec.ChargeSpec))
+	req.Quantity = ucloud.Int(chargeQuantity(scope.UCloudMachine.Spec.ChargeSpec))
 	req.VPCId = ucloud.String(s.scope.UCloudCluster.Status.Network.VPC.VpcId)
-	req.SubnetId = ucloud.String(s.scope.UCloudCluster.Status.Network.Subnet.SubnetId)
-	req.ImageId = ucloud.String(imageId)
-	if scope.UCloudMachine.Spec.CPU != 0 {
-		req.CPU = ucloud.Int(scope.UCloudMachine.Spec.CPU)
-	} else {
-		req.CPU = ucloud.Int(common.DefaultUHostCPU)
-	}
-	if scope.UCloudMachine.Spec.Memory != 0 {
-		req.Memory = ucloud.Int(scope.UCloudMachine.Spec.Memory)
-	} else {
-		req.Memory = ucloud.Int(common.DefaultUHostMemory)
-	}
-	rootDiskSize := scope.UCloudMachine.Spec.RootDiskSize
-	if rootDiskSize == 0 {
-		rootDiskSize = common.DefaultUHostRootDiskSize
-	}
-	req.Disks = append(req.Disks, uhost.UHostDisk{
-		Size:       ucloud.Int(rootDiskSize),
-		Type:       ucloud.String("CLOUD_SSD"),
-		IsBoot:     ucloud.String("true"),
-		BackupType: ucloud.String("NONE"),
-	})
-	dataDiskSize := scope.UCloudMachine.Spec.DataDiskSize
-	if dataDiskSize == 0 {
-		dataDiskSize = common.DefaultUHostDataDiskSize
-	}
-	req.Disks = append(req.Disks, uhost.UHostDisk{
-		Size:       ucloud.Int(dataDiskSize),
-		Type:       ucloud.String("CLOUD_SSD"),
-		IsBoot:     ucloud.String("false"),
-		BackupType: ucloud.String("NONE"),
-	})
-	req.MachineType = ucloud.String("N")
-	req.MinimalCpuPlatform = ucloud.String("Intel/Auto")
-
-	req.LoginMode = ucloud.String("Password")
-	passwd := scope.UCloudMachine.Spec.SSHPassword
-	if passwd == "" {
+	subnet, err := s.subnetFor(scope.Role(), zone)
+	if err != nil {
 		record.Warnf(scope.Machine, "FailedCreate", "Failed to create instance")
-		return nil, errors.Errorf("password is not set")
+		return nil, err
 	}
-	pass, err := base64.StdEncoding.DecodeString(passwd)
+	req.SubnetId = ucloud.String(subnet.SubnetId)
+	firewallId, err := s.machineFirewallId(scope)
 	if err != nil {
-		return nil, errors.Wrap(err, "sshPassword is not a valid base64 string")
+		record.Warnf(scope.Machine, "FailedCreate", "Failed to create instance")
+		return nil, err
 	}
-	req.Password = ucloud.String(string(pass))
-	bootstrapData = strings.ReplaceAll(bootstrapData, `## template: jinja`, "")
-	credentailData, err := json.Marshal(*s.scope.Credential)
+	if firewallId != "" {
+		req.SecurityGroupId = ucloud.String(firewallId)
+	}
+	req.ImageId = ucloud.String(imageId)
+	instanceType, err := scope.InstanceType()
 	if err != nil {
-		return nil, errors.Wrap(err, "marshal credentail failed")
+		record.Warnf(scope.Machine, "FailedCreate", "Failed to create instance")
+		return nil, err
 	}
-	bootstrapData = strings.ReplaceAll(bootstrapData, "UCLOUD_CREDENTIAL", base64.StdEncoding.EncodeToString(credentailData))
-	bootstrapData = strings.ReplaceAll(bootstrapData, "KUBERNETES_VERSION", *scope.Machine.Spec.Version)
-	req.UserData = ucloud.String(base64.StdEncoding.EncodeToString([]byte(bootstrapData)))
+	req.MachineType = ucloud.String(instanceType.MachineType)
+	req.MinimalCpuPlatform = ucloud.String(instanceType.CPUPlatform)
+	req.CPU = ucloud.Int(instanceType.CPU)
+	req.Memory = ucloud.Int(instanceType.Memory)
+	if instanceType.GPU > 0 {
+		req.GPU = ucloud.Int(instanceType.GPU)
+		req.GpuType = ucloud.String(instanceType.GPUType)
+	}
+	req.Disks = instanceDisks(&scope.UCloudMachine.Spec)
 
-	newUHost, err := s.uhostClient.CreateUHostInstance(req)
+	if err := s.setLogin(req, scope.UCloudMachine.Spec.SSHKey, scope.LoginPassword); err != nil {
+		record.Warnf(scope.Machine, "FailedCreate", "Failed to create instance")
+		return nil, err
+	}
+	req.UserData = ucloud.String(userData)
+
+	newUHost, err := s.uhostClient.CreateUHostInstancePlus(req)
 	if err != nil {
 		record.Warnf(scope.Machine, "FailedCreate", "Failed to create instance")
 		return nil, errors.Wrap(err, "create uhost failed")
 	}
-	s.scope.Info("instance created successed, begin waiting for instance running", "uhostid", newUHost.UHostIds[0])
+	s.scope.Info("instance created successed", "uhostid", newUHost.UHostIds[0])
+	scope.UCloudMachine.Status.FirewallId = firewallId
+	record.Eventf(scope.Machine, "SuccessfulCreate", "Created new %s instance with name %q", scope.Role(), ucloud.StringValue(req.Name))
 
+	// The instance is not running yet. Describe it once so that the caller can persist
+	// its id, the caller polls its state in later reconciles.
 	reqDescribe := s.uhostClient.NewDescribeUHostInstanceRequest()
 	reqDescribe.Region = req.Region
 	reqDescribe.ProjectId = req.ProjectId
 	reqDescribe.Zone = req.Zone
 	reqDescribe.UHostIds = newUHost.UHostIds
 	reqDescribe.Tag = ucloud.String(s.scope.GroupName())
-	var finalHost uhost.UHostInstanceSet
-	timer := time.NewTimer(5 * time.Minute)
-	for {
-		s.scope.Info("waiting for uhost ready", "uhostid", newUHost.UHostIds[0])
-		hosts, err := s.uhostClient.DescribeUHostInstance(reqDescribe)
-		if err != nil {
-			record.Warnf(scope.Machine, "FailedCreate", "Failed to create instance")
-			return nil, errors.Wrap(err, "describe uhost failed")
-		}
-		finalHost = hosts.UHostSet[0]
-		if finalHost.State == string(uhost.StateRunning) {
-			timer.Stop()
-			break
-		}
-		select {
-		case <-timer.C:
-			record.Warnf(scope.Machine, "FailedCreate", "Failed to create instance")
-			return nil, errors.Errorf("waiting for uhost %s ready timeout", finalHost.UHostId)
-		default:
-			time.Sleep(30 * time.Second)
-		}
+	hosts, err := s.uhostClient.DescribeUHostInstance(reqDescribe)
+	if err != nil || len(hosts.UHostSet) == 0 {
+		s.scope.Info("describe new uhost failed, its state is left unknown", "uhostid", newUHost.UHostIds[0], "error", err)
+		return &uhost.UHostInstanceSet{
+			UHostId:    newUHost.UHostIds[0],
+			Zone:       ucloud.StringValue(req.Zone),
+			Name:       ucloud.StringValue(req.Name),
+			ImageId:    imageId,
+			ChargeType: ucloud.StringValue(req.ChargeType),
+		}, nil
+	}
+	return &hosts.UHostSet[0], nil
+}
+
+// instanceZone returns the zone to create the instance of a machine in, the failure
+// domain of the machine or a random zone of the region.
+func (s *Service) instanceZone(scope *scope.MachineScope) (string, error) {
+	if zone := scope.Zone(); zone != "" {
+		return zone, nil
+	}
+	if zones := s.subnetZones(scope.Role()); len(zones) > 0 {
+		return zones[rand.Intn(len(zones))], nil
+	}
+	zones, ok := common.RegionZoneMap[s.scope.Region()]
+	if !ok {
+		return "", errors.Errorf("region %s not support", s.scope.Region())
 	}
+	return zones[rand.Intn(len(zones))], nil
+}
 
-	s.scope.Info("create uhost successed", "uhostid", finalHost.UHostId)
-	record.Eventf(scope.Machine, "SuccessfulCreate", "Created new %s instance with name %q", scope.Role(), finalHost.Name)
-	return &finalHost, nil
+// instanceName returns the name of the instance of a machine.
+func instanceName(scope *scope.MachineScope) string {
+	if scope.UCloudMachine.Name != "" {
+		return scope.UCloudCluster.Namespace + "-" + scope.UCloudMachine.Name
+	}
+	return scope.UCloudCluster.Namespace + "-" + scope.UCloudCluster.ClusterName + "-" + scope.Role()
+}
+
+// instanceResourceType returns the resource type of the instance of a machine in the
+// UNet API, which calls bare-metal machines upm.
+func instanceResourceType(scope *scope.MachineScope) string {
+	if scope.InstanceKind() == infrav1.UPHostInstanceKind {
+		return "upm"
+	}
+	return "uhost"
+}
+
+// instanceUserData returns the base64 encoded bootstrap data of a machine, with the
+// placeholders filled in.
+func (s *Service) instanceUserData(scope *scope.MachineScope) (string, error) {
+	bootstrapData, err := scope.GetBootstrapData()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to retrieve bootstrap data")
+	}
+	bootstrapData = strings.ReplaceAll(bootstrapData, `## template: jinja`, "")
+	credentialData, err := s.getUserDataCredential()
+	if err != nil {
+		return "", err
+	}
+	bootstrapData = strings.ReplaceAll(bootstrapData, "UCLOUD_CREDENTIAL", credentialData)
+	bootstrapData = strings.ReplaceAll(bootstrapData, "KUBERNETES_VERSION", *scope.Machine.Spec.Version)
+	return base64.StdEncoding.EncodeToString([]byte(bootstrapData)), nil
 }
 
-func (s *Service) TerminateInstanceAndWait(scope *scope.MachineScope) error {
+// TerminateInstance moves the instance of a machine one step closer to deletion.
+// It returns true once the instance is gone.
+func (s *Service) TerminateInstance(scope *scope.MachineScope) (bool, error) {
 	id := scope.GetInstanceID()
 	if id == nil || *id == "" {
-		return nil
+		return true, nil
+	}
+	if scope.InstanceKind() == infrav1.UPHostInstanceKind {
+		return s.terminatePHost(*id)
 	}
-	s.scope.Info("start terminate uhost", "uhostid", *id)
 
-	if err := s.terminateUHost(*id, scope.Zone()); err != nil {
-		return err
+	deleted, err := s.terminateUHost(*id, scope.Zone())
+	if err != nil {
+		return false, err
+	}
+	if deleted {
+		s.scope.Info("terminate uhost successed", "uhostid", *id)
 	}
+	return deleted, nil
+}
 
-	s.scope.Info("terminate uhost successed", "uhostid", *id)
+// instanceDisks returns the disks of a new instance. Without a disks list, the instance
+// gets a boot disk and one data disk sized by RootDiskSize and DataDiskSize.
+func instanceDisks(spec *infrav1.UCloudMachineSpec) []UHostDiskPlus {
+	if len(spec.Disks) == 0 {
+		dataDiskSize := spec.DataDiskSize
+		if dataDiskSize == 0 {
+			dataDiskSize = common.DefaultUHostDataDiskSize
+		}
+		return []UHostDiskPlus{
+			newUHostDisk(infrav1.DiskSpec{IsBoot: true, Size: spec.RootDiskSize}),
+			newUHostDisk(infrav1.DiskSpec{Size: dataDiskSize}),
+		}
+	}
 
-	return nil
+	var disks []UHostDiskPlus
+	hasBoot := false
+	for _, disk := range spec.Disks {
+		if disk.IsBoot {
+			if disk.Size == 0 {
+				disk.Size = spec.RootDiskSize
+			}
+			hasBoot = true
+		}
+		disks = append(disks, newUHostDisk(disk))
+	}
+	if !hasBoot {
+		disks = append([]UHostDiskPlus{newUHostDisk(infrav1.DiskSpec{IsBoot: true, Size: spec.RootDiskSize})}, disks...)
+	}
+	return disks
 }
 
-// getImageId computes the UHost image id to use as the boot disk
-func (s *Service) getImageId(scope *scope.MachineScope, zone string) string {
-	if scope.UCloudMachine.Spec.ImageId != nil {
-		return *scope.UCloudMachine.Spec.ImageId
-	} else {
-		return common.RegionImageMap[s.scope.Region()][zone]
+// newUHostDisk returns the request parameters of a disk, with the defaults filled in.
+func newUHostDisk(spec infrav1.DiskSpec) UHostDiskPlus {
+	if spec.Type == "" {
+		spec.Type = "CLOUD_SSD"
+	}
+	if spec.BackupType == "" {
+		spec.BackupType = "NONE"
+	}
+	if spec.IsBoot && spec.Size == 0 {
+		spec.Size = common.DefaultUHostRootDiskSize
+	}
+	disk := UHostDiskPlus{
+		Type:       ucloud.String(spec.Type),
+		IsBoot:     ucloud.String(strconv.FormatBool(spec.IsBoot)),
+		BackupType: ucloud.String(spec.BackupType),
+	}
+	if spec.Size != 0 {
+		disk.Size = ucloud.Int(spec.Size)
+	}
+	if spec.KmsKeyId != "" {
+		disk.Encrypted = ucloud.Bool(true)
+		disk.KmsKeyId = ucloud.String(spec.KmsKeyId)
 	}
+	if spec.SnapshotId != "" {
+		disk.SnapshotId = ucloud.String(spec.SnapshotId)
+	}
+	return disk
+}
+
+// getUserDataCredential returns the value substituted for the UCLOUD_CREDENTIAL placeholder.
+// It is empty unless the cluster explicitly opts in to injecting credentials into user data.
+func (s *Service) getUserDataCredential() (string, error) {
+	if !s.scope.UCloudCluster.Spec.NodeCredential.InjectIntoUserData {
+		return "", nil
+	}
+	credential, err := s.scope.NodeCredential()
+	if err != nil {
+		return "", err
+	}
+	credentialData, err := json.Marshal(*credential)
+	if err != nil {
+		return "", errors.Wrap(err, "marshal credential failed")
+	}
+	return base64.StdEncoding.EncodeToString(credentialData), nil
 }
 
 // CreateBastionInstance runs a uhost instance.
 func (s *Service) CreateBastionInstance() error {
-	if s.scope.UCloudCluster.Spec.Bastion.SSHPassword == "" || s.scope.UCloudCluster.Status.Bastion != nil {
+	if !s.scope.BastionEnabled() || s.scope.UCloudCluster.Status.Bastion != nil {
 		return nil
 	}
 
@@ -225,22 +320,25 @@ func (s *Service) CreateBastionInstance() error {
 	reqCheck := s.uhostClient.NewDescribeUHostInstanceRequest()
 	reqCheck.Region = ucloud.String(s.scope.Region())
 	reqCheck.ProjectId = ucloud.String(s.scope.ProjectId())
-	reqCheck.SubnetId = ucloud.String(s.scope.UCloudCluster.Status.Network.Subnet.SubnetId)
+	reqCheck.VPCId = ucloud.String(s.scope.UCloudCluster.Status.Network.VPC.VpcId)
 	reqCheck.Tag = ucloud.String(s.scope.GroupName())
 	hostSet, err := s.uhostClient.DescribeUHostInstance(reqCheck)
 	if err != nil {
-		return errors.Wrapf(err, "failed to describe instance")
+		return errors.Wrap(err, "failed to describe instance")
 	}
 	for _, host := range hostSet.UHostSet {
 		if host.Name == bastionName {
 			return nil
 		}
 	}
-	req := s.uhostClient.NewCreateUHostInstanceRequest()
+	req := &CreateUHostInstanceRequestPlus{}
 	req.Region = ucloud.String(s.scope.Region())
 	req.ProjectId = ucloud.String(s.scope.ProjectId())
 	req.Zone = ucloud.String(s.scope.UCloudCluster.Spec.Bastion.Zone)
 	req.Tag = ucloud.String(s.scope.GroupName())
+	if zones := s.subnetZones(infrav1.SubnetRoleBastion); ucloud.StringValue(req.Zone) == "" && len(zones) > 0 {
+		req.Zone = ucloud.String(zones[rand.Intn(len(zones))])
+	}
 	if ucloud.StringValue(req.Zone) == "" {
 		zones, ok := common.RegionZoneMap[s.scope.Region()]
 		if !ok {
@@ -249,27 +347,30 @@ func (s *Service) CreateBastionInstance() error {
 		}
 		req.Zone = ucloud.String(zones[rand.Intn(len(zones))])
 	}
-	imageId := common.RegionImageMap[s.scope.Region()][ucloud.StringValue(req.Zone)]
-	if imageId == "" {
+	imageId, err := s.resolveImageId(nil, s.scope.UCloudCluster.Spec.Bastion.ImageLookup,
+		ucloud.StringValue(req.Zone), s.scope.UCloudCluster.Spec.Version)
+	if err != nil {
 		record.Warnf(s.scope.UCloudCluster, "FailedCreate", "Failed to create instance")
-		return errors.Errorf("can not get image id for region %s", s.scope.Region())
+		return err
 	}
 	s.scope.Info("use image", "imageid", imageId)
 	req.Name = ucloud.String(bastionName)
-	req.ChargeType = ucloud.String("Month")
-	req.Quantity = ucloud.Int(1)
+	req.ChargeType = ucloud.String(chargeType(s.scope.UCloudCluster.Spec.Bastion.ChargeSpec))
+	req.Quantity = ucloud.Int(chargeQuantity(s.scope.UCloudCluster.Spec.Bastion.ChargeSpec))
 	req.VPCId = ucloud.String(s.scope.UCloudCluster.Status.Network.VPC.VpcId)
-	req.SubnetId = ucloud.String(s.scope.UCloudCluster.Status.Network.Subnet.SubnetId)
+	subnet, err := s.subnetFor(infrav1.SubnetRoleBastion, ucloud.StringValue(req.Zone))
+	if err != nil {
+		record.Warnf(s.scope.UCloudCluster, "FailedCreate", "Failed to create bastion")
+		return err
+	}
+	req.SubnetId = ucloud.String(subnet.SubnetId)
+	if firewallId := s.scope.UCloudCluster.Status.Network.Firewall.FirewallId; firewallId != "" {
+		req.SecurityGroupId = ucloud.String(firewallId)
+	}
 	req.ImageId = ucloud.String(imageId)
 	req.CPU = ucloud.Int(2)
 	req.Memory = ucloud.Int(4096)
-	rootDiskSize := common.DefaultUHostRootDiskSize
-	req.Disks = append(req.Disks, uhost.UHostDisk{
-		Size:       ucloud.Int(rootDiskSize),
-		Type:       ucloud.String("CLOUD_SSD"),
-		IsBoot:     ucloud.String("true"),
-		BackupType: ucloud.String("NONE"),
-	})
+	req.Disks = append(req.Disks, newUHostDisk(infrav1.DiskSpec{IsBoot: true}))
 	req.MachineType = ucloud.String("N")
 	req.MinimalCpuPlatform = ucloud.String("Intel/Auto")
 	req.NetworkInterface = append(req.NetworkInterface, uhost.CreateUHostInstanceParamNetworkInterface{
@@ -280,19 +381,12 @@ func (s *Service) CreateBastionInstance() error {
 		},
 	})
 
-	req.LoginMode = ucloud.String("Password")
-	passwd := s.scope.UCloudCluster.Spec.Bastion.SSHPassword
-	if passwd == "" {
+	if err := s.setLogin(req, s.scope.UCloudCluster.Spec.Bastion.SSHKey, s.scope.BastionLoginPassword); err != nil {
 		record.Warnf(s.scope.UCloudCluster, "FailedCreate", "Failed to create bastion")
-		return errors.Errorf("password is not set")
-	}
-	pass, err := base64.StdEncoding.DecodeString(passwd)
-	if err != nil {
-		return errors.Wrap(err, "sshPassword is not a valid base64 string")
+		return err
 	}
-	req.Password = ucloud.String(string(pass))
 
-	newUHost, err := s.uhostClient.CreateUHostInstance(req)
+	newUHost, err := s.uhostClient.CreateUHostInstancePlus(req)
 	if err != nil {
 		record.Warnf(s.scope.UCloudCluster, "FailedCreate", "Failed to create bastion")
 		return errors.Wrap(err, "create uhost failed")
@@ -320,28 +414,32 @@ func (s *Service) CreateBastionInstance() error {
 		InstanceType: "uhost",
 		Zone:         finalHost.Zone,
 		Name:         finalHost.Name,
+		ChargeType:   finalHost.ChargeType,
+		ExpireTime:   ExpireTime(finalHost.ChargeType, finalHost.ExpireTime),
 	}
 	s.scope.UCloudCluster.Status.Bastion = &bastionInfo
 	record.Eventf(s.scope.UCloudCluster, "SuccessfulCreate", "Created bastion with name %q", finalHost.Name)
 	return nil
 }
 
-func (s *Service) TerminateBastion() error {
+// TerminateBastion moves the bastion one step closer to deletion.
+// It returns true once the bastion is gone.
+func (s *Service) TerminateBastion() (bool, error) {
 	if s.scope.UCloudCluster.Status.Bastion == nil || s.scope.UCloudCluster.Status.Bastion.InstanceId == "" {
-		return nil
+		return true, nil
 	}
 	id := s.scope.UCloudCluster.Status.Bastion.InstanceId
 	zone := s.scope.UCloudCluster.Status.Bastion.Zone
-	s.scope.Info("start terminate instance", "uhostid", id)
 
-	if err := s.terminateUHost(id, zone); err != nil {
-		return err
+	deleted, err := s.terminateUHost(id, zone)
+	if err != nil || !deleted {
+		return false, err
 	}
 
 	s.scope.UCloudCluster.Status.Bastion = nil
 	s.scope.Info("terminate uhost successed", "uhostid", id)
 
-	return nil
+	return true, nil
 }
 
 func (s *Service) getPrivateIP(uhost *uhost.UHostInstanceSet) string {
@@ -362,7 +460,10 @@ func (s *Service) getPublicIP(uhost *uhost.UHostInstanceSet) string {
 	return ""
 }
 
-func (s *Service) terminateUHost(id, zone string) error {
+// terminateUHost takes the next step of deleting an instance without waiting for
+// it to complete: running instances are powered off and stopped ones terminated.
+// It returns true once the instance is gone.
+func (s *Service) terminateUHost(id, zone string) (bool, error) {
 	req := s.uhostClient.NewDescribeUHostInstanceRequest()
 	req.Region = ucloud.String(s.scope.Region())
 	req.ProjectId = ucloud.String(s.scope.ProjectId())
@@ -370,77 +471,133 @@ func (s *Service) terminateUHost(id, zone string) error {
 	req.UHostIds = append(req.UHostIds, id)
 	req.Tag = ucloud.String(s.scope.GroupName())
 	hosts, err := s.uhostClient.DescribeUHostInstance(req)
-	if err != nil && hosts.GetRetCode() != 8039 {
-		return errors.Wrapf(err, "failed to describe instance: %s", id)
-	}
-	if len(hosts.UHostSet) == 0 {
-		return nil
-	}
-
-	reqPowerOff := s.uhostClient.NewPoweroffUHostInstanceRequest()
-	reqPowerOff.Region = ucloud.String(s.scope.Region())
-	reqPowerOff.ProjectId = ucloud.String(s.scope.ProjectId())
-	reqPowerOff.Zone = req.Zone
-	reqPowerOff.UHostId = ucloud.String(id)
-	_, err = s.uhostClient.PoweroffUHostInstance(reqPowerOff)
 	if err != nil {
-		return errors.Wrapf(err, "poweroff uhost %s failed", id)
-	}
-
-	reqDescribe := s.uhostClient.NewDescribeUHostInstanceRequest()
-	reqDescribe.Region = req.Region
-	reqDescribe.ProjectId = req.ProjectId
-	reqDescribe.Zone = req.Zone
-	reqDescribe.UHostIds = append(reqDescribe.UHostIds, id)
-	reqDescribe.Tag = ucloud.String(s.scope.GroupName())
-	timer := time.NewTimer(5 * time.Minute)
-	for {
-		s.scope.Info("waiting for uhost stopped", "uhostid", id)
-		hosts, err := s.uhostClient.DescribeUHostInstance(reqDescribe)
-		if err != nil {
-			return errors.Wrap(err, "describe uhost failed")
-		}
-		host := hosts.UHostSet[0]
-		if host.State == string(uhost.StateStopped) {
-			timer.Stop()
-			break
-		}
-		select {
-		case <-timer.C:
-			return errors.Errorf("waiting for uhost %s stopped timeout", id)
-		default:
-			time.Sleep(10 * time.Second)
+		if common.IsNotFound(err) {
+			return true, nil
 		}
+		return false, errors.Wrapf(err, "failed to describe instance: %s", id)
 	}
-
-	reqTerminate := s.uhostClient.NewTerminateUHostInstanceRequest()
-	reqTerminate.Region = req.Region
-	reqTerminate.ProjectId = req.ProjectId
-	reqTerminate.Zone = req.Zone
-	reqTerminate.UHostId = reqPowerOff.UHostId
-	reqTerminate.ReleaseEIP = ucloud.Bool(true)
-	reqTerminate.ReleaseUDisk = ucloud.Bool(true)
-	_, err = s.uhostClient.TerminateUHostInstance(reqTerminate)
-	if err != nil {
-		return errors.Wrapf(err, "terminate uhost %s failed", id)
-	}
-	timer.Reset(5 * time.Minute)
-	for {
-		s.scope.Info("waiting for uhost deleted", "uhostid", id)
-		hosts, err := s.uhostClient.DescribeUHostInstance(reqDescribe)
-		if err != nil {
-			return errors.Wrap(err, "describe uhost failed")
-		}
-		if len(hosts.UHostSet) == 0 {
-			timer.Stop()
-			break
+	if len(hosts.UHostSet) == 0 {
+		return true, nil
+	}
+
+	state := uhost.State(hosts.UHostSet[0].State)
+	switch state {
+	case uhost.StateStopped, uhost.StateInstallFail:
+		s.scope.Info("start terminate uhost", "uhostid", id)
+		reqTerminate := s.uhostClient.NewTerminateUHostInstanceRequest()
+		reqTerminate.Region = req.Region
+		reqTerminate.ProjectId = req.ProjectId
+		reqTerminate.Zone = req.Zone
+		reqTerminate.UHostId = ucloud.String(id)
+		reqTerminate.ReleaseEIP = ucloud.Bool(true)
+		reqTerminate.ReleaseUDisk = ucloud.Bool(true)
+		if _, err := s.uhostClient.TerminateUHostInstance(reqTerminate); err != nil && !common.IsNotFound(err) {
+			return false, errors.Wrapf(err, "terminate uhost %s failed", id)
 		}
-		select {
-		case <-timer.C:
-			return errors.Errorf("waiting for uhost %s deleted timeout", id)
-		default:
-			time.Sleep(10 * time.Second)
+	case uhost.StateRunning:
+		s.scope.Info("start poweroff uhost", "uhostid", id)
+		reqPowerOff := s.uhostClient.NewPoweroffUHostInstanceRequest()
+		reqPowerOff.Region = req.Region
+		reqPowerOff.ProjectId = req.ProjectId
+		reqPowerOff.Zone = req.Zone
+		reqPowerOff.UHostId = ucloud.String(id)
+		if _, err := s.uhostClient.PoweroffUHostInstance(reqPowerOff); err != nil {
+			return false, errors.Wrapf(err, "poweroff uhost %s failed", id)
 		}
+	default:
+		s.scope.Info("waiting for uhost to settle before deleting it", "uhostid", id, "state", state)
 	}
-	return nil
+	return false, nil
+}
+
+// CreateUHostInstanceRequestPlus is the CreateUHostInstance request with the fields
+// missing from the sdk request, such as KeyPairId.
+type CreateUHostInstanceRequestPlus struct {
+	request.CommonBase
+
+	// 虚拟CPU核数。可选参数：1-64（具体机型与CPU的对应关系参照控制台）。默认值: 4。
+	CPU *int `required:"false"`
+
+	// 计费模式。枚举值为： Year，按年付费； Month，按月付费；Dynamic，按小时预付费 Postpay，按小时后付费 默认为月付
+	ChargeType *string `required:"false"`
+
+	// 磁盘列表
+	Disks []UHostDiskPlus `required:"false"`
+
+	// GPU卡核心数。仅GPU机型支持此字段（可选范围与MachineType+GpuType相关）
+	GPU *int `required:"false"`
+
+	// GPU类型，枚举值["K80", "P40", "V100"]，MachineType为G时必填
+	GpuType *string `required:"false"`
+
+	// 镜像ID。 请通过 DescribeImage 获取
+	ImageId *string `required:"true"`
+
+	// 密钥对ID，LoginMode为KeyPair时必填
+	KeyPairId *string `required:"false"`
+
+	// 主机登陆模式。密码（默认选项）: Password，密钥对: KeyPair。
+	LoginMode *string `required:"true"`
+
+	// 云主机机型（V2.0），枚举值["N", "C", "G", "O"]。
+	MachineType *string `required:"false"`
+
+	// 内存大小。单位：MB。范围 ：[1024, 262144]，取值为1024的倍数。默认值：8192
+	Memory *int `required:"false"`
+
+	// 最低cpu平台，枚举值["Intel/Auto", "Intel/IvyBridge", "Intel/Haswell", "Intel/Broadwell", "Intel/Skylake", "Intel/Cascadelake"。
+	MinimalCpuPlatform *string `required:"false"`
+
+	// UHost实例名称。默认：UHost。
+	Name *string `required:"false"`
+
+	// 网络接口
+	NetworkInterface []uhost.CreateUHostInstanceParamNetworkInterface `required:"false"`
+
+	// UHost密码，LoginMode为Password时必填。发送时使用base64进行编码。
+	Password *string `required:"false"`
+
+	// 购买时长。默认:值 1。按小时购买（Dynamic/Postpay）时无需此参数。 月付时，此参数传0，代表购买至月末。
+	Quantity *int `required:"false"`
+
+	// 防火墙ID，默认：Web推荐防火墙。
+	SecurityGroupId *string `required:"false"`
+
+	// 子网 ID。默认为当前地域的默认子网。
+	SubnetId *string `required:"false"`
+
+	// 业务组。默认：Default（Default即为未分组）。
+	Tag *string `required:"false"`
+
+	// 用户自定义数据。当镜像支持Cloud-init Feature时可填写此字段。注意：1、总数据量大小不超多16K；2、使用base64编码
+	UserData *string `required:"false"`
+
+	// VPC ID。默认为当前地域的默认VPC。
+	VPCId *string `required:"false"`
+}
+
+// UHostDiskPlus is a disk of the CreateUHostInstance request with the fields missing
+// from the sdk, such as SnapshotId.
+type UHostDiskPlus struct {
+	// 磁盘备份方案。枚举值：NONE，无备份；DATAARK，数据方舟；SNAPSHOT，快照服务
+	BackupType *string `required:"false"`
+
+	// 磁盘是否加密。加密：true, 不加密: false加密必须传入对应的的KmsKeyId
+	Encrypted *bool `required:"false"`
+
+	// 是否是系统盘。枚举值：True，是系统盘；False，是数据盘（默认）。Disks数组中有且只能有一块盘是系统盘。
+	IsBoot *string `required:"true"`
+
+	// kms key id。选择加密盘时必填。
+	KmsKeyId *string `required:"false"`
+
+	// 磁盘大小，单位GB，必须是10GB的整数倍。
+	Size *int `required:"false"`
+
+	// 从快照创建数据盘时的快照ID
+	SnapshotId *string `required:"false"`
+
+	// 磁盘类型。
+	Type *string `required:"true"`
 }
@@ -0,0 +1,90 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/auth"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/scope"
+)
+
+func TestGetUserDataCredential(t *testing.T) {
+	nodeCredentials := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-credentials", Namespace: "default"},
+		Data:       map[string][]byte{scope.PublicKeyName: []byte("node-public"), scope.PrivateKeyName: []byte("node-private")},
+	}
+	tests := []struct {
+		name           string
+		nodeCredential infrav1.NodeCredentialSpec
+		want           string
+		wantErr        bool
+	}{
+		{name: "not injected by default"},
+		{name: "not injected with a secret", nodeCredential: infrav1.NodeCredentialSpec{SecretRef: &corev1.LocalObjectReference{Name: "node-credentials"}}},
+		{name: "controller credential injected", nodeCredential: infrav1.NodeCredentialSpec{InjectIntoUserData: true}, want: "controller-public"},
+		{name: "referenced key pair injected", nodeCredential: infrav1.NodeCredentialSpec{SecretRef: &corev1.LocalObjectReference{Name: "node-credentials"}, InjectIntoUserData: true}, want: "node-public"},
+		{name: "missing secret", nodeCredential: infrav1.NodeCredentialSpec{SecretRef: &corev1.LocalObjectReference{Name: "missing"}, InjectIntoUserData: true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			scheme := runtime.NewScheme()
+			g.Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+			cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"}}
+			ucloudCluster := &infrav1.UCloudCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"},
+				Spec:       infrav1.UCloudClusterSpec{NodeCredential: tt.nodeCredential},
+			}
+			clusterScope, err := scope.NewClusterScope(scope.ClusterScopeParams{
+				UCloudClients: scope.UCloudClients{Credential: &auth.Credential{PublicKey: "controller-public", PrivateKey: "controller-private"}},
+				Client:        fake.NewFakeClientWithScheme(scheme, ucloudCluster, nodeCredentials),
+				Cluster:       cluster,
+				UCloudCluster: ucloudCluster,
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+
+			data, err := NewService(clusterScope).getUserDataCredential()
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			if tt.want == "" {
+				g.Expect(data).To(BeEmpty())
+				return
+			}
+			decoded, err := base64.StdEncoding.DecodeString(data)
+			g.Expect(err).NotTo(HaveOccurred())
+			var credential auth.Credential
+			g.Expect(json.Unmarshal(decoded, &credential)).To(Succeed())
+			g.Expect(credential.PublicKey).To(Equal(tt.want))
+		})
+	}
+}
@@ -1,8 +1,6 @@
 package services
 
 import (
-	"time"
-
 	"github.com/pkg/errors"
 	"github.com/ucloud/ucloud-sdk-go/ucloud"
 	"github.com/ucloud/ucloud-sdk-go/ucloud/request"
@@ -15,8 +13,6 @@ func (s *Service) CreateCAPUCluster() error {
 		return nil
 	}
 	req := &CreateCAPUClusterRequest{}
-	req.SetAction("CreateCAPUCluster")
-	req.SetRequestTime(time.Now())
 	req.Region = ucloud.String(s.scope.Region())
 	req.ProjectId = ucloud.String(s.scope.ProjectId())
 	req.VPCId = ucloud.String(s.scope.UCloudCluster.Status.Network.VPC.VpcId)
@@ -36,8 +32,7 @@ func (s *Service) CreateCAPUCluster() error {
 		req.BastionZone = ucloud.String(bastionInfo.Zone)
 	}
 
-	var res CreateCAPUClusterResponse
-	err := s.doRequest(req, &res)
+	res, err := s.uk8sClient.CreateCAPUCluster(req)
 	if err != nil {
 		return errors.Wrap(err, "create uk8s capu cluster failed")
 	}
@@ -50,14 +45,11 @@ func (s *Service) DeleteCAPUCluster() error {
 		return nil
 	}
 	req := &DeleteCAPUClusterRequest{}
-	req.SetAction("DeleteCAPUCluster")
-	req.SetRequestTime(time.Now())
 	req.Region = ucloud.String(s.scope.Region())
 	req.ProjectId = ucloud.String(s.scope.ProjectId())
 	req.ClusterId = ucloud.String(s.scope.UCloudCluster.Status.ClusterId)
 
-	var res DeleteCAPUClusterResponse
-	err := s.doRequest(req, &res)
+	_, err := s.uk8sClient.DeleteCAPUCluster(req)
 	if err != nil {
 		return errors.Wrap(err, "delete uk8s capu cluster failed")
 	}
@@ -69,22 +61,19 @@ func (s *Service) CreateCAPUHost(scope *scope.MachineScope) error {
 		return nil
 	}
 	req := &CreateCAPUHostRequest{}
-	req.SetAction("CreateCAPUHost")
-	req.SetRequestTime(time.Now())
 	req.Region = ucloud.String(s.scope.Region())
 	req.ProjectId = ucloud.String(s.scope.ProjectId())
 	req.ClusterId = ucloud.String(s.scope.UCloudCluster.Status.ClusterId)
 	req.InstanceId = scope.GetInstanceID()
 	req.Zone = ucloud.String(scope.GetZone())
-	req.Type = ucloud.String("uhost")
+	req.Type = ucloud.String(scope.InstanceKind())
 	if scope.IsControlPlane() {
 		req.Role = ucloud.String("master")
 	} else {
 		req.Role = ucloud.String("node")
 	}
 
-	var res CreateCAPUHostResponse
-	err := s.doRequest(req, &res)
+	_, err := s.uk8sClient.CreateCAPUHost(req)
 	if err != nil {
 		return errors.Wrap(err, "create uk8s capu host failed")
 	}
@@ -93,19 +82,20 @@ func (s *Service) CreateCAPUHost(scope *scope.MachineScope) error {
 }
 
 func (s *Service) DeleteCAPUHost(scope *scope.MachineScope) error {
+	if scope.UCloudMachine.Status.ClusterId == "" {
+		return nil
+	}
 	req := &DeleteCAPUHostRequest{}
-	req.SetAction("DeleteCAPUHost")
-	req.SetRequestTime(time.Now())
 	req.Region = ucloud.String(s.scope.Region())
 	req.ProjectId = ucloud.String(s.scope.ProjectId())
 	req.ClusterId = ucloud.String(s.scope.UCloudCluster.Status.ClusterId)
 	req.InstanceId = scope.GetInstanceID()
 
-	var res DeleteCAPUHostResponse
-	err := s.doRequest(req, &res)
+	_, err := s.uk8sClient.DeleteCAPUHost(req)
 	if err != nil {
 		return errors.Wrap(err, "delete uk8s capu host failed")
 	}
+	scope.UCloudMachine.Status.ClusterId = ""
 	return nil
 }
 
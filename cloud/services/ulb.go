@@ -17,20 +17,40 @@ limitations under the License.
 package services
 
 import (
-	"time"
+	"fmt"
 
 	"github.com/pkg/errors"
 	"github.com/ucloud/ucloud-sdk-go/services/ulb"
 	"github.com/ucloud/ucloud-sdk-go/ucloud"
 	"github.com/ucloud/ucloud-sdk-go/ucloud/request"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
 )
 
+// ReconcileULB makes sure the load balancer of the api server exists. A public load balancer
+// has an EIP bound, an internal one is created in the control plane subnet without an EIP.
+// A recorded load balancer that was deleted is created again with the address of the
+// endpoint, unless it was given by the spec.
 func (s *Service) ReconcileULB() error {
-	if len(s.scope.UCloudCluster.Status.Network.ULB.LoadBalancerId) > 0 {
-		return nil
+	ulbSpec := s.scope.UCloudCluster.Spec.Network.ULB
+	status := &s.scope.UCloudCluster.Status.Network.ULB
+	driftedId := status.LoadBalancerId
+	if driftedId != "" {
+		lb, err := s.describeULB(driftedId)
+		if err != nil {
+			return err
+		}
+		if lb != nil {
+			return s.repairULB(lb)
+		}
+		if ulbSpec.LoadBalancerId == driftedId {
+			s.reportDrift("load balancer "+driftedId, "not found", false)
+			return errors.Errorf("load balancer %s not found", driftedId)
+		}
 	}
 	s.scope.Info("reconcile ulb")
-	ulbSpec := s.scope.UCloudCluster.Spec.Network.ULB
+	internal := ulbSpec.Mode == infrav1.ULBModeInternal
 	req := s.ulbClient.NewDescribeULBRequest()
 	req.Region = ucloud.String(s.scope.Region())
 	req.ProjectId = ucloud.String(s.scope.ProjectId())
@@ -43,9 +63,10 @@ func (s *Service) ReconcileULB() error {
 		req.ULBId = ucloud.String(ulbSpec.LoadBalancerId)
 	}
 	var finalULB *ulb.ULBSet
+	var newEIP *infrav1.EIP
 	ulbs, err := s.ulbClient.DescribeULB(req)
 	if err != nil {
-		return errors.Errorf("describe ulb failed: %s", err.Error())
+		return errors.Wrap(err, "describe ulb failed")
 	}
 	ulbExist := false
 	for _, ulb := range ulbs.DataSet {
@@ -57,100 +78,356 @@ func (s *Service) ReconcileULB() error {
 	}
 
 	if !ulbExist {
+		// the endpoint must keep its address, so a deleted load balancer gets its EIP back
+		var eip infrav1.EIP
+		if !internal && driftedId != "" && status.EIP.EIPId != "" {
+			eip, err = s.endpointEIP()
+			if err != nil {
+				return err
+			}
+		}
+
 		// create ulb
 		// req := s.ulbClient.NewCreateULBRequest()
 		req := &CreateULBRequestPlus{}
-		req.SetAction("CreateULB")
-		req.SetRequestTime(time.Now())
 		req.Region = ucloud.String(s.scope.Region())
 		req.ProjectId = ucloud.String(s.scope.ProjectId())
 		req.VPCId = ucloud.String(vpcId)
-		req.ListenType = ucloud.String("RequestProxy")
+		req.ListenType = ucloud.String(s.scope.LoadBalancerListener().ListenType)
 		req.Tag = ucloud.String(s.scope.GroupName())
+		req.ChargeType = ucloud.String(chargeType(ulbSpec.ChargeSpec))
 
 		if ulbSpec.LoadBalancerName != "" {
 			req.ULBName = ucloud.String(ulbSpec.LoadBalancerName)
+		} else if internal {
+			req.ULBName = ucloud.String(common.GenerateInternalLBName(s.scope.Name()))
 		} else {
 			req.ULBName = ucloud.String("ulb-for-" + s.scope.UCloudCluster.ClusterName)
 		}
+		if internal {
+			subnetId := s.scope.UCloudCluster.Status.Network.Subnet.SubnetId
+			if subnetId == "" {
+				return errors.Errorf("subnet is not created")
+			}
+			req.InnerMode = ucloud.String("Yes")
+			req.SubnetId = ucloud.String(subnetId)
+		}
 		// newULB, err := s.ulbClient.CreateULB(req)
 		// newULB, err := s.createULB(req)
-		var newULB ulb.CreateULBResponse
-		err = s.doRequest(req, &newULB)
+		newULB, err := s.ulbClient.CreateULBPlus(req)
 		if err != nil {
-			return errors.Errorf("create ulb failed: %s", err.Error())
+			return errors.Wrap(err, "create ulb failed")
 		}
-
-		// create eip
-		eip, err := s.createEIP(ulbSpec.EIP)
-		if err != nil {
-			return err
+		// the load balancer is recorded before its EIP and vserver are added, so that a
+		// failed step is done again by repairULB instead of creating another one
+		if driftedId != "" {
+			s.reportDrift("load balancer "+driftedId, "not found, replaced by "+newULB.ULBId, true)
+		}
+		status.LoadBalancerId = newULB.ULBId
+		status.LoadBalancerName = ucloud.StringValue(req.ULBName)
+		status.VpcId = vpcId
+		status.VServerId = ""
+		status.ChargeType = chargeType(ulbSpec.ChargeSpec)
+
+		if !internal {
+			// create eip
+			if eip.EIPId == "" {
+				eip, err = s.createEIP(ulbSpec.EIP)
+				if err != nil {
+					return err
+				}
+				status.EIP = eip
+			}
+			newEIP = &eip
+
+			// bind eip
+			err = s.bindEIP(eip.EIPId, newULB.ULBId, "ulb")
+			if err != nil {
+				return err
+			}
 		}
 
-		// bind eip
-		err = s.bindEIP(eip.EIPId, newULB.ULBId, "ulb")
+		// create vserver
+		vserver, err := s.createVServer(newULB.ULBId)
 		if err != nil {
 			return err
 		}
 
-		// create vserver
-		reqVserver := s.ulbClient.NewCreateVServerRequest()
-		reqVserver.Region = ucloud.String(s.scope.Region())
-		reqVserver.ProjectId = ucloud.String(s.scope.ProjectId())
-		reqVserver.ULBId = ucloud.String(newULB.ULBId)
-		reqVserver.Protocol = ucloud.String("TCP")
-		reqVserver.MonitorType = ucloud.String("Port")
-		reqVserver.FrontendPort = ucloud.Int(6443)
-		reqVserver.ListenType = ucloud.String("RequestProxy")
-		if ulbSpec.VServerName != "" {
-			reqVserver.VServerName = ucloud.String(ulbSpec.VServerName)
-		} else {
-			reqVserver.VServerName = ucloud.String("k8s-api-server")
-		}
-		newVserver, err := s.ulbClient.CreateVServer(reqVserver)
-		if err != nil {
-			return errors.Errorf("create vserver failed: %s", err.Error())
+		// the expiry is only known once the load balancer is paid for, and the private
+		// address once it is created
+		expireTime, privateIP := 0, ""
+		if created, err := s.describeULB(newULB.ULBId); err == nil && created != nil {
+			expireTime, privateIP = created.ExpireTime, created.PrivateIP
 		}
 
 		finalULB = &ulb.ULBSet{
-			Bandwidth: eip.Bandwidth,
+			ExpireTime: expireTime,
+			Bandwidth:  eip.Bandwidth,
 			// IPSet:         nil,
-			Name:    ucloud.StringValue(req.ULBName),
-			ULBId:   newULB.ULBId,
-			ULBType: "OuterMode",
-			VPCId:   vpcId,
+			Name:      ucloud.StringValue(req.ULBName),
+			ULBId:     newULB.ULBId,
+			ULBType:   "OuterMode",
+			PrivateIP: privateIP,
+			VPCId:     vpcId,
 			// VServerSet:    nil,
 		}
-		finalULB.IPSet = append(finalULB.IPSet, ulb.ULBIPSet{
-			Bandwidth: eip.Bandwidth,
-			EIP:       eip.EIPAddr,
-			EIPId:     eip.EIPId,
-		})
-		finalULB.VServerSet = append(finalULB.VServerSet, ulb.ULBVServerSet{
-			// BackendSet:      nil,
-			FrontendPort: 6443,
-			ListenType:   "PacketsTransmit",
-			Method:       "Roundrobin",
-			MonitorType:  "Port",
-			Protocol:     "TCP",
-			VServerId:    newVserver.VServerId,
-			VServerName:  ucloud.StringValue(reqVserver.VServerName),
-		})
+		if internal {
+			finalULB.ULBType = "InnerMode"
+		} else {
+			finalULB.IPSet = append(finalULB.IPSet, ulb.ULBIPSet{
+				Bandwidth: eip.Bandwidth,
+				EIP:       eip.EIPAddr,
+				EIPId:     eip.EIPId,
+			})
+		}
+		finalULB.VServerSet = append(finalULB.VServerSet, *vserver)
 
 	}
 
 	s.scope.Info("reconcile ulb success", "status", finalULB)
+	if driftedId != "" && ulbExist {
+		s.reportDrift("load balancer "+driftedId, "not found, replaced by "+finalULB.ULBId, true)
+	}
+
+	status.LoadBalancerId = finalULB.ULBId
+	status.LoadBalancerName = finalULB.Name
+	status.VpcId = finalULB.VPCId
+	status.VServerId = finalULB.VServerSet[0].VServerId
+	status.FrontendPort = finalULB.VServerSet[0].FrontendPort
+	if len(finalULB.IPSet) > 0 {
+		status.EIP.EIPId = finalULB.IPSet[0].EIPId
+		status.EIP.EIPAddr = finalULB.IPSet[0].EIP
+		status.EIP.Bandwidth = finalULB.IPSet[0].Bandwidth
+	}
+	status.ChargeType = chargeType(ulbSpec.ChargeSpec)
+	status.ExpireTime = ExpireTime(chargeType(ulbSpec.ChargeSpec), finalULB.ExpireTime)
+	if newEIP != nil {
+		status.EIP.ChargeType = newEIP.ChargeType
+		status.EIP.ExpireTime = newEIP.ExpireTime
+	}
+	if internal {
+		return s.checkInternalEndpoint(finalULB)
+	}
+	return nil
+}
+
+// createVServer creates the VServer of the api server on the load balancer, with the
+// listener of the spec.
+func (s *Service) createVServer(ulbId string) (*ulb.ULBVServerSet, error) {
+	listener := s.scope.LoadBalancerListener()
+	req := s.ulbClient.NewCreateVServerRequest()
+	req.Region = ucloud.String(s.scope.Region())
+	req.ProjectId = ucloud.String(s.scope.ProjectId())
+	req.ULBId = ucloud.String(ulbId)
+	req.Protocol = ucloud.String("TCP")
+	req.FrontendPort = ucloud.Int(listener.FrontendPort)
+	req.ListenType = ucloud.String(listener.ListenType)
+	req.Method = ucloud.String(listener.Method)
+	req.MonitorType = ucloud.String(listener.HealthCheck.Type)
+	if listener.HealthCheck.Type == infrav1.ULBHealthCheckPath {
+		req.Path = ucloud.String(listener.HealthCheck.Path)
+		req.Domain = ucloud.String(listener.HealthCheck.Domain)
+	}
+	req.PersistenceType = ucloud.String(listener.PersistenceType)
+	if listener.PersistenceType == infrav1.ULBPersistenceUserDefined {
+		req.PersistenceInfo = ucloud.String(listener.PersistenceInfo)
+	}
+	if vserverName := s.scope.UCloudCluster.Spec.Network.ULB.VServerName; vserverName != "" {
+		req.VServerName = ucloud.String(vserverName)
+	} else {
+		req.VServerName = ucloud.String("k8s-api-server")
+	}
+	res, err := s.ulbClient.CreateVServer(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "create vserver failed")
+	}
+	return &ulb.ULBVServerSet{
+		Domain:          ucloud.StringValue(req.Domain),
+		FrontendPort:    listener.FrontendPort,
+		ListenType:      listener.ListenType,
+		Method:          listener.Method,
+		MonitorType:     listener.HealthCheck.Type,
+		Path:            ucloud.StringValue(req.Path),
+		PersistenceInfo: ucloud.StringValue(req.PersistenceInfo),
+		PersistenceType: listener.PersistenceType,
+		Protocol:        "TCP",
+		VServerId:       res.VServerId,
+		VServerName:     ucloud.StringValue(req.VServerName),
+	}, nil
+}
+
+// reconcileVServer updates the attributes of the VServer of the api server that differ
+// from the listener of the spec. The port and the listen type of a VServer can't be
+// updated, a VServer that doesn't have them is reported.
+func (s *Service) reconcileVServer(ulbId string, vserver *ulb.ULBVServerSet) error {
+	listener := s.scope.LoadBalancerListener()
+	resource := "vserver " + vserver.VServerId
+	if vserver.FrontendPort != listener.FrontendPort || vserver.ListenType != listener.ListenType {
+		message := fmt.Sprintf("listens on %d with %s instead of %d with %s", vserver.FrontendPort, vserver.ListenType, listener.FrontendPort, listener.ListenType)
+		s.reportDrift(resource, message, false)
+		return errors.Errorf("%s %s", resource, message)
+	}
+
+	path, domain, persistenceInfo := "", "", ""
+	if listener.HealthCheck.Type == infrav1.ULBHealthCheckPath {
+		path, domain = listener.HealthCheck.Path, listener.HealthCheck.Domain
+	}
+	if listener.PersistenceType == infrav1.ULBPersistenceUserDefined {
+		persistenceInfo = listener.PersistenceInfo
+	}
+	if vserver.Method == listener.Method && vserver.MonitorType == listener.HealthCheck.Type &&
+		(path == "" || vserver.Path == path) && (domain == "" || vserver.Domain == domain) &&
+		vserver.PersistenceType == listener.PersistenceType && (persistenceInfo == "" || vserver.PersistenceInfo == persistenceInfo) {
+		return nil
+	}
+	s.scope.Info("update vserver", "vserverid", vserver.VServerId, "method", listener.Method,
+		"healthcheck", listener.HealthCheck.Type, "persistence", listener.PersistenceType)
+	req := s.ulbClient.NewUpdateVServerAttributeRequest()
+	req.Region = ucloud.String(s.scope.Region())
+	req.ProjectId = ucloud.String(s.scope.ProjectId())
+	req.ULBId = ucloud.String(ulbId)
+	req.VServerId = ucloud.String(vserver.VServerId)
+	req.Method = ucloud.String(listener.Method)
+	req.MonitorType = ucloud.String(listener.HealthCheck.Type)
+	if path != "" {
+		req.Path = ucloud.String(path)
+	}
+	if domain != "" {
+		req.Domain = ucloud.String(domain)
+	}
+	req.PersistenceType = ucloud.String(listener.PersistenceType)
+	if persistenceInfo != "" {
+		req.PersistenceInfo = ucloud.String(persistenceInfo)
+	}
+	if _, err := s.ulbClient.UpdateVServerAttribute(req); err != nil {
+		return errors.Wrapf(err, "update vserver %s failed", vserver.VServerId)
+	}
+	return nil
+}
+
+// describeULB returns the load balancer with the id, or nil if it doesn't exist.
+func (s *Service) describeULB(ulbId string) (*ulb.ULBSet, error) {
+	req := s.ulbClient.NewDescribeULBRequest()
+	req.Region = ucloud.String(s.scope.Region())
+	req.ProjectId = ucloud.String(s.scope.ProjectId())
+	req.ULBId = ucloud.String(ulbId)
+	res, err := s.ulbClient.DescribeULB(req)
+	if err != nil {
+		if common.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "describe ulb %s failed", ulbId)
+	}
+	for i := range res.DataSet {
+		if res.DataSet[i].ULBId == ulbId {
+			return &res.DataSet[i], nil
+		}
+	}
+	return nil, nil
+}
 
-	s.scope.UCloudCluster.Status.Network.ULB.LoadBalancerId = finalULB.ULBId
-	s.scope.UCloudCluster.Status.Network.ULB.LoadBalancerName = finalULB.Name
-	s.scope.UCloudCluster.Status.Network.ULB.VpcId = finalULB.VPCId
-	s.scope.UCloudCluster.Status.Network.ULB.VServerId = finalULB.VServerSet[0].VServerId
-	s.scope.UCloudCluster.Status.Network.ULB.EIP.EIPId = finalULB.IPSet[0].EIPId
-	s.scope.UCloudCluster.Status.Network.ULB.EIP.EIPAddr = finalULB.IPSet[0].EIP
-	s.scope.UCloudCluster.Status.Network.ULB.EIP.Bandwidth = finalULB.IPSet[0].Bandwidth
+// repairULB restores the VServer of the api server and the EIP of the endpoint of the
+// recorded load balancer, and updates the VServer to the listener of the spec. The
+// backends of a new VServer are added again by the machines.
+func (s *Service) repairULB(lb *ulb.ULBSet) error {
+	status := &s.scope.UCloudCluster.Status.Network.ULB
+	resource := "load balancer " + lb.ULBId
+	// a load balancer is recorded before its EIP and vserver are added, what is missing
+	// after a failed creation is added without reporting a drift
+	completed := status.VServerId != ""
+	var vserver *ulb.ULBVServerSet
+	for i := range lb.VServerSet {
+		if lb.VServerSet[i].VServerId == status.VServerId {
+			vserver = &lb.VServerSet[i]
+			break
+		}
+	}
+	if vserver == nil {
+		created, err := s.createVServer(lb.ULBId)
+		if err != nil {
+			return err
+		}
+		if completed {
+			s.reportDrift(resource, fmt.Sprintf("has no vserver %s, replaced by %s", status.VServerId, created.VServerId), true)
+		}
+		status.VServerId = created.VServerId
+		vserver = created
+	}
+	status.FrontendPort = vserver.FrontendPort
+	if err := s.reconcileVServer(lb.ULBId, vserver); err != nil {
+		return err
+	}
+	if status.ExpireTime == nil {
+		status.ExpireTime = ExpireTime(status.ChargeType, lb.ExpireTime)
+	}
+
+	ulbSpec := s.scope.UCloudCluster.Spec.Network.ULB
+	if ulbSpec.Mode == infrav1.ULBModeInternal {
+		return s.checkInternalEndpoint(lb)
+	}
+	if status.EIP.EIPId == "" {
+		eip, err := s.createEIP(ulbSpec.EIP)
+		if err != nil {
+			return err
+		}
+		status.EIP = eip
+		return s.bindEIP(eip.EIPId, lb.ULBId, "ulb")
+	}
+	for _, ip := range lb.IPSet {
+		if ip.EIPId == status.EIP.EIPId {
+			return nil
+		}
+	}
+	eip, err := s.endpointEIP()
+	if err != nil {
+		return err
+	}
+	if err := s.bindEIP(eip.EIPId, lb.ULBId, "ulb"); err != nil {
+		return err
+	}
+	if completed {
+		s.reportDrift(resource, fmt.Sprintf("has no eip %s, bound again", eip.EIPId), true)
+	}
 	return nil
 }
 
+// checkInternalEndpoint records the private address of an internal load balancer as the
+// address of the endpoint, and reports the drift of a load balancer that doesn't have it,
+// e.g. because it was recreated. The endpoint can't move to another address.
+func (s *Service) checkInternalEndpoint(lb *ulb.ULBSet) error {
+	status := &s.scope.UCloudCluster.Status.Network.ULB
+	if status.PrivateIP == "" {
+		status.PrivateIP = lb.PrivateIP
+	}
+	endpoint := status.PrivateIP
+	if lb.PrivateIP == endpoint {
+		return nil
+	}
+	s.reportDrift("load balancer "+lb.ULBId, "has address "+lb.PrivateIP+" instead of the api server endpoint "+endpoint, false)
+	return errors.Errorf("load balancer %s doesn't have the address %s of the api server endpoint", lb.ULBId, endpoint)
+}
+
+// endpointEIP returns the recorded EIP of the api server endpoint if it can be bound to
+// the load balancer again. The endpoint can't move to another address, so the drift is
+// only reported if the EIP was released or bound to another resource.
+func (s *Service) endpointEIP() (infrav1.EIP, error) {
+	recorded := s.scope.UCloudCluster.Status.Network.ULB.EIP
+	resource := "eip " + recorded.EIPId
+	eipSet, err := s.describeEIP(recorded.EIPId)
+	if err != nil {
+		return infrav1.EIP{}, err
+	}
+	if eipSet == nil {
+		s.reportDrift(resource, "of the api server endpoint "+recorded.EIPAddr+" not found", false)
+		return infrav1.EIP{}, errors.Errorf("eip %s of the api server endpoint not found", recorded.EIPId)
+	}
+	if resourceId := eipSet.Resource.ResourceId; resourceId != "" {
+		s.reportDrift(resource, "of the api server endpoint "+recorded.EIPAddr+" is bound to "+resourceId, false)
+		return infrav1.EIP{}, errors.Errorf("eip %s of the api server endpoint is bound to %s", recorded.EIPId, resourceId)
+	}
+	return recorded, nil
+}
+
 func (s *Service) DeleteULB() error {
 
 	s.scope.Info("delete ulb")
@@ -177,13 +454,15 @@ func (s *Service) deleteULB(ulbId string) error {
 	delReq.ProjectId = ucloud.String(s.scope.ProjectId())
 	delReq.ULBId = ucloud.String(ulbId)
 	delReq.ReleaseEip = ucloud.Bool(true)
-	res, err := s.ulbClient.DeleteULB(delReq)
-	if err != nil && res.GetRetCode() != 63059 {
-		return errors.Errorf("delete ulb %s failed: %s", ulbId, err.Error())
+	_, err := s.ulbClient.DeleteULB(delReq)
+	if err != nil && !common.IsNotFound(err) {
+		return errors.Wrapf(err, "delete ulb %s failed", ulbId)
 	}
 	return nil
 }
 
+// AddRealServer adds the host to the VServer of the api server on the backend port, and
+// moves a host that is already added to the backend port.
 func (s *Service) AddRealServer(hostId string) error {
 	req := s.ulbClient.NewDescribeVServerRequest()
 	req.Region = ucloud.String(s.scope.Region())
@@ -192,15 +471,29 @@ func (s *Service) AddRealServer(hostId string) error {
 	req.VServerId = ucloud.String(s.scope.UCloudCluster.Status.Network.ULB.VServerId)
 	res, err := s.ulbClient.DescribeVServer(req)
 	if err != nil {
-		return errors.Wrapf(err, "describe vserver failed")
+		return errors.Wrap(err, "describe vserver failed")
 	}
 	if len(res.DataSet) == 0 {
-		return errors.Errorf("vserver %s not exist", req.VServerId)
+		return errors.Errorf("vserver %s not exist", ucloud.StringValue(req.VServerId))
 	}
+	port := s.scope.LoadBalancerBackendPort()
 	for _, backend := range res.DataSet[0].BackendSet {
-		if backend.ResourceId == hostId {
+		if backend.ResourceId != hostId {
+			continue
+		}
+		if backend.Port == port {
 			return nil
 		}
+		reqUpdate := s.ulbClient.NewUpdateBackendAttributeRequest()
+		reqUpdate.Region = req.Region
+		reqUpdate.ProjectId = req.ProjectId
+		reqUpdate.ULBId = req.ULBId
+		reqUpdate.BackendId = ucloud.String(backend.BackendId)
+		reqUpdate.Port = ucloud.Int(port)
+		if _, err := s.ulbClient.UpdateBackendAttribute(reqUpdate); err != nil {
+			return errors.Wrapf(err, "update port of backend %s to %d failed", backend.BackendId, port)
+		}
+		return nil
 	}
 	reqAddRS := s.ulbClient.NewAllocateBackendRequest()
 	reqAddRS.Region = req.Region
@@ -209,7 +502,7 @@ func (s *Service) AddRealServer(hostId string) error {
 	reqAddRS.VServerId = req.VServerId
 	reqAddRS.ResourceType = ucloud.String("UHost")
 	reqAddRS.ResourceId = ucloud.String(hostId)
-	reqAddRS.Port = ucloud.Int(6443)
+	reqAddRS.Port = ucloud.Int(port)
 	_, err = s.ulbClient.AllocateBackend(reqAddRS)
 	if err != nil {
 		return errors.Wrapf(err, "add resource %s to vserver %s failed", hostId, ucloud.StringValue(req.VServerId))
@@ -225,10 +518,10 @@ func (s *Service) DelRealServer(hostId string) error {
 	req.VServerId = ucloud.String(s.scope.UCloudCluster.Status.Network.ULB.VServerId)
 	res, err := s.ulbClient.DescribeVServer(req)
 	if err != nil {
-		return errors.Wrapf(err, "describe vserver failed")
+		return errors.Wrap(err, "describe vserver failed")
 	}
 	if len(res.DataSet) == 0 {
-		return errors.Errorf("vserver %s not exist", req.VServerId)
+		return errors.Errorf("vserver %s not exist", ucloud.StringValue(req.VServerId))
 	}
 	backendId := ""
 	for _, backend := range res.DataSet[0].BackendSet {
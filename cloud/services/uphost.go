@@ -0,0 +1,274 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"github.com/pkg/errors"
+	"github.com/ucloud/ucloud-sdk-go/services/uhost"
+	"github.com/ucloud/ucloud-sdk-go/services/uphost"
+	"github.com/ucloud/ucloud-sdk-go/ucloud"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/request"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/scope"
+)
+
+// Bare-metal machines are described as uhost instances, so that the machine controller
+// handles both kinds the same way.
+
+// phostIfExists returns the bare-metal machine id or nothing if it doesn't exist.
+func (s *Service) phostIfExists(id string) (*uhost.UHostInstanceSet, error) {
+	s.scope.Info("looking for uphost by id", "id", id)
+	host, err := s.describePHost(id)
+	if err != nil || host == nil {
+		return nil, err
+	}
+	return phostInstance(host), nil
+}
+
+// createPHost runs a bare-metal machine.
+func (s *Service) createPHost(scope *scope.MachineScope) (*uhost.UHostInstanceSet, error) {
+	s.scope.Info("Creating a uphost")
+	userData, err := s.instanceUserData(scope)
+	if err != nil {
+		record.Warnf(scope.Machine, "FailedCreate", "Failed to create uphost")
+		return nil, err
+	}
+	zone, err := s.instanceZone(scope)
+	if err != nil {
+		record.Warnf(scope.Machine, "FailedCreate", "Failed to create uphost")
+		return nil, err
+	}
+	if scope.UCloudMachine.Spec.PHost == nil || scope.UCloudMachine.Spec.ImageId == nil {
+		record.Warnf(scope.Machine, "FailedCreate", "Failed to create uphost")
+		return nil, errors.New("uphost instances need pHost and imageId")
+	}
+	password, err := scope.LoginPassword()
+	if err != nil {
+		record.Warnf(scope.Machine, "FailedCreate", "Failed to create uphost")
+		return nil, err
+	}
+
+	req := &CreatePHostRequestPlus{}
+	req.Region = ucloud.String(s.scope.Region())
+	req.ProjectId = ucloud.String(s.scope.ProjectId())
+	req.Zone = ucloud.String(zone)
+	req.Tag = ucloud.String(s.scope.GroupName())
+	req.Name = ucloud.String(instanceName(scope))
+	req.Type = ucloud.String(scope.UCloudMachine.Spec.PHost.Type)
+	if raid := scope.UCloudMachine.Spec.PHost.Raid; raid != "" {
+		req.Raid = ucloud.String(raid)
+	}
+	req.ImageId = scope.UCloudMachine.Spec.ImageId
+	req.Password = ucloud.String(password)
+	req.ChargeType = ucloud.String(chargeType(scope.UCloudMachine.Spec.ChargeSpec))
+	req.Quantity = ucloud.Int(chargeQuantity(scope.UCloudMachine.Spec.ChargeSpec))
+	req.VPCId = ucloud.String(s.scope.UCloudCluster.Status.Network.VPC.VpcId)
+	subnet, err := s.subnetFor(scope.Role(), zone)
+	if err != nil {
+		record.Warnf(scope.Machine, "FailedCreate", "Failed to create uphost")
+		return nil, err
+	}
+	req.SubnetId = ucloud.String(subnet.SubnetId)
+	req.UserData = ucloud.String(userData)
+
+	res, err := s.uphostClient.CreatePHostPlus(req)
+	if err != nil {
+		record.Warnf(scope.Machine, "FailedCreate", "Failed to create uphost")
+		return nil, errors.Wrap(err, "create uphost failed")
+	}
+	if len(res.PHostId) == 0 {
+		return nil, errors.New("create uphost returned no id")
+	}
+	id := res.PHostId[0]
+	s.scope.Info("uphost created successed", "phostid", id)
+	record.Eventf(scope.Machine, "SuccessfulCreate", "Created new %s uphost with name %q", scope.Role(), ucloud.StringValue(req.Name))
+
+	// Like instances, bare-metal machines take a while to install. Describe it once so
+	// that the caller can persist its id, the caller polls its state in later reconciles.
+	host, err := s.describePHost(id)
+	if err != nil || host == nil {
+		s.scope.Info("describe new uphost failed, its state is left unknown", "phostid", id, "error", err)
+		return &uhost.UHostInstanceSet{
+			UHostId:    id,
+			Zone:       zone,
+			Name:       ucloud.StringValue(req.Name),
+			ImageId:    ucloud.StringValue(req.ImageId),
+			ChargeType: ucloud.StringValue(req.ChargeType),
+		}, nil
+	}
+	instance := phostInstance(host)
+	instance.ImageId = ucloud.StringValue(req.ImageId)
+	return instance, nil
+}
+
+// terminatePHost moves a bare-metal machine one step closer to deletion, it is powered
+// off before it is terminated. It returns true once the machine is gone.
+func (s *Service) terminatePHost(id string) (bool, error) {
+	host, err := s.describePHost(id)
+	if err != nil {
+		return false, err
+	}
+	if host == nil {
+		s.scope.Info("terminate uphost successed", "phostid", id)
+		return true, nil
+	}
+
+	state := phostState(host.PMStatus)
+	switch state {
+	case uhost.StateStopped, uhost.StateInstallFail:
+		s.scope.Info("start terminate uphost", "phostid", id)
+		req := s.uphostClient.NewTerminatePHostRequest()
+		req.Region = ucloud.String(s.scope.Region())
+		req.ProjectId = ucloud.String(s.scope.ProjectId())
+		req.Zone = ucloud.String(host.Zone)
+		req.PHostId = ucloud.String(id)
+		req.ReleaseEIP = ucloud.Bool(true)
+		if _, err := s.uphostClient.TerminatePHost(req); err != nil && !common.IsNotFound(err) {
+			return false, errors.Wrapf(err, "terminate uphost %s failed", id)
+		}
+	case uhost.StateRunning:
+		s.scope.Info("start poweroff uphost", "phostid", id)
+		req := s.uphostClient.NewPoweroffPHostRequest()
+		req.Region = ucloud.String(s.scope.Region())
+		req.ProjectId = ucloud.String(s.scope.ProjectId())
+		req.Zone = ucloud.String(host.Zone)
+		req.PHostId = ucloud.String(id)
+		if _, err := s.uphostClient.PoweroffPHost(req); err != nil {
+			return false, errors.Wrapf(err, "poweroff uphost %s failed", id)
+		}
+	default:
+		s.scope.Info("waiting for uphost to settle before deleting it", "phostid", id, "state", state)
+	}
+	return false, nil
+}
+
+// describePHost returns the bare-metal machine with the given id, or nil if it doesn't exist.
+func (s *Service) describePHost(id string) (*uphost.PHostSet, error) {
+	req := s.uphostClient.NewDescribePHostRequest()
+	req.Region = ucloud.String(s.scope.Region())
+	req.ProjectId = ucloud.String(s.scope.ProjectId())
+	req.PHostId = []string{id}
+	res, err := s.uphostClient.DescribePHost(req)
+	if err != nil {
+		if common.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to describe uphost: %s", id)
+	}
+	if len(res.PHostSet) == 0 {
+		return nil, nil
+	}
+	return &res.PHostSet[0], nil
+}
+
+// phostInstance describes a bare-metal machine as a uhost instance.
+func phostInstance(host *uphost.PHostSet) *uhost.UHostInstanceSet {
+	instance := &uhost.UHostInstanceSet{
+		UHostId:     host.PHostId,
+		Zone:        host.Zone,
+		Name:        host.Name,
+		Tag:         host.Tag,
+		State:       string(phostState(host.PMStatus)),
+		CPU:         host.CPUSet.Count * host.CPUSet.CoreCount,
+		Memory:      host.Memory,
+		MachineType: host.PHostType,
+		OsName:      host.OSname,
+		OsType:      host.OSType,
+		ChargeType:  host.ChargeType,
+		CreateTime:  host.CreateTime,
+		ExpireTime:  host.ExpireTime,
+	}
+	for _, ip := range host.IPSet {
+		instance.IPSet = append(instance.IPSet, uhost.UHostIPSet{
+			Type:      phostIPType(ip.OperatorName),
+			IP:        ip.IPAddr,
+			IPId:      ip.IPId,
+			Mac:       ip.MACAddr,
+			Bandwidth: ip.Bandwidth,
+			SubnetId:  ip.SubnetId,
+			VPCId:     ip.VPCId,
+		})
+	}
+	return instance
+}
+
+// phostState returns the uhost state matching the state of a bare-metal machine.
+func phostState(status string) uhost.State {
+	switch status {
+	case "Install Fail", "InstallFailed":
+		return uhost.StateInstallFail
+	default:
+		return uhost.State(status)
+	}
+}
+
+// phostIPType returns the uhost address type matching the operator of an address of
+// a bare-metal machine.
+func phostIPType(operatorName string) string {
+	switch operatorName {
+	case "BGP":
+		return "Bgp"
+	case "International":
+		return "Internation"
+	default:
+		return operatorName
+	}
+}
+
+// CreatePHostRequestPlus is the CreatePHost request with the fields missing from the
+// sdk request, such as UserData.
+type CreatePHostRequestPlus struct {
+	request.CommonBase
+
+	// ImageId
+	ImageId *string `required:"true"`
+
+	// 密码（密码需使用base64进行编码）
+	Password *string `required:"true"`
+
+	// 物理机类型，默认为：db-2(基础型-SAS-V3)
+	Type *string `required:"false"`
+
+	// 物理机名称，默认为phost
+	Name *string `required:"false"`
+
+	// 物理机备注，默认为空
+	Remark *string `required:"false"`
+
+	// 业务组，默认为default
+	Tag *string `required:"false"`
+
+	// 计费模式，枚举值为："Year"，按年付费； "Month"，按月付费；"Dynamic"，按小时付费; 默认按月付费
+	ChargeType *string `required:"false"`
+
+	// 购买时长，默认为1。月付时，此参数传0，代表了购买至月末。
+	Quantity *int `required:"false"`
+
+	// Raid配置，默认Raid10  支持:Raid0、Raid1、Raid5、Raid10，NoRaid
+	Raid *string `required:"false"`
+
+	// VPC ID，不填为默认，VPC2.0下需要填写此字段。
+	VPCId *string `required:"false"`
+
+	// 子网ID，不填为默认，VPC2.0下需要填写此字段。
+	SubnetId *string `required:"false"`
+
+	// 用户自定义数据，需要base64编码
+	UserData *string `required:"false"`
+}
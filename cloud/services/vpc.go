@@ -17,25 +17,47 @@ limitations under the License.
 package services
 
 import (
+	"fmt"
+
 	"github.com/pkg/errors"
 	"github.com/ucloud/ucloud-sdk-go/services/vpc"
 	"github.com/ucloud/ucloud-sdk-go/ucloud"
+
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
 )
 
+// ReconcileVPC makes sure the VPC of the cluster exists. A recorded VPC that was deleted
+// is created again, unless it was given by the spec.
 func (s *Service) ReconcileVPC() error {
-	if len(s.scope.UCloudCluster.Status.Network.VPC.VpcId) > 0 {
-		return nil
+	vpcSpec := s.scope.UCloudCluster.Spec.Network.VPC
+	driftedId := s.scope.UCloudCluster.Status.Network.VPC.VpcId
+	if driftedId != "" {
+		vpcInfo, err := s.describeVPC(driftedId)
+		if err != nil {
+			return err
+		}
+		if vpcInfo != nil {
+			s.checkVPC(vpcInfo)
+			// the network segments of VPCs recorded by older versions are filled in, and
+			// segments changed since are picked up
+			return s.recordVPC(vpcInfo)
+		}
+		if vpcSpec.VpcId == driftedId {
+			s.reportDrift("vpc "+driftedId, "not found", false)
+			return errors.Errorf("vpc %s not found", driftedId)
+		}
 	}
 	s.scope.Info("reconcile VPC")
 	// Create VPC
 	var finalVpc *vpc.VPCInfo
-	vpcSpec := s.scope.UCloudCluster.Spec.Network.VPC
 	req := s.vpcClient.NewDescribeVPCRequest()
 	req.Region = ucloud.String(s.scope.Region())
 	req.ProjectId = ucloud.String(s.scope.ProjectId())
-	req.Tag = ucloud.String(s.scope.GroupName())
+	// an existing VPC may be shared with other clusters, and tagged with another group
 	if vpcSpec.VpcId != "" {
 		req.VPCIds = append(req.VPCIds, vpcSpec.VpcId)
+	} else {
+		req.Tag = ucloud.String(s.scope.GroupName())
 	}
 	vpcName := vpcSpec.VpcName
 	if vpcName == "" {
@@ -43,7 +65,7 @@ func (s *Service) ReconcileVPC() error {
 	}
 	vpcs, err := s.vpcClient.DescribeVPC(req)
 	if err != nil {
-		return errors.Errorf("describe vpc failed: %s", err.Error())
+		return errors.Wrap(err, "describe vpc failed")
 	}
 	vpcExist := false
 	for _, vpcInfo := range vpcs.DataSet {
@@ -54,31 +76,82 @@ func (s *Service) ReconcileVPC() error {
 		}
 	}
 	if !vpcExist {
+		cidrBlock := vpcSpec.CidrBlock
+		if cidrBlock == "" {
+			cidrBlock = common.DefaultVnetCIDR
+		}
 		req := s.vpcClient.NewCreateVPCRequest()
 		req.Region = ucloud.String(s.scope.Region())
 		req.ProjectId = ucloud.String(s.scope.ProjectId())
 		req.Name = ucloud.String(vpcName)
-		req.Network = append(req.Network, vpcSpec.CidrBlock)
+		req.Network = append(req.Network, cidrBlock)
 		req.Tag = ucloud.String(s.scope.GroupName())
 		vpcInfo, err := s.vpcClient.CreateVPC(req)
 		if err != nil {
-			return errors.Errorf("create vpc failed: name %s, cidr %s", vpcName, vpcSpec.CidrBlock)
+			return errors.Wrapf(err, "create vpc failed: name %s, cidr %s", vpcName, cidrBlock)
 		}
 		finalVpc = &vpc.VPCInfo{
 			Name:    vpcName,
-			Network: []string{vpcSpec.CidrBlock},
+			Network: []string{cidrBlock},
 			VPCId:   vpcInfo.VPCId,
 		}
 	}
 
 	s.scope.Info("reconcile VPC success", "status", finalVpc)
+	if driftedId != "" {
+		s.reportDrift("vpc "+driftedId, "not found, replaced by "+finalVpc.VPCId, true)
+	}
 
-	s.scope.UCloudCluster.Status.Network.VPC.CidrBlock = finalVpc.Network[0]
-	s.scope.UCloudCluster.Status.Network.VPC.VpcName = finalVpc.Name
-	s.scope.UCloudCluster.Status.Network.VPC.VpcId = finalVpc.VPCId
+	return s.recordVPC(finalVpc)
+}
+
+// checkVPC reports the differences between the recorded VPC and the VPC in UCloud. They
+// are only reported, the VPC is not changed back.
+func (s *Service) checkVPC(vpcInfo *vpc.VPCInfo) {
+	status := s.scope.UCloudCluster.Status.Network.VPC
+	resource := "vpc " + vpcInfo.VPCId
+	if status.VpcName != "" && vpcInfo.Name != status.VpcName {
+		s.reportDrift(resource, fmt.Sprintf("is named %s instead of %s", vpcInfo.Name, status.VpcName), false)
+	}
+	if len(status.CidrBlocks) != 0 && !sameIds(vpcInfo.Network, status.CidrBlocks) {
+		s.reportDrift(resource, fmt.Sprintf("has network segments %v instead of %v", vpcInfo.Network, status.CidrBlocks), false)
+	}
+}
+
+// recordVPC records the VPC of the cluster in the status.
+func (s *Service) recordVPC(vpcInfo *vpc.VPCInfo) error {
+	if len(vpcInfo.Network) == 0 {
+		return errors.Errorf("vpc %s has no network segment", vpcInfo.VPCId)
+	}
+	status := &s.scope.UCloudCluster.Status.Network.VPC
+	status.CidrBlock = vpcInfo.Network[0]
+	status.CidrBlocks = append([]string(nil), vpcInfo.Network...)
+	status.VpcName = vpcInfo.Name
+	status.VpcId = vpcInfo.VPCId
 	return nil
 }
 
+// describeVPC returns the VPC with the id, or nil if it doesn't exist.
+func (s *Service) describeVPC(vpcId string) (*vpc.VPCInfo, error) {
+	req := s.vpcClient.NewDescribeVPCRequest()
+	req.Region = ucloud.String(s.scope.Region())
+	req.ProjectId = ucloud.String(s.scope.ProjectId())
+	req.VPCIds = []string{vpcId}
+	res, err := s.vpcClient.DescribeVPC(req)
+	if err != nil {
+		if common.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "describe vpc %s failed", vpcId)
+	}
+	for i := range res.DataSet {
+		if res.DataSet[i].VPCId == vpcId {
+			return &res.DataSet[i], nil
+		}
+	}
+	return nil, nil
+}
+
 func (s *Service) DeleteVPC() error {
 
 	s.scope.Info("delete VPC")
@@ -96,9 +169,9 @@ func (s *Service) DeleteVPC() error {
 	delReq.Region = ucloud.String(s.scope.Region())
 	delReq.ProjectId = ucloud.String(s.scope.ProjectId())
 	delReq.VPCId = ucloud.String(id)
-	res, err := s.vpcClient.DeleteVPC(delReq)
-	if err != nil && res.GetRetCode() != 58103 {
-		return errors.Errorf("delete vpc %s failed", id)
+	_, err := s.vpcClient.DeleteVPC(delReq)
+	if err != nil && !common.IsNotFound(err) {
+		return errors.Wrapf(err, "delete vpc %s failed", id)
 	}
 	s.scope.Info("delete vpc success", "vpcid", id)
 	return nil
@@ -0,0 +1,243 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/ucloud/ucloud-sdk-go/services/vpc"
+	"github.com/ucloud/ucloud-sdk-go/ucloud"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/auth"
+	uerr "github.com/ucloud/ucloud-sdk-go/ucloud/error"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/scope"
+)
+
+// fakeVPCAPI is an in-memory VPCAPI holding VPCs. The subnet and NAT gateway actions are
+// not implemented.
+type fakeVPCAPI struct {
+	VPCAPI
+
+	vpcs        []vpc.VPCInfo
+	created     int
+	createErr   error
+	describeErr error
+}
+
+func (f *fakeVPCAPI) NewCreateVPCRequest() *vpc.CreateVPCRequest {
+	return &vpc.CreateVPCRequest{}
+}
+
+func (f *fakeVPCAPI) CreateVPC(req *vpc.CreateVPCRequest) (*vpc.CreateVPCResponse, error) {
+	if f.createErr != nil {
+		return nil, f.createErr
+	}
+	f.created++
+	id := fmt.Sprintf("uvnet-%d", f.created)
+	f.vpcs = append(f.vpcs, vpc.VPCInfo{
+		VPCId:   id,
+		Name:    ucloud.StringValue(req.Name),
+		Network: req.Network,
+		Tag:     ucloud.StringValue(req.Tag),
+	})
+	return &vpc.CreateVPCResponse{VPCId: id}, nil
+}
+
+func (f *fakeVPCAPI) NewDescribeVPCRequest() *vpc.DescribeVPCRequest {
+	return &vpc.DescribeVPCRequest{}
+}
+
+func (f *fakeVPCAPI) DescribeVPC(req *vpc.DescribeVPCRequest) (*vpc.DescribeVPCResponse, error) {
+	if f.describeErr != nil {
+		return nil, f.describeErr
+	}
+	res := &vpc.DescribeVPCResponse{}
+	for _, info := range f.vpcs {
+		if len(req.VPCIds) > 0 && info.VPCId != req.VPCIds[0] {
+			continue
+		}
+		if req.Tag != nil && info.Tag != *req.Tag {
+			continue
+		}
+		res.DataSet = append(res.DataSet, info)
+	}
+	return res, nil
+}
+
+func (f *fakeVPCAPI) NewDeleteVPCRequest() *vpc.DeleteVPCRequest {
+	return &vpc.DeleteVPCRequest{}
+}
+
+func (f *fakeVPCAPI) DeleteVPC(req *vpc.DeleteVPCRequest) (*vpc.DeleteVPCResponse, error) {
+	f.delete(ucloud.StringValue(req.VPCId))
+	return &vpc.DeleteVPCResponse{}, nil
+}
+
+// delete removes a VPC, as if it was deleted in the console.
+func (f *fakeVPCAPI) delete(id string) {
+	for i := range f.vpcs {
+		if f.vpcs[i].VPCId == id {
+			f.vpcs = append(f.vpcs[:i], f.vpcs[i+1:]...)
+			return
+		}
+	}
+}
+
+// newVPCTestService returns the service of a cluster with the VPC spec, using vpcAPI.
+func newVPCTestService(g *WithT, vpcSpec infrav1.VPCSpec, vpcAPI VPCAPI) (*Service, *infrav1.UCloudCluster) {
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	ucloudCluster := &infrav1.UCloudCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"},
+		Spec: infrav1.UCloudClusterSpec{
+			ProjectId: "org-test",
+			Region:    "cn-bj2",
+			Network:   infrav1.NetworkSpec{VPC: vpcSpec},
+		},
+	}
+	ucloudCluster.Status.Group.GroupName = "capu-test"
+	clusterScope, err := scope.NewClusterScope(scope.ClusterScopeParams{
+		UCloudClients: scope.UCloudClients{Credential: &auth.Credential{PublicKey: "public", PrivateKey: "private"}},
+		Client:        fake.NewFakeClientWithScheme(scheme, ucloudCluster),
+		Cluster:       &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"}},
+		UCloudCluster: ucloudCluster,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	return NewServiceWithClients(clusterScope, Clients{VPC: vpcAPI}), ucloudCluster
+}
+
+func TestReconcileVPC(t *testing.T) {
+	t.Run("creates a VPC once", func(t *testing.T) {
+		g := NewWithT(t)
+		vpcAPI := &fakeVPCAPI{}
+		svc, ucloudCluster := newVPCTestService(g, infrav1.VPCSpec{}, vpcAPI)
+
+		g.Expect(svc.ReconcileVPC()).To(Succeed())
+		g.Expect(svc.ReconcileVPC()).To(Succeed())
+		g.Expect(vpcAPI.created).To(Equal(1))
+		g.Expect(vpcAPI.vpcs).To(ConsistOf(vpc.VPCInfo{VPCId: "uvnet-1", Name: "cluster-api-my-cluster-vpc", Network: []string{"10.0.0.0/8"}, Tag: "capu-test"}))
+		status := ucloudCluster.Status.Network.VPC
+		g.Expect(status.VpcId).To(Equal("uvnet-1"))
+		g.Expect(status.VpcName).To(Equal("cluster-api-my-cluster-vpc"))
+		g.Expect(status.CidrBlock).To(Equal("10.0.0.0/8"))
+		g.Expect(status.CidrBlocks).To(Equal([]string{"10.0.0.0/8"}))
+		g.Expect(svc.Drifts()).To(BeEmpty())
+
+		g.Expect(svc.DeleteVPC()).To(Succeed())
+		g.Expect(vpcAPI.vpcs).To(BeEmpty())
+	})
+
+	t.Run("uses and keeps the VPC of the spec", func(t *testing.T) {
+		g := NewWithT(t)
+		vpcAPI := &fakeVPCAPI{vpcs: []vpc.VPCInfo{{VPCId: "uvnet-shared", Name: "shared", Network: []string{"172.16.0.0/16", "10.10.0.0/16"}, Tag: "other"}}}
+		svc, ucloudCluster := newVPCTestService(g, infrav1.VPCSpec{VpcId: "uvnet-shared"}, vpcAPI)
+
+		g.Expect(svc.ReconcileVPC()).To(Succeed())
+		g.Expect(vpcAPI.created).To(BeZero())
+		g.Expect(ucloudCluster.Status.Network.VPC.VpcId).To(Equal("uvnet-shared"))
+		g.Expect(ucloudCluster.Status.Network.VPC.CidrBlock).To(Equal("172.16.0.0/16"))
+		g.Expect(ucloudCluster.Status.Network.VPC.CidrBlocks).To(Equal([]string{"172.16.0.0/16", "10.10.0.0/16"}))
+
+		g.Expect(svc.DeleteVPC()).To(Succeed())
+		g.Expect(vpcAPI.vpcs).To(HaveLen(1))
+
+		// a VPC of the spec deleted in the console is only reported
+		vpcAPI.delete("uvnet-shared")
+		g.Expect(svc.ReconcileVPC()).NotTo(Succeed())
+		g.Expect(vpcAPI.created).To(BeZero())
+		g.Expect(svc.Drifts()).To(HaveLen(1))
+		g.Expect(svc.Drifts()[0].Repaired).To(BeFalse())
+	})
+
+	t.Run("creates a deleted VPC again", func(t *testing.T) {
+		g := NewWithT(t)
+		vpcAPI := &fakeVPCAPI{}
+		svc, ucloudCluster := newVPCTestService(g, infrav1.VPCSpec{CidrBlock: "192.168.0.0/16"}, vpcAPI)
+
+		g.Expect(svc.ReconcileVPC()).To(Succeed())
+		vpcAPI.delete("uvnet-1")
+		g.Expect(svc.ReconcileVPC()).To(Succeed())
+		g.Expect(vpcAPI.created).To(Equal(2))
+		g.Expect(ucloudCluster.Status.Network.VPC.VpcId).To(Equal("uvnet-2"))
+		g.Expect(ucloudCluster.Status.Network.VPC.CidrBlock).To(Equal("192.168.0.0/16"))
+		g.Expect(svc.Drifts()).To(HaveLen(1))
+		g.Expect(svc.Drifts()[0].Repaired).To(BeTrue())
+	})
+
+	t.Run("refreshes the network segments of a recorded VPC", func(t *testing.T) {
+		g := NewWithT(t)
+		vpcAPI := &fakeVPCAPI{vpcs: []vpc.VPCInfo{{VPCId: "uvnet-1", Name: "cluster-api-my-cluster-vpc", Network: []string{"10.0.0.0/8", "172.16.0.0/16"}, Tag: "capu-test"}}}
+		svc, ucloudCluster := newVPCTestService(g, infrav1.VPCSpec{}, vpcAPI)
+		ucloudCluster.Status.Network.VPC = infrav1.VPC{VpcId: "uvnet-1", VpcName: "cluster-api-my-cluster-vpc", CidrBlock: "10.0.0.0/8"}
+
+		g.Expect(svc.ReconcileVPC()).To(Succeed())
+		g.Expect(vpcAPI.created).To(BeZero())
+		g.Expect(ucloudCluster.Status.Network.VPC.CidrBlocks).To(Equal([]string{"10.0.0.0/8", "172.16.0.0/16"}))
+		g.Expect(svc.Drifts()).To(BeEmpty())
+	})
+
+	t.Run("reports the changes of a recorded VPC", func(t *testing.T) {
+		g := NewWithT(t)
+		vpcAPI := &fakeVPCAPI{}
+		svc, ucloudCluster := newVPCTestService(g, infrav1.VPCSpec{}, vpcAPI)
+		g.Expect(svc.ReconcileVPC()).To(Succeed())
+
+		vpcAPI.vpcs[0].Name = "renamed"
+		vpcAPI.vpcs[0].Network = []string{"10.0.0.0/8", "172.16.0.0/16"}
+		g.Expect(svc.ReconcileVPC()).To(Succeed())
+		g.Expect(svc.ReconcileVPC()).To(Succeed())
+		g.Expect(vpcAPI.created).To(Equal(1))
+		g.Expect(svc.Drifts()).To(Equal([]Drift{
+			{Resource: "vpc uvnet-1", Message: "is named renamed instead of cluster-api-my-cluster-vpc"},
+			{Resource: "vpc uvnet-1", Message: "has network segments [10.0.0.0/8 172.16.0.0/16] instead of [10.0.0.0/8]"},
+		}))
+		status := ucloudCluster.Status.Network.VPC
+		g.Expect(status.VpcName).To(Equal("renamed"))
+		g.Expect(status.CidrBlocks).To(Equal([]string{"10.0.0.0/8", "172.16.0.0/16"}))
+	})
+
+	t.Run("keeps the cause of a failed create", func(t *testing.T) {
+		g := NewWithT(t)
+		quota := uerr.NewServerCodeError(8300, "Quota not enough")
+		svc, _ := newVPCTestService(g, infrav1.VPCSpec{}, &fakeVPCAPI{createErr: quota})
+
+		err := svc.ReconcileVPC()
+		g.Expect(err).To(MatchError(ContainSubstring("create vpc failed")))
+		g.Expect(errors.Cause(err)).To(Equal(quota))
+		g.Expect(common.IsQuotaExceeded(err)).To(BeTrue())
+	})
+
+	t.Run("fails on a failed describe", func(t *testing.T) {
+		g := NewWithT(t)
+		vpcAPI := &fakeVPCAPI{describeErr: errors.New("boom")}
+		svc, ucloudCluster := newVPCTestService(g, infrav1.VPCSpec{}, vpcAPI)
+
+		err := svc.ReconcileVPC()
+		g.Expect(err).To(MatchError(ContainSubstring("describe vpc failed")))
+		g.Expect(vpcAPI.created).To(BeZero())
+		g.Expect(ucloudCluster.Status.Network.VPC.VpcId).To(BeEmpty())
+	})
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/ucloud/ucloud-sdk-go/ucloud/auth"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/klogr"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-ucloud/test/fakeucloud"
+)
+
+// fakeUCloudFixture is a cluster whose reconcilers run against a fake UCloud API.
+type fakeUCloudFixture struct {
+	server        *fakeucloud.Server
+	client        client.Client
+	cluster       *clusterv1.Cluster
+	ucloudCluster *infrav1.UCloudCluster
+	clusterScope  *scope.ClusterScope
+}
+
+// newFakeUCloudCluster returns the cluster my-cluster with spec, in the project org-test
+// and the region cn-bj2 of a new fake UCloud API. The caller closes the server.
+func newFakeUCloudCluster(t *testing.T, spec infrav1.UCloudClusterSpec) *fakeUCloudFixture {
+	g := NewWithT(t)
+
+	server := fakeucloud.NewServer()
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	spec.ProjectId = "org-test"
+	spec.Region = "cn-bj2"
+	spec.Version = "1.18.3"
+	spec.API = &infrav1.APISpec{BaseURL: server.URL}
+	cluster := newCluster("my-cluster")
+	cluster.Spec.ClusterNetwork = &clusterv1.ClusterNetwork{
+		Pods:     &clusterv1.NetworkRanges{CIDRBlocks: []string{"192.168.0.0/16"}},
+		Services: &clusterv1.NetworkRanges{CIDRBlocks: []string{"10.96.0.0/12"}},
+	}
+	ucloudCluster := &infrav1.UCloudCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"},
+		Spec:       spec,
+	}
+	kubeClient := fake.NewFakeClientWithScheme(scheme, cluster, ucloudCluster)
+	clusterScope, err := scope.NewClusterScope(scope.ClusterScopeParams{
+		UCloudClients: scope.UCloudClients{Credential: &auth.Credential{PublicKey: "public", PrivateKey: "private"}},
+		Client:        kubeClient,
+		Logger:        klogr.New(),
+		Cluster:       cluster,
+		UCloudCluster: ucloudCluster,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	return &fakeUCloudFixture{
+		server:        server,
+		client:        kubeClient,
+		cluster:       cluster,
+		ucloudCluster: ucloudCluster,
+		clusterScope:  clusterScope,
+	}
+}
+
+// newMachineScope adds the machine name of the cluster in the zone cn-bj2-02, with its
+// UCloudMachine and bootstrap data, and returns its scope.
+func (f *fakeUCloudFixture) newMachineScope(g *WithT, name string, controlPlane bool, spec infrav1.UCloudMachineSpec) *scope.MachineScope {
+	machine := newMachine("my-cluster", name)
+	if controlPlane {
+		machine.Labels[clusterv1.MachineControlPlaneLabelName] = ""
+	}
+	machine.Spec.Version = pointer.StringPtr("v1.18.3")
+	machine.Spec.FailureDomain = pointer.StringPtr("cn-bj2-02")
+	machine.Spec.Bootstrap.DataSecretName = pointer.StringPtr(name + "-bootstrap")
+	ucloudMachine := &infrav1.UCloudMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "ucloud" + name, Namespace: "default"},
+		Spec:       spec,
+	}
+	bootstrapData := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name + "-bootstrap", Namespace: "default"},
+		Data:       map[string][]byte{"value": []byte("#cloud-config")},
+	}
+	for _, obj := range []runtime.Object{machine, ucloudMachine, bootstrapData} {
+		g.Expect(f.client.Create(context.TODO(), obj)).To(Succeed())
+	}
+	machineScope, err := scope.NewMachineScope(scope.MachineScopeParams{
+		Client:        f.client,
+		Logger:        klogr.New(),
+		Cluster:       f.cluster,
+		Machine:       machine,
+		UCloudCluster: f.ucloudCluster,
+		UCloudMachine: ucloudMachine,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	return machineScope
+}
@@ -17,13 +17,43 @@ limitations under the License.
 package controllers
 
 import (
+	"time"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	clusterutil "sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
+)
+
+const (
+	// throttledRequeueAfter is how long to wait after the UCloud API rate limited a request.
+	throttledRequeueAfter = 30 * time.Second
+	// insufficientResourcesRequeueAfter is how long to wait for a quota increase or a top up.
+	insufficientResourcesRequeueAfter = 5 * time.Minute
+	// instancePendingRequeueAfter is how long to wait before checking on an instance that is starting.
+	instancePendingRequeueAfter = 15 * time.Second
+	// instanceDeletingRequeueAfter is how long to wait before taking the next step of deleting an instance.
+	instanceDeletingRequeueAfter = 10 * time.Second
+	// instanceNotFoundTimeout is how long a new instance may be missing from the UCloud API,
+	// which can lag behind a create, before its machine fails.
+	instanceNotFoundTimeout = 5 * time.Minute
 )
 
+// requeueAfterForError returns how long to wait before retrying after a UCloud API
+// error that clears up over time, or zero if the error should be returned as is.
+func requeueAfterForError(err error) time.Duration {
+	switch common.ReasonForError(err) {
+	case common.ReasonThrottled:
+		return throttledRequeueAfter
+	case common.ReasonQuotaExceeded, common.ReasonInsufficientBalance:
+		return insufficientResourcesRequeueAfter
+	}
+	return 0
+}
+
 // TODO: Move to Cluster API
 var pausePredicates = predicate.Funcs{
 	UpdateFunc: func(e event.UpdateEvent) bool {
@@ -0,0 +1,47 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/pkg/errors"
+	uerr "github.com/ucloud/ucloud-sdk-go/ucloud/error"
+)
+
+func TestRequeueAfterForError(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name string
+		err  error
+		want time.Duration
+	}{
+		{"no error", nil, 0},
+		{"plain error", errors.New("boom"), 0},
+		{"not found", errors.Wrap(uerr.NewServerCodeError(8039, "uhost not exist"), "describe uhost failed"), 0},
+		{"rate limited status", errors.Wrap(uerr.NewServerStatusError(429, "429 Too Many Requests"), "create uhost failed"), throttledRequeueAfter},
+		{"quota message", errors.Wrap(uerr.NewServerCodeError(8300, "Quota not enough"), "create uhost failed"), insufficientResourcesRequeueAfter},
+		{"balance message", errors.Wrap(uerr.NewServerCodeError(8301, "Account balance not enough"), "create uhost failed"), insufficientResourcesRequeueAfter},
+	}
+	for _, tt := range tests {
+		g.Expect(requeueAfterForError(tt.err)).To(Equal(tt.want), tt.name)
+	}
+}
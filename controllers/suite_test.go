@@ -17,12 +17,15 @@ limitations under the License.
 package controllers
 
 import (
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/ucloud/ucloud-sdk-go/ucloud/auth"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog"
@@ -34,6 +37,7 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-ucloud/test/fakeucloud"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -44,6 +48,19 @@ var cfg *rest.Config
 var k8sClient client.Client
 var testEnv *envtest.Environment
 
+// fakeUCloud serves the UCloud API to the clusters of the suite that set
+// spec.api.baseURL to fakeUCloud.URL.
+var fakeUCloud *fakeucloud.Server
+
+// fakeUCloudCredentials signs the requests of the reconcilers of the suite to fakeUCloud,
+// which does not check signatures.
+type fakeUCloudCredentials struct{}
+
+// Credential implements scope.CredentialProvider.
+func (fakeUCloudCredentials) Credential() (*auth.Credential, error) {
+	return &auth.Credential{PublicKey: "public", PrivateKey: "private"}, nil
+}
+
 func init() {
 	klog.InitFlags(nil)
 	klog.SetOutput(GinkgoWriter)
@@ -60,13 +77,15 @@ func TestAPIs(t *testing.T) {
 
 var _ = BeforeSuite(func(done Done) {
 	By("bootstrapping test environment")
+	clusterAPIDir, err := moduleDir("sigs.k8s.io/cluster-api")
+	Expect(err).ToNot(HaveOccurred())
 	testEnv = &envtest.Environment{
 		CRDDirectoryPaths: []string{
 			filepath.Join("..", "config", "crd", "bases"),
+			filepath.Join(clusterAPIDir, "config", "crd", "bases"),
 		},
 	}
 
-	var err error
 	cfg, err = testEnv.Start()
 	Expect(err).ToNot(HaveOccurred())
 	Expect(cfg).ToNot(BeNil())
@@ -80,11 +99,27 @@ var _ = BeforeSuite(func(done Done) {
 	Expect(err).ToNot(HaveOccurred())
 	Expect(k8sClient).ToNot(BeNil())
 
+	By("starting the fake UCloud API")
+	fakeUCloud = fakeucloud.NewServer()
+
 	close(done)
 }, 60)
 
+// moduleDir returns the directory of the given module in the module cache, at
+// the version required by go.mod.
+func moduleDir(module string) (string, error) {
+	out, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", module).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
 var _ = AfterSuite(func() {
 	By("tearing down the test environment")
+	if fakeUCloud != nil {
+		fakeUCloud.Close()
+	}
 	err := testEnv.Stop()
 	Expect(err).ToNot(HaveOccurred())
 })
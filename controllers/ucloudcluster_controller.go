@@ -23,9 +23,14 @@ import (
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controllers/remote"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -36,14 +41,27 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
 	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/scope"
 	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/services"
 )
 
+// NodeCredentialSecretName is the name of the Secret in the kube-system namespace of the
+// workload cluster holding the node key pair.
+const NodeCredentialSecretName = "ucloud-credentials"
+
 // UCloudClusterReconciler reconciles a UCloudCluster object
 type UCloudClusterReconciler struct {
 	client.Client
 	Log logr.Logger
+
+	// CredentialProvider supplies the UCloud key pair for clusters without an identityRef.
+	// The environment of the manager is used if not set.
+	CredentialProvider scope.CredentialProvider
+
+	// remoteClientGetter returns the client of a workload cluster, remote.NewClusterClient
+	// if not set.
+	remoteClientGetter remote.ClusterClientGetter
 }
 
 func (r *UCloudClusterReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
@@ -81,6 +99,9 @@ func (r *UCloudClusterReconciler) SetupWithManager(mgr ctrl.Manager, options con
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=ucloudclusters,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=ucloudclusters/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=ucloudclusteridentities,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create
 
 func (r *UCloudClusterReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr error) {
 	ctx := context.TODO()
@@ -116,6 +137,7 @@ func (r *UCloudClusterReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, re
 
 	// Create the scope.
 	clusterScope, err := scope.NewClusterScope(scope.ClusterScopeParams{
+		UCloudClients: scope.UCloudClients{CredentialProvider: r.CredentialProvider},
 		Client:        r.Client,
 		Logger:        log,
 		Cluster:       cluster,
@@ -132,13 +154,23 @@ func (r *UCloudClusterReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, re
 		}
 	}()
 
-	// Handle deleted clusters
+	var result ctrl.Result
 	if !ucloudCluster.DeletionTimestamp.IsZero() {
-		return r.reconcileDelete(clusterScope)
+		// Handle deleted clusters
+		result, err = r.reconcileDelete(clusterScope)
+	} else {
+		// Handle non-deleted clusters
+		result, err = r.reconcile(clusterScope)
 	}
 
-	// Handle non-deleted clusters
-	return r.reconcile(clusterScope)
+	// Back off on UCloud API errors that clear up over time instead of failing hot.
+	if requeueAfter := requeueAfterForError(err); requeueAfter > 0 {
+		reason := common.ReasonForError(err)
+		log.Info("UCloud API request failed, requeueing", "reason", reason, "requeueAfter", requeueAfter, "error", err.Error())
+		record.Warnf(ucloudCluster, string(reason), "Failed to reconcile UCloudCluster: %v", err)
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+	return result, err
 }
 
 func (r *UCloudClusterReconciler) reconcile(clusterScope *scope.ClusterScope) (ctrl.Result, error) {
@@ -155,35 +187,35 @@ func (r *UCloudClusterReconciler) reconcile(clusterScope *scope.ClusterScope) (c
 
 	computeSvc := services.NewService(clusterScope)
 
-	if err := computeSvc.ReconcileUGroup(); err != nil {
-		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile group for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
-	}
-
-	if err := computeSvc.ReconcileVPC(); err != nil {
-		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile network for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
+	err := reconcileNetwork(computeSvc, ucloudCluster)
+	// a failed reconcile may not have checked all the resources, so it only records the drifts it found
+	if drifts := computeSvc.Drifts(); err == nil || len(drifts) > 0 {
+		setNetworkInSyncCondition(ucloudCluster, drifts)
 	}
-
-	if err := computeSvc.ReconcileSubnet(); err != nil {
-		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile subnet for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
+	if err != nil {
+		return ctrl.Result{}, err
 	}
 
-	if err := computeSvc.ReconcileNat(); err != nil {
-		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile nat gateway for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
+	// an internal load balancer is only reached at its private address
+	endpointHost := ucloudCluster.Status.Network.ULB.EIP.EIPAddr
+	if ucloudCluster.Spec.Network.ULB.Mode == infrav1.ULBModeInternal {
+		endpointHost = ucloudCluster.Status.Network.ULB.PrivateIP
 	}
-
-	if err := computeSvc.ReconcileULB(); err != nil {
-		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile load balancers for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
+	if endpointHost == "" {
+		clusterScope.Info("Waiting on API server IP Address")
+		return ctrl.Result{RequeueAfter: 15 * time.Second}, nil
 	}
 
-	if ucloudCluster.Status.Network.ULB.EIP.EIPAddr == "" {
-		clusterScope.Info("Waiting on API server Global IP Address")
-		return ctrl.Result{RequeueAfter: 15 * time.Second}, nil
+	// the port of the endpoint is the port the listener actually listens on
+	endpointPort := ucloudCluster.Status.Network.ULB.FrontendPort
+	if endpointPort == 0 {
+		endpointPort = clusterScope.LoadBalancerFrontendPort()
 	}
 
 	// Set APIEndpoints so the Cluster API Cluster Controller can pull them
 	ucloudCluster.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{
-		Host: ucloudCluster.Status.Network.ULB.EIP.EIPAddr,
-		Port: 6443,
+		Host: endpointHost,
+		Port: int32(endpointPort),
 	}
 
 	// Set FailureDomains on the UCloudCluster Status
@@ -209,9 +241,118 @@ func (r *UCloudClusterReconciler) reconcile(clusterScope *scope.ClusterScope) (c
 		return ctrl.Result{}, errors.Wrapf(err, "failed to create uk8s capu cluster for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
 	}
 
+	if err = r.reconcileNodeCredential(clusterScope); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile node credential for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
+	}
+
+	if clusterScope.HasNodeCredential() && !clusterScope.Cluster.Status.ControlPlaneInitialized {
+		clusterScope.Info("Waiting on control plane initialization to deliver node credential")
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+	}
+
 	return ctrl.Result{}, nil
 }
 
+// reconcileNetwork makes sure the network resources of the cluster exist and match the spec.
+func reconcileNetwork(computeSvc *services.Service, ucloudCluster *infrav1.UCloudCluster) error {
+	if err := computeSvc.ReconcileUGroup(); err != nil {
+		return errors.Wrapf(err, "failed to reconcile group for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
+	}
+
+	if err := computeSvc.ReconcileVPC(); err != nil {
+		return errors.Wrapf(err, "failed to reconcile network for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
+	}
+
+	if err := computeSvc.ReconcileSubnet(); err != nil {
+		return errors.Wrapf(err, "failed to reconcile subnet for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
+	}
+
+	if err := computeSvc.ReconcileFirewall(); err != nil {
+		return errors.Wrapf(err, "failed to reconcile firewall for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
+	}
+
+	if err := computeSvc.ReconcileNat(); err != nil {
+		return errors.Wrapf(err, "failed to reconcile nat gateway for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
+	}
+
+	if err := computeSvc.ReconcileMachineFirewalls(); err != nil {
+		return errors.Wrapf(err, "failed to reconcile machine firewalls for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
+	}
+
+	if err := computeSvc.ReconcileULB(); err != nil {
+		return errors.Wrapf(err, "failed to reconcile load balancers for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
+	}
+
+	return nil
+}
+
+// setNetworkInSyncCondition records the drifts of the network resources found by a reconcile
+// in the NetworkInSync condition. The cluster is not ready while a drift is not repaired.
+func setNetworkInSyncCondition(ucloudCluster *infrav1.UCloudCluster, drifts []services.Drift) {
+	var repaired, drifted []string
+	for _, drift := range drifts {
+		if drift.Repaired {
+			repaired = append(repaired, drift.String())
+		} else {
+			drifted = append(drifted, drift.String())
+		}
+	}
+	condition := infrav1.Condition{Type: infrav1.NetworkInSyncCondition, Status: corev1.ConditionTrue}
+	switch {
+	case len(drifted) > 0:
+		ucloudCluster.Status.Ready = false
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = infrav1.NetworkDriftedReason
+		condition.Message = strings.Join(drifted, "; ")
+	case len(repaired) > 0:
+		condition.Reason = infrav1.NetworkRepairedReason
+		condition.Message = strings.Join(repaired, "; ")
+	}
+	ucloudCluster.SetCondition(condition)
+}
+
+// reconcileNodeCredential copies the dedicated node key pair into the workload cluster
+// so that components such as the cloud provider can read it from a Secret.
+func (r *UCloudClusterReconciler) reconcileNodeCredential(clusterScope *scope.ClusterScope) error {
+	if !clusterScope.HasNodeCredential() || !clusterScope.Cluster.Status.ControlPlaneInitialized {
+		return nil
+	}
+	ctx := context.TODO()
+
+	credential, err := clusterScope.NodeCredential()
+	if err != nil {
+		return err
+	}
+
+	remoteClientGetter := r.remoteClientGetter
+	if remoteClientGetter == nil {
+		remoteClientGetter = remote.NewClusterClient
+	}
+	remoteClient, err := remoteClientGetter(ctx, r.Client, util.ObjectKey(clusterScope.Cluster), clientgoscheme.Scheme)
+	if err != nil {
+		return errors.Wrap(err, "failed to create client for workload cluster")
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceSystem,
+			Name:      NodeCredentialSecretName,
+		},
+	}
+	_, err = controllerutil.CreateOrUpdate(ctx, remoteClient, secret, func() error {
+		secret.Type = corev1.SecretTypeOpaque
+		secret.Data = map[string][]byte{
+			scope.PublicKeyName:  []byte(credential.PublicKey),
+			scope.PrivateKeyName: []byte(credential.PrivateKey),
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to write secret %s/%s to workload cluster", secret.Namespace, secret.Name)
+	}
+	return nil
+}
+
 func (r *UCloudClusterReconciler) reconcileDelete(clusterScope *scope.ClusterScope) (ctrl.Result, error) {
 	clusterScope.Info("Reconciling UCloudCluster delete")
 	ctx := context.TODO()
@@ -238,9 +379,14 @@ func (r *UCloudClusterReconciler) reconcileDelete(clusterScope *scope.ClusterSco
 	computeSvc := services.NewService(clusterScope)
 	ucloudCluster := clusterScope.UCloudCluster
 
-	if err := computeSvc.TerminateBastion(); err != nil {
+	bastionDeleted, err := computeSvc.TerminateBastion()
+	if err != nil {
 		return ctrl.Result{}, errors.Wrapf(err, "error deleting bastion for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
 	}
+	if !bastionDeleted {
+		clusterScope.Info("Waiting on bastion deleted")
+		return ctrl.Result{RequeueAfter: instanceDeletingRequeueAfter}, nil
+	}
 
 	if err := computeSvc.DeleteULB(); err != nil {
 		return ctrl.Result{}, errors.Wrapf(err, "error deleting load balancer for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
@@ -250,9 +396,26 @@ func (r *UCloudClusterReconciler) reconcileDelete(clusterScope *scope.ClusterSco
 		return ctrl.Result{}, errors.Wrapf(err, "error deleting nat gateway for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
 	}
 
-	if err := computeSvc.CleanResourceInGroup(); err != nil {
+	groupCleaned, err := computeSvc.CleanResourceInGroup()
+	if err != nil {
 		return ctrl.Result{}, errors.Wrapf(err, "error cleaning resource in business group for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
 	}
+	if !groupCleaned {
+		clusterScope.Info("Waiting on all uhosts in business group deleted")
+		return ctrl.Result{RequeueAfter: instanceDeletingRequeueAfter}, nil
+	}
+
+	if err := computeSvc.DeleteMachineFirewalls(); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "error deleting machine firewalls for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
+	}
+
+	if err := computeSvc.DeleteFirewall(); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "error deleting firewall for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
+	}
+
+	if err := computeSvc.DeleteKeyPairs(); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "error deleting key pairs for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
+	}
 
 	if err := computeSvc.DeleteSubnet(); err != nil {
 		return ctrl.Result{}, errors.Wrapf(err, "error deleting vpc subnet for UCloudCluster %s/%s", ucloudCluster.Namespace, ucloudCluster.Name)
@@ -21,7 +21,10 @@ import (
 	. "github.com/onsi/gomega"
 
 	"golang.org/x/net/context"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -59,4 +62,82 @@ var _ = Describe("UCloudClusterReconciler", func() {
 			Expect(result.Requeue).To(BeFalse())
 		})
 	})
+
+	Context("Reconcile an UCloudCluster against the fake UCloud API", func() {
+		It("should create the network of the cluster, then delete it", func() {
+			ctx := context.Background()
+
+			reconciler := &UCloudClusterReconciler{
+				Client:             k8sClient,
+				Log:                log.Log,
+				CredentialProvider: fakeUCloudCredentials{},
+			}
+			_, ucloudCluster := createFakeUCloudCluster(ctx, "fake-cluster")
+			key := client.ObjectKey{Namespace: ucloudCluster.Namespace, Name: ucloudCluster.Name}
+
+			By("creating the network")
+			result, err := reconciler.Reconcile(ctrl.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+			Expect(k8sClient.Get(ctx, key, ucloudCluster)).To(Succeed())
+			Expect(ucloudCluster.Finalizers).To(ContainElement(infrav1.ClusterFinalizer))
+			Expect(ucloudCluster.Status.Ready).To(BeTrue())
+			Expect(ucloudCluster.Status.ClusterId).NotTo(BeEmpty())
+			Expect(ucloudCluster.Status.FailureDomains).To(HaveKey("cn-bj2-02"))
+			Expect(ucloudCluster.Spec.ControlPlaneEndpoint.Host).To(Equal(ucloudCluster.Status.Network.ULB.EIP.EIPAddr))
+			Expect(ucloudCluster.Spec.ControlPlaneEndpoint.Port).To(BeEquivalentTo(6443))
+			vpcId := ucloudCluster.Status.Network.VPC.VpcId
+			Expect(fakeUCloud.ResourceIds()).To(ContainElement(vpcId))
+
+			By("deleting the network")
+			Expect(k8sClient.Delete(ctx, ucloudCluster)).To(Succeed())
+			result, err = reconciler.Reconcile(ctrl.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+			Expect(apierrors.IsNotFound(k8sClient.Get(ctx, key, ucloudCluster))).To(BeTrue())
+			Expect(fakeUCloud.ResourceIds()).NotTo(ContainElement(vpcId))
+		})
+	})
 })
+
+// createFakeUCloudCluster creates the Cluster name, and its UCloudCluster served by
+// fakeUCloud in the region cn-bj2.
+func createFakeUCloudCluster(ctx context.Context, name string) (*clusterv1.Cluster, *infrav1.UCloudCluster) {
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: clusterv1.ClusterSpec{
+			ClusterNetwork: &clusterv1.ClusterNetwork{
+				Pods:     &clusterv1.NetworkRanges{CIDRBlocks: []string{"192.168.0.0/16"}},
+				Services: &clusterv1.NetworkRanges{CIDRBlocks: []string{"10.96.0.0/12"}},
+			},
+			InfrastructureRef: &corev1.ObjectReference{
+				APIVersion: infrav1.GroupVersion.String(),
+				Kind:       "UCloudCluster",
+				Namespace:  "default",
+				Name:       name,
+			},
+		},
+	}
+	Expect(k8sClient.Create(ctx, cluster)).To(Succeed())
+
+	ucloudCluster := &infrav1.UCloudCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: clusterv1.GroupVersion.String(),
+				Kind:       "Cluster",
+				Name:       cluster.Name,
+				UID:        cluster.UID,
+			}},
+		},
+		Spec: infrav1.UCloudClusterSpec{
+			ProjectId: "org-test",
+			Region:    "cn-bj2",
+			Version:   "1.18.3",
+			API:       &infrav1.APISpec{BaseURL: fakeUCloud.URL},
+		},
+	}
+	Expect(k8sClient.Create(ctx, ucloudCluster)).To(Succeed())
+	return cluster, ucloudCluster
+}
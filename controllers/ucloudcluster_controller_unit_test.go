@@ -0,0 +1,145 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/auth"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/klog/klogr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/services"
+)
+
+func TestReconcileNodeCredential(t *testing.T) {
+	tests := []struct {
+		name        string
+		secretRef   *corev1.LocalObjectReference
+		initialized bool
+		want        string
+		wantErr     bool
+	}{
+		{name: "no key pair referenced", initialized: true},
+		{name: "control plane not initialized", secretRef: &corev1.LocalObjectReference{Name: "node-credentials"}},
+		{name: "copied to the workload cluster", secretRef: &corev1.LocalObjectReference{Name: "node-credentials"}, initialized: true, want: "node-public"},
+		{name: "missing secret", secretRef: &corev1.LocalObjectReference{Name: "missing"}, initialized: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			scheme := runtime.NewScheme()
+			g.Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+			g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+			cluster := newCluster("my-cluster")
+			cluster.Status.ControlPlaneInitialized = tt.initialized
+			ucloudCluster := &infrav1.UCloudCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"},
+				Spec:       infrav1.UCloudClusterSpec{NodeCredential: infrav1.NodeCredentialSpec{SecretRef: tt.secretRef}},
+			}
+			nodeCredentials := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-credentials", Namespace: "default"},
+				Data:       map[string][]byte{scope.PublicKeyName: []byte("node-public"), scope.PrivateKeyName: []byte("node-private")},
+			}
+			managementClient := fake.NewFakeClientWithScheme(scheme, cluster, ucloudCluster, nodeCredentials)
+			workloadClient := fake.NewFakeClientWithScheme(scheme)
+			reconciler := &UCloudClusterReconciler{
+				Client: managementClient,
+				Log:    klogr.New(),
+				remoteClientGetter: func(context.Context, client.Client, client.ObjectKey, *runtime.Scheme) (client.Client, error) {
+					return workloadClient, nil
+				},
+			}
+			clusterScope, err := scope.NewClusterScope(scope.ClusterScopeParams{
+				UCloudClients: scope.UCloudClients{Credential: &auth.Credential{PublicKey: "controller-public", PrivateKey: "controller-private"}},
+				Client:        managementClient,
+				Cluster:       cluster,
+				UCloudCluster: ucloudCluster,
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+
+			// the secret is written again by every reconcile
+			for i := 0; i < 2; i++ {
+				err = reconciler.reconcileNodeCredential(clusterScope)
+				if tt.wantErr {
+					g.Expect(err).To(HaveOccurred())
+					return
+				}
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			secret := &corev1.Secret{}
+			err = workloadClient.Get(context.TODO(), client.ObjectKey{Namespace: metav1.NamespaceSystem, Name: NodeCredentialSecretName}, secret)
+			if tt.want == "" {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(string(secret.Data[scope.PublicKeyName])).To(Equal(tt.want))
+			g.Expect(string(secret.Data[scope.PrivateKeyName])).To(Equal("node-private"))
+		})
+	}
+}
+
+func TestSetNetworkInSyncCondition(t *testing.T) {
+	tests := []struct {
+		name       string
+		drifts     []services.Drift
+		wantStatus corev1.ConditionStatus
+		wantReason string
+		wantReady  bool
+	}{
+		{name: "in sync", wantStatus: corev1.ConditionTrue, wantReady: true},
+		{name: "repaired", drifts: []services.Drift{{Resource: "nat gateway natgw-1", Message: "not found, replaced by natgw-2", Repaired: true}}, wantStatus: corev1.ConditionTrue, wantReason: infrav1.NetworkRepairedReason, wantReady: true},
+		{name: "drifted", drifts: []services.Drift{
+			{Resource: "subnet subnet-1", Message: "not found, replaced by subnet-2", Repaired: true},
+			{Resource: "vpc uvnet-1", Message: "not found"},
+		}, wantStatus: corev1.ConditionFalse, wantReason: infrav1.NetworkDriftedReason},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			cluster := &infrav1.UCloudCluster{Status: infrav1.UCloudClusterStatus{Ready: true}}
+			setNetworkInSyncCondition(cluster, tt.drifts)
+			condition := cluster.GetCondition(infrav1.NetworkInSyncCondition)
+			g.Expect(condition).NotTo(BeNil())
+			g.Expect(condition.Status).To(Equal(tt.wantStatus))
+			g.Expect(condition.Reason).To(Equal(tt.wantReason))
+			g.Expect(cluster.Status.Ready).To(Equal(tt.wantReady))
+			if tt.wantStatus == corev1.ConditionFalse {
+				g.Expect(condition.Message).To(Equal("vpc uvnet-1 not found"))
+			}
+
+			// the transition time only changes with the status
+			transitionTime := condition.LastTransitionTime
+			setNetworkInSyncCondition(cluster, tt.drifts)
+			g.Expect(cluster.Status.Conditions).To(HaveLen(1))
+			g.Expect(cluster.GetCondition(infrav1.NetworkInSyncCondition).LastTransitionTime).To(Equal(transitionTime))
+		})
+	}
+}
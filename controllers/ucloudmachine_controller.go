@@ -25,6 +25,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/ucloud/ucloud-sdk-go/services/uhost"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util"
@@ -39,6 +40,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
 	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/scope"
 	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/services"
 )
@@ -47,6 +49,14 @@ import (
 type UCloudMachineReconciler struct {
 	client.Client
 	Log logr.Logger
+
+	// CredentialProvider supplies the UCloud key pair for clusters without an identityRef.
+	// The environment of the manager is used if not set.
+	CredentialProvider scope.CredentialProvider
+
+	// InstanceTypes resolves the instance types of machines. Only the default instance
+	// type is known if not set.
+	InstanceTypes *scope.InstanceTypeCatalog
 }
 
 func (r *UCloudMachineReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
@@ -96,7 +106,8 @@ func (r *UCloudMachineReconciler) SetupWithManager(mgr ctrl.Manager, options con
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=ucloudmachines/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;machines/status,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;update;patch
-// +kubebuilder:rbac:groups="",resources=secrets;,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets;,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get
 
 func (r *UCloudMachineReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr error) {
 	ctx := context.TODO()
@@ -153,6 +164,7 @@ func (r *UCloudMachineReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, re
 
 	// Create the cluster scope
 	clusterScope, err := scope.NewClusterScope(scope.ClusterScopeParams{
+		UCloudClients: scope.UCloudClients{CredentialProvider: r.CredentialProvider},
 		Client:        r.Client,
 		Logger:        logger,
 		Cluster:       cluster,
@@ -171,6 +183,7 @@ func (r *UCloudMachineReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, re
 
 	// Create the machine scope
 	machineScope, err := scope.NewMachineScope(scope.MachineScopeParams{
+		InstanceTypes: r.InstanceTypes,
 		Logger:        logger,
 		Client:        r.Client,
 		Cluster:       cluster,
@@ -189,13 +202,23 @@ func (r *UCloudMachineReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, re
 		}
 	}()
 
-	// Handle deleted machines
+	var result ctrl.Result
 	if !ucloudMachine.ObjectMeta.DeletionTimestamp.IsZero() {
-		return r.reconcileDelete(machineScope, clusterScope)
+		// Handle deleted machines
+		result, err = r.reconcileDelete(machineScope, clusterScope)
+	} else {
+		// Handle non-deleted machines
+		result, err = r.reconcile(ctx, machineScope, clusterScope)
 	}
 
-	// Handle non-deleted machines
-	return r.reconcile(ctx, machineScope, clusterScope)
+	// Back off on UCloud API errors that clear up over time instead of failing hot.
+	if requeueAfter := requeueAfterForError(err); requeueAfter > 0 {
+		reason := common.ReasonForError(err)
+		logger.Info("UCloud API request failed, requeueing", "reason", reason, "requeueAfter", requeueAfter, "error", err.Error())
+		record.Warnf(ucloudMachine, string(reason), "Failed to reconcile UCloudMachine: %v", err)
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+	return result, err
 }
 
 func (r *UCloudMachineReconciler) reconcile(ctx context.Context, machineScope *scope.MachineScope, clusterScope *scope.ClusterScope) (ctrl.Result, error) {
@@ -230,6 +253,14 @@ func (r *UCloudMachineReconciler) reconcile(ctx context.Context, machineScope *s
 		return ctrl.Result{}, nil
 	}
 
+	// Bare-metal machines only join clusters as workers.
+	if machineScope.InstanceKind() == infrav1.UPHostInstanceKind && machineScope.IsControlPlane() {
+		machineScope.SetFailureReason(capierrors.InvalidConfigurationMachineError)
+		machineScope.SetFailureMessage(errors.New("uphost instances cannot be control plane machines"))
+		machineScope.SetNotReady()
+		return ctrl.Result{}, nil
+	}
+
 	computeSvc := services.NewService(clusterScope)
 
 	// try to find instance
@@ -239,6 +270,11 @@ func (r *UCloudMachineReconciler) reconcile(ctx context.Context, machineScope *s
 	}
 
 	if instance == nil {
+		// A new instance may not be listed yet, so wait for it before giving up on it.
+		if machineScope.GetInstanceID() != nil && instanceMayBePending(machineScope.UCloudMachine, time.Now()) {
+			machineScope.Info("Machine instance is not found yet", "instance-id", *machineScope.GetInstanceID())
+			return ctrl.Result{RequeueAfter: instancePendingRequeueAfter}, nil
+		}
 		// Set a failure message if we couldn't find the instance.
 		if machineScope.GetInstanceID() != nil {
 			machineScope.SetFailureReason(capierrors.UpdateMachineError)
@@ -254,20 +290,41 @@ func (r *UCloudMachineReconciler) reconcile(ctx context.Context, machineScope *s
 		// Create a new UCloudMachine instance if we couldn't find a running instance.
 		instance, err = computeSvc.CreateInstance(machineScope)
 		if err != nil {
+			// Retrying a request with rejected parameters is pointless until the spec changes.
+			if common.IsInvalidParameter(err) {
+				machineScope.SetFailureReason(capierrors.InvalidConfigurationMachineError)
+				machineScope.SetFailureMessage(errors.Wrap(err, "failed to create UCloudMachine instance"))
+				machineScope.SetNotReady()
+				return ctrl.Result{}, nil
+			}
 			return ctrl.Result{}, errors.Wrapf(err, "failed to create UCloudMachine instance")
 		}
+
+		// Persist the instance id right away, so that the instance is found again instead
+		// of created twice if anything below fails.
+		r.setInstanceID(machineScope, clusterScope, instance)
+		machineScope.UCloudMachine.Status.CreateTime = &metav1.Time{Time: time.Now()}
+		if err := machineScope.PatchObject(); err != nil {
+			return ctrl.Result{}, err
+		}
 	}
 
 	// Make sure Spec.ProviderID is always set.
-	machineScope.SetProviderID(fmt.Sprintf("ucloud://%s/%s/%s", clusterScope.ProjectId(), instance.Zone, instance.UHostId))
-
-	machineScope.SetZone(instance.Zone)
-	machineScope.UCloudMachine.Status.InstanceId = instance.UHostId
+	r.setInstanceID(machineScope, clusterScope, instance)
 
 	// Proceed to reconcile the UCloudMachine state.
 	machineScope.SetInstanceStatus(string(instance.State))
 
-	machineScope.SetAddresses(r.getAddresses(instance))
+	if uhost.State(instance.State) == uhost.StateRunning {
+		if err := computeSvc.ReconcileMachineEIP(machineScope, instance); err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile eip of UCloudMachine instance")
+		}
+		if err := computeSvc.ReconcileInstanceFirewall(machineScope, instance); err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile firewall of UCloudMachine instance")
+		}
+	}
+
+	machineScope.SetAddresses(r.getAddresses(machineScope, instance))
 
 	switch uhost.State(instance.State) {
 	case uhost.StateRunning:
@@ -280,6 +337,7 @@ func (r *UCloudMachineReconciler) reconcile(ctx context.Context, machineScope *s
 		}
 	case uhost.StateInitializing, uhost.StateStarting:
 		machineScope.Info("Machine instance is pending", "instance-id", *machineScope.GetInstanceID())
+		return ctrl.Result{RequeueAfter: instancePendingRequeueAfter}, nil
 	case uhost.State(""):
 		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 	default:
@@ -311,6 +369,12 @@ func (r *UCloudMachineReconciler) reconcileDelete(machineScope *scope.MachineSco
 		return ctrl.Result{}, errors.Wrapf(err, "failed to query UCloudMachine instance")
 	}
 
+	// Release the EIP of the machine before its instance, which would release it too
+	// even if it was an existing EIP.
+	if err := computeSvc.DeleteMachineEIP(machineScope); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to delete eip of UCloudMachine instance")
+	}
+
 	if instance == nil {
 		// The machine was never created or was deleted by some other entity
 		machineScope.V(3).Info("Unable to locate instance by ID or tags")
@@ -328,10 +392,15 @@ func (r *UCloudMachineReconciler) reconcileDelete(machineScope *scope.MachineSco
 		}
 
 		machineScope.Info("Terminating instance")
-		if err := computeSvc.TerminateInstanceAndWait(machineScope); err != nil {
+		deleted, err := computeSvc.TerminateInstance(machineScope)
+		if err != nil {
 			record.Warnf(machineScope.UCloudMachine, "FailedTerminate", "Failed to terminate instance %q: %v", instance.UHostId, err)
 			return ctrl.Result{}, errors.Errorf("failed to terminate instance: %+v", err)
 		}
+		if !deleted {
+			machineScope.SetInstanceStatus(instance.State)
+			return ctrl.Result{RequeueAfter: instanceDeletingRequeueAfter}, nil
+		}
 
 		record.Eventf(machineScope.UCloudMachine, "SuccessfulTerminate", "Terminated instance %q", instance.UHostId)
 	}
@@ -342,14 +411,49 @@ func (r *UCloudMachineReconciler) reconcileDelete(machineScope *scope.MachineSco
 	return ctrl.Result{}, nil
 }
 
-func (r *UCloudMachineReconciler) getAddresses(instance *uhost.UHostInstanceSet) []clusterv1.MachineAddress {
-	addresses := make([]clusterv1.MachineAddress, 0, len(instance.IPSet))
+// instanceMayBePending returns whether the instance of a machine that can't be found may
+// still be listed, as the machine has no address nor was ready yet and the instance was
+// created less than instanceNotFoundTimeout ago.
+func instanceMayBePending(ucloudMachine *infrav1.UCloudMachine, now time.Time) bool {
+	status := ucloudMachine.Status
+	if status.Ready || len(status.Addresses) > 0 || status.CreateTime == nil {
+		return false
+	}
+	return now.Sub(status.CreateTime.Time) < instanceNotFoundTimeout
+}
+
+// setInstanceID records the instance backing the machine.
+func (r *UCloudMachineReconciler) setInstanceID(machineScope *scope.MachineScope, clusterScope *scope.ClusterScope, instance *uhost.UHostInstanceSet) {
+	if machineScope.InstanceKind() == infrav1.UPHostInstanceKind {
+		machineScope.SetProviderID(fmt.Sprintf("ucloud://%s/%s/uphost/%s", clusterScope.ProjectId(), instance.Zone, instance.UHostId))
+	} else {
+		machineScope.SetProviderID(fmt.Sprintf("ucloud://%s/%s/%s", clusterScope.ProjectId(), instance.Zone, instance.UHostId))
+	}
+	machineScope.SetZone(instance.Zone)
+	machineScope.UCloudMachine.Status.InstanceId = instance.UHostId
+	if instance.ImageId != "" {
+		machineScope.UCloudMachine.Status.ImageId = instance.ImageId
+	}
+	if instance.ChargeType != "" {
+		machineScope.UCloudMachine.Status.ChargeType = instance.ChargeType
+		machineScope.UCloudMachine.Status.ExpireTime = services.ExpireTime(instance.ChargeType, instance.ExpireTime)
+	}
+}
+
+func (r *UCloudMachineReconciler) getAddresses(machineScope *scope.MachineScope, instance *uhost.UHostInstanceSet) []clusterv1.MachineAddress {
+	addresses := make([]clusterv1.MachineAddress, 0, len(instance.IPSet)+1)
+	var eipAddr string
+	if eip := machineScope.UCloudMachine.Status.EIP; eip != nil {
+		eipAddr = eip.EIPAddr
+	}
+	eipListed := eipAddr == ""
 	for _, nic := range instance.IPSet {
 		var addressType clusterv1.MachineAddressType
-		switch nic.Type {
-		case "Internation", "Bgp":
+		switch {
+		case nic.Type == "Internation", nic.Type == "Bgp", nic.IP == eipAddr:
 			addressType = clusterv1.MachineExternalIP
-		case "Private":
+			eipListed = eipListed || nic.IP == eipAddr
+		case nic.Type == "Private":
 			addressType = clusterv1.MachineInternalIP
 		}
 		internalAddress := clusterv1.MachineAddress{
@@ -358,6 +462,10 @@ func (r *UCloudMachineReconciler) getAddresses(instance *uhost.UHostInstanceSet)
 		}
 		addresses = append(addresses, internalAddress)
 	}
+	// A newly bound EIP is not listed by the instance yet.
+	if !eipListed {
+		addresses = append(addresses, clusterv1.MachineAddress{Type: clusterv1.MachineExternalIP, Address: eipAddr})
+	}
 
 	return addresses
 }
@@ -20,7 +20,12 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"golang.org/x/net/context"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -51,4 +56,104 @@ var _ = Describe("UCloudMachineReconciler", func() {
 			Expect(result.Requeue).To(BeFalse())
 		})
 	})
+
+	Context("Reconcile an UCloudMachine against the fake UCloud API", func() {
+		It("should create the instance of a control plane machine, then delete it", func() {
+			ctx := context.Background()
+
+			clusterReconciler := &UCloudClusterReconciler{
+				Client:             k8sClient,
+				Log:                log.Log,
+				CredentialProvider: fakeUCloudCredentials{},
+			}
+			reconciler := &UCloudMachineReconciler{
+				Client:             k8sClient,
+				Log:                log.Log,
+				CredentialProvider: fakeUCloudCredentials{},
+			}
+			fakeUCloud.SetTransitionDelay(0)
+
+			cluster, ucloudCluster := createFakeUCloudCluster(ctx, "fake-machine-cluster")
+			clusterKey := client.ObjectKey{Namespace: ucloudCluster.Namespace, Name: ucloudCluster.Name}
+			_, err := clusterReconciler.Reconcile(ctrl.Request{NamespacedName: clusterKey})
+			Expect(err).NotTo(HaveOccurred())
+			cluster.Status.InfrastructureReady = true
+			Expect(k8sClient.Status().Update(ctx, cluster)).To(Succeed())
+
+			bootstrapData := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "fake-machine-bootstrap", Namespace: "default"},
+				Data:       map[string][]byte{"value": []byte("#cloud-config")},
+			}
+			Expect(k8sClient.Create(ctx, bootstrapData)).To(Succeed())
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "fake-machine",
+					Namespace: "default",
+					Labels: map[string]string{
+						clusterv1.ClusterLabelName:             cluster.Name,
+						clusterv1.MachineControlPlaneLabelName: "",
+					},
+				},
+				Spec: clusterv1.MachineSpec{
+					ClusterName:   cluster.Name,
+					Version:       pointer.StringPtr("v1.18.3"),
+					FailureDomain: pointer.StringPtr("cn-bj2-02"),
+					Bootstrap:     clusterv1.Bootstrap{DataSecretName: pointer.StringPtr(bootstrapData.Name)},
+				},
+			}
+			Expect(k8sClient.Create(ctx, machine)).To(Succeed())
+			ucloudMachine := &infrav1.UCloudMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "fake-machine",
+					Namespace: "default",
+					OwnerReferences: []metav1.OwnerReference{{
+						APIVersion: clusterv1.GroupVersion.String(),
+						Kind:       "Machine",
+						Name:       machine.Name,
+						UID:        machine.UID,
+					}},
+				},
+				Spec: infrav1.UCloudMachineSpec{ImageId: pointer.StringPtr("uimage-test")},
+			}
+			Expect(k8sClient.Create(ctx, ucloudMachine)).To(Succeed())
+			key := client.ObjectKey{Namespace: ucloudMachine.Namespace, Name: ucloudMachine.Name}
+
+			By("creating the instance")
+			result, err := reconciler.Reconcile(ctrl.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+			Expect(k8sClient.Get(ctx, key, ucloudMachine)).To(Succeed())
+			Expect(ucloudMachine.Finalizers).To(ContainElement(infrav1.MachineFinalizer))
+			Expect(ucloudMachine.Status.Ready).To(BeTrue())
+			Expect(ucloudMachine.Status.FailureReason).To(BeNil())
+			Expect(ucloudMachine.Status.CreateTime).NotTo(BeNil())
+			instanceId := ucloudMachine.Status.InstanceId
+			Expect(ucloudMachine.Spec.ProviderID).To(Equal(pointer.StringPtr("ucloud://org-test/cn-bj2-02/" + instanceId)))
+			Expect(ucloudMachine.Status.Addresses).NotTo(BeEmpty())
+			_, exists := fakeUCloud.UHost(instanceId)
+			Expect(exists).To(BeTrue())
+
+			By("deleting the instance, one step per reconcile")
+			Expect(k8sClient.Delete(ctx, ucloudMachine)).To(Succeed())
+			for i := 0; i < 2; i++ {
+				result, err = reconciler.Reconcile(ctrl.Request{NamespacedName: key})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.RequeueAfter).To(Equal(instanceDeletingRequeueAfter))
+			}
+			result, err = reconciler.Reconcile(ctrl.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+			Expect(apierrors.IsNotFound(k8sClient.Get(ctx, key, ucloudMachine))).To(BeTrue())
+			_, exists = fakeUCloud.UHost(instanceId)
+			Expect(exists).To(BeFalse())
+
+			By("deleting the cluster once the machine is gone")
+			Expect(k8sClient.Get(ctx, clusterKey, ucloudCluster)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, ucloudCluster)).To(Succeed())
+			result, err = clusterReconciler.Reconcile(ctrl.Request{NamespacedName: clusterKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+			Expect(apierrors.IsNotFound(k8sClient.Get(ctx, clusterKey, ucloudCluster))).To(BeTrue())
+		})
+	})
 })
@@ -17,15 +17,21 @@ limitations under the License.
 package controllers
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/klogr"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 
@@ -101,3 +107,66 @@ func TestUCloudMachineReconciler_UCloudClusterToUCloudMachines(t *testing.T) {
 	})
 	g.Expect(requests).To(HaveLen(2))
 }
+
+func TestUCloudMachineReconciler_MissingInstance(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name        string
+		status      infrav1.UCloudMachineStatus
+		wantPending bool
+	}{
+		{
+			name:        "new instance",
+			status:      infrav1.UCloudMachineStatus{CreateTime: &metav1.Time{Time: now}},
+			wantPending: true,
+		},
+		{
+			name:   "new instance missing for longer than the timeout",
+			status: infrav1.UCloudMachineStatus{CreateTime: &metav1.Time{Time: now.Add(-instanceNotFoundTimeout)}},
+		},
+		{
+			name:   "instance of unknown age",
+			status: infrav1.UCloudMachineStatus{},
+		},
+		{
+			name:   "instance of a machine that was ready",
+			status: infrav1.UCloudMachineStatus{Ready: true, CreateTime: &metav1.Time{Time: now}},
+		},
+		{
+			name: "instance of a machine that had an address",
+			status: infrav1.UCloudMachineStatus{
+				Addresses:  []clusterv1.MachineAddress{{Type: clusterv1.MachineInternalIP, Address: "10.0.0.2"}},
+				CreateTime: &metav1.Time{Time: now},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			f := newFakeUCloudCluster(t, infrav1.UCloudClusterSpec{})
+			defer f.server.Close()
+			f.cluster.Status.InfrastructureReady = true
+
+			machineScope := f.newMachineScope(g, "my-machine-0", false, infrav1.UCloudMachineSpec{ImageId: pointer.StringPtr("uimage-test")})
+			machineScope.UCloudMachine.Status = tt.status
+			machineScope.UCloudMachine.Status.Zone = "cn-bj2-02"
+			machineScope.SetProviderID("ucloud://org-test/cn-bj2-02/uhost-missing")
+
+			r := &UCloudMachineReconciler{Client: f.client, Log: klogr.New()}
+			result, err := r.reconcile(context.TODO(), machineScope, f.clusterScope)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(f.server.Requests("CreateUHostInstance")).To(BeZero())
+			err = f.client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "my-machine-0"}, &clusterv1.Machine{})
+			if tt.wantPending {
+				g.Expect(result.RequeueAfter).To(Equal(instancePendingRequeueAfter))
+				g.Expect(machineScope.UCloudMachine.Status.FailureReason).To(BeNil())
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(result.RequeueAfter).To(BeZero())
+				g.Expect(machineScope.UCloudMachine.Status.FailureReason).NotTo(BeNil())
+				g.Expect(*machineScope.UCloudMachine.Status.FailureReason).To(Equal(capierrors.UpdateMachineError))
+				g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			}
+		})
+	}
+}
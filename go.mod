@@ -11,6 +11,8 @@ require (
 	github.com/onsi/ginkgo v1.12.0
 	github.com/onsi/gomega v1.9.0
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.5.0
+	github.com/prometheus/client_model v0.2.0
 	github.com/ucloud/ucloud-sdk-go v0.15.0
 	golang.org/x/net v0.0.0-20200301022130-244492dfa37a
 	k8s.io/api v0.17.2
@@ -21,8 +21,10 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/klog"
@@ -30,10 +32,12 @@ import (
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/util/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-ucloud/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/scope"
 	"sigs.k8s.io/cluster-api-provider-ucloud/controllers"
 	// +kubebuilder:scaffold:imports
 )
@@ -64,6 +68,10 @@ func main() {
 		syncPeriod               time.Duration
 		webhookPort              int
 		healthAddr               string
+		credentialsDir           string
+		credentialsSecret        string
+		credentialsExec          string
+		instanceTypeCatalog      string
 	)
 
 	flag.StringVar(
@@ -131,6 +139,30 @@ func main() {
 		"The address the health endpoint binds to.",
 	)
 
+	flag.StringVar(&credentialsDir,
+		"credentials-dir",
+		"",
+		"Directory holding the UCLOUD_ACCESS_PUBKEY and UCLOUD_ACCESS_PRIKEY files, e.g. a mounted Secret. Re-read when the files change.",
+	)
+
+	flag.StringVar(&credentialsSecret,
+		"credentials-secret",
+		"",
+		"Secret holding the UCLOUD_ACCESS_PUBKEY and UCLOUD_ACCESS_PRIKEY keys, in the form namespace/name. Re-read on every reconcile.",
+	)
+
+	flag.StringVar(&credentialsExec,
+		"credentials-exec",
+		"",
+		"Command, with space separated arguments, printing a JSON object with publicKey, privateKey and optionally securityToken and expiration.",
+	)
+
+	flag.StringVar(&instanceTypeCatalog,
+		"instance-type-catalog",
+		"",
+		"ConfigMap holding the instance type catalog, in the form namespace/name. If unspecified, only the uhost instance type is known.",
+	)
+
 	flag.Parse()
 
 	if watchNamespace != "" {
@@ -162,33 +194,57 @@ func main() {
 		os.Exit(1)
 	}
 
+	credentialProvider, err := newCredentialProvider(mgr.GetClient(), credentialsExec, credentialsDir, credentialsSecret)
+	if err != nil {
+		setupLog.Error(err, "invalid credential configuration")
+		os.Exit(1)
+	}
+
+	instanceTypes, err := newInstanceTypeCatalog(mgr.GetAPIReader(), instanceTypeCatalog)
+	if err != nil {
+		setupLog.Error(err, "invalid instance type catalog configuration")
+		os.Exit(1)
+	}
+
 	// Initialize event recorder.
 	record.InitFromRecorder(mgr.GetEventRecorderFor("ucloud-controller"))
 
 	if webhookPort == 0 {
 		if err = (&controllers.UCloudMachineReconciler{
-			Client: mgr.GetClient(),
-			Log:    ctrl.Log.WithName("controllers").WithName("UCloudMachine"),
+			Client:             mgr.GetClient(),
+			Log:                ctrl.Log.WithName("controllers").WithName("UCloudMachine"),
+			CredentialProvider: credentialProvider,
+			InstanceTypes:      instanceTypes,
 		}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: ucloudMachineConcurrency}); err != nil {
 			setupLog.Error(err, "unable to create controller", "controller", "UCloudMachine")
 			os.Exit(1)
 		}
 		if err = (&controllers.UCloudClusterReconciler{
-			Client: mgr.GetClient(),
-			Log:    ctrl.Log.WithName("controllers").WithName("UCloudCluster"),
+			Client:             mgr.GetClient(),
+			Log:                ctrl.Log.WithName("controllers").WithName("UCloudCluster"),
+			CredentialProvider: credentialProvider,
 		}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: ucloudClusterConcurrency}); err != nil {
 			setupLog.Error(err, "unable to create controller", "controller", "UCloudCluster")
 			os.Exit(1)
 		}
 	} else {
-		if err = (&infrav1.UCloudMachineTemplate{}).SetupWebhookWithManager(mgr); err != nil {
+		// a nil catalog must be passed as a nil validator, instance types aren't validated then
+		var instanceTypeValidator infrav1.InstanceTypeValidator
+		if instanceTypes != nil {
+			instanceTypeValidator = instanceTypes
+		}
+		if err = (&infrav1.UCloudMachineTemplate{}).SetupWebhookWithManager(mgr, instanceTypeValidator); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "UCloudMachineTemplate")
 			os.Exit(1)
 		}
-		if err = (&infrav1.UCloudMachine{}).SetupWebhookWithManager(mgr); err != nil {
+		if err = (&infrav1.UCloudMachine{}).SetupWebhookWithManager(mgr, instanceTypeValidator); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "UCloudMachine")
 			os.Exit(1)
 		}
+		if err = (&infrav1.UCloudCluster{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "UCloudCluster")
+			os.Exit(1)
+		}
 	}
 	// +kubebuilder:scaffold:builder
 
@@ -208,3 +264,48 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// newCredentialProvider chains the configured credential sources, in order of precedence
+// exec plugin, directory and Secret. The environment of the manager is only used if no
+// source is configured: when the configured sources fail, falling back to it could use
+// keys with more permissions than the configured ones.
+func newCredentialProvider(c client.Client, execCommand, dir, secret string) (scope.CredentialProvider, error) {
+	var chain scope.CredentialProviderChain
+	if execCommand != "" {
+		args := strings.Fields(execCommand)
+		chain = append(chain, &scope.ExecCredentialProvider{Command: args[0], Args: args[1:]})
+	}
+	if dir != "" {
+		chain = append(chain, &scope.FileCredentialProvider{Dir: dir})
+	}
+	if secret != "" {
+		parts := strings.Split(secret, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.Errorf("credentials secret %q must be in the form namespace/name", secret)
+		}
+		chain = append(chain, &scope.SecretCredentialProvider{
+			Client: c,
+			Key:    client.ObjectKey{Namespace: parts[0], Name: parts[1]},
+		})
+	}
+	if len(chain) == 0 {
+		return &scope.EnvCredentialProvider{}, nil
+	}
+	return chain, nil
+}
+
+// newInstanceTypeCatalog returns the instance type catalog read from the ConfigMap
+// namespace/name, or nil if no ConfigMap is configured.
+func newInstanceTypeCatalog(r client.Reader, configMap string) (*scope.InstanceTypeCatalog, error) {
+	if configMap == "" {
+		return nil, nil
+	}
+	parts := strings.Split(configMap, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, errors.Errorf("instance type catalog %q must be in the form namespace/name", configMap)
+	}
+	return &scope.InstanceTypeCatalog{
+		Reader: r,
+		Key:    client.ObjectKey{Namespace: parts[0], Name: parts[1]},
+	}, nil
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakeucloud
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
+
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
+)
+
+// Firewall returns the firewall with the given id.
+func (s *Server) Firewall(id string) (unet.FirewallDataSet, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	firewall, ok := s.firewalls[id]
+	if !ok {
+		return unet.FirewallDataSet{}, false
+	}
+	return s.firewallView(firewall), true
+}
+
+// FirewallOf returns the id of the firewall attached to an instance or a bare-metal
+// machine.
+func (s *Server) FirewallOf(resourceId string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.firewallOf(resourceId)
+}
+
+// firewallOf returns the id of the firewall attached to a resource.
+func (s *Server) firewallOf(resourceId string) string {
+	if host, ok := s.uhosts[resourceId]; ok {
+		return host.firewallId
+	}
+	if host, ok := s.phosts[resourceId]; ok {
+		return host.firewallId
+	}
+	if nat, ok := s.natgws[resourceId]; ok {
+		return nat.FirewallId
+	}
+	return ""
+}
+
+// firewallResources returns the ids of the resources a firewall is attached to.
+func (s *Server) firewallResources(firewallId string) []string {
+	var ids []string
+	for _, id := range sortedIds(s.uhosts) {
+		if s.uhosts[id].firewallId == firewallId {
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range sortedIds(s.phosts) {
+		if s.phosts[id].firewallId == firewallId {
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range sortedIds(s.natgws) {
+		if s.natgws[id].FirewallId == firewallId {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// firewallView returns the firewall as described by the API.
+func (s *Server) firewallView(firewall *unet.FirewallDataSet) unet.FirewallDataSet {
+	info := *firewall
+	info.ResourceCount = len(s.firewallResources(firewall.FWId))
+	return info
+}
+
+// parseFirewallRules parses the Rule parameters, protocol|ports|source|action|priority|remark.
+func parseFirewallRules(p params) ([]unet.FirewallRuleSet, error) {
+	values := p.list("Rule")
+	if len(values) == 0 {
+		return nil, missingParam("Rule.0")
+	}
+	var rules []unet.FirewallRuleSet
+	for _, value := range values {
+		fields := strings.Split(value, "|")
+		if len(fields) < 5 || len(fields) > 6 {
+			return nil, errorf(common.RetCodeInvalidParameter, "invalid Rule %q", value)
+		}
+		rule := unet.FirewallRuleSet{
+			ProtocolType: fields[0],
+			DstPort:      fields[1],
+			SrcIP:        fields[2],
+			RuleAction:   fields[3],
+			Priority:     fields[4],
+		}
+		if len(fields) == 6 {
+			rule.Remark = fields[5]
+		}
+		switch rule.ProtocolType {
+		case "TCP", "UDP", "ICMP", "GRE":
+		default:
+			return nil, errorf(common.RetCodeInvalidParameter, "invalid protocol in Rule %q", value)
+		}
+		if rule.RuleAction != "ACCEPT" && rule.RuleAction != "DROP" {
+			return nil, errorf(common.RetCodeInvalidParameter, "invalid action in Rule %q", value)
+		}
+		if rule.Priority != "HIGH" && rule.Priority != "MEDIUM" && rule.Priority != "LOW" {
+			return nil, errorf(common.RetCodeInvalidParameter, "invalid priority in Rule %q", value)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (s *Server) createFirewall(p params) (interface{}, error) {
+	if err := p.require("Name"); err != nil {
+		return nil, err
+	}
+	rules, err := parseFirewallRules(p)
+	if err != nil {
+		return nil, err
+	}
+	firewall := &unet.FirewallDataSet{
+		FWId:       s.newId("firewall"),
+		Name:       p.str("Name"),
+		Tag:        p.str("Tag"),
+		Remark:     p.str("Remark"),
+		Type:       "user defined",
+		Rule:       rules,
+		CreateTime: int(time.Now().Unix()),
+	}
+	if firewall.Tag == "" {
+		firewall.Tag = "Default"
+	}
+	s.firewalls[firewall.FWId] = firewall
+	return &unet.CreateFirewallResponse{FWId: firewall.FWId}, nil
+}
+
+// getFirewall returns the firewall named by the FWId parameter.
+func (s *Server) getFirewall(p params) (*unet.FirewallDataSet, error) {
+	id := p.str("FWId")
+	firewall, ok := s.firewalls[id]
+	if !ok {
+		return nil, errorf(retCodeNotFound, "firewall %s not exist", id)
+	}
+	return firewall, nil
+}
+
+func (s *Server) describeFirewall(p params) (interface{}, error) {
+	id := p.str("FWId")
+	if id != "" {
+		if _, ok := s.firewalls[id]; !ok {
+			return nil, errorf(retCodeNotFound, "firewall %s not exist", id)
+		}
+	}
+	resourceId := p.str("ResourceId")
+	var firewalls []unet.FirewallDataSet
+	for _, fwId := range sortedIds(s.firewalls) {
+		if (id != "" && fwId != id) || (resourceId != "" && s.firewallOf(resourceId) != fwId) {
+			continue
+		}
+		firewalls = append(firewalls, s.firewallView(s.firewalls[fwId]))
+	}
+	res := &unet.DescribeFirewallResponse{TotalCount: len(firewalls)}
+	offset, limit := p.int("Offset"), p.int("Limit")
+	if limit == 0 {
+		limit = 20
+	}
+	for i := offset; i < len(firewalls) && i < offset+limit; i++ {
+		res.DataSet = append(res.DataSet, firewalls[i])
+	}
+	return res, nil
+}
+
+func (s *Server) updateFirewall(p params) (interface{}, error) {
+	firewall, err := s.getFirewall(p)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := parseFirewallRules(p)
+	if err != nil {
+		return nil, err
+	}
+	firewall.Rule = rules
+	return &unet.UpdateFirewallResponse{FWId: firewall.FWId}, nil
+}
+
+func (s *Server) deleteFirewall(p params) (interface{}, error) {
+	firewall, err := s.getFirewall(p)
+	if err != nil {
+		return nil, err
+	}
+	if firewall.FWId == DefaultFirewallId {
+		return nil, errorf(common.RetCodeInvalidParameter, "firewall %s is a recommended firewall", firewall.FWId)
+	}
+	if resources := s.firewallResources(firewall.FWId); len(resources) > 0 {
+		return nil, errorf(retCodeInUse, "firewall %s is still used by %s", firewall.FWId, strings.Join(resources, ", "))
+	}
+	delete(s.firewalls, firewall.FWId)
+	return &unet.DeleteFirewallResponse{}, nil
+}
+
+func (s *Server) grantFirewall(p params) (interface{}, error) {
+	if err := p.require("FWId", "ResourceType", "ResourceId"); err != nil {
+		return nil, err
+	}
+	firewall, err := s.getFirewall(p)
+	if err != nil {
+		return nil, err
+	}
+	resourceType, resourceId := p.str("ResourceType"), p.str("ResourceId")
+	notFound := errorf(retCodeNotFound, "%s %s not exist", resourceType, resourceId)
+	switch resourceType {
+	case "uhost":
+		host, ok := s.uhosts[resourceId]
+		if !ok {
+			return nil, notFound
+		}
+		host.firewallId = firewall.FWId
+	case "upm":
+		host, ok := s.phosts[resourceId]
+		if !ok {
+			return nil, notFound
+		}
+		host.firewallId = firewall.FWId
+	case "unatgw":
+		nat, ok := s.natgws[resourceId]
+		if !ok {
+			return nil, notFound
+		}
+		nat.FirewallId = firewall.FWId
+	default:
+		return nil, errorf(common.RetCodeInvalidParameter, "invalid ResourceType %q", resourceType)
+	}
+	return &unet.GrantFirewallResponse{}, nil
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakeucloud
+
+import (
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/services"
+)
+
+// defaultSearchLimit is the number of resources SearchBusinessGroupResource returns
+// when no limit is given.
+const defaultSearchLimit = 10
+
+// capuCluster is a cluster registered with the CAPU actions, and the roles of its hosts.
+type capuCluster struct {
+	name  string
+	hosts map[string]string
+}
+
+func (s *Server) listBusinessGroup(p params) (interface{}, error) {
+	res := &services.ListBusinessGroupResponse{}
+	for _, id := range sortedIds(s.groups) {
+		res.Infos = append(res.Infos, *s.groups[id])
+	}
+	return res, nil
+}
+
+func (s *Server) createBusinessGroup(p params) (interface{}, error) {
+	if err := p.require("BusinessName"); err != nil {
+		return nil, err
+	}
+	name := p.str("BusinessName")
+	for _, group := range s.groups {
+		if group.BusinessName == name {
+			return nil, errorf(retCodeInUse, "business group %s already exists", name)
+		}
+	}
+	group := &services.BusinessGroupInfo{BusinessId: s.newId("bg"), BusinessName: name}
+	s.groups[group.BusinessId] = group
+	return &services.CreateBusinessGroupResponse{BusinessId: group.BusinessId, BusinessName: name}, nil
+}
+
+func (s *Server) deleteBusinessGroup(p params) (interface{}, error) {
+	id := p.str("BusinessId")
+	group, ok := s.groups[id]
+	if !ok {
+		return nil, errorf(retCodeNotFound, "business group %s not exist", id)
+	}
+	if resources := s.groupResources(group.BusinessName); len(resources) > 0 {
+		return nil, errorf(retCodeInUse, "business group %s still has %d resources", id, len(resources))
+	}
+	delete(s.groups, id)
+	return &services.DeleteBusinessGroupResponse{}, nil
+}
+
+func (s *Server) searchBusinessGroupResource(p params) (interface{}, error) {
+	id := p.str("BusinessId")
+	group, ok := s.groups[id]
+	if !ok {
+		return nil, errorf(retCodeNotFound, "business group %s not exist", id)
+	}
+	resources := s.groupResources(group.BusinessName)
+	res := &services.SearchBusinessGroupResourceResponse{TotalCount: len(resources)}
+	offset, limit := p.int("Offset"), p.int("Limit")
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+	for i := offset; i < len(resources) && i < offset+limit; i++ {
+		res.Infos = append(res.Infos, resources[i])
+	}
+	return res, nil
+}
+
+// groupResources returns the resources tagged with the name of a business group.
+func (s *Server) groupResources(name string) []services.ResourceInfo {
+	var resources []services.ResourceInfo
+	add := func(typeName, id, zone, tag string) {
+		if tag == name {
+			resources = append(resources, services.ResourceInfo{Id: id, ResourceId: id, ResourceTypeName: typeName, ZoneId: zone})
+		}
+	}
+	for _, id := range sortedIds(s.vpcs) {
+		add("vpc", id, "", s.vpcs[id].Tag)
+	}
+	for _, id := range sortedIds(s.subnets) {
+		add("subnet", id, "", s.subnets[id].Tag)
+	}
+	for _, id := range sortedIds(s.natgws) {
+		add("natgw", id, "", s.natgws[id].Tag)
+	}
+	for _, id := range sortedIds(s.eips) {
+		add("eip", id, "", s.eips[id].tag)
+	}
+	for _, id := range sortedIds(s.ulbs) {
+		add("ulb", id, "", s.ulbs[id].Tag)
+	}
+	for _, id := range sortedIds(s.uhosts) {
+		add("uhost", id, s.uhosts[id].Zone, s.uhosts[id].Tag)
+	}
+	for _, id := range sortedIds(s.phosts) {
+		add("uphost", id, s.phosts[id].Zone, s.phosts[id].Tag)
+	}
+	return resources
+}
+
+func (s *Server) createCAPUCluster(p params) (interface{}, error) {
+	if err := p.require("VPCId", "SubnetId", "ClusterName", "ULBId", "K8SVersion", "APIServer"); err != nil {
+		return nil, err
+	}
+	if _, ok := s.vpcs[p.str("VPCId")]; !ok {
+		return nil, errorf(common.RetCodeVPCNotFound, "vpc %s not exist", p.str("VPCId"))
+	}
+	if _, ok := s.subnets[p.str("SubnetId")]; !ok {
+		return nil, errorf(retCodeNotFound, "subnet %s not exist", p.str("SubnetId"))
+	}
+	if _, ok := s.ulbs[p.str("ULBId")]; !ok {
+		return nil, errorf(common.RetCodeULBNotFound, "ulb %s not exist", p.str("ULBId"))
+	}
+	id := s.newId("uk8s")
+	s.capuClusters[id] = &capuCluster{name: p.str("ClusterName"), hosts: map[string]string{}}
+	return &services.CreateCAPUClusterResponse{ClusterId: id}, nil
+}
+
+func (s *Server) deleteCAPUCluster(p params) (interface{}, error) {
+	id := p.str("ClusterId")
+	if _, ok := s.capuClusters[id]; !ok {
+		return nil, errorf(retCodeNotFound, "cluster %s not exist", id)
+	}
+	delete(s.capuClusters, id)
+	return &services.DeleteCAPUClusterResponse{}, nil
+}
+
+// getCAPUCluster returns the cluster named by the ClusterId parameter.
+func (s *Server) getCAPUCluster(p params) (*capuCluster, error) {
+	id := p.str("ClusterId")
+	cluster, ok := s.capuClusters[id]
+	if !ok {
+		return nil, errorf(retCodeNotFound, "cluster %s not exist", id)
+	}
+	return cluster, nil
+}
+
+func (s *Server) createCAPUHost(p params) (interface{}, error) {
+	if err := p.require("InstanceId", "Type", "Role"); err != nil {
+		return nil, err
+	}
+	cluster, err := s.getCAPUCluster(p)
+	if err != nil {
+		return nil, err
+	}
+	switch p.str("Type") {
+	case "uhost":
+		if _, ok := s.uhosts[p.str("InstanceId")]; !ok {
+			return nil, errorf(common.RetCodeUHostNotFound, "uhost %s not exist", p.str("InstanceId"))
+		}
+	case "uphost":
+		if _, ok := s.phosts[p.str("InstanceId")]; !ok {
+			return nil, errorf(retCodeNotFound, "uphost %s not exist", p.str("InstanceId"))
+		}
+	default:
+		return nil, errorf(common.RetCodeInvalidParameter, "invalid Type %q", p.str("Type"))
+	}
+	cluster.hosts[p.str("InstanceId")] = p.str("Role")
+	return &services.CreateCAPUHostResponse{}, nil
+}
+
+func (s *Server) deleteCAPUHost(p params) (interface{}, error) {
+	cluster, err := s.getCAPUCluster(p)
+	if err != nil {
+		return nil, err
+	}
+	delete(cluster.hosts, p.str("InstanceId"))
+	return &services.DeleteCAPUHostResponse{}, nil
+}
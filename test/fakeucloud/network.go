@@ -0,0 +1,477 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakeucloud
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
+	"github.com/ucloud/ucloud-sdk-go/services/vpc"
+
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
+)
+
+// SubnetOf returns the id of the subnet of an instance or a bare-metal machine.
+func (s *Server) SubnetOf(resourceId string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if host, ok := s.uhosts[resourceId]; ok {
+		return host.subnetId
+	}
+	if host, ok := s.phosts[resourceId]; ok {
+		return host.subnetId
+	}
+	return ""
+}
+
+// NATGWSubnets returns the ids of the subnets a NAT gateway serves.
+func (s *Server) NATGWSubnets(id string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nat, ok := s.natgws[id]
+	if !ok {
+		return nil
+	}
+	var ids []string
+	for _, subnet := range nat.SubnetSet {
+		ids = append(ids, subnet.SubnetworkId)
+	}
+	return ids
+}
+
+// eip is an allocated EIP and the resource it is bound to, if any.
+type eip struct {
+	unet.UnetAllocateEIPSet
+	bandwidth    int
+	tag          string
+	resourceType string
+	resourceId   string
+	chargeType   string
+	expireTime   int
+	payMode      string
+	name         string
+}
+
+func (s *Server) createVPC(p params) (interface{}, error) {
+	if err := p.require("Name", "Network.0"); err != nil {
+		return nil, err
+	}
+	id := s.newId("uvnet")
+	s.vpcs[id] = &vpc.VPCInfo{
+		VPCId:   id,
+		Name:    p.str("Name"),
+		Network: p.list("Network"),
+		Tag:     p.str("Tag"),
+	}
+	return &vpc.CreateVPCResponse{VPCId: id}, nil
+}
+
+func (s *Server) describeVPC(p params) (interface{}, error) {
+	ids := p.list("VPCIds")
+	res := &vpc.DescribeVPCResponse{}
+	for _, id := range sortedIds(s.vpcs) {
+		v := s.vpcs[id]
+		if !matches(ids, id) || !matchesTag(p, v.Tag) {
+			continue
+		}
+		info := *v
+		info.SubnetCount = len(s.subnetsOf(id))
+		res.DataSet = append(res.DataSet, info)
+	}
+	return res, nil
+}
+
+func (s *Server) deleteVPC(p params) (interface{}, error) {
+	id := p.str("VPCId")
+	if _, ok := s.vpcs[id]; !ok {
+		return nil, errorf(common.RetCodeVPCNotFound, "vpc %s not exist", id)
+	}
+	if subnets := s.subnetsOf(id); len(subnets) > 0 {
+		return nil, errorf(retCodeInUse, "vpc %s still has subnets %v", id, subnets)
+	}
+	delete(s.vpcs, id)
+	return &vpc.DeleteVPCResponse{}, nil
+}
+
+// subnetsOf returns the ids of the subnets in a VPC.
+func (s *Server) subnetsOf(vpcId string) []string {
+	var ids []string
+	for _, id := range sortedIds(s.subnets) {
+		if s.subnets[id].VPCId == vpcId {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (s *Server) createSubnet(p params) (interface{}, error) {
+	if err := p.require("VPCId", "Subnet", "Netmask"); err != nil {
+		return nil, err
+	}
+	vpcInfo, ok := s.vpcs[p.str("VPCId")]
+	if !ok {
+		return nil, errorf(common.RetCodeVPCNotFound, "vpc %s not exist", p.str("VPCId"))
+	}
+	if net.ParseIP(p.str("Subnet")).To4() == nil {
+		return nil, errorf(common.RetCodeInvalidParameter, "Params [Subnet] not available")
+	}
+	id := s.newId("subnet")
+	s.subnets[id] = &vpc.VPCSubnetInfoSet{
+		SubnetId:   id,
+		SubnetName: p.str("SubnetName"),
+		Subnet:     p.str("Subnet"),
+		Netmask:    p.str("Netmask"),
+		Gateway:    nthIP(p.str("Subnet"), 1),
+		VPCId:      vpcInfo.VPCId,
+		VPCName:    vpcInfo.Name,
+		Tag:        p.str("Tag"),
+	}
+	return &vpc.CreateSubnetResponse{SubnetId: id}, nil
+}
+
+func (s *Server) describeSubnet(p params) (interface{}, error) {
+	ids := p.list("SubnetIds")
+	if id := p.str("SubnetId"); id != "" {
+		ids = append(ids, id)
+	}
+	vpcId := p.str("VPCId")
+	res := &vpc.DescribeSubnetResponse{}
+	for _, id := range sortedIds(s.subnets) {
+		subnet := s.subnets[id]
+		if !matches(ids, id) || !matchesTag(p, subnet.Tag) || (vpcId != "" && subnet.VPCId != vpcId) {
+			continue
+		}
+		res.DataSet = append(res.DataSet, *subnet)
+	}
+	res.TotalCount = len(res.DataSet)
+	return res, nil
+}
+
+func (s *Server) deleteSubnet(p params) (interface{}, error) {
+	id := p.str("SubnetId")
+	if _, ok := s.subnets[id]; !ok {
+		return nil, errorf(retCodeNotFound, "subnet %s not exist", id)
+	}
+	for hostId, host := range s.uhosts {
+		if host.subnetId == id {
+			return nil, errorf(retCodeInUse, "subnet %s is still used by uhost %s", id, hostId)
+		}
+	}
+	for hostId, host := range s.phosts {
+		if host.subnetId == id {
+			return nil, errorf(retCodeInUse, "subnet %s is still used by uphost %s", id, hostId)
+		}
+	}
+	for natId, nat := range s.natgws {
+		for _, subnet := range nat.SubnetSet {
+			if subnet.SubnetworkId == id {
+				return nil, errorf(retCodeInUse, "subnet %s is still used by natgw %s", id, natId)
+			}
+		}
+	}
+	delete(s.subnets, id)
+	delete(s.assignedIPs, id)
+	return &vpc.DeleteSubnetResponse{}, nil
+}
+
+// assignPrivateIP returns an unused address of a subnet.
+func (s *Server) assignPrivateIP(subnetId string) string {
+	s.assignedIPs[subnetId]++
+	return nthIP(s.subnets[subnetId].Subnet, 1+s.assignedIPs[subnetId])
+}
+
+// nthIP returns the n-th address after base.
+func nthIP(base string, n int) string {
+	ip := net.ParseIP(base).To4()
+	if ip == nil {
+		return ""
+	}
+	next := make(net.IP, 4)
+	binary.BigEndian.PutUint32(next, binary.BigEndian.Uint32(ip)+uint32(n))
+	return next.String()
+}
+
+func (s *Server) createNATGW(p params) (interface{}, error) {
+	if err := p.require("VPCId", "FirewallId", "SubnetworkIds.0", "EIPIds.0"); err != nil {
+		return nil, err
+	}
+	vpcId := p.str("VPCId")
+	if _, ok := s.vpcs[vpcId]; !ok {
+		return nil, errorf(common.RetCodeVPCNotFound, "vpc %s not exist", vpcId)
+	}
+	if _, ok := s.firewalls[p.str("FirewallId")]; !ok {
+		return nil, errorf(retCodeNotFound, "firewall %s not exist", p.str("FirewallId"))
+	}
+	nat := &vpc.NatGatewayDataSet{
+		NATGWName:  p.str("NATGWName"),
+		FirewallId: p.str("FirewallId"),
+		VPCId:      vpcId,
+		Tag:        p.str("Tag"),
+	}
+	for _, subnetId := range p.list("SubnetworkIds") {
+		subnet, ok := s.subnets[subnetId]
+		if !ok || subnet.VPCId != vpcId {
+			return nil, errorf(retCodeNotFound, "subnet %s not exist in vpc %s", subnetId, vpcId)
+		}
+		nat.SubnetSet = append(nat.SubnetSet, vpc.NatGatewaySubnetSet{
+			Subnet:       subnet.Subnet,
+			SubnetName:   subnet.SubnetName,
+			SubnetworkId: subnetId,
+		})
+	}
+	eipIds := p.list("EIPIds")
+	for _, eipId := range eipIds {
+		if err := s.checkEIPUnbound(eipId); err != nil {
+			return nil, err
+		}
+	}
+	nat.NATGWId = s.newId("natgw")
+	for _, eipId := range eipIds {
+		s.eips[eipId].resourceType, s.eips[eipId].resourceId = "natgw", nat.NATGWId
+	}
+	s.natgws[nat.NATGWId] = nat
+	return &vpc.CreateNATGWResponse{NATGWId: nat.NATGWId}, nil
+}
+
+func (s *Server) describeNATGW(p params) (interface{}, error) {
+	ids := p.list("NATGWIds")
+	vpcId := p.str("VPCId")
+	res := &vpc.DescribeNATGWResponse{}
+	for _, id := range sortedIds(s.natgws) {
+		nat := *s.natgws[id]
+		if !matches(ids, id) || (vpcId != "" && nat.VPCId != vpcId) {
+			continue
+		}
+		nat.IPSet = nil
+		for _, e := range s.eipsBoundTo(id) {
+			nat.IPSet = append(nat.IPSet, vpc.NatGatewayIPSet{
+				Bandwidth: e.bandwidth,
+				EIPId:     e.EIPId,
+				IPResInfo: []vpc.NatGWIPResInfo{{EIP: e.EIPAddr[0].IP, OperatorName: e.EIPAddr[0].OperatorName}},
+			})
+		}
+		res.DataSet = append(res.DataSet, nat)
+	}
+	res.TotalCount = len(res.DataSet)
+	return res, nil
+}
+
+func (s *Server) deleteNATGW(p params) (interface{}, error) {
+	id := p.str("NATGWId")
+	if _, ok := s.natgws[id]; !ok {
+		return nil, errorf(common.RetCodeNATGWNotFound, "natgw %s not exist", id)
+	}
+	s.detachEIPs(id, p.bool("ReleaseEip"))
+	delete(s.natgws, id)
+	return &vpc.DeleteNATGWResponse{}, nil
+}
+
+func (s *Server) updateNATGWSubnet(p params) (interface{}, error) {
+	if err := p.require("NATGWId", "SubnetworkIds.0"); err != nil {
+		return nil, err
+	}
+	id := p.str("NATGWId")
+	nat, ok := s.natgws[id]
+	if !ok {
+		return nil, errorf(common.RetCodeNATGWNotFound, "natgw %s not exist", id)
+	}
+	var subnets []vpc.NatGatewaySubnetSet
+	for _, subnetId := range p.list("SubnetworkIds") {
+		subnet, ok := s.subnets[subnetId]
+		if !ok || subnet.VPCId != nat.VPCId {
+			return nil, errorf(retCodeNotFound, "subnet %s not exist in vpc %s", subnetId, nat.VPCId)
+		}
+		subnets = append(subnets, vpc.NatGatewaySubnetSet{
+			Subnet:       subnet.Subnet,
+			SubnetName:   subnet.SubnetName,
+			SubnetworkId: subnetId,
+		})
+	}
+	nat.SubnetSet = subnets
+	return &vpc.UpdateNATGWSubnetResponse{}, nil
+}
+
+func (s *Server) allocateEIP(p params) (interface{}, error) {
+	if err := p.require("OperatorName"); err != nil {
+		return nil, err
+	}
+	chargeType, expireTime, err := p.charge("Month", "Year", "Dynamic")
+	if err != nil {
+		return nil, err
+	}
+	payMode := p.str("PayMode")
+	switch payMode {
+	case "":
+		payMode = "Bandwidth"
+	case "Bandwidth", "Traffic":
+	default:
+		return nil, errorf(common.RetCodeInvalidParameter, "invalid PayMode %q", payMode)
+	}
+	e := s.newEIP(p.str("OperatorName"), p.int("Bandwidth"), p.str("Tag"))
+	e.chargeType, e.expireTime, e.payMode, e.name = chargeType, expireTime, payMode, p.str("Name")
+	return &unet.AllocateEIPResponse{EIPSet: []unet.UnetAllocateEIPSet{e.UnetAllocateEIPSet}}, nil
+}
+
+// newEIP allocates an EIP with a unique public address.
+func (s *Server) newEIP(operatorName string, bandwidth int, tag string) *eip {
+	if bandwidth == 0 {
+		bandwidth = 1
+	}
+	id := s.newId("eip")
+	e := &eip{
+		UnetAllocateEIPSet: unet.UnetAllocateEIPSet{
+			EIPId:   id,
+			EIPAddr: []unet.UnetEIPAddrSet{{OperatorName: operatorName, IP: nthIP("106.75.0.0", s.nextId)}},
+		},
+		bandwidth: bandwidth,
+		tag:       tag,
+	}
+	s.eips[id] = e
+	return e
+}
+
+func (s *Server) describeEIP(p params) (interface{}, error) {
+	ids := p.list("EIPIds")
+	res := &unet.DescribeEIPResponse{}
+	for _, id := range sortedIds(s.eips) {
+		e := s.eips[id]
+		if !matches(ids, id) {
+			continue
+		}
+		info := unet.UnetEIPSet{
+			EIPId:      e.EIPId,
+			Bandwidth:  e.bandwidth,
+			ChargeType: e.chargeType,
+			ExpireTime: e.expireTime,
+			PayMode:    e.payMode,
+			Name:       e.name,
+			Tag:        e.tag,
+			Status:     "free",
+		}
+		for _, addr := range e.EIPAddr {
+			info.EIPAddr = append(info.EIPAddr, unet.UnetEIPAddrSet{IP: addr.IP, OperatorName: addr.OperatorName})
+		}
+		if e.resourceId != "" {
+			info.Status = "used"
+			info.Resource = unet.UnetEIPResourceSet{ResourceId: e.resourceId, ResourceType: e.resourceType}
+		}
+		res.EIPSet = append(res.EIPSet, info)
+	}
+	res.TotalCount = len(res.EIPSet)
+	return res, nil
+}
+
+func (s *Server) releaseEIP(p params) (interface{}, error) {
+	id := p.str("EIPId")
+	e, ok := s.eips[id]
+	if !ok {
+		return nil, errorf(retCodeNotFound, "eip %s not exist", id)
+	}
+	if e.resourceId != "" {
+		return nil, errorf(retCodeInUse, "eip %s is still bound to %s %s", id, e.resourceType, e.resourceId)
+	}
+	delete(s.eips, id)
+	return &unet.ReleaseEIPResponse{}, nil
+}
+
+func (s *Server) bindEIP(p params) (interface{}, error) {
+	if err := p.require("EIPId", "ResourceType", "ResourceId"); err != nil {
+		return nil, err
+	}
+	id := p.str("EIPId")
+	if err := s.checkEIPUnbound(id); err != nil {
+		return nil, err
+	}
+	resourceType, resourceId := strings.ToLower(p.str("ResourceType")), p.str("ResourceId")
+	if !s.resourceExists(resourceType, resourceId) {
+		return nil, errorf(retCodeNotFound, "%s %s not exist", resourceType, resourceId)
+	}
+	s.eips[id].resourceType, s.eips[id].resourceId = resourceType, resourceId
+	return &unet.BindEIPResponse{}, nil
+}
+
+func (s *Server) unbindEIP(p params) (interface{}, error) {
+	id := p.str("EIPId")
+	e, ok := s.eips[id]
+	if !ok {
+		return nil, errorf(retCodeNotFound, "eip %s not exist", id)
+	}
+	if e.resourceId != p.str("ResourceId") {
+		return nil, errorf(retCodeInvalidState, "eip %s is not bound to %s", id, p.str("ResourceId"))
+	}
+	e.resourceType, e.resourceId = "", ""
+	return &unet.UnBindEIPResponse{}, nil
+}
+
+// checkEIPUnbound returns an error unless the EIP exists and is not bound.
+func (s *Server) checkEIPUnbound(id string) error {
+	e, ok := s.eips[id]
+	if !ok {
+		return errorf(retCodeNotFound, "eip %s not exist", id)
+	}
+	if e.resourceId != "" {
+		return errorf(retCodeInUse, "eip %s is already bound to %s %s", id, e.resourceType, e.resourceId)
+	}
+	return nil
+}
+
+// eipsBoundTo returns the EIPs bound to a resource.
+func (s *Server) eipsBoundTo(resourceId string) []*eip {
+	var bound []*eip
+	for _, id := range sortedIds(s.eips) {
+		if s.eips[id].resourceId == resourceId {
+			bound = append(bound, s.eips[id])
+		}
+	}
+	return bound
+}
+
+// detachEIPs unbinds the EIPs of a resource that is deleted, releasing them if asked to.
+func (s *Server) detachEIPs(resourceId string, release bool) {
+	for _, e := range s.eipsBoundTo(resourceId) {
+		e.resourceType, e.resourceId = "", ""
+		if release {
+			delete(s.eips, e.EIPId)
+		}
+	}
+}
+
+// resourceExists returns true if an EIP can be bound to the resource.
+func (s *Server) resourceExists(resourceType, id string) bool {
+	switch resourceType {
+	case "ulb":
+		_, ok := s.ulbs[id]
+		return ok
+	case "uhost":
+		_, ok := s.uhosts[id]
+		return ok
+	case "upm":
+		_, ok := s.phosts[id]
+		return ok
+	case "natgw":
+		_, ok := s.natgws[id]
+		return ok
+	}
+	return false
+}
+
+// matchesTag returns true if a resource with tag is selected by the Tag parameter.
+func matchesTag(p params, tag string) bool {
+	return p.str("Tag") == "" || p.str("Tag") == tag
+}
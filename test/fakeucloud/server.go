@@ -0,0 +1,495 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fakeucloud implements an in-process, stateful stand-in for the UCloud API.
+// It serves the actions used by the services package, so that the cluster and machine
+// flows can run offline by pointing spec.api.baseURL at Server.URL.
+package fakeucloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ucloud/ucloud-sdk-go/services/uhost"
+	"github.com/ucloud/ucloud-sdk-go/services/ulb"
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
+	"github.com/ucloud/ucloud-sdk-go/services/vpc"
+
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/services"
+)
+
+// Retcodes returned by the fake for conditions without a dedicated constant in the
+// common package. The messages are chosen so that common.Classify recognizes them.
+const (
+	retCodeActionNotFound = 160
+	retCodeNotFound       = 8000
+	retCodeInUse          = 8001
+	retCodeInvalidState   = 8002
+)
+
+// DefaultFirewallId is the id of the firewall every fake account starts with.
+const DefaultFirewallId = "firewall-default"
+
+// handler serves one action. It is called with the server lock held.
+type handler func(s *Server, p params) (interface{}, error)
+
+var handlers = map[string]handler{
+	"CreateVPC":                   (*Server).createVPC,
+	"DescribeVPC":                 (*Server).describeVPC,
+	"DeleteVPC":                   (*Server).deleteVPC,
+	"CreateSubnet":                (*Server).createSubnet,
+	"DescribeSubnet":              (*Server).describeSubnet,
+	"DeleteSubnet":                (*Server).deleteSubnet,
+	"CreateNATGW":                 (*Server).createNATGW,
+	"DescribeNATGW":               (*Server).describeNATGW,
+	"DeleteNATGW":                 (*Server).deleteNATGW,
+	"UpdateNATGWSubnet":           (*Server).updateNATGWSubnet,
+	"AllocateEIP":                 (*Server).allocateEIP,
+	"ReleaseEIP":                  (*Server).releaseEIP,
+	"DescribeEIP":                 (*Server).describeEIP,
+	"BindEIP":                     (*Server).bindEIP,
+	"UnBindEIP":                   (*Server).unbindEIP,
+	"DescribeFirewall":            (*Server).describeFirewall,
+	"CreateFirewall":              (*Server).createFirewall,
+	"UpdateFirewall":              (*Server).updateFirewall,
+	"DeleteFirewall":              (*Server).deleteFirewall,
+	"GrantFirewall":               (*Server).grantFirewall,
+	"CreateULB":                   (*Server).createULB,
+	"DescribeULB":                 (*Server).describeULB,
+	"DeleteULB":                   (*Server).deleteULB,
+	"CreateVServer":               (*Server).createVServer,
+	"DescribeVServer":             (*Server).describeVServer,
+	"UpdateVServerAttribute":      (*Server).updateVServerAttribute,
+	"AllocateBackend":             (*Server).allocateBackend,
+	"UpdateBackendAttribute":      (*Server).updateBackendAttribute,
+	"ReleaseBackend":              (*Server).releaseBackend,
+	"CreateUHostInstance":         (*Server).createUHostInstance,
+	"DescribeUHostInstance":       (*Server).describeUHostInstance,
+	"PoweroffUHostInstance":       (*Server).poweroffUHostInstance,
+	"TerminateUHostInstance":      (*Server).terminateUHostInstance,
+	"CreatePHost":                 (*Server).createPHost,
+	"DescribePHost":               (*Server).describePHost,
+	"PoweroffPHost":               (*Server).poweroffPHost,
+	"TerminatePHost":              (*Server).terminatePHost,
+	"ImportUHostKeyPairs":         (*Server).importUHostKeyPairs,
+	"DescribeUHostKeyPairs":       (*Server).describeUHostKeyPairs,
+	"DeleteUHostKeyPairs":         (*Server).deleteUHostKeyPairs,
+	"DescribeImage":               (*Server).describeImage,
+	"ListBusinessGroup":           (*Server).listBusinessGroup,
+	"CreateBusinessGroup":         (*Server).createBusinessGroup,
+	"DeleteBusinessGroup":         (*Server).deleteBusinessGroup,
+	"SearchBusinessGroupResource": (*Server).searchBusinessGroupResource,
+	"CreateCAPUCluster":           (*Server).createCAPUCluster,
+	"DeleteCAPUCluster":           (*Server).deleteCAPUCluster,
+	"CreateCAPUHost":              (*Server).createCAPUHost,
+	"DeleteCAPUHost":              (*Server).deleteCAPUHost,
+}
+
+// fault is an injected failure of an action.
+type fault struct {
+	retCode    int
+	message    string
+	statusCode int
+	count      int
+}
+
+// Server is a fake UCloud API. The zero value is not usable, use NewServer.
+type Server struct {
+	*httptest.Server
+
+	mu              sync.Mutex
+	nextId          int
+	transitionDelay time.Duration
+	latency         map[string]time.Duration
+	faults          map[string][]*fault
+	requests        map[string]int
+	failedInstalls  int
+
+	vpcs         map[string]*vpc.VPCInfo
+	subnets      map[string]*vpc.VPCSubnetInfoSet
+	natgws       map[string]*vpc.NatGatewayDataSet
+	eips         map[string]*eip
+	firewalls    map[string]*unet.FirewallDataSet
+	ulbs         map[string]*ulb.ULBSet
+	uhosts       map[string]*uhostInstance
+	phosts       map[string]*uhostInstance
+	groups       map[string]*services.BusinessGroupInfo
+	capuClusters map[string]*capuCluster
+	keyPairs     map[string]*services.KeyPair
+	images       map[string]*uhost.UHostImageSet
+
+	// assignedIPs counts the private addresses handed out in each subnet.
+	assignedIPs map[string]int
+}
+
+// NewServer starts a fake UCloud API. Callers should Close it when finished.
+func NewServer() *Server {
+	s := &Server{
+		latency:      map[string]time.Duration{},
+		faults:       map[string][]*fault{},
+		requests:     map[string]int{},
+		vpcs:         map[string]*vpc.VPCInfo{},
+		subnets:      map[string]*vpc.VPCSubnetInfoSet{},
+		natgws:       map[string]*vpc.NatGatewayDataSet{},
+		eips:         map[string]*eip{},
+		firewalls:    map[string]*unet.FirewallDataSet{},
+		ulbs:         map[string]*ulb.ULBSet{},
+		uhosts:       map[string]*uhostInstance{},
+		phosts:       map[string]*uhostInstance{},
+		groups:       map[string]*services.BusinessGroupInfo{},
+		capuClusters: map[string]*capuCluster{},
+		keyPairs:     map[string]*services.KeyPair{},
+		images:       map[string]*uhost.UHostImageSet{},
+		assignedIPs:  map[string]int{},
+	}
+	s.firewalls[DefaultFirewallId] = &unet.FirewallDataSet{
+		FWId: DefaultFirewallId,
+		Name: "Web Server Recommended",
+		Type: "recommend web",
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// InjectError makes the next count requests of action fail with retCode and message.
+func (s *Server) InjectError(action string, retCode int, message string, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[action] = append(s.faults[action], &fault{retCode: retCode, message: message, count: count})
+}
+
+// InjectStatus makes the next count requests of action fail with the HTTP status code.
+func (s *Server) InjectStatus(action string, statusCode int, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[action] = append(s.faults[action], &fault{statusCode: statusCode, count: count})
+}
+
+// SetLatency delays the responses to action by d. An empty action applies to every
+// action without a latency of its own.
+func (s *Server) SetLatency(action string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency[action] = d
+}
+
+// SetTransitionDelay sets how long instances stay in transitional states such as
+// Initializing and Stopping. It is zero by default, so that they settle immediately.
+// The delay applies to instances that are already in transition, too.
+func (s *Server) SetTransitionDelay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transitionDelay = d
+}
+
+// Requests returns the number of requests received for action, including failed ones.
+func (s *Server) Requests(action string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[action]
+}
+
+// UHost returns the instance with the given id.
+func (s *Server) UHost(id string) (uhost.UHostInstanceSet, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	host, ok := s.uhosts[id]
+	if !ok {
+		return uhost.UHostInstanceSet{}, false
+	}
+	s.settle(host)
+	return host.UHostInstanceSet, true
+}
+
+// ResourceIds returns the ids of all resources that exist, except the default firewall.
+// It is empty once everything created through the API has been deleted again.
+func (s *Server) ResourceIds() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for id := range s.vpcs {
+		ids = append(ids, id)
+	}
+	for id := range s.subnets {
+		ids = append(ids, id)
+	}
+	for id := range s.natgws {
+		ids = append(ids, id)
+	}
+	for id := range s.eips {
+		ids = append(ids, id)
+	}
+	for id := range s.ulbs {
+		ids = append(ids, id)
+	}
+	for id := range s.firewalls {
+		if id != DefaultFirewallId {
+			ids = append(ids, id)
+		}
+	}
+	for id := range s.uhosts {
+		ids = append(ids, id)
+	}
+	for id := range s.phosts {
+		ids = append(ids, id)
+	}
+	for id := range s.groups {
+		ids = append(ids, id)
+	}
+	for id := range s.capuClusters {
+		ids = append(ids, id)
+	}
+	for id := range s.keyPairs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Delete deletes the network resource with the given id as if it were deleted in the
+// console, without the checks of the API. The EIPs bound to a deleted resource are kept
+// and unbound. It returns false if there is no such resource.
+func (s *Server) Delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.groups[id]; ok {
+		delete(s.groups, id)
+		return true
+	}
+	if _, ok := s.vpcs[id]; ok {
+		delete(s.vpcs, id)
+		return true
+	}
+	if _, ok := s.subnets[id]; ok {
+		delete(s.subnets, id)
+		return true
+	}
+	if _, ok := s.eips[id]; ok {
+		delete(s.eips, id)
+		return true
+	}
+	if _, ok := s.natgws[id]; ok {
+		s.detachEIPs(id, false)
+		delete(s.natgws, id)
+		return true
+	}
+	if _, ok := s.ulbs[id]; ok {
+		s.detachEIPs(id, false)
+		delete(s.ulbs, id)
+		return true
+	}
+	for _, lb := range s.ulbs {
+		for i, vserver := range lb.VServerSet {
+			if vserver.VServerId == id {
+				lb.VServerSet = append(lb.VServerSet[:i], lb.VServerSet[i+1:]...)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	action := r.Form.Get("Action")
+
+	s.mu.Lock()
+	s.requests[action]++
+	delay, ok := s.latency[action]
+	if !ok {
+		delay = s.latency[""]
+	}
+	f := s.takeFault(action)
+	s.mu.Unlock()
+
+	time.Sleep(delay)
+	if f != nil && f.statusCode != 0 {
+		http.Error(w, http.StatusText(f.statusCode), f.statusCode)
+		return
+	}
+	if f != nil {
+		writeJSON(w, map[string]interface{}{"Action": action + "Response", "RetCode": f.retCode, "Message": f.message})
+		return
+	}
+
+	h, ok := handlers[action]
+	if !ok {
+		writeJSON(w, map[string]interface{}{"Action": action + "Response", "RetCode": retCodeActionNotFound, "Message": fmt.Sprintf("Action [%s] not found", action)})
+		return
+	}
+	s.mu.Lock()
+	res, err := h(s, params(r.Form))
+	s.mu.Unlock()
+	if err != nil {
+		e := err.(*apiError)
+		writeJSON(w, map[string]interface{}{"Action": action + "Response", "RetCode": e.retCode, "Message": e.message})
+		return
+	}
+
+	// the sdk response types embed response.CommonBase, whose fields are set here
+	body := map[string]interface{}{}
+	data, _ := json.Marshal(res)
+	_ = json.Unmarshal(data, &body)
+	body["Action"] = action + "Response"
+	body["RetCode"] = 0
+	body["Message"] = ""
+	writeJSON(w, body)
+}
+
+// takeFault returns the next injected failure of action, if any.
+func (s *Server) takeFault(action string) *fault {
+	faults := s.faults[action]
+	if len(faults) == 0 {
+		return nil
+	}
+	f := faults[0]
+	f.count--
+	if f.count <= 0 {
+		s.faults[action] = faults[1:]
+	}
+	return f
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// newId returns a new resource id with the given prefix. Ids with the same prefix
+// sort in the order they were created in.
+func (s *Server) newId(prefix string) string {
+	s.nextId++
+	return fmt.Sprintf("%s-%06x", prefix, s.nextId)
+}
+
+// apiError is a failed action, returned to the client as a non-zero retcode.
+type apiError struct {
+	retCode int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("retcode %d: %s", e.retCode, e.message)
+}
+
+func errorf(retCode int, format string, args ...interface{}) error {
+	return &apiError{retCode: retCode, message: fmt.Sprintf(format, args...)}
+}
+
+func missingParam(name string) error {
+	return errorf(common.RetCodeInvalidParameter, "Params [%s] not available", name)
+}
+
+// params holds the parameters of a request, encoded the way the sdk does.
+type params url.Values
+
+func (p params) str(name string) string {
+	return url.Values(p).Get(name)
+}
+
+func (p params) int(name string) int {
+	i, _ := strconv.Atoi(p.str(name))
+	return i
+}
+
+func (p params) bool(name string) bool {
+	return strings.EqualFold(p.str(name), "true")
+}
+
+// list returns the values of an array parameter, sent as name.0, name.1 and so on.
+func (p params) list(name string) []string {
+	var values []string
+	for i := 0; ; i++ {
+		v, ok := p[fmt.Sprintf("%s.%d", name, i)]
+		if !ok {
+			return values
+		}
+		values = append(values, v[0])
+	}
+}
+
+// require returns an error if one of the named parameters is empty.
+func (p params) require(names ...string) error {
+	for _, name := range names {
+		if p.str(name) == "" {
+			return missingParam(name)
+		}
+	}
+	return nil
+}
+
+// charge returns the charge type and the expiry as a unix time of a resource created
+// with the ChargeType and Quantity parameters, if it supports the charge type.
+// Resources paid by the hour expire at the next hour.
+func (p params) charge(chargeTypes ...string) (string, int, error) {
+	chargeType := p.str("ChargeType")
+	if chargeType == "" {
+		chargeType = "Month"
+	}
+	if !matches(chargeTypes, chargeType) {
+		return "", 0, errorf(common.RetCodeInvalidParameter, "invalid ChargeType %q", chargeType)
+	}
+	quantity := p.int("Quantity")
+	if quantity == 0 {
+		quantity = 1
+	}
+	now := time.Now()
+	switch chargeType {
+	case "Year":
+		return chargeType, int(now.AddDate(quantity, 0, 0).Unix()), nil
+	case "Month":
+		return chargeType, int(now.AddDate(0, quantity, 0).Unix()), nil
+	default:
+		return chargeType, int(now.Truncate(time.Hour).Add(time.Hour).Unix()), nil
+	}
+}
+
+// matches returns true if id is selected by a filter of ids, where an empty filter selects everything.
+func matches(filter []string, id string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, f := range filter {
+		if f == id {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedIds returns the ids of a map of resources, such as Server.vpcs, in the order
+// they were created in.
+func sortedIds(resources interface{}) []string {
+	var ids []string
+	for _, key := range reflect.ValueOf(resources).MapKeys() {
+		ids = append(ids, key.String())
+	}
+	sort.Strings(ids)
+	return ids
+}
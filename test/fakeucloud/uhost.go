@@ -0,0 +1,345 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakeucloud
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"github.com/ucloud/ucloud-sdk-go/services/uhost"
+
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/services"
+)
+
+// uhostInstance is an instance and the state it is transitioning to.
+type uhostInstance struct {
+	uhost.UHostInstanceSet
+	subnetId   string
+	privateIP  string
+	firewallId string
+
+	// target is the state the instance settles in once the transition delay has
+	// passed since transitionedAt.
+	target         uhost.State
+	transitionedAt time.Time
+}
+
+// transition moves the instance to state, and to target after the transition delay.
+func (s *Server) transition(host *uhostInstance, state, target uhost.State) {
+	host.State = string(state)
+	host.target = target
+	host.transitionedAt = time.Now()
+}
+
+// settle completes the transition of an instance if it is due.
+func (s *Server) settle(host *uhostInstance) {
+	if host.target != "" && time.Since(host.transitionedAt) >= s.transitionDelay {
+		host.State = string(host.target)
+		host.target = ""
+	}
+}
+
+// FailInstalls makes the next count instances end up in the InstallFail state
+// instead of Running.
+func (s *Server) FailInstalls(count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failedInstalls += count
+}
+
+func (s *Server) createUHostInstance(p params) (interface{}, error) {
+	if err := p.require("Zone", "ImageId"); err != nil {
+		return nil, err
+	}
+	switch p.str("LoginMode") {
+	case "Password":
+		if p.str("Password") == "" {
+			return nil, missingParam("Password")
+		}
+	case "KeyPair":
+		if err := p.require("KeyPairId"); err != nil {
+			return nil, err
+		}
+		if _, ok := s.keyPairs[p.str("KeyPairId")]; !ok {
+			return nil, errorf(retCodeNotFound, "key pair %s not exist", p.str("KeyPairId"))
+		}
+	default:
+		return nil, errorf(common.RetCodeInvalidParameter, "invalid LoginMode %q", p.str("LoginMode"))
+	}
+	chargeType, expireTime, err := p.charge("Month", "Year", "Dynamic", "Postpay")
+	if err != nil {
+		return nil, err
+	}
+	subnetId := p.str("SubnetId")
+	if subnetId != "" {
+		subnet, ok := s.subnets[subnetId]
+		if !ok {
+			return nil, errorf(retCodeNotFound, "subnet %s not exist", subnetId)
+		}
+		if vpcId := p.str("VPCId"); vpcId != "" && subnet.VPCId != vpcId {
+			return nil, errorf(retCodeNotFound, "subnet %s not exist in vpc %s", subnetId, vpcId)
+		}
+	}
+
+	firewallId := p.str("SecurityGroupId")
+	if firewallId == "" {
+		firewallId = DefaultFirewallId
+	}
+	if _, ok := s.firewalls[firewallId]; !ok {
+		return nil, errorf(retCodeNotFound, "firewall %s not exist", firewallId)
+	}
+
+	host := &uhostInstance{
+		UHostInstanceSet: uhost.UHostInstanceSet{
+			Name:        p.str("Name"),
+			Zone:        p.str("Zone"),
+			ImageId:     p.str("ImageId"),
+			CPU:         p.int("CPU"),
+			Memory:      p.int("Memory"),
+			MachineType: p.str("MachineType"),
+			ChargeType:  chargeType,
+			ExpireTime:  expireTime,
+			Tag:         p.str("Tag"),
+			CreateTime:  int(time.Now().Unix()),
+		},
+		subnetId:   subnetId,
+		firewallId: firewallId,
+	}
+	if host.Name == "" {
+		host.Name = "UHost"
+	}
+	bootDisks := 0
+	for i := 0; p.str(fmt.Sprintf("Disks.%d.Type", i)) != ""; i++ {
+		prefix := fmt.Sprintf("Disks.%d.", i)
+		if p.bool(prefix+"Encrypted") && p.str(prefix+"KmsKeyId") == "" {
+			return nil, missingParam(prefix + "KmsKeyId")
+		}
+		disk := uhost.UHostDiskSet{
+			DiskId:     s.newId("bsi"),
+			DiskType:   p.str(prefix + "Type"),
+			Type:       p.str(prefix + "Type"),
+			IsBoot:     p.str(prefix + "IsBoot"),
+			Size:       p.int(prefix + "Size"),
+			BackupType: p.str(prefix + "BackupType"),
+			Encrypted:  fmt.Sprint(p.bool(prefix + "Encrypted")),
+		}
+		if p.bool(prefix + "IsBoot") {
+			bootDisks++
+		}
+		host.DiskSet = append(host.DiskSet, disk)
+		host.TotalDiskSpace += disk.Size
+	}
+	if bootDisks != 1 {
+		return nil, errorf(common.RetCodeInvalidParameter, "exactly one boot disk is required, got %d", bootDisks)
+	}
+	if subnetId != "" {
+		host.privateIP = s.assignPrivateIP(subnetId)
+	}
+
+	host.UHostId = s.newId("uhost")
+	target := uhost.StateRunning
+	if s.failedInstalls > 0 {
+		s.failedInstalls--
+		target = uhost.StateInstallFail
+	}
+	s.transition(host, uhost.StateInitializing, target)
+	s.uhosts[host.UHostId] = host
+
+	if p.str("NetworkInterface.0.EIP.OperatorName") != "" {
+		e := s.newEIP(p.str("NetworkInterface.0.EIP.OperatorName"), p.int("NetworkInterface.0.EIP.Bandwidth"), host.Tag)
+		e.resourceType, e.resourceId = "uhost", host.UHostId
+	}
+	return &uhost.CreateUHostInstanceResponse{UHostIds: []string{host.UHostId}, IPs: []string{host.privateIP}}, nil
+}
+
+// view returns the instance as described by the API.
+func (s *Server) view(host *uhostInstance) uhost.UHostInstanceSet {
+	s.settle(host)
+	info := host.UHostInstanceSet
+	info.IPSet = nil
+	if host.privateIP != "" {
+		info.IPSet = append(info.IPSet, uhost.UHostIPSet{
+			Default:  "true",
+			IP:       host.privateIP,
+			SubnetId: host.subnetId,
+			Type:     "Private",
+			VPCId:    s.subnets[host.subnetId].VPCId,
+		})
+	}
+	for _, e := range s.eipsBoundTo(host.UHostId) {
+		info.IPSet = append(info.IPSet, uhost.UHostIPSet{
+			Bandwidth: e.bandwidth,
+			IP:        e.EIPAddr[0].IP,
+			IPId:      e.EIPId,
+			Type:      e.EIPAddr[0].OperatorName,
+		})
+	}
+	return info
+}
+
+func (s *Server) describeUHostInstance(p params) (interface{}, error) {
+	ids := p.list("UHostIds")
+	zone, subnetId, vpcId := p.str("Zone"), p.str("SubnetId"), p.str("VPCId")
+	res := &uhost.DescribeUHostInstanceResponse{}
+	for _, id := range sortedIds(s.uhosts) {
+		host := s.uhosts[id]
+		if !matches(ids, id) || !matchesTag(p, host.Tag) ||
+			(zone != "" && host.Zone != zone) ||
+			(subnetId != "" && host.subnetId != subnetId) ||
+			(vpcId != "" && (host.subnetId == "" || s.subnets[host.subnetId].VPCId != vpcId)) {
+			continue
+		}
+		res.UHostSet = append(res.UHostSet, s.view(host))
+	}
+	res.TotalCount = len(res.UHostSet)
+	return res, nil
+}
+
+// getUHost returns the instance named by the UHostId parameter.
+func (s *Server) getUHost(p params) (*uhostInstance, error) {
+	id := p.str("UHostId")
+	host, ok := s.uhosts[id]
+	if !ok {
+		return nil, errorf(common.RetCodeUHostNotFound, "uhost %s not exist", id)
+	}
+	s.settle(host)
+	return host, nil
+}
+
+func (s *Server) poweroffUHostInstance(p params) (interface{}, error) {
+	host, err := s.getUHost(p)
+	if err != nil {
+		return nil, err
+	}
+	switch uhost.State(host.State) {
+	case uhost.StateStopped, uhost.StateStopping:
+	case uhost.StateRunning, uhost.StateInstallFail:
+		s.transition(host, uhost.StateStopping, uhost.StateStopped)
+	default:
+		return nil, errorf(retCodeInvalidState, "uhost %s can not be powered off in state %s", host.UHostId, host.State)
+	}
+	return &uhost.PoweroffUHostInstanceResponse{UhostId: host.UHostId}, nil
+}
+
+func (s *Server) terminateUHostInstance(p params) (interface{}, error) {
+	host, err := s.getUHost(p)
+	if err != nil {
+		return nil, err
+	}
+	if uhost.State(host.State) != uhost.StateStopped && uhost.State(host.State) != uhost.StateInstallFail {
+		return nil, errorf(retCodeInvalidState, "uhost %s must be stopped before it is terminated, state %s", host.UHostId, host.State)
+	}
+	s.detachEIPs(host.UHostId, p.bool("ReleaseEIP"))
+	delete(s.uhosts, host.UHostId)
+	return &uhost.TerminateUHostInstanceResponse{UHostId: host.UHostId, InRecycle: "No"}, nil
+}
+
+func (s *Server) importUHostKeyPairs(p params) (interface{}, error) {
+	if err := p.require("KeyPairName", "PublicKeyBody"); err != nil {
+		return nil, err
+	}
+	name := p.str("KeyPairName")
+	for _, keyPair := range s.keyPairs {
+		if keyPair.KeyPairName == name {
+			return nil, errorf(retCodeInUse, "key pair %s already exists", name)
+		}
+	}
+	keyPair := &services.KeyPair{
+		KeyPairId:          s.newId("uhostkp"),
+		KeyPairName:        name,
+		KeyPairFingerPrint: fmt.Sprintf("%x", sha256.Sum256([]byte(p.str("PublicKeyBody")))),
+		CreateTime:         int(time.Now().Unix()),
+	}
+	s.keyPairs[keyPair.KeyPairId] = keyPair
+	return &services.ImportUHostKeyPairsResponse{
+		KeyPairId:          keyPair.KeyPairId,
+		KeyPairName:        keyPair.KeyPairName,
+		KeyPairFingerPrint: keyPair.KeyPairFingerPrint,
+	}, nil
+}
+
+func (s *Server) describeUHostKeyPairs(p params) (interface{}, error) {
+	name := p.str("KeyPairName")
+	var keyPairs []services.KeyPair
+	for _, id := range sortedIds(s.keyPairs) {
+		if name == "" || s.keyPairs[id].KeyPairName == name {
+			keyPairs = append(keyPairs, *s.keyPairs[id])
+		}
+	}
+	res := &services.DescribeUHostKeyPairsResponse{TotalCount: len(keyPairs)}
+	offset, limit := p.int("Offset"), p.int("Limit")
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+	for i := offset; i < len(keyPairs) && i < offset+limit; i++ {
+		res.KeyPairs = append(res.KeyPairs, keyPairs[i])
+	}
+	return res, nil
+}
+
+func (s *Server) deleteUHostKeyPairs(p params) (interface{}, error) {
+	ids := p.list("KeyPairIds")
+	if len(ids) == 0 {
+		return nil, missingParam("KeyPairIds")
+	}
+	for _, id := range ids {
+		if _, ok := s.keyPairs[id]; !ok {
+			return nil, errorf(retCodeNotFound, "key pair %s not exist", id)
+		}
+	}
+	for _, id := range ids {
+		delete(s.keyPairs, id)
+	}
+	return &services.DeleteUHostKeyPairsResponse{}, nil
+}
+
+// AddImage makes an image available to DescribeImage. Images are not resources of a
+// cluster, so they are not reported by ResourceIds.
+func (s *Server) AddImage(image uhost.UHostImageSet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if image.ImageId == "" {
+		image.ImageId = s.newId("uimage")
+	}
+	s.images[image.ImageId] = &image
+}
+
+func (s *Server) describeImage(p params) (interface{}, error) {
+	zone, imageId, imageType, osType := p.str("Zone"), p.str("ImageId"), p.str("ImageType"), p.str("OsType")
+	var images []uhost.UHostImageSet
+	for _, id := range sortedIds(s.images) {
+		image := s.images[id]
+		if (zone != "" && image.Zone != zone) ||
+			(imageId != "" && id != imageId) ||
+			(imageType != "" && image.ImageType != imageType) ||
+			(osType != "" && image.OsType != osType) {
+			continue
+		}
+		images = append(images, *image)
+	}
+	res := &uhost.DescribeImageResponse{TotalCount: len(images)}
+	offset, limit := p.int("Offset"), p.int("Limit")
+	if limit == 0 {
+		limit = 20
+	}
+	for i := offset; i < len(images) && i < offset+limit; i++ {
+		res.ImageSet = append(res.ImageSet, images[i])
+	}
+	return res, nil
+}
@@ -0,0 +1,304 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakeucloud
+
+import (
+	"github.com/ucloud/ucloud-sdk-go/services/ulb"
+
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
+)
+
+// VServer returns the VServer with the given id of a load balancer.
+func (s *Server) VServer(ulbId, vserverId string) (ulb.ULBVServerSet, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lb, ok := s.ulbs[ulbId]
+	if !ok {
+		return ulb.ULBVServerSet{}, false
+	}
+	for _, vserver := range lb.VServerSet {
+		if vserver.VServerId == vserverId {
+			return vserver, true
+		}
+	}
+	return ulb.ULBVServerSet{}, false
+}
+
+func (s *Server) createULB(p params) (interface{}, error) {
+	if err := p.require("VPCId"); err != nil {
+		return nil, err
+	}
+	vpcId := p.str("VPCId")
+	if _, ok := s.vpcs[vpcId]; !ok {
+		return nil, errorf(common.RetCodeVPCNotFound, "vpc %s not exist", vpcId)
+	}
+	_, expireTime, err := p.charge("Month", "Year", "Dynamic")
+	if err != nil {
+		return nil, err
+	}
+	lb := &ulb.ULBSet{
+		ExpireTime: expireTime,
+		Name:       p.str("ULBName"),
+		ULBType:    "OuterMode",
+		VPCId:      vpcId,
+		Tag:        p.str("Tag"),
+		Remark:     p.str("Remark"),
+	}
+	if lb.Name == "" {
+		lb.Name = "ULB"
+	}
+	if p.str("InnerMode") == "Yes" {
+		subnetId := p.str("SubnetId")
+		subnet, ok := s.subnets[subnetId]
+		if !ok || subnet.VPCId != vpcId {
+			return nil, errorf(retCodeNotFound, "subnet %s not exist in vpc %s", subnetId, vpcId)
+		}
+		lb.ULBType = "InnerMode"
+		lb.SubnetId = subnetId
+		lb.PrivateIP = s.assignPrivateIP(subnetId)
+	}
+	lb.ULBId = s.newId("ulb")
+	s.ulbs[lb.ULBId] = lb
+	return &ulb.CreateULBResponse{ULBId: lb.ULBId}, nil
+}
+
+func (s *Server) describeULB(p params) (interface{}, error) {
+	id := p.str("ULBId")
+	vpcId := p.str("VPCId")
+	res := &ulb.DescribeULBResponse{}
+	for _, ulbId := range sortedIds(s.ulbs) {
+		lb := *s.ulbs[ulbId]
+		if (id != "" && ulbId != id) || (vpcId != "" && lb.VPCId != vpcId) {
+			continue
+		}
+		lb.IPSet = nil
+		for _, e := range s.eipsBoundTo(ulbId) {
+			lb.Bandwidth = e.bandwidth
+			lb.IPSet = append(lb.IPSet, ulb.ULBIPSet{
+				Bandwidth:    e.bandwidth,
+				EIP:          e.EIPAddr[0].IP,
+				EIPId:        e.EIPId,
+				OperatorName: e.EIPAddr[0].OperatorName,
+			})
+		}
+		res.DataSet = append(res.DataSet, lb)
+	}
+	res.TotalCount = len(res.DataSet)
+	return res, nil
+}
+
+func (s *Server) deleteULB(p params) (interface{}, error) {
+	id := p.str("ULBId")
+	if _, ok := s.ulbs[id]; !ok {
+		return nil, errorf(common.RetCodeULBNotFound, "ulb %s not exist", id)
+	}
+	s.detachEIPs(id, p.bool("ReleaseEip"))
+	delete(s.ulbs, id)
+	return &ulb.DeleteULBResponse{}, nil
+}
+
+// getULB returns the ULB named by the ULBId parameter.
+func (s *Server) getULB(p params) (*ulb.ULBSet, error) {
+	id := p.str("ULBId")
+	lb, ok := s.ulbs[id]
+	if !ok {
+		return nil, errorf(common.RetCodeULBNotFound, "ulb %s not exist", id)
+	}
+	return lb, nil
+}
+
+// getVServer returns the VServer named by the VServerId parameter.
+func (s *Server) getVServer(lb *ulb.ULBSet, p params) (*ulb.ULBVServerSet, error) {
+	id := p.str("VServerId")
+	for i := range lb.VServerSet {
+		if lb.VServerSet[i].VServerId == id {
+			return &lb.VServerSet[i], nil
+		}
+	}
+	return nil, errorf(retCodeNotFound, "vserver %s not exist in ulb %s", id, lb.ULBId)
+}
+
+func (s *Server) createVServer(p params) (interface{}, error) {
+	lb, err := s.getULB(p)
+	if err != nil {
+		return nil, err
+	}
+	vserver := ulb.ULBVServerSet{
+		VServerName:     p.str("VServerName"),
+		Protocol:        p.str("Protocol"),
+		FrontendPort:    p.int("FrontendPort"),
+		ListenType:      p.str("ListenType"),
+		Method:          p.str("Method"),
+		MonitorType:     p.str("MonitorType"),
+		Path:            p.str("Path"),
+		Domain:          p.str("Domain"),
+		PersistenceType: p.str("PersistenceType"),
+		PersistenceInfo: p.str("PersistenceInfo"),
+	}
+	if vserver.Protocol == "" {
+		vserver.Protocol = "HTTP"
+	}
+	if vserver.FrontendPort == 0 {
+		vserver.FrontendPort = 80
+	}
+	if vserver.ListenType == "" {
+		vserver.ListenType = "RequestProxy"
+	}
+	if vserver.Method == "" {
+		vserver.Method = "Roundrobin"
+	}
+	if vserver.MonitorType == "" {
+		vserver.MonitorType = "Port"
+	}
+	if vserver.PersistenceType == "" {
+		vserver.PersistenceType = "None"
+	}
+	for _, existing := range lb.VServerSet {
+		if existing.FrontendPort == vserver.FrontendPort && existing.Protocol == vserver.Protocol {
+			return nil, errorf(retCodeInUse, "frontend port %d of ulb %s is already in use", vserver.FrontendPort, lb.ULBId)
+		}
+	}
+	vserver.VServerId = s.newId("vserver")
+	lb.VServerSet = append(lb.VServerSet, vserver)
+	return &ulb.CreateVServerResponse{VServerId: vserver.VServerId}, nil
+}
+
+func (s *Server) describeVServer(p params) (interface{}, error) {
+	lb, err := s.getULB(p)
+	if err != nil {
+		return nil, err
+	}
+	id := p.str("VServerId")
+	res := &ulb.DescribeVServerResponse{}
+	for _, vserver := range lb.VServerSet {
+		if id == "" || vserver.VServerId == id {
+			res.DataSet = append(res.DataSet, vserver)
+		}
+	}
+	res.TotalCount = len(res.DataSet)
+	return res, nil
+}
+
+// updateVServerAttribute updates the attributes given, the port and the listen type of a
+// VServer can't be updated.
+func (s *Server) updateVServerAttribute(p params) (interface{}, error) {
+	lb, err := s.getULB(p)
+	if err != nil {
+		return nil, err
+	}
+	vserver, err := s.getVServer(lb, p)
+	if err != nil {
+		return nil, err
+	}
+	for name, attribute := range map[string]*string{
+		"VServerName":     &vserver.VServerName,
+		"Method":          &vserver.Method,
+		"MonitorType":     &vserver.MonitorType,
+		"Path":            &vserver.Path,
+		"Domain":          &vserver.Domain,
+		"PersistenceType": &vserver.PersistenceType,
+		"PersistenceInfo": &vserver.PersistenceInfo,
+	} {
+		if value := p.str(name); value != "" {
+			*attribute = value
+		}
+	}
+	return &ulb.UpdateVServerAttributeResponse{}, nil
+}
+
+func (s *Server) allocateBackend(p params) (interface{}, error) {
+	if err := p.require("ResourceType", "ResourceId"); err != nil {
+		return nil, err
+	}
+	lb, err := s.getULB(p)
+	if err != nil {
+		return nil, err
+	}
+	vserver, err := s.getVServer(lb, p)
+	if err != nil {
+		return nil, err
+	}
+	resourceId := p.str("ResourceId")
+	host, ok := s.uhosts[resourceId]
+	if !ok {
+		return nil, errorf(common.RetCodeUHostNotFound, "uhost %s not exist", resourceId)
+	}
+	port := p.int("Port")
+	if port == 0 {
+		port = 80
+	}
+	for _, backend := range vserver.BackendSet {
+		if backend.ResourceId == resourceId && backend.Port == port {
+			return nil, errorf(retCodeInUse, "uhost %s is already a backend of vserver %s", resourceId, vserver.VServerId)
+		}
+	}
+	backend := ulb.ULBBackendSet{
+		BackendId:    s.newId("backend"),
+		Enabled:      1,
+		Port:         port,
+		PrivateIP:    host.privateIP,
+		ResourceId:   resourceId,
+		ResourceName: host.Name,
+		ResourceType: p.str("ResourceType"),
+		SubnetId:     host.subnetId,
+		Weight:       1,
+	}
+	vserver.BackendSet = append(vserver.BackendSet, backend)
+	return &ulb.AllocateBackendResponse{BackendId: backend.BackendId}, nil
+}
+
+func (s *Server) updateBackendAttribute(p params) (interface{}, error) {
+	lb, err := s.getULB(p)
+	if err != nil {
+		return nil, err
+	}
+	id := p.str("BackendId")
+	for i := range lb.VServerSet {
+		backends := lb.VServerSet[i].BackendSet
+		for j := range backends {
+			if backends[j].BackendId != id {
+				continue
+			}
+			if port := p.int("Port"); port != 0 {
+				backends[j].Port = port
+			}
+			if weight := p.int("Weight"); weight != 0 {
+				backends[j].Weight = weight
+			}
+			return &ulb.UpdateBackendAttributeResponse{}, nil
+		}
+	}
+	return nil, errorf(retCodeNotFound, "backend %s not exist in ulb %s", id, lb.ULBId)
+}
+
+func (s *Server) releaseBackend(p params) (interface{}, error) {
+	lb, err := s.getULB(p)
+	if err != nil {
+		return nil, err
+	}
+	id := p.str("BackendId")
+	for i := range lb.VServerSet {
+		backends := lb.VServerSet[i].BackendSet
+		for j := range backends {
+			if backends[j].BackendId == id {
+				lb.VServerSet[i].BackendSet = append(backends[:j:j], backends[j+1:]...)
+				return &ulb.ReleaseBackendResponse{}, nil
+			}
+		}
+	}
+	return nil, errorf(retCodeNotFound, "backend %s not exist in ulb %s", id, lb.ULBId)
+}
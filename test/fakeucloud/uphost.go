@@ -0,0 +1,172 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakeucloud
+
+import (
+	"time"
+
+	"github.com/ucloud/ucloud-sdk-go/services/uhost"
+	"github.com/ucloud/ucloud-sdk-go/services/uphost"
+
+	"sigs.k8s.io/cluster-api-provider-ucloud/cloud/common"
+)
+
+// Bare-metal machines are kept as instances, so that they share the state transitions
+// of instances. Their PMStatus is the state of the instance.
+
+func (s *Server) createPHost(p params) (interface{}, error) {
+	if err := p.require("Zone", "ImageId", "Password", "Type"); err != nil {
+		return nil, err
+	}
+	chargeType, expireTime, err := p.charge("Month", "Year", "Dynamic")
+	if err != nil {
+		return nil, err
+	}
+	switch raid := p.str("Raid"); raid {
+	case "", "Raid0", "Raid1", "Raid5", "Raid10", "NoRaid":
+	default:
+		return nil, errorf(common.RetCodeInvalidParameter, "invalid Raid %q", raid)
+	}
+	subnetId := p.str("SubnetId")
+	if subnetId != "" {
+		subnet, ok := s.subnets[subnetId]
+		if !ok {
+			return nil, errorf(retCodeNotFound, "subnet %s not exist", subnetId)
+		}
+		if vpcId := p.str("VPCId"); vpcId != "" && subnet.VPCId != vpcId {
+			return nil, errorf(retCodeNotFound, "subnet %s not exist in vpc %s", subnetId, vpcId)
+		}
+	}
+
+	host := &uhostInstance{
+		UHostInstanceSet: uhost.UHostInstanceSet{
+			Name:        p.str("Name"),
+			Zone:        p.str("Zone"),
+			ImageId:     p.str("ImageId"),
+			MachineType: p.str("Type"),
+			ChargeType:  chargeType,
+			ExpireTime:  expireTime,
+			Tag:         p.str("Tag"),
+			CreateTime:  int(time.Now().Unix()),
+		},
+		subnetId:   subnetId,
+		firewallId: DefaultFirewallId,
+	}
+	if host.Name == "" {
+		host.Name = "phost"
+	}
+	if subnetId != "" {
+		host.privateIP = s.assignPrivateIP(subnetId)
+	}
+
+	host.UHostId = s.newId("upm")
+	target := uhost.StateRunning
+	if s.failedInstalls > 0 {
+		s.failedInstalls--
+		target = uhost.StateInstallFail
+	}
+	s.transition(host, uhost.StateInitializing, target)
+	s.phosts[host.UHostId] = host
+	return &uphost.CreatePHostResponse{PHostId: []string{host.UHostId}}, nil
+}
+
+// phostView returns the bare-metal machine as described by the API.
+func (s *Server) phostView(host *uhostInstance) uphost.PHostSet {
+	s.settle(host)
+	info := uphost.PHostSet{
+		PHostId:    host.UHostId,
+		Zone:       host.Zone,
+		Name:       host.Name,
+		Tag:        host.Tag,
+		PMStatus:   host.State,
+		PHostType:  host.MachineType,
+		ChargeType: host.ChargeType,
+		CreateTime: host.CreateTime,
+		ExpireTime: host.ExpireTime,
+	}
+	if info.PMStatus == string(uhost.StateInstallFail) {
+		info.PMStatus = "Install Fail"
+	}
+	if host.privateIP != "" {
+		info.IPSet = append(info.IPSet, uphost.PHostIPSet{
+			IPAddr:       host.privateIP,
+			OperatorName: "Private",
+			SubnetId:     host.subnetId,
+			VPCId:        s.subnets[host.subnetId].VPCId,
+		})
+	}
+	for _, e := range s.eipsBoundTo(host.UHostId) {
+		info.IPSet = append(info.IPSet, uphost.PHostIPSet{
+			Bandwidth:    e.bandwidth,
+			IPAddr:       e.EIPAddr[0].IP,
+			IPId:         e.EIPId,
+			OperatorName: e.EIPAddr[0].OperatorName,
+		})
+	}
+	return info
+}
+
+func (s *Server) describePHost(p params) (interface{}, error) {
+	ids := p.list("PHostId")
+	res := &uphost.DescribePHostResponse{}
+	for _, id := range sortedIds(s.phosts) {
+		if matches(ids, id) {
+			res.PHostSet = append(res.PHostSet, s.phostView(s.phosts[id]))
+		}
+	}
+	res.TotalCount = len(res.PHostSet)
+	return res, nil
+}
+
+// getPHost returns the bare-metal machine named by the PHostId parameter.
+func (s *Server) getPHost(p params) (*uhostInstance, error) {
+	id := p.str("PHostId")
+	host, ok := s.phosts[id]
+	if !ok {
+		return nil, errorf(retCodeNotFound, "uphost %s not exist", id)
+	}
+	s.settle(host)
+	return host, nil
+}
+
+func (s *Server) poweroffPHost(p params) (interface{}, error) {
+	host, err := s.getPHost(p)
+	if err != nil {
+		return nil, err
+	}
+	switch uhost.State(host.State) {
+	case uhost.StateStopped, uhost.StateStopping:
+	case uhost.StateRunning, uhost.StateInstallFail:
+		s.transition(host, uhost.StateStopping, uhost.StateStopped)
+	default:
+		return nil, errorf(retCodeInvalidState, "uphost %s can not be powered off in state %s", host.UHostId, host.State)
+	}
+	return &uphost.PoweroffPHostResponse{PHostId: host.UHostId}, nil
+}
+
+func (s *Server) terminatePHost(p params) (interface{}, error) {
+	host, err := s.getPHost(p)
+	if err != nil {
+		return nil, err
+	}
+	if uhost.State(host.State) != uhost.StateStopped && uhost.State(host.State) != uhost.StateInstallFail {
+		return nil, errorf(retCodeInvalidState, "uphost %s must be stopped before it is terminated, state %s", host.UHostId, host.State)
+	}
+	s.detachEIPs(host.UHostId, p.bool("ReleaseEIP"))
+	delete(s.phosts, host.UHostId)
+	return &uphost.TerminatePHostResponse{PHostId: host.UHostId}, nil
+}